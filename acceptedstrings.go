@@ -0,0 +1,270 @@
+package automaton
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+	"slices"
+	"strings"
+
+	"github.com/bits-and-blooms/bitset"
+)
+
+// CountAcceptedStrings Returns the number of strings of exactly the given length the automaton accepts. Strings
+// are counted by label, so for a Unicode automaton every code point in a transition's range counts, including
+// surrogates. Non-deterministic automata are determinized first (with DefaultWorkLimit()) so that
+// strings accepted along several paths are counted once. Cost is O(length * numTransitions).
+func CountAcceptedStrings(a *Automaton, length int) (*big.Int, error) {
+	counts, err := countAcceptedStrings(a, length)
+	if err != nil {
+		return nil, err
+	}
+	return counts[length], nil
+}
+
+// CountAcceptedStringsUpTo Returns the number of strings of length at most maxLength the automaton accepts,
+// counted like CountAcceptedStrings.
+func CountAcceptedStringsUpTo(a *Automaton, maxLength int) (*big.Int, error) {
+	counts, err := countAcceptedStrings(a, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	total := new(big.Int)
+	for _, count := range counts {
+		total.Add(total, count)
+	}
+	return total, nil
+}
+
+// Returns the number of accepted strings of every length up to maxLength, indexed by length.
+func countAcceptedStrings(a *Automaton, maxLength int) ([]*big.Int, error) {
+	if maxLength < 0 {
+		return nil, errors.New("length must be >= 0")
+	}
+	counts := make([]*big.Int, maxLength+1)
+	for i := range counts {
+		counts[i] = new(big.Int)
+	}
+	if a.GetNumStates() == 0 {
+		return counts, nil
+	}
+	a, err := determinize(a, DefaultWorkLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	// suffixes[s] is the number of strings of the current length leading from s to an accept state:
+	numStates := a.GetNumStates()
+	suffixes := make([]*big.Int, numStates)
+	next := make([]*big.Int, numStates)
+	for s := 0; s < numStates; s++ {
+		suffixes[s] = new(big.Int)
+		next[s] = new(big.Int)
+		if a.IsAccept(s) {
+			suffixes[s].SetInt64(1)
+		}
+	}
+	counts[0].Set(suffixes[0])
+
+	t := NewTransition()
+	width := new(big.Int)
+	product := new(big.Int)
+	for length := 1; length <= maxLength; length++ {
+		for s := 0; s < numStates; s++ {
+			next[s].SetInt64(0)
+			count := a.InitTransition(s, t)
+			for i := 0; i < count; i++ {
+				a.GetNextTransition(t)
+				width.SetInt64(int64(t.Max - t.Min + 1))
+				next[s].Add(next[s], product.Mul(width, suffixes[t.Dest]))
+			}
+		}
+		suffixes, next = next, suffixes
+		counts[length].Set(suffixes[0])
+	}
+	return counts, nil
+}
+
+// SampleAcceptedString Returns a string drawn uniformly at random from the (finite) language of the automaton.
+// Labels of a binary automaton (see Alphabet) become bytes, other labels code points. Returns an error if the
+// automaton accepts no strings or infinitely many.
+func SampleAcceptedString(a *Automaton, r *rand.Rand) (string, error) {
+	if IsEmptyAutomaton(a) {
+		return "", errors.New("automaton accepts no strings")
+	}
+	alphabet := a.Alphabet()
+	a, err := determinize(a, DefaultWorkLimit())
+	if err != nil {
+		return "", err
+	}
+	a, err = RemoveDeadStates(a)
+	if err != nil {
+		return "", err
+	}
+	if !IsFiniteAutomaton(a).Load() {
+		return "", errors.New("automaton accepts infinitely many strings")
+	}
+
+	// totals[s] is the number of strings leading from s to an accept state; the live automaton is acyclic.
+	numStates := a.GetNumStates()
+	totals := make([]*big.Int, numStates)
+	var total func(s int) *big.Int
+	total = func(s int) *big.Int {
+		if totals[s] != nil {
+			return totals[s]
+		}
+		sum := new(big.Int)
+		if a.IsAccept(s) {
+			sum.SetInt64(1)
+		}
+		width := new(big.Int)
+		for _, t := range a.TransitionsSlice(s) {
+			width.SetInt64(int64(t.Max - t.Min + 1))
+			sum.Add(sum, width.Mul(width, total(t.Dest)))
+		}
+		totals[s] = sum
+		return sum
+	}
+
+	// Pick the index of the string among all strings accepted from the current state, then walk down to it:
+	b := new(strings.Builder)
+	s := 0
+	index := new(big.Int).Rand(r, total(s))
+	label := new(big.Int)
+	for {
+		if a.IsAccept(s) {
+			if index.Sign() == 0 {
+				return b.String(), nil
+			}
+			index.Sub(index, big.NewInt(1))
+		}
+		for _, t := range a.TransitionsSlice(s) {
+			width := big.NewInt(int64(t.Max - t.Min + 1))
+			block := new(big.Int).Mul(width, total(t.Dest))
+			if index.Cmp(block) >= 0 {
+				index.Sub(index, block)
+				continue
+			}
+			// Within this transition, strings are grouped by label:
+			index.QuoRem(index, total(t.Dest), label)
+			index, label = label, index
+			c := t.Min + int(label.Int64())
+			if alphabet == ALPHABET_BINARY {
+				b.WriteByte(byte(c))
+			} else {
+				b.WriteRune(rune(c))
+			}
+			s = t.Dest
+			break
+		}
+	}
+}
+
+// GetShortestAcceptedString Returns a shortest string accepted by the automaton, or false if it accepts none.
+// It is found with a breadth first search over the states, following the smallest label of every transition,
+// so the automaton need not be deterministic. Labels of a binary automaton (see Alphabet) become bytes, other
+// labels code points.
+func GetShortestAcceptedString(a *Automaton) (string, bool) {
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return "", false
+	}
+	// parents[s] is the state s was first reached from, and labels[s] the label it was reached with:
+	parents := make([]int, numStates)
+	labels := make([]int, numStates)
+	for i := range parents {
+		parents[i] = -1
+	}
+	parents[0] = 0
+	queue := []int{0}
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if a.IsAccept(s) {
+			var path []int
+			for ; s != 0; s = parents[s] {
+				path = append(path, labels[s])
+			}
+			slices.Reverse(path)
+			return labelsToString(path, a.alphabet), true
+		}
+		for _, t := range a.TransitionsSlice(s) {
+			if parents[t.Dest] == -1 {
+				parents[t.Dest] = s
+				labels[t.Dest] = t.Min
+				queue = append(queue, t.Dest)
+			}
+		}
+	}
+	return "", false
+}
+
+// GetLongestAcceptedString Returns a longest string of at most maxLength labels accepted by the automaton, or
+// false if it accepts none. For a finite language a maxLength of at least the number of states finds the
+// longest accepted string. Among strings of the longest length, the one following the smallest label of every
+// transition is returned; the automaton need not be deterministic. Cost is O(maxLength * numTransitions).
+func GetLongestAcceptedString(a *Automaton, maxLength int) (string, bool) {
+	if a.GetNumStates() == 0 || maxLength < 0 {
+		return "", false
+	}
+	// exact[k] holds the states from which an accept state is reached in exactly k steps; once it is empty, so
+	// are all following sets:
+	exact := []*bitset.BitSet{a.getAcceptStates().Clone()}
+	longest := -1
+	for length := 0; length <= maxLength; length++ {
+		if length > 0 {
+			next := statesWithTransitionTo(a, exact[length-1])
+			if next.None() {
+				break
+			}
+			exact = append(exact, next)
+		}
+		if exact[length].Test(0) {
+			longest = length
+		}
+	}
+	if longest == -1 {
+		return "", false
+	}
+
+	path := make([]int, 0, longest)
+	s := 0
+	for remaining := longest; remaining > 0; remaining-- {
+		for _, t := range a.TransitionsSlice(s) {
+			if exact[remaining-1].Test(uint(t.Dest)) {
+				path = append(path, t.Min)
+				s = t.Dest
+				break
+			}
+		}
+	}
+	return labelsToString(path, a.alphabet), true
+}
+
+// statesWithTransitionTo Returns the states having a transition to one of the given states.
+func statesWithTransitionTo(a *Automaton, states *bitset.BitSet) *bitset.BitSet {
+	numStates := a.GetNumStates()
+	result := bitset.New(uint(numStates))
+	for s := 0; s < numStates; s++ {
+		for _, t := range a.TransitionsSlice(s) {
+			if states.Test(uint(t.Dest)) {
+				result.Set(uint(s))
+				break
+			}
+		}
+	}
+	return result
+}
+
+// labelsToString Converts labels into a string: bytes for a binary alphabet, code points otherwise.
+func labelsToString(labels []int, alphabet Alphabet) string {
+	b := new(strings.Builder)
+	for _, label := range labels {
+		if alphabet == ALPHABET_BINARY {
+			b.WriteByte(byte(label))
+		} else {
+			b.WriteRune(rune(label))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,146 @@
+package automaton
+
+import (
+	"math/big"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountAcceptedStrings(t *testing.T) {
+	re, err := NewRegExp("(a|b)*c?")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+
+	// (a|b)^n plus (a|b)^(n-1)c: 2^n + 2^(n-1)
+	for n, want := range []int64{1, 3, 6, 12, 24} {
+		count, err := CountAcceptedStrings(a, n)
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(want), count, "length %d", n)
+	}
+	count, err := CountAcceptedStringsUpTo(a, 4)
+	assert.Nil(t, err)
+	assert.Equal(t, big.NewInt(1+3+6+12+24), count)
+
+	t.Run("nfa", func(t *testing.T) {
+		// Both branches accept "ab"; it is counted once.
+		re, err := NewRegExp("ab|a.")
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton(WithMaxStates(0))
+		assert.Nil(t, err)
+		nfa, err := union(a, a)
+		assert.Nil(t, err)
+		count, err := CountAcceptedStrings(nfa, 2)
+		assert.Nil(t, err)
+		assert.Equal(t, big.NewInt(0x110000), count)
+	})
+
+	t.Run("huge", func(t *testing.T) {
+		a, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		count, err := CountAcceptedStrings(a, 100)
+		assert.Nil(t, err)
+		assert.Equal(t, new(big.Int).Lsh(big.NewInt(1), 800), count)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		count, err := CountAcceptedStringsUpTo(MakeEmpty(), 3)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, count.Sign())
+
+		_, err = CountAcceptedStrings(MakeEmpty(), -1)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestSampleAcceptedString(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	re, err := NewRegExp("(ab|c)d?|e")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+
+	seen := make(map[string]int)
+	for i := 0; i < 5000; i++ {
+		s, err := SampleAcceptedString(a, r)
+		assert.Nil(t, err)
+		assert.True(t, Run(a, s), s)
+		seen[s]++
+	}
+	assert.Len(t, seen, 5)
+	for s, n := range seen {
+		// Each of the 5 strings is expected 1000 times.
+		assert.InDelta(t, 1000, n, 150, s)
+	}
+
+	t.Run("binary", func(t *testing.T) {
+		a, err := MakeBinaryRange(0x80, 0xFF)
+		assert.Nil(t, err)
+		s, err := SampleAcceptedString(a, r)
+		assert.Nil(t, err)
+		assert.Len(t, s, 1)
+		assert.GreaterOrEqual(t, s[0], byte(0x80))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := SampleAcceptedString(MakeEmpty(), r)
+		assert.NotNil(t, err)
+
+		a, err := MakeAnyString()
+		assert.Nil(t, err)
+		_, err = SampleAcceptedString(a, r)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestGetShortestAcceptedString(t *testing.T) {
+	s, ok := GetShortestAcceptedString(MustNewRegExp("x*(abc|de)f|ghij").MustToAutomaton())
+	assert.True(t, ok)
+	assert.Equal(t, "def", s)
+
+	s, ok = GetShortestAcceptedString(MustNewRegExp("a*").MustToAutomaton())
+	assert.True(t, ok)
+	assert.Equal(t, "", s)
+
+	// non-deterministic automata work as well:
+	a, err := concatenate(MustNewRegExp("[a-c]*").MustToAutomaton(), MustNewRegExp("c世").MustToAutomaton())
+	assert.Nil(t, err)
+	s, ok = GetShortestAcceptedString(a)
+	assert.True(t, ok)
+	assert.Equal(t, "c世", s)
+
+	_, ok = GetShortestAcceptedString(MakeEmpty())
+	assert.False(t, ok)
+
+	b, err := MakeBinary([]byte{0xfe, 0xff})
+	assert.Nil(t, err)
+	s, ok = GetShortestAcceptedString(b)
+	assert.True(t, ok)
+	assert.Equal(t, "\xfe\xff", s)
+}
+
+func TestGetLongestAcceptedString(t *testing.T) {
+	a := MustNewRegExp("ab|cde|fg").MustToAutomaton()
+	s, ok := GetLongestAcceptedString(a, 10)
+	assert.True(t, ok)
+	assert.Equal(t, "cde", s)
+
+	s, ok = GetLongestAcceptedString(a, 2)
+	assert.True(t, ok)
+	assert.Equal(t, "ab", s)
+
+	_, ok = GetLongestAcceptedString(a, 1)
+	assert.False(t, ok)
+
+	// an infinite language is bounded by maxLength:
+	s, ok = GetLongestAcceptedString(MustNewRegExp("(ab)*").MustToAutomaton(), 5)
+	assert.True(t, ok)
+	assert.Equal(t, "abab", s)
+
+	_, ok = GetLongestAcceptedString(MakeEmpty(), 5)
+	assert.False(t, ok)
+	_, ok = GetLongestAcceptedString(a, -1)
+	assert.False(t, ok)
+}
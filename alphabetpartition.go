@@ -0,0 +1,70 @@
+package automaton
+
+import (
+	"slices"
+	"sort"
+	"unicode"
+)
+
+// AlphabetPartition Splits the labels into the intervals between the start points of the transitions of one or
+// more automata: no transition tells two labels of the same interval (class) apart, so algorithms can work on
+// classes instead of labels. Classes are numbered in order, class 0 starts at label 0. The partition of a
+// single automaton is available from Automaton.AlphabetPartition, which caches it once the automaton is frozen.
+type AlphabetPartition struct {
+	// Sorted start points of the classes.
+	points []int
+}
+
+// NewAlphabetPartition Returns the coarsest partition refining those of all the given automata.
+func NewAlphabetPartition(automata ...*Automaton) *AlphabetPartition {
+	if len(automata) == 1 {
+		return automata[0].AlphabetPartition()
+	}
+	points := []int{0}
+	for _, a := range automata {
+		points = append(points, a.AlphabetPartition().points...)
+	}
+	slices.Sort(points)
+	return &AlphabetPartition{points: slices.Compact(points)}
+}
+
+// AlphabetPartition Returns the partition of the labels by the start points of this automaton's transitions.
+// The partition is computed on each call while the automaton can still change, and only once after Freeze.
+func (a *Automaton) AlphabetPartition() *AlphabetPartition {
+	if p := a.partition.Load(); p != nil {
+		return p
+	}
+	p := &AlphabetPartition{points: a.CollectAlphabetPoints()}
+	if a.frozen {
+		a.partition.Store(p)
+	}
+	return p
+}
+
+// NumClasses Returns the number of classes.
+func (p *AlphabetPartition) NumClasses() int {
+	return len(p.points)
+}
+
+// Points Returns the sorted start points of the classes. The slice should not be modified by the caller.
+func (p *AlphabetPartition) Points() []int {
+	return p.points
+}
+
+// Class Returns the class of the given label.
+func (p *AlphabetPartition) Class(label int) int {
+	return sort.SearchInts(p.points, label+1) - 1
+}
+
+// Start Returns the smallest label of the given class.
+func (p *AlphabetPartition) Start(class int) int {
+	return p.points[class]
+}
+
+// End Returns the largest label of the given class.
+func (p *AlphabetPartition) End(class int) int {
+	if class+1 < len(p.points) {
+		return p.points[class+1] - 1
+	}
+	return unicode.MaxRune
+}
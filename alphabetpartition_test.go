@@ -0,0 +1,55 @@
+package automaton
+
+import (
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlphabetPartition(t *testing.T) {
+	a, err := MakeCharRange('a', 'z')
+	assert.Nil(t, err)
+	p := a.AlphabetPartition()
+
+	// [0, 'a'), ['a', 'z'], ('z', max]
+	assert.Equal(t, 3, p.NumClasses())
+	assert.Equal(t, []int{0, 'a', 'z' + 1}, p.Points())
+	assert.Equal(t, p.Points(), a.GetStartPoints())
+	assert.Equal(t, 0, p.Class(0))
+	assert.Equal(t, 0, p.Class('a'-1))
+	assert.Equal(t, 1, p.Class('a'))
+	assert.Equal(t, 1, p.Class('z'))
+	assert.Equal(t, 2, p.Class('z'+1))
+	assert.Equal(t, 2, p.Class(unicode.MaxRune))
+	assert.Equal(t, 'a', rune(p.Start(1)))
+	assert.Equal(t, 'z', rune(p.End(1)))
+	assert.Equal(t, int(unicode.MaxRune), p.End(2))
+
+	t.Run("testCached", func(t *testing.T) {
+		assert.NotSame(t, a.AlphabetPartition(), a.AlphabetPartition())
+		a.Freeze()
+		assert.Same(t, a.AlphabetPartition(), a.AlphabetPartition())
+	})
+
+	t.Run("testCollectAlphabetPoints", func(t *testing.T) {
+		b := NewAutomaton()
+		s0, s1 := b.CreateState(), b.CreateState()
+		assert.Nil(t, b.AddTransition(s0, s1, 'a', 'c'))
+		assert.Nil(t, b.AddTransition(s0, s0, 'x', unicode.MaxRune))
+		assert.Nil(t, b.AddTransition(s1, s1, 'b', 'd'))
+		assert.Nil(t, b.AddTransition(s1, s0, 'a', 'c'))
+		b.FinishState()
+		// Shared points are listed once, and MaxRune has no point after it:
+		assert.Equal(t, []int{0, 'a', 'b', 'c' + 1, 'd' + 1, 'x'}, b.CollectAlphabetPoints())
+		assert.Equal(t, []int{0}, NewAutomaton().CollectAlphabetPoints())
+	})
+
+	t.Run("testMultiple", func(t *testing.T) {
+		digits, err := MakeCharRange('0', '9')
+		assert.Nil(t, err)
+		p := NewAlphabetPartition(a, digits)
+		assert.Equal(t, []int{0, '0', '9' + 1, 'a', 'z' + 1}, p.Points())
+		assert.Equal(t, []int{0}, NewAlphabetPartition().Points())
+	})
+}
@@ -5,11 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
+	"strconv"
 	"unicode"
+	"unicode/utf8"
 )
 
 var defaultAutomata = &Automata{}
 
+// Automata Construction of basic automata. The factory methods are also available as package functions, e.g.
+// MakeString, which use a shared default instance.
 type Automata struct {
 }
 
@@ -18,6 +23,17 @@ type Automata struct {
 func (*Automata) MakeEmpty() *Automaton {
 	a := NewAutomaton()
 	a.FinishState()
+	a.noDeadStates = true
+	return a
+}
+
+// MakeEmptyWithKind
+// Returns a new (deterministic) automaton with the empty language over the given alphabet. The language is the
+// same for every alphabet, but operations on the automaton depend on it, e.g. Complement accepts all byte
+// strings for ALPHABET_BINARY and all code point strings for ALPHABET_UNICODE.
+func (r *Automata) MakeEmptyWithKind(alphabet Alphabet) *Automaton {
+	a := r.MakeEmpty()
+	a.alphabet = alphabet
 	return a
 }
 
@@ -27,6 +43,7 @@ func (*Automata) MakeEmptyString() *Automaton {
 	a := NewAutomaton()
 	a.CreateState()
 	a.SetAccept(0, true)
+	a.noDeadStates = true
 	return a
 }
 
@@ -40,22 +57,30 @@ func (*Automata) MakeAnyString() (*Automaton, error) {
 		return nil, err
 	}
 	a.FinishState()
+	a.noDeadStates = true
 	return a, nil
 }
 
+// MakeAnyBinary
+// Returns a new (deterministic) automaton that accepts all binary terms.
 func (*Automata) MakeAnyBinary() (*Automaton, error) {
 	a := NewAutomaton()
+	a.alphabet = ALPHABET_BINARY
 	s := a.CreateState()
 	a.SetAccept(s, true)
 	if err := a.AddTransition(s, s, 0, math.MaxUint8); err != nil {
 		return nil, err
 	}
 	a.FinishState()
+	a.noDeadStates = true
 	return a, nil
 }
 
+// MakeNonEmptyBinary
+// Returns a new (deterministic) automaton that accepts all binary terms except the empty string.
 func (*Automata) MakeNonEmptyBinary() (*Automaton, error) {
 	a := NewAutomaton()
+	a.alphabet = ALPHABET_BINARY
 	s1 := a.CreateState()
 	s2 := a.CreateState()
 	a.SetAccept(s2, true)
@@ -66,6 +91,81 @@ func (*Automata) MakeNonEmptyBinary() (*Automaton, error) {
 		return nil, err
 	}
 	a.FinishState()
+	a.noDeadStates = true
+	return a, nil
+}
+
+// MakeAnyStringOfLength
+// Returns a new (deterministic, minimal) automaton that accepts all strings of exactly n code points.
+func (*Automata) MakeAnyStringOfLength(n int) (*Automaton, error) {
+	return makeAnyOfLength(n, n, unicode.MaxRune, ALPHABET_UNICODE)
+}
+
+// MakeAnyStringUpToLength
+// Returns a new (deterministic, minimal) automaton that accepts all strings of at most n code points,
+// including the empty string.
+func (*Automata) MakeAnyStringUpToLength(n int) (*Automaton, error) {
+	return makeAnyOfLength(0, n, unicode.MaxRune, ALPHABET_UNICODE)
+}
+
+// MakeAnyBinaryOfLength
+// Returns a new (deterministic, minimal) automaton that accepts all binary terms of exactly n bytes.
+func (*Automata) MakeAnyBinaryOfLength(n int) (*Automaton, error) {
+	return makeAnyOfLength(n, n, math.MaxUint8, ALPHABET_BINARY)
+}
+
+// MakeAnyBinaryUpToLength
+// Returns a new (deterministic, minimal) automaton that accepts all binary terms of at most n bytes,
+// including the empty term.
+func (*Automata) MakeAnyBinaryUpToLength(n int) (*Automaton, error) {
+	return makeAnyOfLength(0, n, math.MaxUint8, ALPHABET_BINARY)
+}
+
+// makeAnyOfLength Returns a chain of maxLength+1 states, each reading any label up to maxLabel, that accepts
+// the strings of minLength to maxLength labels.
+func makeAnyOfLength(minLength, maxLength, maxLabel int, alphabet Alphabet) (*Automaton, error) {
+	if maxLength < 0 {
+		return nil, fmt.Errorf("length must be >= 0, got %d", maxLength)
+	}
+	a := NewAutomaton()
+	a.alphabet = alphabet
+	for i := 0; i <= maxLength; i++ {
+		s := a.CreateState()
+		a.SetAccept(s, i >= minLength)
+		if i > 0 {
+			if err := a.AddTransition(s-1, s, 0, maxLabel); err != nil {
+				return nil, err
+			}
+		}
+	}
+	a.FinishState()
+	a.noDeadStates = true
+	return a, nil
+}
+
+// MakeBinaryChar
+// Returns a new (deterministic) automaton that accepts the single byte b.
+func (r *Automata) MakeBinaryChar(b byte) (*Automaton, error) {
+	return r.MakeBinaryRange(b, b)
+}
+
+// MakeBinaryRange
+// Returns a new (deterministic) automaton that accepts a single byte whose value is in the given interval
+// (including both end points).
+func (r *Automata) MakeBinaryRange(min, max byte) (*Automaton, error) {
+	if min > max {
+		return asBinary(r.MakeEmpty(), nil)
+	}
+	a := NewAutomaton()
+	a.alphabet = ALPHABET_BINARY
+	s1 := a.CreateState()
+	s2 := a.CreateState()
+	a.SetAccept(s2, true)
+	if err := a.AddTransition(s1, s2, int(min), int(max)); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+	a.noDeadStates = true
 	return a, nil
 }
 
@@ -89,27 +189,95 @@ func (r *Automata) MakeCharRange(min, max int32) (*Automaton, error) {
 		return nil, err
 	}
 	a.FinishState()
+	a.noDeadStates = true
 	return a, nil
 }
 
-func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
-	max []byte, maxInclusive bool) (*Automaton, error) {
+// MakeCharSet
+// Returns a new (deterministic) automaton that accepts a single character of the given set.
+func (r *Automata) MakeCharSet(runes []rune) (*Automaton, error) {
+	if len(runes) == 0 {
+		return r.MakeEmpty(), nil
+	}
+	a := NewAutomaton()
+	s1 := a.CreateState()
+	s2 := a.CreateState()
+	a.SetAccept(s2, true)
+	for _, c := range runes {
+		if err := a.AddTransitionLabel(s1, s2, int(c)); err != nil {
+			return nil, err
+		}
+	}
+	a.FinishState()
+	return a, nil
+}
+
+// MakeRangeTable
+// Returns a new (deterministic) automaton that accepts a single character of the given table, e.g.
+// unicode.Letter or unicode.Han.
+func (r *Automata) MakeRangeTable(rt *unicode.RangeTable) (*Automaton, error) {
+	a := NewAutomaton()
+	s1 := a.CreateState()
+	s2 := a.CreateState()
+	a.SetAccept(s2, true)
 
-	if len(min) == 0 && minInclusive == false {
-		return nil, errors.New("minInclusive must be true when min is null (open ended)")
+	addRange := func(lo, hi, stride int) error {
+		if stride == 1 {
+			return a.AddTransition(s1, s2, lo, hi)
+		}
+		for c := lo; c <= hi; c += stride {
+			if err := a.AddTransitionLabel(s1, s2, c); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+	for _, rng := range rt.R16 {
+		if err := addRange(int(rng.Lo), int(rng.Hi), int(rng.Stride)); err != nil {
+			return nil, err
+		}
+	}
+	for _, rng := range rt.R32 {
+		if err := addRange(int(rng.Lo), int(rng.Hi), int(rng.Stride)); err != nil {
+			return nil, err
+		}
+	}
+	a.FinishState()
 
-	if len(max) == 0 && maxInclusive == false {
-		return nil, errors.New("maxInclusive must be true when max is null (open ended)")
+	if a.GetNumTransitions() == 0 {
+		return r.MakeEmpty(), nil
 	}
+	return a, nil
+}
+
+// Bound One end of an interval of byte strings, or of the strings of MakeStringRange (whose Value is UTF-8).
+// Unlike a nil Value, which is just the empty string, an Unbounded bound leaves that end of the interval open,
+// and Value and Inclusive are ignored.
+type Bound struct {
+	Value     []byte
+	Inclusive bool
+	Unbounded bool
+}
+
+// MakeBinaryInterval Creates a new deterministic automaton accepting all binary terms in the specified
+// interval. Note that unlike MakeDecimalInterval, the returned automaton is infinite, because terms behave
+// like floating point numbers leading with a decimal point. However, in the special case where lower bound
+// and upper bound share a prefix and the upper bound is that prefix followed by zero bytes, the result is
+// finite.
+func (r *Automata) MakeBinaryInterval(lower, upper Bound) (*Automaton, error) {
+	return asBinary(r.makeBinaryInterval(lower, upper))
+}
 
-	if len(min) == 0 {
-		//min = new BytesRef();
-		minInclusive = true
+func (r *Automata) makeBinaryInterval(lower, upper Bound) (*Automaton, error) {
+	min, minInclusive := lower.Value, lower.Inclusive
+	max, maxInclusive := upper.Value, upper.Inclusive
+	if lower.Unbounded {
+		min, minInclusive = nil, true
 	}
+	hasMax := !upper.Unbounded
 
 	var cmp int
-	if len(max) != 0 {
+	if hasMax {
 		cmp = bytes.Compare(min, max)
 	} else {
 		cmp = -1
@@ -133,7 +301,7 @@ func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
 		return r.MakeEmpty(), nil
 	}
 
-	if len(max) != 0 &&
+	if hasMax &&
 		bytes.HasPrefix(max, min) &&
 		suffixIsZeros(max, len(min)) {
 
@@ -203,7 +371,7 @@ func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
 		minLabel := int(min[i])
 
 		var maxLabel int
-		if len(max) != 0 && equalPrefix && i < len(max) {
+		if hasMax && equalPrefix && i < len(max) {
 			maxLabel = int(max[i])
 		} else {
 			maxLabel = -1
@@ -223,7 +391,7 @@ func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
 				if err := a.AddTransitionLabel(lastState, nextState, minLabel); err != nil {
 					return nil, err
 				}
-			} else if len(max) == 0 {
+			} else if !hasMax {
 				equalPrefix = false
 				sharedPrefixLength = 0
 				if err := a.AddTransition(lastState, sinkState, minLabel+1, 0xff); err != nil {
@@ -285,7 +453,7 @@ func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
 		a.SetAccept(lastState, true)
 	}
 
-	if len(max) != 0 {
+	if hasMax {
 
 		// Now do max:
 		if firstMaxState == -1 {
@@ -326,6 +494,14 @@ func (r *Automata) MakeBinaryInterval(min []byte, minInclusive bool,
 	return a, nil
 }
 
+// Marks the automaton returned by a factory as binary.
+func asBinary(a *Automaton, err error) (*Automaton, error) {
+	if a != nil {
+		a.alphabet = ALPHABET_BINARY
+	}
+	return a, err
+}
+
 func suffixIsZeros(bs []byte, size int) bool {
 	for _, v := range bs[size:] {
 		if v != 0 {
@@ -335,19 +511,32 @@ func suffixIsZeros(bs []byte, size int) bool {
 	return true
 }
 
+// MakeDecimalInterval
+// Returns a new automaton that accepts strings representing decimal (base 10) non-negative integers in the
+// given interval. If digits > 0, the strings must have exactly that many digits (smaller numbers are prefixed
+// by 0s); otherwise the number of digits is not fixed and any number of leading 0s is accepted.
 func (r *Automata) MakeDecimalInterval(min, max, digits int) (*Automaton, error) {
-	x := fmt.Sprint(min)
-	y := fmt.Sprint(max)
 	if min > max {
 		return nil, errors.New("min > max")
 	}
+	if min < 0 {
+		return nil, errors.New("min must be >= 0")
+	}
+	return makeDecimalInterval(strconv.Itoa(min), strconv.Itoa(max), digits)
+}
 
+// Same as MakeDecimalInterval, with the bounds given as strings of decimal digits, so the bounds are not
+// limited to the range of int.
+func makeDecimalInterval(x, y string, digits int) (*Automaton, error) {
 	var d int
 	if digits > 0 {
 		d = digits
 	} else {
 		d = len(y)
 	}
+	if len(y) > d {
+		return nil, fmt.Errorf("max has more than %d digits", d)
+	}
 
 	bx := new(bytes.Buffer)
 	for i := len(x); i < d; i++ {
@@ -365,12 +554,11 @@ func (r *Automata) MakeDecimalInterval(min, max, digits int) (*Automaton, error)
 
 	builder := NewBuilder()
 	if digits <= 0 {
+		// Reserve the "real" initial state:
 		builder.CreateState()
 	}
 
-	initials := make([]int, 0, 4)
-
-	between(builder, x, y, 0, initials, digits <= 0)
+	initials, _ := between(builder, x, y, 0, make([]int, 0, 4), digits <= 0)
 
 	a1 := builder.Finish()
 
@@ -387,6 +575,75 @@ func (r *Automata) MakeDecimalInterval(min, max, digits int) (*Automaton, error)
 	return a1, nil
 }
 
+// MakeIntegerRange
+// Returns a new (deterministic) automaton that accepts the decimal representations of the integers in the
+// given interval (including both end points). Any number of leading 0s is accepted, negative integers are
+// written with a leading '-', and non-negative integers may be written with a leading '+'; zero is never
+// negative.
+func (r *Automata) MakeIntegerRange(min, max int64) (*Automaton, error) {
+	if min > max {
+		return nil, errors.New("min > max")
+	}
+
+	parts := make([]*Automaton, 0, 2)
+	if max >= 0 {
+		// max(min, 0) <= n <= max, optionally signed:
+		lo := min
+		if lo < 0 {
+			lo = 0
+		}
+		a, err := makeDecimalInterval(strconv.FormatInt(lo, 10), strconv.FormatInt(max, 10), 0)
+		if err != nil {
+			return nil, err
+		}
+		plus, err := r.MakeChar('+')
+		if err != nil {
+			return nil, err
+		}
+		plus, err = optional(plus)
+		if err != nil {
+			return nil, err
+		}
+		a, err = concatenate(plus, a)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, a)
+	}
+	if min < 0 {
+		// -magnitude for magnitude in [-min(max, -1), -min]:
+		hi := max
+		if hi > -1 {
+			hi = -1
+		}
+		a, err := makeDecimalInterval(magnitude(hi), magnitude(min), 0)
+		if err != nil {
+			return nil, err
+		}
+		minus, err := r.MakeChar('-')
+		if err != nil {
+			return nil, err
+		}
+		a, err = concatenate(minus, a)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, a)
+	}
+
+	a, err := union(parts...)
+	if err != nil {
+		return nil, err
+	}
+	return Minimize(a, DefaultWorkLimit())
+}
+
+// Returns the decimal digits of a negative int64 without the sign; -n does not fit in an int64 for
+// math.MinInt64.
+func magnitude(n int64) string {
+	return strconv.FormatUint(uint64(-(n+1))+1, 10)
+}
+
 func between(builder *Builder, x, y string, n int, initials []int, zeros bool) ([]int, int) {
 	s := builder.CreateState()
 	if len(x) == n {
@@ -459,6 +716,8 @@ func anyOfRightLength(builder *Builder, x string, n int) int {
 	return s
 }
 
+// MakeString
+// Returns a new (deterministic) automaton that accepts the single given string.
 func (r *Automata) MakeString(s string) (*Automaton, error) {
 	a := NewAutomaton()
 	lastState := a.CreateState()
@@ -474,11 +733,15 @@ func (r *Automata) MakeString(s string) (*Automaton, error) {
 	a.SetAccept(lastState, true)
 	a.FinishState()
 
+	a.noDeadStates = true
 	return a, nil
 }
 
+// MakeBinary
+// Returns a new (deterministic) automaton that accepts the single given binary term.
 func (r *Automata) MakeBinary(term []byte) (*Automaton, error) {
 	a := NewAutomaton()
+	a.alphabet = ALPHABET_BINARY
 	lastState := a.CreateState()
 	for i := 0; i < len(term); i++ {
 		state := a.CreateState()
@@ -492,5 +755,406 @@ func (r *Automata) MakeBinary(term []byte) (*Automaton, error) {
 	a.SetAccept(lastState, true)
 	a.FinishState()
 
+	a.noDeadStates = true
+	return a, nil
+}
+
+// MakeBinaryPrefix
+// Returns a new (deterministic) automaton that accepts all binary terms starting with the given prefix.
+func (r *Automata) MakeBinaryPrefix(prefix []byte) (*Automaton, error) {
+	a := NewAutomaton()
+	a.alphabet = ALPHABET_BINARY
+	lastState := a.CreateState()
+	for i := 0; i < len(prefix); i++ {
+		state := a.CreateState()
+		label := int(prefix[i])
+		if err := a.AddTransition(lastState, state, label, label); err != nil {
+			return nil, err
+		}
+		lastState = state
+	}
+
+	a.SetAccept(lastState, true)
+	if err := a.AddTransition(lastState, lastState, 0, math.MaxUint8); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+
+	a.noDeadStates = true
 	return a, nil
 }
+
+// MakePrefix
+// Returns a new (deterministic) automaton that accepts all strings starting with the given prefix.
+func (r *Automata) MakePrefix(prefix string) (*Automaton, error) {
+	a := NewAutomaton()
+	lastState := a.CreateState()
+	for _, v := range prefix {
+		state := a.CreateState()
+		if err := a.AddTransitionLabel(lastState, state, int(v)); err != nil {
+			return nil, err
+		}
+		lastState = state
+	}
+
+	a.SetAccept(lastState, true)
+	if err := a.AddTransition(lastState, lastState, 0, unicode.MaxRune); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+
+	a.noDeadStates = true
+	return a, nil
+}
+
+// MakeStringRange
+// Returns a new (deterministic) automaton that accepts all strings between lower and upper, comparing code
+// point by code point; this is the Unicode analogue of MakeBinaryInterval, and takes the same bounds, whose
+// values must be valid UTF-8. An Unbounded bound leaves that end open; an inclusive empty lower bound is the
+// same as an unbounded one, but an empty upper bound admits at most the empty string.
+func (r *Automata) MakeStringRange(lower, upper Bound) (*Automaton, error) {
+	if !lower.Unbounded && !utf8.Valid(lower.Value) || !upper.Unbounded && !utf8.Valid(upper.Value) {
+		return nil, errors.New("bound is not valid UTF-8")
+	}
+	if lower.Unbounded {
+		lower = Bound{Inclusive: true}
+	}
+	atLeast, err := makeAtLeastString([]rune(string(lower.Value)), lower.Inclusive)
+	if err != nil {
+		return nil, err
+	}
+	if upper.Unbounded {
+		return atLeast, nil
+	}
+	atMost, err := makeAtMostString([]rune(string(upper.Value)), upper.Inclusive)
+	if err != nil {
+		return nil, err
+	}
+	return intersection(atLeast, atMost)
+}
+
+// Returns an automaton accepting the strings after (or equal to, if inclusive) the given one. States 0 to
+// len(s) follow s, the last state accepts every string.
+func makeAtLeastString(s []rune, inclusive bool) (*Automaton, error) {
+	a := NewAutomaton()
+	for i := 0; i <= len(s)+1; i++ {
+		a.CreateState()
+	}
+	after := len(s) + 1
+	for i, c := range s {
+		if err := a.AddTransition(i, i+1, int(c), int(c)); err != nil {
+			return nil, err
+		}
+		if c < unicode.MaxRune {
+			if err := a.AddTransition(i, after, int(c)+1, unicode.MaxRune); err != nil {
+				return nil, err
+			}
+		}
+	}
+	// Any string extending s comes after it:
+	a.SetAccept(len(s), inclusive)
+	if err := a.AddTransition(len(s), after, 0, unicode.MaxRune); err != nil {
+		return nil, err
+	}
+	a.SetAccept(after, true)
+	if err := a.AddTransition(after, after, 0, unicode.MaxRune); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+	return a, nil
+}
+
+// Returns an automaton accepting the strings before (or equal to, if inclusive) the given one. States 0 to
+// len(s) follow s, the last state accepts every string.
+func makeAtMostString(s []rune, inclusive bool) (*Automaton, error) {
+	a := NewAutomaton()
+	for i := 0; i <= len(s)+1; i++ {
+		a.CreateState()
+	}
+	before := len(s) + 1
+	for i, c := range s {
+		// Proper prefixes of s come before it:
+		a.SetAccept(i, true)
+		if c > 0 {
+			if err := a.AddTransition(i, before, 0, int(c)-1); err != nil {
+				return nil, err
+			}
+		}
+		if err := a.AddTransition(i, i+1, int(c), int(c)); err != nil {
+			return nil, err
+		}
+	}
+	a.SetAccept(len(s), inclusive)
+	a.SetAccept(before, true)
+	if err := a.AddTransition(before, before, 0, unicode.MaxRune); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+	return a, nil
+}
+
+// MakeSubstring
+// Returns a new (deterministic) automaton that accepts all strings containing s, like the pattern .*s.* but
+// without escaping. State i means that the longest suffix of the input that is a prefix of s has length i, as
+// in Knuth-Morris-Pratt matching.
+func (r *Automata) MakeSubstring(s string) (*Automaton, error) {
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return r.MakeAnyString()
+	}
+
+	// fail[i] is the length of the longest proper suffix of runes[:i+1] that is also a prefix of runes:
+	fail := make([]int, len(runes))
+	for i, k := 1, 0; i < len(runes); i++ {
+		for k > 0 && runes[i] != runes[k] {
+			k = fail[k-1]
+		}
+		if runes[i] == runes[k] {
+			k++
+		}
+		fail[i] = k
+	}
+	distinct := []rune(string(runes))
+	slices.Sort(distinct)
+	distinct = slices.Compact(distinct)
+
+	a := NewAutomaton()
+	for i := 0; i <= len(runes); i++ {
+		a.CreateState()
+	}
+	for i := 0; i < len(runes); i++ {
+		// Code points that do not occur in s go back to the initial state:
+		next := 0
+		for _, c := range distinct {
+			k := i
+			for k > 0 && runes[k] != c {
+				k = fail[k-1]
+			}
+			dest := 0
+			if runes[k] == c {
+				dest = k + 1
+			}
+			if int(c) > next {
+				if err := a.AddTransition(i, 0, next, int(c)-1); err != nil {
+					return nil, err
+				}
+			}
+			if err := a.AddTransitionLabel(i, dest, int(c)); err != nil {
+				return nil, err
+			}
+			next = int(c) + 1
+		}
+		if next <= unicode.MaxRune {
+			if err := a.AddTransition(i, 0, next, unicode.MaxRune); err != nil {
+				return nil, err
+			}
+		}
+	}
+	last := len(runes)
+	a.SetAccept(last, true)
+	if err := a.AddTransition(last, last, 0, unicode.MaxRune); err != nil {
+		return nil, err
+	}
+	a.FinishState()
+	return a, nil
+}
+
+// MakeNGrams
+// Returns a new (deterministic, minimal) automaton that accepts all strings containing at least one of the
+// n code point long substrings of s. If s is shorter than n the language is empty.
+func (r *Automata) MakeNGrams(s string, n int) (*Automaton, error) {
+	if n <= 0 {
+		return nil, errors.New("n must be > 0")
+	}
+	runes := []rune(s)
+	if len(runes) < n {
+		return r.MakeEmpty(), nil
+	}
+
+	ngrams := make([]*Automaton, 0, len(runes)-n+1)
+	seen := make(map[string]struct{})
+	for i := 0; i+n <= len(runes); i++ {
+		ngram := string(runes[i : i+n])
+		if _, ok := seen[ngram]; ok {
+			continue
+		}
+		seen[ngram] = struct{}{}
+		a, err := r.MakeString(ngram)
+		if err != nil {
+			return nil, err
+		}
+		ngrams = append(ngrams, a)
+	}
+	anyOf, err := union(ngrams...)
+	if err != nil {
+		return nil, err
+	}
+	anyString, err := r.MakeAnyString()
+	if err != nil {
+		return nil, err
+	}
+	a, err := concatenate(anyString, anyOf, anyString)
+	if err != nil {
+		return nil, err
+	}
+	return Minimize(a, DefaultWorkLimit())
+}
+
+// MakeEmpty Returns a new (deterministic) automaton with the empty language. See Automata.MakeEmpty.
+func MakeEmpty() *Automaton {
+	return defaultAutomata.MakeEmpty()
+}
+
+// MakeEmptyWithKind Returns a new (deterministic) automaton with the empty language over the given alphabet. See
+// Automata.MakeEmptyWithKind.
+func MakeEmptyWithKind(alphabet Alphabet) *Automaton {
+	return defaultAutomata.MakeEmptyWithKind(alphabet)
+}
+
+// MakeEmptyString Returns a new (deterministic) automaton that accepts only the empty string. See
+// Automata.MakeEmptyString.
+func MakeEmptyString() *Automaton {
+	return defaultAutomata.MakeEmptyString()
+}
+
+// MakeAnyString Returns a new (deterministic) automaton that accepts all strings. See Automata.MakeAnyString.
+func MakeAnyString() (*Automaton, error) {
+	return defaultAutomata.MakeAnyString()
+}
+
+// MakeAnyBinary Returns a new (deterministic) automaton that accepts all binary terms. See
+// Automata.MakeAnyBinary.
+func MakeAnyBinary() (*Automaton, error) {
+	return defaultAutomata.MakeAnyBinary()
+}
+
+// MakeNonEmptyBinary Returns a new (deterministic) automaton that accepts all binary terms except the empty
+// string. See Automata.MakeNonEmptyBinary.
+func MakeNonEmptyBinary() (*Automaton, error) {
+	return defaultAutomata.MakeNonEmptyBinary()
+}
+
+// MakeAnyStringOfLength Returns a new (deterministic) automaton that accepts all strings of exactly n code
+// points. See Automata.MakeAnyStringOfLength.
+func MakeAnyStringOfLength(n int) (*Automaton, error) {
+	return defaultAutomata.MakeAnyStringOfLength(n)
+}
+
+// MakeAnyStringUpToLength Returns a new (deterministic) automaton that accepts all strings of at most n code
+// points. See Automata.MakeAnyStringUpToLength.
+func MakeAnyStringUpToLength(n int) (*Automaton, error) {
+	return defaultAutomata.MakeAnyStringUpToLength(n)
+}
+
+// MakeAnyBinaryOfLength Returns a new (deterministic) automaton that accepts all binary terms of exactly n
+// bytes. See Automata.MakeAnyBinaryOfLength.
+func MakeAnyBinaryOfLength(n int) (*Automaton, error) {
+	return defaultAutomata.MakeAnyBinaryOfLength(n)
+}
+
+// MakeAnyBinaryUpToLength Returns a new (deterministic) automaton that accepts all binary terms of at most n
+// bytes. See Automata.MakeAnyBinaryUpToLength.
+func MakeAnyBinaryUpToLength(n int) (*Automaton, error) {
+	return defaultAutomata.MakeAnyBinaryUpToLength(n)
+}
+
+// MakeBinaryChar Returns a new (deterministic) automaton that accepts the single given byte. See
+// Automata.MakeBinaryChar.
+func MakeBinaryChar(b byte) (*Automaton, error) {
+	return defaultAutomata.MakeBinaryChar(b)
+}
+
+// MakeBinaryRange Returns a new (deterministic) automaton that accepts a single byte in [min, max]. See
+// Automata.MakeBinaryRange.
+func MakeBinaryRange(min, max byte) (*Automaton, error) {
+	return defaultAutomata.MakeBinaryRange(min, max)
+}
+
+// MakeAnyChar Returns a new (deterministic) automaton that accepts any single code point. See
+// Automata.MakeAnyChar.
+func MakeAnyChar() (*Automaton, error) {
+	return defaultAutomata.MakeAnyChar()
+}
+
+// MakeChar Returns a new (deterministic) automaton that accepts a single code point. See Automata.MakeChar.
+func MakeChar(c int32) (*Automaton, error) {
+	return defaultAutomata.MakeChar(c)
+}
+
+// MakeCharRange Returns a new (deterministic) automaton that accepts a single code point in [min, max]. See
+// Automata.MakeCharRange.
+func MakeCharRange(min, max int32) (*Automaton, error) {
+	return defaultAutomata.MakeCharRange(min, max)
+}
+
+// MakeCharSet Returns a new (deterministic) automaton that accepts a single code point from the given set.
+// See Automata.MakeCharSet.
+func MakeCharSet(runes []rune) (*Automaton, error) {
+	return defaultAutomata.MakeCharSet(runes)
+}
+
+// MakeRangeTable Returns a new (deterministic) automaton that accepts a single code point from the given
+// table. See Automata.MakeRangeTable.
+func MakeRangeTable(rt *unicode.RangeTable) (*Automaton, error) {
+	return defaultAutomata.MakeRangeTable(rt)
+}
+
+// MakeBinaryInterval Returns a new (deterministic) automaton that accepts all binary terms in the given
+// interval. See Automata.MakeBinaryInterval.
+func MakeBinaryInterval(lower, upper Bound) (*Automaton, error) {
+	return defaultAutomata.MakeBinaryInterval(lower, upper)
+}
+
+// MakeDecimalInterval Returns a new automaton that accepts the decimal non-negative integers in the given
+// interval. See Automata.MakeDecimalInterval.
+func MakeDecimalInterval(min, max, digits int) (*Automaton, error) {
+	return defaultAutomata.MakeDecimalInterval(min, max, digits)
+}
+
+// MakeIntegerRange Returns a new (deterministic) automaton that accepts the decimal integers in the given
+// interval. See Automata.MakeIntegerRange.
+func MakeIntegerRange(min, max int64) (*Automaton, error) {
+	return defaultAutomata.MakeIntegerRange(min, max)
+}
+
+// MakeString Returns a new (deterministic) automaton that accepts the single given string. See
+// Automata.MakeString.
+func MakeString(s string) (*Automaton, error) {
+	return defaultAutomata.MakeString(s)
+}
+
+// MakeBinary Returns a new (deterministic) automaton that accepts the single given binary term. See
+// Automata.MakeBinary.
+func MakeBinary(term []byte) (*Automaton, error) {
+	return defaultAutomata.MakeBinary(term)
+}
+
+// MakeBinaryPrefix Returns a new (deterministic) automaton that accepts all binary terms starting with the
+// given prefix. See Automata.MakeBinaryPrefix.
+func MakeBinaryPrefix(prefix []byte) (*Automaton, error) {
+	return defaultAutomata.MakeBinaryPrefix(prefix)
+}
+
+// MakePrefix Returns a new (deterministic) automaton that accepts all strings starting with the given prefix.
+// See Automata.MakePrefix.
+func MakePrefix(prefix string) (*Automaton, error) {
+	return defaultAutomata.MakePrefix(prefix)
+}
+
+// MakeStringRange Returns a new (deterministic) automaton that accepts all strings between lower and upper.
+// See Automata.MakeStringRange.
+func MakeStringRange(lower, upper Bound) (*Automaton, error) {
+	return defaultAutomata.MakeStringRange(lower, upper)
+}
+
+// MakeSubstring Returns a new (deterministic) automaton that accepts all strings containing s. See
+// Automata.MakeSubstring.
+func MakeSubstring(s string) (*Automaton, error) {
+	return defaultAutomata.MakeSubstring(s)
+}
+
+// MakeNGrams Returns a new (deterministic) automaton that accepts all strings containing an n-gram of s. See
+// Automata.MakeNGrams.
+func MakeNGrams(s string, n int) (*Automaton, error) {
+	return defaultAutomata.MakeNGrams(s, n)
+}
@@ -0,0 +1,494 @@
+package automaton
+
+import (
+	"bytes"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutomata_Binary(t *testing.T) {
+	run := func(a *Automaton, s string) bool {
+		r, err := NewByteRunAutomaton(a, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		return r.Run([]byte(s))
+	}
+
+	t.Run("testMakeAnyBinary", func(t *testing.T) {
+		a, err := defaultAutomata.MakeAnyBinary()
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		assert.True(t, run(a, ""))
+		assert.True(t, run(a, "\x00\xff"))
+		assert.True(t, IsTotalAutomatonRange(a, 0, 255))
+	})
+
+	t.Run("testMakeNonEmptyBinary", func(t *testing.T) {
+		a, err := defaultAutomata.MakeNonEmptyBinary()
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		assert.False(t, run(a, ""))
+		assert.True(t, run(a, "\x00"))
+		assert.True(t, run(a, "\xff\x00abc"))
+	})
+
+	t.Run("testMakeBinaryChar", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinaryChar(0xfe)
+		assert.Nil(t, err)
+		assert.True(t, run(a, "\xfe"))
+		assert.False(t, run(a, "\xff"))
+		assert.False(t, run(a, "\xfe\xfe"))
+		assert.False(t, run(a, ""))
+	})
+
+	t.Run("testMakeBinaryRange", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinaryRange('b', 'd')
+		assert.Nil(t, err)
+		assert.False(t, run(a, "a"))
+		assert.True(t, run(a, "b"))
+		assert.True(t, run(a, "d"))
+		assert.False(t, run(a, "e"))
+		assert.False(t, run(a, "bb"))
+
+		a, err = defaultAutomata.MakeBinaryRange('d', 'b')
+		assert.Nil(t, err)
+		assert.True(t, IsEmptyAutomaton(a))
+	})
+
+	t.Run("testMakeBinaryPrefix", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinaryPrefix([]byte("ab"))
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		assert.False(t, run(a, ""))
+		assert.False(t, run(a, "a"))
+		assert.True(t, run(a, "ab"))
+		assert.True(t, run(a, "ab\x00\xff"))
+		assert.False(t, run(a, "b"))
+
+		a, err = defaultAutomata.MakeBinaryPrefix(nil)
+		assert.Nil(t, err)
+		assert.True(t, run(a, ""))
+		assert.True(t, run(a, "\xff"))
+	})
+}
+
+func TestAutomata_MakeEmptyWithKind(t *testing.T) {
+	for _, alphabet := range []Alphabet{ALPHABET_UNICODE, ALPHABET_BINARY} {
+		a := defaultAutomata.MakeEmptyWithKind(alphabet)
+		assert.Equal(t, alphabet, a.Alphabet())
+		assert.True(t, IsEmptyAutomaton(a))
+		assert.Nil(t, a.Validate())
+
+		c, err := complement(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, alphabet, c.Alphabet())
+		if alphabet == ALPHABET_BINARY {
+			assert.True(t, IsTotalAutomatonRange(c, 0, 0xFF))
+		} else {
+			assert.True(t, IsTotalAutomatonRange(c, 0, unicode.MaxRune))
+		}
+	}
+	assert.Equal(t, ALPHABET_UNICODE, MakeEmpty().Alphabet())
+}
+
+func TestAutomata_MakeBinaryInterval(t *testing.T) {
+	// Every byte string of length <= 2 over {0, 1, 255}, as inclusive and exclusive bounds, plus unbounded:
+	values := [][]byte{{}}
+	for i := 0; i < len(values); i++ {
+		for _, b := range []byte{0, 1, 255} {
+			if len(values[i]) < 2 {
+				values = append(values, append(bytes.Clone(values[i]), b))
+			}
+		}
+	}
+	bounds := []Bound{{Unbounded: true}}
+	for _, v := range values {
+		bounds = append(bounds, Bound{Value: v, Inclusive: true}, Bound{Value: v})
+	}
+	// Terms to check, one byte longer than the bounds:
+	terms := [][]byte{{}}
+	for i := 0; i < len(terms); i++ {
+		for _, b := range []byte{0, 1, 2, 255} {
+			if len(terms[i]) < 3 {
+				terms = append(terms, append(bytes.Clone(terms[i]), b))
+			}
+		}
+	}
+
+	run := func(a *Automaton, term []byte) bool {
+		if a.GetNumStates() == 0 {
+			return false
+		}
+		state := 0
+		for _, b := range term {
+			if state = a.Step(state, int(b)); state == -1 {
+				return false
+			}
+		}
+		return a.IsAccept(state)
+	}
+	inBound := func(lower, upper Bound, term []byte) bool {
+		if !lower.Unbounded {
+			if cmp := bytes.Compare(lower.Value, term); cmp > 0 || (cmp == 0 && !lower.Inclusive) {
+				return false
+			}
+		}
+		if !upper.Unbounded {
+			if cmp := bytes.Compare(term, upper.Value); cmp > 0 || (cmp == 0 && !upper.Inclusive) {
+				return false
+			}
+		}
+		return true
+	}
+
+	for _, lower := range bounds {
+		for _, upper := range bounds {
+			a, err := defaultAutomata.MakeBinaryInterval(lower, upper)
+			if !assert.Nil(t, err) {
+				return
+			}
+			if !assert.True(t, a.IsDeterministic(), "lower=%+v upper=%+v", lower, upper) {
+				return
+			}
+			for _, term := range terms {
+				if !assert.Equal(t, inBound(lower, upper, term), run(a, term),
+					"lower=%+v upper=%+v term=%v", lower, upper, term) {
+					return
+				}
+			}
+		}
+	}
+
+	t.Run("testEmptyIsNotUnbounded", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinaryInterval(Bound{Unbounded: true}, Bound{Value: []byte{}, Inclusive: true})
+		assert.Nil(t, err)
+		assert.True(t, run(a, nil))
+		assert.False(t, run(a, []byte{0}))
+
+		a, err = defaultAutomata.MakeBinaryInterval(Bound{Value: nil}, Bound{Unbounded: true})
+		assert.Nil(t, err)
+		assert.False(t, run(a, nil))
+		assert.True(t, run(a, []byte{0}))
+	})
+}
+
+func TestAutomata_MakeDecimalInterval(t *testing.T) {
+	a, err := defaultAutomata.MakeDecimalInterval(7, 255, 0)
+	assert.Nil(t, err)
+	for _, s := range []string{"7", "007", "42", "0042", "255", "000255"} {
+		assert.True(t, runNFA(a, s), s)
+	}
+	for _, s := range []string{"", "0", "6", "006", "256", "1000", "-7"} {
+		assert.False(t, runNFA(a, s), s)
+	}
+
+	a, err = defaultAutomata.MakeDecimalInterval(7, 255, 4)
+	assert.Nil(t, err)
+	assert.True(t, runNFA(a, "0007"))
+	assert.True(t, runNFA(a, "0255"))
+	assert.False(t, runNFA(a, "7"))
+	assert.False(t, runNFA(a, "00007"))
+
+	_, err = defaultAutomata.MakeDecimalInterval(7, 255, 2)
+	assert.Error(t, err)
+	_, err = defaultAutomata.MakeDecimalInterval(-1, 255, 0)
+	assert.Error(t, err)
+}
+
+func TestAutomata_MakeIntegerRange(t *testing.T) {
+	r := rand.New(rand.NewSource(1550))
+	for i := 0; i < 30; i++ {
+		min := int64(r.Intn(200) - 100)
+		max := min + int64(r.Intn(100))
+		a, err := defaultAutomata.MakeIntegerRange(min, max)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+
+		for n := int64(-150); n <= 150; n++ {
+			want := min <= n && n <= max
+			s := strconv.FormatInt(n, 10)
+			assert.Equal(t, want, Run(a, s), "[%d, %d] %s", min, max, s)
+			if n >= 0 {
+				assert.Equal(t, want, Run(a, "+"+s), "[%d, %d] +%s", min, max, s)
+				assert.Equal(t, want, Run(a, "00"+s), "[%d, %d] 00%s", min, max, s)
+			} else {
+				assert.Equal(t, want, Run(a, "-00"+s[1:]), "[%d, %d] -00%s", min, max, s[1:])
+			}
+		}
+		assert.False(t, Run(a, "-0"))
+		assert.False(t, Run(a, "+-1"))
+		assert.False(t, Run(a, ""))
+	}
+
+	t.Run("testExtremes", func(t *testing.T) {
+		a, err := defaultAutomata.MakeIntegerRange(math.MinInt64, math.MaxInt64)
+		assert.Nil(t, err)
+		assert.True(t, Run(a, "-9223372036854775808"))
+		assert.False(t, Run(a, "-9223372036854775809"))
+		assert.True(t, Run(a, "9223372036854775807"))
+		assert.False(t, Run(a, "9223372036854775808"))
+		assert.True(t, Run(a, "0"))
+
+		_, err = defaultAutomata.MakeIntegerRange(1, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestAutomata_MakeCharSet(t *testing.T) {
+	a, err := defaultAutomata.MakeCharSet([]rune("aeiouü"))
+	assert.Nil(t, err)
+	assert.True(t, a.IsDeterministic())
+	for _, c := range "aeiouü" {
+		assert.True(t, Run(a, string(c)), string(c))
+	}
+	assert.False(t, Run(a, "b"))
+	assert.False(t, Run(a, "ae"))
+	assert.False(t, Run(a, ""))
+
+	// Adjacent characters collapse into one transition:
+	a, err = defaultAutomata.MakeCharSet([]rune("cbad"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, a.GetNumTransitions())
+
+	a, err = defaultAutomata.MakeCharSet(nil)
+	assert.Nil(t, err)
+	assert.True(t, IsEmptyAutomaton(a))
+}
+
+func TestAutomata_MakeRangeTable(t *testing.T) {
+	for _, table := range []*unicode.RangeTable{unicode.Letter, unicode.Greek, unicode.Nd, unicode.Lu} {
+		a, err := defaultAutomata.MakeRangeTable(table)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		for c := rune(0); c <= 0x20000; c += 7 {
+			assert.Equal(t, unicode.Is(table, c), Run(a, string(c)), "%U", c)
+		}
+	}
+
+	a, err := defaultAutomata.MakeRangeTable(&unicode.RangeTable{})
+	assert.Nil(t, err)
+	assert.True(t, IsEmptyAutomaton(a))
+}
+
+func TestAutomata_MakePrefix(t *testing.T) {
+	automata := &Automata{}
+	a, err := automata.MakePrefix("hé")
+	assert.Nil(t, err)
+	assert.True(t, a.IsDeterministic())
+	assert.True(t, Run(a, "hé"))
+	assert.True(t, Run(a, "héllo 世界"))
+	assert.False(t, Run(a, "h"))
+	assert.False(t, Run(a, "hello"))
+
+	a, err = automata.MakePrefix("")
+	assert.Nil(t, err)
+	assert.True(t, Run(a, ""))
+	assert.True(t, Run(a, "anything"))
+}
+
+func TestAutomata_MakeStringRange(t *testing.T) {
+	automata := &Automata{}
+
+	// All strings over {a, b, c} of length at most 3, and a few outside that alphabet:
+	values := []string{"", "\x00", "\U0010FFFF", "d", "é"}
+	for i := 0; i < len(values); i++ {
+		if len(values[i]) < 3 {
+			for _, c := range "abc" {
+				values = append(values, values[i]+string(c))
+			}
+		}
+	}
+
+	r := rand.New(rand.NewSource(1575))
+	for i := 0; i < 200; i++ {
+		lower := values[r.Intn(len(values))]
+		upper := values[r.Intn(len(values))]
+		includeLower, includeUpper := r.Intn(2) == 0, r.Intn(2) == 0
+		unboundedLower, unboundedUpper := r.Intn(5) == 0, r.Intn(5) == 0
+		a, err := automata.MakeStringRange(Bound{Value: []byte(lower), Inclusive: includeLower, Unbounded: unboundedLower},
+			Bound{Value: []byte(upper), Inclusive: includeUpper, Unbounded: unboundedUpper})
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+
+		for _, s := range values {
+			// Go compares valid UTF-8 strings in code point order:
+			want := (unboundedLower || s > lower || (includeLower && s == lower)) &&
+				(unboundedUpper || s < upper || (includeUpper && s == upper))
+			if !assert.Equal(t, want, Run(a, s), "lower=%q upper=%q s=%q", lower, upper, s) {
+				return
+			}
+		}
+	}
+
+	t.Run("testEmptyBounds", func(t *testing.T) {
+		// An empty upper bound is the empty string, not an open end:
+		a, err := automata.MakeStringRange(Bound{Inclusive: true}, Bound{Inclusive: true})
+		assert.Nil(t, err)
+		assert.True(t, Run(a, ""))
+		assert.False(t, Run(a, "a"))
+
+		a, err = automata.MakeStringRange(Bound{Value: []byte("b")}, Bound{Unbounded: true})
+		assert.Nil(t, err)
+		assert.False(t, Run(a, "b"))
+		assert.True(t, Run(a, "ba"))
+		assert.True(t, Run(a, "\U0010FFFF"))
+
+		a, err = automata.MakeStringRange(Bound{Unbounded: true}, Bound{Value: []byte("b")})
+		assert.Nil(t, err)
+		assert.True(t, Run(a, ""))
+		assert.True(t, Run(a, "azz"))
+		assert.False(t, Run(a, "b"))
+
+		_, err = automata.MakeStringRange(Bound{Value: []byte("\xff")}, Bound{Unbounded: true})
+		assert.Error(t, err)
+	})
+}
+
+func TestAutomata_MakeSubstring(t *testing.T) {
+	automata := &Automata{}
+	r := rand.New(rand.NewSource(1577))
+	for i := 0; i < 100; i++ {
+		sub := randomString(r, 4)
+		a, err := automata.MakeSubstring(sub)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		for j := 0; j < 50; j++ {
+			s := randomString(r, 10)
+			if !assert.Equal(t, strings.Contains(s, sub), Run(a, s), "sub=%q s=%q", sub, s) {
+				break
+			}
+		}
+	}
+
+	a, err := automata.MakeSubstring("世界")
+	assert.Nil(t, err)
+	assert.True(t, Run(a, "hello 世界!"))
+	assert.False(t, Run(a, "hello 世 界"))
+}
+
+func TestAutomata_MakeNGrams(t *testing.T) {
+	automata := &Automata{}
+	r := rand.New(rand.NewSource(1577))
+	for i := 0; i < 50; i++ {
+		s := randomString(r, 6)
+		n := 1 + r.Intn(3)
+		a, err := automata.MakeNGrams(s, n)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		for j := 0; j < 50; j++ {
+			input := randomString(r, 8)
+			want := false
+			for k := 0; k+n <= len(s); k++ {
+				if strings.Contains(input, s[k:k+n]) {
+					want = true
+				}
+			}
+			if !assert.Equal(t, want, Run(a, input), "s=%q n=%d input=%q", s, n, input) {
+				break
+			}
+		}
+	}
+
+	_, err := automata.MakeNGrams("abc", 0)
+	assert.NotNil(t, err)
+}
+
+func TestAutomata_MakeAnyOfLength(t *testing.T) {
+	automata := &Automata{}
+	t.Run("testMakeAnyStringOfLength", func(t *testing.T) {
+		a, err := automata.MakeAnyStringOfLength(2)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic())
+		assert.Equal(t, 3, a.GetNumStates())
+		assert.False(t, Run(a, "a"))
+		assert.True(t, Run(a, "ab"))
+		assert.True(t, Run(a, "世界"))
+		assert.False(t, Run(a, "abc"))
+
+		a, err = automata.MakeAnyStringOfLength(0)
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(automata.MakeEmptyString(), a))
+	})
+
+	t.Run("testMakeAnyStringUpToLength", func(t *testing.T) {
+		a, err := automata.MakeAnyStringUpToLength(2)
+		assert.Nil(t, err)
+		assert.True(t, Run(a, ""))
+		assert.True(t, Run(a, "世"))
+		assert.True(t, Run(a, "ab"))
+		assert.False(t, Run(a, "abc"))
+
+		m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, m.GetNumStates(), a.GetNumStates())
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		a, err := automata.MakeAnyBinaryOfLength(3)
+		assert.Nil(t, err)
+		assert.True(t, IsBinaryAutomaton(a))
+		r, err := NewByteRunAutomaton(a, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, r.Run([]byte{0, 0xff, 7}))
+		assert.False(t, r.Run([]byte{0, 0xff}))
+
+		a, err = automata.MakeAnyBinaryUpToLength(1)
+		assert.Nil(t, err)
+		r, err = NewByteRunAutomaton(a, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, r.Run(nil))
+		assert.True(t, r.Run([]byte{0xff}))
+		assert.False(t, r.Run([]byte{1, 2}))
+	})
+
+	_, err := automata.MakeAnyStringOfLength(-1)
+	assert.Error(t, err)
+	_, err = automata.MakeAnyBinaryUpToLength(-1)
+	assert.Error(t, err)
+}
+
+func TestMakeFunctions(t *testing.T) {
+	automata := &Automata{}
+	same := func(expected, actual *Automaton, err error) {
+		if assert.Nil(t, err) {
+			assert.True(t, StructurallyEqual(expected, actual))
+		}
+	}
+
+	expected, _ := automata.MakeString("hello")
+	actual, err := MakeString("hello")
+	same(expected, actual, err)
+
+	expected, _ = automata.MakeCharRange('a', 'z')
+	actual, err = MakeCharRange('a', 'z')
+	same(expected, actual, err)
+
+	expected, _ = automata.MakeBinaryInterval(Bound{Value: []byte("b")}, Bound{Unbounded: true})
+	actual, err = MakeBinaryInterval(Bound{Value: []byte("b")}, Bound{Unbounded: true})
+	same(expected, actual, err)
+
+	expected, _ = automata.MakeIntegerRange(-5, 120)
+	actual, err = MakeIntegerRange(-5, 120)
+	same(expected, actual, err)
+
+	expected, _ = automata.MakeStringRange(Bound{Value: []byte("a"), Inclusive: true}, Bound{Value: []byte("c")})
+	actual, err = MakeStringRange(Bound{Value: []byte("a"), Inclusive: true}, Bound{Value: []byte("c")})
+	same(expected, actual, err)
+
+	expected, _ = automata.MakePrefix("ab")
+	actual, err = MakePrefix("ab")
+	same(expected, actual, err)
+
+	expected, _ = automata.MakeAnyStringUpToLength(3)
+	actual, err = MakeAnyStringUpToLength(3)
+	same(expected, actual, err)
+
+	same(automata.MakeEmpty(), MakeEmpty(), nil)
+	same(automata.MakeEmptyWithKind(ALPHABET_BINARY), MakeEmptyWithKind(ALPHABET_BINARY), nil)
+	same(automata.MakeEmptyString(), MakeEmptyString(), nil)
+}
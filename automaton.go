@@ -1,8 +1,13 @@
 package automaton
 
 import (
+	"cmp"
+	"errors"
 	"fmt"
-	"sort"
+	"maps"
+	"slices"
+	"sync/atomic"
+	"unicode"
 
 	"github.com/bits-and-blooms/bitset"
 )
@@ -28,18 +33,75 @@ type Automaton struct {
 	curState int
 
 	// Index in the transitions array, where this states leaving transitions are stored, or -1
-	// if this state has not added any transitions yet, followed by number of transitions.
-	states []int
+	// if this state has not added any transitions yet, followed by number of transitions. Stored as int32
+	// since state and transition counts never exceed it, which halves the footprint of large automata.
+	states []int32
 
 	isAccept *bitset.BitSet
 
-	// Holds toState, min, max for each transition.
-	transitions []int
+	// The transitions of all states, those of each state stored together.
+	transitions []packedTransition
 
 	// True if no state has two transitions leaving with the same label.
 	deterministic bool
+
+	// True if the automaton is known to have no dead states (see HasDeadStates). Set by the factories and
+	// operations that guarantee it and cleared by any modification, so union can skip removing dead states.
+	noDeadStates bool
+
+	// True if the transitions of the states were split by Normalize, so ranges to the same dest may be adjacent.
+	normalized bool
+
+	// True once Freeze was called; a frozen automaton can no longer be modified.
+	frozen bool
+
+	// Whether labels are code points or bytes.
+	alphabet Alphabet
+
+	// Optional debugging labels of some states (see SetStateLabel); nil unless one was set.
+	stateLabels map[int]string
+
+	// Cached by AlphabetPartition once the automaton is frozen.
+	partition atomic.Pointer[AlphabetPartition]
+
+	// Dense table of the steps of the ASCII labels, built by Run once the automaton is frozen.
+	ascii atomic.Pointer[asciiTable]
+
+	// Cached by Hash64 once the automaton is frozen.
+	hash atomic.Pointer[uint64]
+}
+
+// Alphabet Tells how the labels of an automaton are to be interpreted.
+type Alphabet int
+
+const (
+	ALPHABET_UNICODE = Alphabet(iota) // Labels are Unicode code points, the default
+	ALPHABET_BINARY                   // Labels are bytes, as created by the binary factories (e.g. MakeBinary)
+)
+
+// String Returns the name of the alphabet.
+func (a Alphabet) String() string {
+	if a == ALPHABET_BINARY {
+		return "binary"
+	}
+	return "unicode"
 }
 
+// maxLabel The largest label of the alphabet.
+func (a Alphabet) maxLabel() int {
+	if a == ALPHABET_BINARY {
+		return 0xFF
+	}
+	return unicode.MaxRune
+}
+
+// unsetOffset The offset into transitions of a state to which no transitions were added (yet); its count is 0.
+const unsetOffset = -1
+
+// ErrFrozen Returned (or, by methods that cannot return an error, raised as a panic) when modifying an
+// automaton after Freeze.
+var ErrFrozen = errors.New("automaton is frozen")
+
 func NewAutomaton() *Automaton {
 	return NewAutomatonV1(2, 2)
 }
@@ -48,16 +110,20 @@ func NewAutomatonV1(numStates, numTransitions int) *Automaton {
 	return &Automaton{
 		curState:      -1,
 		deterministic: true,
-		states:        make([]int, 0, numStates*2),
+		states:        make([]int32, 0, numStates*2),
 		isAccept:      bitset.New(uint(numStates)),
-		transitions:   make([]int, 0, numTransitions*3),
+		transitions:   make([]packedTransition, 0, numTransitions),
 	}
 }
 
 // CreateState Create a new state.
 func (a *Automaton) CreateState() int {
+	if a.frozen {
+		panic(ErrFrozen)
+	}
 	state := len(a.states) / 2
-	a.states = append(a.states, -1, 0)
+	a.states = append(a.states, unsetOffset, 0)
+	a.noDeadStates = false
 	return state
 	//state := a.nextState / 2
 	//a.states[a.nextState] = -1
@@ -67,7 +133,48 @@ func (a *Automaton) CreateState() int {
 
 // SetAccept Set or clear this state as an accept state.
 func (a *Automaton) SetAccept(state int, accept bool) {
+	if a.frozen {
+		panic(ErrFrozen)
+	}
 	a.isAccept.SetTo(uint(state), accept)
+	a.noDeadStates = false
+}
+
+// SetStateLabel Attaches a label to the state, e.g. the name of the sub-automaton it came from, for debugging;
+// an empty label removes it. Labels are stored only for the states that have one, survive Copy, Clone,
+// Builder.Finish and the operations that keep the states of their operands, such as union, concatenation and
+// RemoveDeadStates, and are shown by ToDot, but are dropped by operations that build new states, such as
+// determinize or minimize.
+func (a *Automaton) SetStateLabel(state int, label string) {
+	if a.frozen {
+		panic(ErrFrozen)
+	}
+	a.stateLabels = setStateLabel(a.stateLabels, state, label)
+}
+
+// GetStateLabel Returns the label attached to the state with SetStateLabel, or "" if it has none.
+func (a *Automaton) GetStateLabel(state int) string {
+	return a.stateLabels[state]
+}
+
+func setStateLabel(labels map[int]string, state int, label string) map[int]string {
+	if label == "" {
+		delete(labels, state)
+		return labels
+	}
+	if labels == nil {
+		labels = make(map[int]string)
+	}
+	labels[state] = label
+	return labels
+}
+
+// copyStateLabels Adds the labels of other to labels, with the state numbers shifted by offset.
+func copyStateLabels(labels, other map[int]string, offset int) map[int]string {
+	for state, label := range other {
+		labels = setStateLabel(labels, offset+state, label)
+	}
+	return labels
 }
 
 // Sugar to get all transitions for all states. This is object-heavy; it's better to iterate state by state instead.
@@ -76,14 +183,7 @@ func (a *Automaton) getSortedTransitions() [][]Transition {
 	transitions := make([][]Transition, numStates)
 
 	for s := 0; s < numStates; s++ {
-		numTransitions := a.GetNumTransitionsWithState(s)
-		transitions[s] = make([]Transition, numTransitions)
-
-		for t := 0; t < numTransitions; t++ {
-			transition := Transition{}
-			a.getTransition(s, t, &transition)
-			transitions[s][t] = transition
-		}
+		transitions[s] = a.TransitionsSlice(s)
 	}
 
 	return transitions
@@ -106,6 +206,9 @@ func (a *Automaton) AddTransitionLabel(source, dest, label int) error {
 
 // AddTransition Add a new transition with the specified source, dest, min, max.
 func (a *Automaton) AddTransition(source, dest, min, max int) error {
+	if a.frozen {
+		return ErrFrozen
+	}
 	if a.curState != source {
 		if a.curState != -1 {
 			a.finishCurrentState()
@@ -113,13 +216,14 @@ func (a *Automaton) AddTransition(source, dest, min, max int) error {
 
 		// Move to next source:
 		a.curState = source
-		if a.states[2*a.curState] != -1 {
+		if a.states[2*a.curState] != unsetOffset {
 			return fmt.Errorf("from state (%d) already had transitions added", source)
 		}
-		a.states[2*a.curState] = len(a.transitions)
+		a.states[2*a.curState] = int32(len(a.transitions))
 	}
 
-	a.transitions = append(a.transitions, dest, min, max)
+	a.transitions = append(a.transitions, packedTransition{int32(dest), int32(min), int32(max)})
+	a.noDeadStates = false
 
 	//a.transitions[a.nextTransition] = dest
 	//a.nextTransition++
@@ -133,9 +237,118 @@ func (a *Automaton) AddTransition(source, dest, min, max int) error {
 	return nil
 }
 
+// AddTransitionRune Add a new transition over the code points [lo, hi]. Unlike AddTransition, the transition
+// is validated first: both states must exist, lo must not exceed hi, and the labels must be within the
+// alphabet of the automaton (at most 0xFF for a binary automaton, see SetAlphabet).
+func (a *Automaton) AddTransitionRune(source, dest int, lo, hi rune) error {
+	if err := checkTransition(source, dest, int(lo), int(hi), a.GetNumStates()); err != nil {
+		return err
+	}
+	if int(hi) > a.alphabet.maxLabel() {
+		return fmt.Errorf("label range [%d, %d] is outside the %s alphabet", lo, hi, a.alphabet)
+	}
+	return a.AddTransition(source, dest, int(lo), int(hi))
+}
+
+// AddTransitionByte Add a new transition over the bytes [lo, hi], validated like AddTransitionRune. Bytes are
+// within both alphabets, but are usually added to a binary automaton (see SetAlphabet).
+func (a *Automaton) AddTransitionByte(source, dest int, lo, hi byte) error {
+	if err := checkTransition(source, dest, int(lo), int(hi), a.GetNumStates()); err != nil {
+		return err
+	}
+	return a.AddTransition(source, dest, int(lo), int(hi))
+}
+
+// AddTransitions Add a batch of transitions, given as consecutive (source, dest, min, max) quadruples. As with
+// AddTransition, all transitions leaving a state must be added at once: the quadruples must be grouped by
+// source, and a source (other than the state currently being added to) must not already have transitions.
+// The whole batch is validated before anything is added, so on error the automaton is unchanged.
+func (a *Automaton) AddTransitions(transitions []int) error {
+	if a.frozen {
+		return ErrFrozen
+	}
+	if len(transitions)%4 != 0 {
+		return fmt.Errorf("transitions length (%d) must be a multiple of 4", len(transitions))
+	}
+	numStates := a.GetNumStates()
+	curState := a.curState
+	started := bitset.New(uint(numStates))
+	for i := 0; i < len(transitions); i += 4 {
+		source := transitions[i]
+		if err := checkTransition(source, transitions[i+1], transitions[i+2], transitions[i+3], numStates); err != nil {
+			return err
+		}
+		if source == curState {
+			continue
+		}
+		if a.states[2*source] != unsetOffset || started.Test(uint(source)) {
+			return fmt.Errorf("from state (%d) already had transitions added", source)
+		}
+		started.Set(uint(source))
+		curState = source
+	}
+
+	a.transitions = slices.Grow(a.transitions, len(transitions)/4)
+	a.noDeadStates = false
+	for i := 0; i < len(transitions); {
+		source := transitions[i]
+		if a.curState != source {
+			if a.curState != -1 {
+				a.finishCurrentState()
+			}
+			a.curState = source
+			a.states[2*source] = int32(len(a.transitions))
+		}
+
+		// Append the whole group of transitions leaving source:
+		j := i
+		trans := a.transitions
+		for ; j < len(transitions) && transitions[j] == source; j += 4 {
+			trans = append(trans, packedTransition{int32(transitions[j+1]), int32(transitions[j+2]),
+				int32(transitions[j+3])})
+		}
+		a.transitions = trans
+		a.states[2*source+1] += int32((j - i) / 4)
+		i = j
+	}
+	return nil
+}
+
+// Validates a batch of (source, dest, min, max) quadruples against the given number of states.
+func checkTransitions(transitions []int, numStates int) error {
+	if len(transitions)%4 != 0 {
+		return fmt.Errorf("transitions length (%d) must be a multiple of 4", len(transitions))
+	}
+	for i := 0; i < len(transitions); i += 4 {
+		err := checkTransition(transitions[i], transitions[i+1], transitions[i+2], transitions[i+3], numStates)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkTransition(source, dest, min, max, numStates int) error {
+	if source < 0 || source >= numStates {
+		return fmt.Errorf("source state (%d) does not exist", source)
+	}
+	if dest < 0 || dest >= numStates {
+		return fmt.Errorf("dest state (%d) does not exist", dest)
+	}
+	if min < 0 || min > max {
+		return fmt.Errorf("invalid label range [%d, %d]", min, max)
+	}
+	return nil
+}
+
 // AddEpsilon Add a [virtual] epsilon transition between source and dest. Dest state must already have all
-// transitions added because this method simply copies those same transitions over to source.
+// transitions added because this method simply copies those same transitions over to source. Copies that
+// duplicate (or overlap) transitions already leaving source to the same dest are merged when source is
+// finished, so they never survive into the finished automaton.
 func (a *Automaton) AddEpsilon(source, dest int) {
+	if a.frozen {
+		panic(ErrFrozen)
+	}
 	t := Transition{}
 	count := a.InitTransition(dest, &t)
 
@@ -151,6 +364,12 @@ func (a *Automaton) AddEpsilon(source, dest int) {
 
 // Copy Copies over all states/transitions from other. The states numbers are sequentially assigned (appended).
 func (a *Automaton) Copy(other *Automaton) {
+	if a.frozen {
+		panic(ErrFrozen)
+	}
+
+	a.noDeadStates = false
+	a.normalized = a.normalized || other.normalized
 
 	// Bulk copy and then fixup the state pointers:
 	stateOffset := a.GetNumStates()
@@ -160,7 +379,9 @@ func (a *Automaton) Copy(other *Automaton) {
 
 	a.states = append(a.states, other.states...)
 	for i := nextState; i < len(a.states); i += 2 {
-		a.states[i] += nextTransition
+		if a.states[i] != unsetOffset {
+			a.states[i] += int32(nextTransition)
+		}
 	}
 
 	//a.nextState += other.nextState
@@ -186,14 +407,15 @@ func (a *Automaton) Copy(other *Automaton) {
 	//nextTransition := len(a.transitions)
 	a.transitions = append(a.transitions, other.transitions...)
 	//copy(a.transitions[a.nextTransition:a.nextTransition+other.nextTransition], other.transitions)
-	for i := 0; i < len(other.transitions); i += 3 {
-		a.transitions[nextTransition+i] += stateOffset
+	for i := range other.transitions {
+		a.transitions[nextTransition+i].dest += int32(stateOffset)
 	}
 	//a.nextTransition += other.nextTransition
 
 	if other.deterministic == false {
 		a.deterministic = false
 	}
+	a.stateLabels = copyStateLabels(a.stateLabels, other.stateLabels, stateOffset)
 }
 
 // Freezes the last state, sorting and reducing the transitions.
@@ -204,93 +426,114 @@ func (a *Automaton) Copy(other *Automaton) {
 // 4. 再次排序：按字符范围排序以提高匹配效率；
 // 5. 检查确定性：若多个转移存在重叠输入范围，则标记为非确定性（deterministic = false）。
 func (a *Automaton) finishCurrentState() {
-	numTransitions := a.states[2*a.curState+1]
-	offset := a.states[2*a.curState]
-
-	start := offset / 3
+	numTransitions := int(a.states[2*a.curState+1])
+	offset := int(a.states[2*a.curState])
 
 	//根据目标状态和字符范围对转移进行排序
-	sort.Sort(&destMinMaxSorter{
-		Automaton: a,
-		from:      start,
-		to:        start + numTransitions,
-	})
+	slices.SortFunc(a.transitions[offset:offset+numTransitions], compareDestMinMax)
 
 	// Reduce any "adjacent" transitions:
 	upto := 0
-	minValue := -1
-	maxValue := -1
-	dest := -1
+	minValue := int32(-1)
+	maxValue := int32(-1)
+	dest := int32(-1)
 
 	for i := 0; i < numTransitions; i++ {
-		idx := offset + 3*i
-		tDest := a.transitions[idx]
-		tMin := a.transitions[idx+1]
-		tMax := a.transitions[idx+2]
-
-		if dest == tDest {
-			if tMin <= maxValue+1 {
-				if tMax > maxValue {
-					maxValue = tMax
+		t := a.transitions[offset+i]
+
+		if dest == t.dest {
+			if t.min <= maxValue+1 {
+				if t.max > maxValue {
+					maxValue = t.max
 				}
 			} else {
 				if dest != -1 {
-					uptoIdx := offset + 3*upto
-					a.transitions[uptoIdx] = dest
-					a.transitions[uptoIdx+1] = minValue
-					a.transitions[uptoIdx+2] = maxValue
+					a.transitions[offset+upto] = packedTransition{dest, minValue, maxValue}
 					upto++
 				}
-				minValue = tMin
-				maxValue = tMax
+				minValue = t.min
+				maxValue = t.max
 			}
 		} else {
 			if dest != -1 {
-				uptoIdx := offset + 3*upto
-				a.transitions[uptoIdx] = dest
-				a.transitions[uptoIdx+1] = minValue
-				a.transitions[uptoIdx+2] = maxValue
+				a.transitions[offset+upto] = packedTransition{dest, minValue, maxValue}
 				upto++
 			}
-			dest = tDest
-			minValue = tMin
-			maxValue = tMax
+			dest = t.dest
+			minValue = t.min
+			maxValue = t.max
 		}
 	}
 
 	if dest != -1 {
 		// Last transition
-		uptoIndex := offset + 3*upto
-		a.transitions[uptoIndex] = dest
-		a.transitions[uptoIndex+1] = minValue
-		a.transitions[uptoIndex+2] = maxValue
+		a.transitions[offset+upto] = packedTransition{dest, minValue, maxValue}
 		upto++
 	}
 
-	newTransitionsSize := len(a.transitions) - (numTransitions-upto)*3
+	newTransitionsSize := len(a.transitions) - (numTransitions - upto)
 	a.transitions = a.transitions[:newTransitionsSize]
-	//
-	//a.nextTransition -= (numTransitions - upto) * 3
-	//a.states[2*a.curState+1] = upto
+	a.states[2*a.curState+1] = int32(upto)
 
 	// Sort transitions by minValue/maxValue/dest:
-	sort.Sort(&minMaxDestSorter{
-		Automaton: a,
-		from:      start,
-		to:        start + upto,
-	})
-
-	if a.deterministic && upto > 1 {
-		lastMax := a.transitions[offset+2]
-		for i := 1; i < upto; i++ {
-			i3 := 3 * i
-			minValue = a.transitions[offset+i3+1]
-			if minValue <= lastMax {
+	slices.SortFunc(a.transitions[offset:offset+upto], compareMinMaxDest)
+
+	a.checkDeterministic(offset, upto)
+}
+
+// Clears the deterministic flag if any of the count transitions (sorted by min/max/dest) starting at offset in
+// the transitions array overlap.
+func (a *Automaton) checkDeterministic(offset, count int) {
+	if a.deterministic && count > 1 {
+		lastMax := a.transitions[offset].max
+		for _, t := range a.transitions[offset+1 : offset+count] {
+			if t.min <= lastMax {
 				a.deterministic = false
 				break
 			}
-			lastMax = a.transitions[offset+i3+2]
+			lastMax = t.max
+		}
+	}
+}
+
+// Bulk loads transitions sorted by source, dest, min and max, as Builder.Finish produces them. The order is trusted:
+// instead of resorting every state's transitions by dest like finishCurrentState, adjacent ranges to the same dest are
+// merged on the fly, and the transitions of a state are only resorted by min/max/dest when they are not already in that
+// order. The transitions array grows once. The sources must exist and must not have transitions yet.
+func (a *Automaton) addSortedTransitions(transitions []builderTransition) {
+	a.transitions = slices.Grow(a.transitions, len(transitions))
+	a.noDeadStates = false
+	for i := 0; i < len(transitions); {
+		source := transitions[i].source
+		offset := len(a.transitions)
+
+		// Merge adjacent or overlapping ranges going to the same dest:
+		trans := a.transitions
+		dest, minValue, maxValue := -1, -1, -1
+		j := i
+		for ; j < len(transitions) && transitions[j].source == source; j++ {
+			tDest, tMin, tMax := transitions[j].dest, transitions[j].min, transitions[j].max
+
+			if tDest == dest && tMin <= maxValue+1 {
+				maxValue = max(maxValue, tMax)
+				continue
+			}
+			if dest != -1 {
+				trans = append(trans, packedTransition{int32(dest), int32(minValue), int32(maxValue)})
+			}
+			dest, minValue, maxValue = tDest, tMin, tMax
+		}
+		trans = append(trans, packedTransition{int32(dest), int32(minValue), int32(maxValue)})
+		a.transitions = trans
+
+		count := len(a.transitions) - offset
+		a.states[2*source] = int32(offset)
+		a.states[2*source+1] = int32(count)
+		if !slices.IsSortedFunc(a.transitions[offset:], compareMinMaxDest) {
+			slices.SortFunc(a.transitions[offset:], compareMinMaxDest)
 		}
+		a.checkDeterministic(offset, count)
+		i = j
 	}
 }
 
@@ -311,247 +554,399 @@ func (a *Automaton) FinishState() {
 	}
 }
 
-// GetNumStates How many states this automaton has.
-func (a *Automaton) GetNumStates() int {
-	return len(a.states) / 2
+// Freeze Finishes the current state and marks this automaton as immutable: afterwards AddTransition and
+// AddTransitions return ErrFrozen, and CreateState, SetAccept, AddEpsilon and Copy panic with ErrFrozen.
+// Operations never modify their inputs, so a frozen automaton can be shared freely, e.g. in a cache, and read
+// by any number of goroutines at once (each with its own Transition for iterating). Use Clone to get a
+// modifiable copy. Returns the automaton itself.
+func (a *Automaton) Freeze() *Automaton {
+	a.FinishState()
+	a.frozen = true
+	return a
 }
 
-// GetNumTransitions How many transitions this automaton has.
-func (a *Automaton) GetNumTransitions() int {
-	return len(a.transitions) / 3
+// Alphabet Returns whether the labels of this automaton are code points or bytes. Binary factories return
+// ALPHABET_BINARY automata, and operations on binary automata return binary automata (complement included, which
+// complements over the bytes); everything else is ALPHABET_UNICODE.
+func (a *Automaton) Alphabet() Alphabet {
+	return a.alphabet
 }
 
-// GetNumTransitionsWithState How many transitions this state has.
-func (a *Automaton) GetNumTransitionsWithState(state int) int {
-	idx := 2*state + 1
-	if len(a.states) <= idx {
-		return 0
-	}
-	count := a.states[idx]
-	if count == -1 {
-		return 0
+// SetAlphabet Sets how the labels of this automaton are to be interpreted, for automata built by hand.
+func (a *Automaton) SetAlphabet(alphabet Alphabet) {
+	if a.frozen {
+		panic(ErrFrozen)
 	}
-	return count
+	a.alphabet = alphabet
 }
 
-//func (a *Automaton) growStates() {
-//	if a.nextState+2 > len(a.states) {
-//		a.states = grow(a.states, a.nextState+2)
-//	}
-//}
-
-//func (a *Automaton) growTransitions() {
-//	if a.nextTransition+3 > len(a.transitions) {
-//		a.transitions = grow(a.transitions, a.nextTransition+3)
-//	}
-//}
-
-// Sorts transitions by dest, ascending, then min label ascending, then max label ascending
-type destMinMaxSorter struct {
-	*Automaton
+// Returns ALPHABET_BINARY if all automata are binary, otherwise ALPHABET_UNICODE.
+func commonAlphabet(automata ...*Automaton) Alphabet {
+	if len(automata) == 0 {
+		return ALPHABET_UNICODE
+	}
+	for _, a := range automata {
+		if a.alphabet != ALPHABET_BINARY {
+			return ALPHABET_UNICODE
+		}
+	}
+	return ALPHABET_BINARY
+}
 
-	from, to int
+// IsBinaryAutomaton Returns true if all labels of the given automaton are bytes (at most 255), so it can match
+// byte strings, e.g. in a ByteRunAutomaton. Unlike Alphabet, which tells how the labels are meant, this checks
+// the transitions themselves.
+func IsBinaryAutomaton(a *Automaton) bool {
+	return checkBinary(a) == nil
 }
 
-func (r *destMinMaxSorter) Len() int {
-	return r.to - r.from
+// checkBinary Returns an error naming the first transition of the given automaton with a label above 255.
+func checkBinary(a *Automaton) error {
+	numStates := a.GetNumStates()
+	for s := 0; s < numStates; s++ {
+		offset := int(a.states[2*s])
+		count := int(a.states[2*s+1])
+		for i := 0; i < count; i++ {
+			t := a.transitions[offset+i]
+			if t.max > 0xFF {
+				return fmt.Errorf("automaton is not binary: state %d has a transition [%d, %d] above byte 255", s,
+					t.min, t.max)
+			}
+		}
+	}
+	return nil
 }
 
-func (r *destMinMaxSorter) Less(i, j int) bool {
-	iStart := 3 * (i + r.from)
-	jStart := 3 * (j + r.from)
+// IsFrozen Returns true if Freeze was called on this automaton.
+func (a *Automaton) IsFrozen() bool {
+	return a.frozen
+}
 
-	iDest := r.transitions[iStart]
-	jDest := r.transitions[jStart]
+// Clone Returns a modifiable deep copy of this automaton.
+func (a *Automaton) Clone() *Automaton {
+	return &Automaton{
+		curState:      a.curState,
+		states:        slices.Clone(a.states),
+		isAccept:      a.isAccept.Clone(),
+		transitions:   slices.Clone(a.transitions),
+		deterministic: a.deterministic,
+		noDeadStates:  a.noDeadStates,
+		normalized:    a.normalized,
+		alphabet:      a.alphabet,
+		stateLabels:   maps.Clone(a.stateLabels),
+	}
+}
 
-	// First dest:
-	if iDest < jDest {
-		return true
-	} else if iDest > jDest {
-		return false
+// Validate Checks the structural invariants of this automaton: every state is finished, transitions point to
+// existing states, have labels within the alphabet and are sorted (by min, then max, then dest) without
+// duplicates or adjacent ranges left unmerged (unless split by Normalize), no accept state lies beyond the last
+// state, if the automaton claims to be deterministic, no state has overlapping transitions, and, if it is known
+// to have no dead states, it has none. Returns an error describing the first violation found.
+func (a *Automaton) Validate() error {
+	if a.curState != -1 {
+		return fmt.Errorf("state %d is not finished", a.curState)
 	}
 
-	// Then min:
-	iMin := r.transitions[iStart+1]
-	jMin := r.transitions[jStart+1]
-	if iMin < jMin {
-		return true
-	} else if iMin > jMin {
-		return false
+	numStates := a.GetNumStates()
+	if extra, ok := a.isAccept.NextSet(uint(numStates)); ok {
+		return fmt.Errorf("accept state %d does not exist (%d states)", extra, numStates)
 	}
 
-	// Then max:
-	iMax := r.transitions[iStart+2]
-	jMax := r.transitions[jStart+2]
-	if iMax < jMax {
-		return true
-	} else if iMax > jMax {
-		return false
+	for s := 0; s < numStates; s++ {
+		offset := int(a.states[2*s])
+		count := int(a.states[2*s+1])
+		if count < 0 || (count > 0 && (offset < 0 || offset+count > len(a.transitions))) {
+			return fmt.Errorf("state %d has invalid transitions offset=%d count=%d", s, offset, count)
+		}
+
+		for i := 0; i < count; i++ {
+			t := a.transitions[offset+i]
+			dest, minLabel, maxLabel := t.dest, t.min, t.max
+			if dest < 0 || int(dest) >= numStates {
+				return fmt.Errorf("state %d has a transition to nonexistent state %d", s, dest)
+			}
+			if minLabel > maxLabel {
+				return fmt.Errorf("state %d has a transition with min %d > max %d", s, minLabel, maxLabel)
+			}
+			if minLabel < 0 || int(maxLabel) > a.alphabet.maxLabel() {
+				return fmt.Errorf("state %d has a transition [%d, %d] outside of the %s alphabet", s, minLabel,
+					maxLabel, a.alphabet)
+			}
+			if i == 0 {
+				continue
+			}
+
+			prev := a.transitions[offset+i-1]
+			prevDest, prevMax := prev.dest, prev.max
+			if !transitionBefore(prev, t) {
+				return fmt.Errorf("transitions of state %d are not sorted", s)
+			}
+			if prevDest == dest && (minLabel <= prevMax || minLabel == prevMax+1 && !a.normalized) {
+				return fmt.Errorf("state %d has unmerged transitions to state %d", s, dest)
+			}
+			if a.deterministic && minLabel <= prevMax {
+				return fmt.Errorf("automaton is marked deterministic but state %d has overlapping transitions", s)
+			}
+		}
+	}
+	if a.noDeadStates && HasDeadStates(a) {
+		return errors.New("automaton is marked as having no dead states but has some")
 	}
+	return nil
+}
 
-	return false
+// VerifyAutomaton Checks the structural invariants of the given automaton, see Validate. Builds with the
+// automaton_debug tag run this check on the result of every operation and panic on a violation.
+func VerifyAutomaton(a *Automaton) error {
+	return a.Validate()
 }
 
-func (r *destMinMaxSorter) Swap(i, j int) {
-	iStart, jStart := 3*(i+r.from), 3*(j+r.from)
-	r.swapOne(iStart, jStart)
-	r.swapOne(iStart+1, jStart+1)
-	r.swapOne(iStart+2, jStart+2)
+// GetNumStates How many states this automaton has.
+func (a *Automaton) GetNumStates() int {
+	return len(a.states) / 2
 }
 
-func (r *destMinMaxSorter) swapOne(i, j int) {
-	r.transitions[i], r.transitions[j] =
-		r.transitions[j], r.transitions[i]
+// GetNumTransitions How many transitions this automaton has.
+func (a *Automaton) GetNumTransitions() int {
+	return len(a.transitions)
 }
 
-// Sorts transitions by min label, ascending, then max label ascending, then dest ascending
-type minMaxDestSorter struct {
-	from, to int
-	*Automaton
+// GetNumTransitionsWithState How many transitions this state has; 0 if the state does not exist.
+func (a *Automaton) GetNumTransitionsWithState(state int) int {
+	if state < 0 || state >= a.GetNumStates() {
+		return 0
+	}
+	return int(a.states[2*state+1])
 }
 
-func (r *minMaxDestSorter) Len() int {
-	return r.to - r.from
+// NumAcceptStates How many accept states this automaton has.
+func (a *Automaton) NumAcceptStates() int {
+	return int(a.isAccept.Count())
 }
 
-func (r *minMaxDestSorter) Less(i, j int) bool {
-	iStart := 3 * (i + r.from)
-	jStart := 3 * (j + r.from)
+// NumReachableStates How many states can be reached from the initial state, including itself; the others can be
+// dropped with RemoveDeadStates. This walks the transitions.
+func (a *Automaton) NumReachableStates() int {
+	return int(getLiveStatesFromInitial(a).Count())
+}
 
-	// First min:
-	iMin := r.transitions[iStart+1]
-	jMin := r.transitions[jStart+1]
-	if iMin < jMin {
-		return true
-	} else if iMin > jMin {
-		return false
-	}
+// AlphabetIntervalCount How many intervals the transitions split the alphabet into, i.e. the number of start
+// points (see GetStartPoints): the labels of an interval lead every state to the same states. This is at least
+// the number of classes of the AlphabetPartition, which merges intervals that behave alike. Only the intervals
+// within the alphabet of the automaton count, e.g. at most 256 for a binary automaton.
+func (a *Automaton) AlphabetIntervalCount() int {
+	count, _ := slices.BinarySearch(a.AlphabetPartition().points, a.alphabet.maxLabel()+1)
+	return count
+}
 
-	// Then max:
-	iMax := r.transitions[iStart+2]
-	jMax := r.transitions[jStart+2]
-	if iMax < jMax {
-		return true
-	} else if iMax > jMax {
-		return false
+// MaxLabel The largest label of any transition of this automaton, or -1 if it has none. This walks the
+// transitions.
+func (a *Automaton) MaxLabel() int {
+	maxLabel := int32(-1)
+	for _, t := range a.transitions {
+		maxLabel = max(maxLabel, t.max)
 	}
+	return int(maxLabel)
+}
 
-	// Then dest:
-	iDest := r.transitions[iStart]
-	jDest := r.transitions[jStart]
-	if iDest < jDest {
-		return true
-	} else if iDest > jDest {
-		return false
+// String Returns a one line summary of this automaton, for logs, e.g. "5 states (1 accept, 5 reachable), 7
+// transitions, deterministic, unicode".
+func (a *Automaton) String() string {
+	kind := "non-deterministic"
+	if a.deterministic {
+		kind = "deterministic"
 	}
+	return fmt.Sprintf("%d states (%d accept, %d reachable), %d transitions, %s, %s", a.GetNumStates(),
+		a.NumAcceptStates(), a.NumReachableStates(), a.GetNumTransitions(), kind, a.alphabet)
+}
+
+//func (a *Automaton) growStates() {
+//	if a.nextState+2 > len(a.states) {
+//		a.states = grow(a.states, a.nextState+2)
+//	}
+//}
 
-	return false
+//func (a *Automaton) growTransitions() {
+//	if a.nextTransition+3 > len(a.transitions) {
+//		a.transitions = grow(a.transitions, a.nextTransition+3)
+//	}
+//}
+
+// packedTransition A transition in the transitions array of an Automaton; its source is the state whose
+// transitions it belongs to.
+type packedTransition struct {
+	dest, min, max int32
 }
 
-func (r *minMaxDestSorter) Swap(i, j int) {
-	iStart, jStart := 3*(i+r.from), 3*(j+r.from)
-	r.swapOne(iStart, jStart)
-	r.swapOne(iStart+1, jStart+1)
-	r.swapOne(iStart+2, jStart+2)
+// Orders transitions by dest, ascending, then min label ascending, then max label ascending
+func compareDestMinMax(t1, t2 packedTransition) int {
+	if t1.dest != t2.dest {
+		return cmp.Compare(t1.dest, t2.dest)
+	}
+	if t1.min != t2.min {
+		return cmp.Compare(t1.min, t2.min)
+	}
+	return cmp.Compare(t1.max, t2.max)
 }
 
-func (r *minMaxDestSorter) swapOne(i, j int) {
-	r.transitions[i], r.transitions[j] =
-		r.transitions[j], r.transitions[i]
+// Orders transitions by min label, ascending, then max label ascending, then dest ascending
+func compareMinMaxDest(t1, t2 packedTransition) int {
+	if t1.min != t2.min {
+		return cmp.Compare(t1.min, t2.min)
+	}
+	if t1.max != t2.max {
+		return cmp.Compare(t1.max, t2.max)
+	}
+	return cmp.Compare(t1.dest, t2.dest)
 }
 
 // InitTransition Initialize the provided Transition to iterate through all transitions leaving the specified
 // state. You must call GetNextTransition to get each transition. Returns the number of transitions leaving
-// this state.
+// this state, which is 0 for states without transitions and states that do not exist.
 func (a *Automaton) InitTransition(state int, t *Transition) int {
 	t.Source = state
-	t.TransitionUpto = a.states[2*state]
-	return a.GetNumTransitionsWithState(state)
+	count := a.GetNumTransitionsWithState(state)
+	if count == 0 {
+		t.TransitionUpto = -1
+		return 0
+	}
+	t.TransitionUpto = int(a.states[2*state])
+	return count
 }
 
 // GetNextTransition Iterate to the next transition after the provided one
 func (a *Automaton) GetNextTransition(t *Transition) {
-	t.Dest = a.transitions[t.TransitionUpto]
-	t.TransitionUpto++
-	t.Min = a.transitions[t.TransitionUpto]
-	t.TransitionUpto++
-	t.Max = a.transitions[t.TransitionUpto]
+	if debugAssertions && t.TransitionUpto != int(a.states[2*t.Source]) {
+		prev := packedTransition{int32(t.Dest), int32(t.Min), int32(t.Max)}
+		if !transitionBefore(prev, a.transitions[t.TransitionUpto]) {
+			panic(fmt.Sprintf("automaton: transitions of state %d are not sorted", t.Source))
+		}
+	}
+	next := a.transitions[t.TransitionUpto]
+	t.Dest, t.Min, t.Max = int(next.dest), int(next.min), int(next.max)
 	t.TransitionUpto++
 }
 
-func (a *Automaton) transitionSorted(t *Transition) bool {
-	upto := t.TransitionUpto
-	if upto == a.states[2*t.Source] {
-		// Transition isn't initialized yet (this is the first transition); don't check:
-		return true
+// AssertTransitionsSorted Returns an error if the transitions leaving the given state are not sorted by min,
+// then max, then dest, without duplicates, which Step, Next and most operations rely on. Returns an error if the
+// state does not exist.
+func (a *Automaton) AssertTransitionsSorted(state int) error {
+	if state < 0 || state >= a.GetNumStates() {
+		return fmt.Errorf("state (%d) does not exist", state)
 	}
-
-	nextDest := a.transitions[upto]
-	nextMin := a.transitions[upto+1]
-	nextMax := a.transitions[upto+2]
-	if nextMin > t.Min {
-		return true
-	} else if nextMin < t.Min {
-		return false
+	offset := int(a.states[2*state])
+	count := a.GetNumTransitionsWithState(state)
+	for i := 1; i < count; i++ {
+		if !transitionBefore(a.transitions[offset+i-1], a.transitions[offset+i]) {
+			return fmt.Errorf("transitions of state %d are not sorted", state)
+		}
 	}
+	return nil
+}
 
-	// Min is equal, now test max:
-	if nextMax > t.Max {
-		return true
-	} else if nextMax < t.Max {
-		return false
-	}
+// transitionBefore Returns true if the packed transition t1 sorts strictly before t2.
+func transitionBefore(t1, t2 packedTransition) bool {
+	return compareMinMaxDest(t1, t2) < 0
+}
 
-	// Max is also equal, now test dest:
-	if nextDest > t.Dest {
-		return true
-	} else if nextDest < t.Dest {
-		return false
+// GetTransition Fill the provided Transition with the index'th transition leaving the specified state, in
+// min/max/dest order. Returns an error if the state or index is out of bounds.
+func (a *Automaton) GetTransition(state, index int, t *Transition) error {
+	if state < 0 || state >= a.GetNumStates() {
+		return fmt.Errorf("state (%d) does not exist", state)
+	}
+	if count := a.GetNumTransitionsWithState(state); index < 0 || index >= count {
+		return fmt.Errorf("state %d has no transition %d (%d transitions)", state, index, count)
 	}
+	a.getTransition(state, index, t)
+	return nil
+}
 
-	// We should never see fully equal transitions here:
-	return false
+// TransitionsSlice Returns the transitions leaving the specified state, in min/max/dest order. This allocates
+// a new slice on every call; prefer GetTransition or InitTransition/GetNextTransition in hot loops. Returns nil
+// if the state does not exist or has no transitions.
+func (a *Automaton) TransitionsSlice(state int) []Transition {
+	if state < 0 || state >= a.GetNumStates() {
+		return nil
+	}
+	count := a.GetNumTransitionsWithState(state)
+	if count == 0 {
+		return nil
+	}
+	transitions := make([]Transition, count)
+	for i := range transitions {
+		a.getTransition(state, i, &transitions[i])
+	}
+	return transitions
 }
 
-// Fill the provided Transition with the index'th transition leaving the specified state.
+// Fill the provided Transition with the index'th transition leaving the specified state, which must exist:
+// states without transitions have no offset into transitions (see unsetOffset).
 func (a *Automaton) getTransition(state, index int, t *Transition) {
-	i := a.states[2*state] + 3*index
+	tr := a.transitions[int(a.states[2*state])+index]
 	t.Source = state
-	t.Dest = a.transitions[i]
-	i++
-	t.Min = a.transitions[i]
-	i++
-	t.Max = a.transitions[i]
-	i++
+	t.Dest, t.Min, t.Max = int(tr.dest), int(tr.min), int(tr.max)
 }
 
-// GetStartPoints Returns sorted array of all interval start points.
+// GetStartPoints Returns sorted array of all interval start points. See also AlphabetPartition and
+// CollectAlphabetPoints.
 func (a *Automaton) GetStartPoints() []int {
-	pointset := make(map[int]struct{})
-	pointset[0] = struct{}{}
+	return slices.Clone(a.AlphabetPartition().points)
+}
 
+// CollectAlphabetPoints Returns the sorted, distinct labels at which the transitions of this automaton start or
+// end (the label after their max), with 0; each label between two consecutive points leads to the same states.
+// Unlike GetStartPoints it computes a new slice on every call.
+func (a *Automaton) CollectAlphabetPoints() []int {
+	points := make([]int, 1, 1+2*len(a.transitions))
 	for s := 0; s < len(a.states); s += 2 {
-		trans := a.states[s]
-		limit := trans + 3*a.states[s+1]
-		//System.out.println("  state=" + (s/2) + " trans=" + trans + " limit=" + limit);
-		for trans < limit {
-			minTrans := a.transitions[trans+1]
-			maxTrans := a.transitions[trans+2]
-			//System.out.println("    min=" + min);
-			pointset[minTrans] = struct{}{}
-			if maxTrans < 0x10FFFF {
-				pointset[maxTrans+1] = struct{}{}
+		trans := int(a.states[s])
+		limit := trans + int(a.states[s+1])
+		for ; trans < limit; trans++ {
+			points = append(points, int(a.transitions[trans].min))
+			if maxTrans := int(a.transitions[trans].max); maxTrans < unicode.MaxRune {
+				points = append(points, maxTrans+1)
 			}
-			trans += 3
 		}
 	}
+	slices.Sort(points)
+	return slices.Compact(points)
+}
 
-	points := make([]int, 0, len(pointset))
-	for k, _ := range pointset {
-		points = append(points, k)
+// StepString Steps through the code points of s starting at the given state, assuming determinism. Returns
+// the state reached, or -1 if there is no matching transition. Returns an error if this is a binary automaton;
+// use StepBytes instead.
+func (a *Automaton) StepString(state int, s string) (int, error) {
+	if a.alphabet != ALPHABET_UNICODE {
+		return -1, fmt.Errorf("cannot step a %s automaton by code point", a.alphabet)
 	}
-	sort.Ints(points)
-	return points
+	if a.GetNumStates() == 0 {
+		return -1, nil
+	}
+	for _, c := range s {
+		if state = a.Step(state, int(c)); state == -1 {
+			break
+		}
+	}
+	return state, nil
+}
+
+// StepBytes Steps through the bytes of b starting at the given state, assuming determinism. Returns the state
+// reached, or -1 if there is no matching transition. Returns an error unless this is a binary automaton; use
+// StepString instead.
+func (a *Automaton) StepBytes(state int, b []byte) (int, error) {
+	if a.alphabet != ALPHABET_BINARY {
+		return -1, fmt.Errorf("cannot step a %s automaton by byte", a.alphabet)
+	}
+	if a.GetNumStates() == 0 {
+		return -1, nil
+	}
+	for _, c := range b {
+		if state = a.Step(state, int(c)); state == -1 {
+			break
+		}
+	}
+	return state, nil
 }
 
 // Step Performs lookup in transitions, assuming determinism.
@@ -569,7 +964,9 @@ func (a *Automaton) Step(state, label int) int {
 // This method is similar to step(int, int) but is used more efficiently when iterating over multiple
 // transitions from the same source state. It keeps the latest reached transition index in
 // transition.transitionUpto so the next call to this method can continue from there instead of restarting
-// from the first transition.
+// from the first transition. As with GetNextTransition, transitionUpto points right after the transition held
+// in transition, so GetNextTransition continues with the transitions following the match; a transition of
+// another state (or a new Transition) starts from the first transition.
 //
 // transition: The transition to start the lookup from (inclusive, using its Transition.source
 // and Transition.transitionUpto). It is updated with the matched transition; or with
@@ -577,9 +974,22 @@ func (a *Automaton) Step(state, label int) int {
 //
 // label: The codepoint to look up.
 //
-// Returns: The destination state; or -1 if no matching outgoing transition.
-func (a *Automaton) Next(transition *Transition, label int) int {
-	return a.next(transition.Source, 0, label, transition)
+// Returns: The destination state; or -1 if no matching outgoing transition. Returns an error if the
+// automaton is not deterministic, since the lookup could miss matching transitions.
+func (a *Automaton) Next(transition *Transition, label int) (int, error) {
+	if !a.deterministic {
+		return -1, errors.New("next requires a deterministic automaton")
+	}
+	count := a.GetNumTransitionsWithState(transition.Source)
+	if count == 0 {
+		transition.Dest = -1
+		return -1, nil
+	}
+	from := 0
+	if upto := transition.TransitionUpto - int(a.states[2*transition.Source]); upto > 0 && upto <= count {
+		from = upto - 1
+	}
+	return a.next(transition.Source, from, label, transition), nil
 }
 
 // Looks for the next transition that matches the provided label, assuming determinism.
@@ -591,8 +1001,8 @@ func (a *Automaton) Next(transition *Transition, label int) int {
 // Returns: The destination state; or -1 if no matching outgoing transition.
 func (a *Automaton) next(state, fromTransitionIndex, label int, transition *Transition) int {
 	stateIndex := 2 * state
-	firstTransitionIndex := a.states[stateIndex]
-	numTransitions := a.states[stateIndex+1]
+	firstTransitionIndex := int(a.states[stateIndex])
+	numTransitions := int(a.states[stateIndex+1])
 
 	// Since transitions are sorted,
 	// binary search the transition for which label is within [minLabel, maxLabel].
@@ -601,21 +1011,21 @@ func (a *Automaton) next(state, fromTransitionIndex, label int, transition *Tran
 
 	for low <= high {
 		mid := (low + high) >> 1
-		transitionIndex := firstTransitionIndex + 3*mid
-		minLabel := a.transitions[transitionIndex+1]
+		transitionIndex := firstTransitionIndex + mid
+		minLabel := int(a.transitions[transitionIndex].min)
 		if minLabel > label {
 			high = mid - 1
 		} else {
-			maxLabel := a.transitions[transitionIndex+2]
+			maxLabel := int(a.transitions[transitionIndex].max)
 			if maxLabel < label {
 				low = mid + 1
 			} else {
-				destState := a.transitions[transitionIndex]
+				destState := int(a.transitions[transitionIndex].dest)
 				if transition != nil {
 					transition.Dest = destState
 					transition.Min = minLabel
 					transition.Max = maxLabel
-					transition.TransitionUpto = mid
+					transition.TransitionUpto = transitionIndex + 1
 				}
 				return destState
 			}
@@ -625,68 +1035,55 @@ func (a *Automaton) next(state, fromTransitionIndex, label int, transition *Tran
 	destState := -1
 	if transition != nil {
 		transition.Dest = destState
-		transition.TransitionUpto = low
+		transition.TransitionUpto = firstTransitionIndex + low
+
 	}
 	return destState
 }
 
-var _ sort.Interface = &builderSorter{}
-
-type builderSorter struct {
-	values []int
-	size   int
+// builderTransition A transition recorded by a Builder.
+type builderTransition struct {
+	source, dest, min, max int
 }
 
-func (b *builderSorter) Len() int {
-	return b.size
+// Orders builder transitions by source, dest, min and max, ascending.
+func compareBuilderTransitions(t1, t2 builderTransition) int {
+	if t1.source != t2.source {
+		return cmp.Compare(t1.source, t2.source)
+	}
+	if t1.dest != t2.dest {
+		return cmp.Compare(t1.dest, t2.dest)
+	}
+	if t1.min != t2.min {
+		return cmp.Compare(t1.min, t2.min)
+	}
+	return cmp.Compare(t1.max, t2.max)
 }
 
-func (b *builderSorter) Less(i, j int) bool {
-	i *= 4
-	j *= 4
-
-	if b.values[i] < b.values[j] {
-		return true
-	} else if b.values[i] > b.values[j] {
-		return false
+// Sorts the transitions by source, dest, min and max: a counting sort groups them by source in linear time,
+// then each (usually small) group is sorted on its own.
+func (r *Builder) sort() {
+	starts := make([]int, r.nextState+1)
+	for _, t := range r.transitions {
+		starts[t.source+1]++
 	}
-
-	if b.values[i+1] < b.values[j+1] {
-		return true
-	} else if b.values[i+1] > b.values[j+1] {
-		return false
+	for s := 1; s < len(starts); s++ {
+		starts[s] += starts[s-1]
 	}
 
-	if b.values[i+2] < b.values[j+2] {
-		return true
-	} else if b.values[i+2] > b.values[j+2] {
-		return false
+	sorted := make([]builderTransition, len(r.transitions))
+	next := slices.Clone(starts[:r.nextState])
+	for _, t := range r.transitions {
+		sorted[next[t.source]] = t
+		next[t.source]++
 	}
 
-	if b.values[i+3] < b.values[j+3] {
-		return true
-	} else if b.values[i+3] > b.values[j+3] {
-		return false
+	for s := 0; s < r.nextState; s++ {
+		if starts[s+1]-starts[s] > 1 {
+			slices.SortFunc(sorted[starts[s]:starts[s+1]], compareBuilderTransitions)
+		}
 	}
-
-	return false
-}
-
-func (b *builderSorter) Swap(i, j int) {
-	i *= 4
-	j *= 4
-
-	b.values[i], b.values[j] = b.values[j], b.values[i]
-	b.values[i+1], b.values[j+1] = b.values[j+1], b.values[i+1]
-	b.values[i+2], b.values[j+2] = b.values[j+2], b.values[i+2]
-	b.values[i+3], b.values[j+3] = b.values[j+3], b.values[i+3]
-}
-
-func (r *Builder) sort(from, to int) {
-	sort.Sort(&builderSorter{
-		values: r.transitions,
-		size:   to - from,
-	})
+	r.transitions = sorted
 }
 
 func (r *Builder) IsAccept(state int) bool {
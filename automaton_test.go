@@ -1,7 +1,9 @@
 package automaton
 
 import (
+	"math/rand"
 	"testing"
+	"unicode"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -71,3 +73,633 @@ func Test_getCommonPrefix(t *testing.T) {
 	})
 
 }
+
+func Test_getCommonSuffixBytesRef(t *testing.T) {
+	suffix := func(t *testing.T, pattern string, workLimit int) string {
+		re, err := NewRegExp(pattern)
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		a, err := re.ToAutomaton()
+		if !assert.Nil(t, err) {
+			return ""
+		}
+		ref, err := getCommonSuffixBytesRef(a, workLimit)
+		assert.Nil(t, err)
+		return string(ref)
+	}
+
+	t.Run("testCommonSuffixCycle", func(t *testing.T) {
+		assert.Equal(t, "c", suffix(t, "(ab)*c", DEFAULT_DETERMINIZE_WORK_LIMIT))
+		assert.Equal(t, "", suffix(t, "(ab)*", DEFAULT_DETERMINIZE_WORK_LIMIT))
+		assert.Equal(t, "bar", suffix(t, "(foo|x*)*bar", DEFAULT_DETERMINIZE_WORK_LIMIT))
+		assert.Equal(t, "ab", suffix(t, "(ab)+", DEFAULT_DETERMINIZE_WORK_LIMIT))
+	})
+
+	t.Run("testCommonSuffixWorkLimit", func(t *testing.T) {
+		assert.Equal(t, "", suffix(t, "x*foobar", 3))
+		assert.Equal(t, "foobar", suffix(t, "x*foobar", DEFAULT_DETERMINIZE_WORK_LIMIT))
+	})
+
+	t.Run("testCommonSuffixBinary", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinary([]byte{0xC3, 0xA9})
+		assert.Nil(t, err)
+		ref, err := getCommonSuffixBytesRef(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{0xC3, 0xA9}, ref)
+	})
+}
+
+// Returns the number of transitions that exactly duplicate an earlier transition of the same state.
+func countDuplicateTransitions(a *Automaton) int {
+	duplicates := 0
+	for _, transitions := range a.getSortedTransitions() {
+		seen := make(map[[3]int]struct{})
+		for _, t := range transitions {
+			key := [3]int{t.Dest, t.Min, t.Max}
+			if _, ok := seen[key]; ok {
+				duplicates++
+			}
+			seen[key] = struct{}{}
+		}
+	}
+	return duplicates
+}
+
+func TestAutomaton_AddEpsilonDuplicates(t *testing.T) {
+	t.Run("testSelfLoops", func(t *testing.T) {
+		a := NewAutomaton()
+		s0 := a.CreateState()
+		s1 := a.CreateState()
+		a.SetAccept(s1, true)
+		assert.Nil(t, a.AddTransition(s1, s1, 'a', 'z'))
+		a.FinishState()
+		assert.Nil(t, a.AddTransition(s0, s1, 'a', 'z'))
+		// Each epsilon copies the same transition again:
+		for i := 0; i < 10; i++ {
+			a.AddEpsilon(s0, s1)
+		}
+		a.FinishState()
+
+		assert.Equal(t, 0, countDuplicateTransitions(a))
+		assert.Equal(t, 1, a.GetNumTransitionsWithState(s0))
+		assert.Equal(t, 2, a.GetNumTransitions())
+	})
+
+	t.Run("testUnionHeavy", func(t *testing.T) {
+		automata := make([]*Automaton, 0, 50)
+		for i := 0; i < 50; i++ {
+			a, err := defaultAutomata.MakeAnyString()
+			assert.Nil(t, err)
+			automata = append(automata, a)
+		}
+
+		a := NewAutomaton()
+		a.CreateState()
+		for _, other := range automata {
+			a.Copy(other)
+		}
+		for i := range automata {
+			a.AddEpsilon(0, 1+i)
+		}
+		a.FinishState()
+
+		assert.Equal(t, 0, countDuplicateTransitions(a))
+		// One transition per operand, all to distinct dests:
+		assert.Equal(t, 50, a.GetNumTransitionsWithState(0))
+		assert.Equal(t, 100, a.GetNumTransitions())
+
+		u, err := union(automata...)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, countDuplicateTransitions(u))
+	})
+}
+
+func TestAutomaton_Validate(t *testing.T) {
+	newAutomaton := func() *Automaton {
+		a := NewAutomaton()
+		s0 := a.CreateState()
+		s1 := a.CreateState()
+		a.SetAccept(s1, true)
+		assert.Nil(t, a.AddTransition(s0, s1, 'a', 'b'))
+		assert.Nil(t, a.AddTransition(s0, s0, 'x', 'z'))
+		a.FinishState()
+		return a
+	}
+
+	assert.Nil(t, newAutomaton().Validate())
+	assert.Nil(t, defaultAutomata.MakeEmpty().Validate())
+
+	t.Run("testUnfinished", func(t *testing.T) {
+		a := NewAutomaton()
+		a.CreateState()
+		assert.Nil(t, a.AddTransition(0, 0, 'a', 'a'))
+		assert.Error(t, a.Validate())
+	})
+
+	t.Run("testNonexistentDest", func(t *testing.T) {
+		a := newAutomaton()
+		a.transitions[0].dest = 7
+		assert.Error(t, a.Validate())
+	})
+
+	t.Run("testUnsorted", func(t *testing.T) {
+		a := newAutomaton()
+		a.transitions[0], a.transitions[1] = a.transitions[1], a.transitions[0]
+		assert.Error(t, a.Validate())
+		assert.Error(t, a.AssertTransitionsSorted(0))
+		assert.Nil(t, a.AssertTransitionsSorted(1))
+		assert.Error(t, a.AssertTransitionsSorted(-1))
+		assert.Error(t, a.AssertTransitionsSorted(a.GetNumStates()))
+		assert.Error(t, a.AssertTransitionsSorted(100))
+	})
+
+	t.Run("testStaleDeterministicFlag", func(t *testing.T) {
+		a := newAutomaton()
+		a.transitions[1].min = 'b'
+		assert.Error(t, a.Validate())
+		a.deterministic = false
+		assert.Nil(t, a.Validate())
+	})
+
+	t.Run("testAcceptBeyondStates", func(t *testing.T) {
+		a := newAutomaton()
+		a.SetAccept(5, true)
+		assert.Error(t, a.Validate())
+	})
+
+	t.Run("testLabelOutsideAlphabet", func(t *testing.T) {
+		a := newAutomaton()
+		assert.Nil(t, VerifyAutomaton(a))
+		a.alphabet = ALPHABET_BINARY
+		assert.Nil(t, VerifyAutomaton(a))
+		a.transitions[1].max = 0x100
+		assert.Error(t, VerifyAutomaton(a))
+		a.alphabet = ALPHABET_UNICODE
+		assert.Nil(t, VerifyAutomaton(a))
+		a.transitions[1].max = 0x110000
+		assert.Error(t, VerifyAutomaton(a))
+	})
+
+	t.Run("testUnmergedTransitions", func(t *testing.T) {
+		a := newAutomaton()
+		a.transitions[1].dest = 1
+		a.transitions[1].min = 'c'
+		assert.Error(t, a.Validate())
+	})
+}
+
+func TestAutomaton_StatesWithoutTransitions(t *testing.T) {
+	// State 1 is created between states with transitions but never gets any, state 3 is never finished:
+	a := NewAutomaton()
+	for i := 0; i < 4; i++ {
+		a.CreateState()
+	}
+	a.SetAccept(1, true)
+	assert.Nil(t, a.AddTransition(0, 1, 'a', 'a'))
+	assert.Nil(t, a.AddTransition(2, 1, 'b', 'b'))
+	a.FinishState()
+	assert.Nil(t, a.Validate())
+
+	transition := NewTransition()
+	for _, state := range []int{1, 3, 4, -1} {
+		assert.Equal(t, 0, a.GetNumTransitionsWithState(state), state)
+		assert.Equal(t, 0, a.InitTransition(state, transition), state)
+		assert.Nil(t, a.TransitionsSlice(state), state)
+		assert.Error(t, a.GetTransition(state, 0, transition), state)
+	}
+	for _, state := range []int{1, 3} {
+		assert.Equal(t, -1, a.Step(state, 'a'))
+		transition.Source = state
+		dest, err := a.Next(transition, 'a')
+		assert.Nil(t, err)
+		assert.Equal(t, -1, dest)
+		assert.Nil(t, a.AssertTransitionsSorted(state))
+	}
+	assert.True(t, Run(a, "a"))
+	assert.False(t, Run(a, "ab"))
+
+	// Copying keeps the states without transitions:
+	b := NewAutomaton()
+	b.CreateState()
+	assert.Nil(t, b.AddTransition(0, 0, 'x', 'x'))
+	b.FinishState()
+	b.Copy(a)
+	assert.Nil(t, b.Validate())
+	assert.Equal(t, 0, b.GetNumTransitionsWithState(2))
+	assert.Equal(t, 1, b.GetNumTransitionsWithState(3))
+	assert.Equal(t, []Transition{{Source: 3, Dest: 2, Min: 'b', Max: 'b'}}, b.TransitionsSlice(3))
+}
+
+func TestAutomaton_Next(t *testing.T) {
+	a := NewAutomaton()
+	for i := 0; i < 4; i++ {
+		a.CreateState()
+	}
+	assert.Nil(t, a.AddTransition(0, 1, 'a', 'c'))
+	assert.Nil(t, a.AddTransition(0, 2, 'e', 'g'))
+	assert.Nil(t, a.AddTransition(0, 3, 'x', 'x'))
+	assert.Nil(t, a.AddTransition(1, 0, 'a', 'z'))
+	a.FinishState()
+
+	// Labels looked up in increasing order continue from the last transition reached:
+	transition := NewTransition()
+	transition.Source = 0
+	for label := 'A'; label <= 'z'; label++ {
+		dest, err := a.Next(transition, int(label))
+		assert.Nil(t, err)
+		assert.Equal(t, a.Step(0, int(label)), dest, string(label))
+		assert.Equal(t, dest, transition.Dest)
+	}
+
+	// A transition of another state starts from the first transition:
+	a.InitTransition(1, transition)
+	a.GetNextTransition(transition)
+	transition.Source = 0
+	dest, err := a.Next(transition, 'b')
+	assert.Nil(t, err)
+	assert.Equal(t, 1, dest)
+
+	// Iteration continues after the transition reached:
+	dest, err = a.Next(transition, 'f')
+	assert.Nil(t, err)
+	assert.Equal(t, 2, dest)
+	a.GetNextTransition(transition)
+	assert.Equal(t, 'x', rune(transition.Min))
+	assert.Equal(t, 3, transition.Dest)
+
+	nfa := NewAutomaton()
+	nfa.CreateState()
+	nfa.CreateState()
+	assert.Nil(t, nfa.AddTransition(0, 0, 'a', 'b'))
+	assert.Nil(t, nfa.AddTransition(0, 1, 'b', 'c'))
+	nfa.FinishState()
+	assert.False(t, nfa.IsDeterministic())
+	_, err = nfa.Next(NewTransition(), 'a')
+	assert.NotNil(t, err)
+}
+
+func TestAutomaton_AddTransitions(t *testing.T) {
+	newAutomaton := func() *Automaton {
+		a := NewAutomaton()
+		a.CreateState()
+		a.CreateState()
+		a.CreateState()
+		a.SetAccept(2, true)
+		return a
+	}
+
+	a := newAutomaton()
+	assert.Nil(t, a.AddTransition(0, 1, 'a', 'a'))
+	// Continues state 0, then adds state 1:
+	assert.Nil(t, a.AddTransitions([]int{
+		0, 1, 'b', 'c',
+		0, 2, 'x', 'x',
+		1, 2, 'd', 'd',
+	}))
+	a.FinishState()
+	assert.Nil(t, a.Validate())
+
+	expected := newAutomaton()
+	assert.Nil(t, expected.AddTransition(0, 1, 'a', 'c'))
+	assert.Nil(t, expected.AddTransition(0, 2, 'x', 'x'))
+	assert.Nil(t, expected.AddTransition(1, 2, 'd', 'd'))
+	expected.FinishState()
+	assert.True(t, StructurallyEqual(expected, a))
+
+	t.Run("testInvalid", func(t *testing.T) {
+		for _, transitions := range [][]int{
+			{0, 1, 'a'},
+			{0, 3, 'a', 'a'},
+			{-1, 1, 'a', 'a'},
+			{0, 1, 'b', 'a'},
+			{0, 1, 'a', 'a', 1, 2, 'b', 'b', 0, 2, 'c', 'c'},
+		} {
+			a := newAutomaton()
+			assert.Error(t, a.AddTransitions(transitions), "%v", transitions)
+			assert.Equal(t, 0, a.GetNumTransitions())
+		}
+
+		a := newAutomaton()
+		assert.Nil(t, a.AddTransition(0, 1, 'a', 'a'))
+		assert.Nil(t, a.AddTransition(1, 2, 'a', 'a'))
+		assert.Error(t, a.AddTransitions([]int{0, 2, 'b', 'b'}))
+	})
+
+	t.Run("testBuilder", func(t *testing.T) {
+		b := NewBuilder()
+		b.CreateState()
+		b.CreateState()
+		b.CreateState()
+		b.SetAccept(2, true)
+		assert.Nil(t, b.AddTransitions([]int{
+			1, 2, 'd', 'd',
+			0, 1, 'a', 'c',
+			0, 2, 'x', 'x',
+		}))
+		assert.Error(t, b.AddTransitions([]int{0, 3, 'a', 'a'}))
+		assert.Equal(t, 3, b.GetNumTransitions())
+		assert.True(t, StructurallyEqual(expected, b.Finish()))
+	})
+}
+
+// Sorting the transitions of states with many of them, added out of order, dominates finishing such states.
+func BenchmarkAutomaton_FinishState(b *testing.B) {
+	const numStates, perState = 1000, 200
+	r := rand.New(rand.NewSource(1620))
+	transitions := make([]int, 0, 3*numStates*perState)
+	for i := 0; i < numStates*perState; i++ {
+		label := r.Intn(5000)
+		transitions = append(transitions, r.Intn(numStates), label, label+r.Intn(3))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a := NewAutomatonV1(numStates, numStates*perState)
+		for s := 0; s < numStates; s++ {
+			a.CreateState()
+		}
+		for s := 0; s < numStates; s++ {
+			for j := 3 * s * perState; j < 3*(s+1)*perState; j += 3 {
+				if err := a.AddTransition(s, transitions[j], transitions[j+1], transitions[j+2]); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+		a.FinishState()
+	}
+}
+
+func BenchmarkAutomaton_CollectAlphabetPoints(b *testing.B) {
+	const numStates, perState = 1000, 50
+	r := rand.New(rand.NewSource(1622))
+	a := NewAutomatonV1(numStates, numStates*perState)
+	for s := 0; s < numStates; s++ {
+		a.CreateState()
+	}
+	for s := 0; s < numStates; s++ {
+		for i := 0; i < perState; i++ {
+			label := r.Intn(unicode.MaxRune - 10)
+			if err := a.AddTransition(s, r.Intn(numStates), label, label+r.Intn(10)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	a.FinishState()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.CollectAlphabetPoints()
+	}
+}
+
+func BenchmarkAutomaton_AddTransitions(b *testing.B) {
+	const numStates = 100000
+	transitions := make([]int, 0, 4*10*numStates)
+	for s := 0; s < numStates; s++ {
+		for i := 0; i < 10; i++ {
+			transitions = append(transitions, s, (s+i+1)%numStates, 'a'+2*i, 'a'+2*i)
+		}
+	}
+	newAutomaton := func() *Automaton {
+		a := NewAutomatonV1(numStates, len(transitions)/4)
+		for s := 0; s < numStates; s++ {
+			a.CreateState()
+		}
+		return a
+	}
+
+	b.Run("single", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			a := newAutomaton()
+			for j := 0; j < len(transitions); j += 4 {
+				if err := a.AddTransition(transitions[j], transitions[j+1], transitions[j+2], transitions[j+3]); err != nil {
+					b.Fatal(err)
+				}
+			}
+			a.FinishState()
+		}
+	})
+
+	b.Run("batch", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			a := newAutomaton()
+			if err := a.AddTransitions(transitions); err != nil {
+				b.Fatal(err)
+			}
+			a.FinishState()
+		}
+	})
+}
+
+func TestAutomaton_AddTransitionTyped(t *testing.T) {
+	a := NewAutomaton()
+	a.CreateState()
+	a.CreateState()
+	a.SetAccept(1, true)
+	assert.Nil(t, a.AddTransitionRune(0, 1, 'a', '世'))
+	assert.Error(t, a.AddTransitionRune(0, 1, 'b', 'a'))
+	assert.Error(t, a.AddTransitionRune(0, 2, 'a', 'a'))
+	assert.Error(t, a.AddTransitionRune(0, 1, -1, 'a'))
+	assert.Error(t, a.AddTransitionRune(0, 1, 'a', unicode.MaxRune+1))
+	a.FinishState()
+	assert.True(t, Run(a, "x"))
+	assert.Equal(t, 1, a.GetNumTransitions())
+
+	b := NewAutomaton()
+	b.SetAlphabet(ALPHABET_BINARY)
+	b.CreateState()
+	b.CreateState()
+	b.SetAccept(1, true)
+	assert.Nil(t, b.AddTransitionByte(0, 1, 0x80, 0xff))
+	assert.Error(t, b.AddTransitionByte(0, 1, 0xff, 0x80))
+	assert.Error(t, b.AddTransitionByte(1, 5, 0, 0))
+	assert.Error(t, b.AddTransitionRune(0, 1, 'a', 0x100))
+	b.FinishState()
+	assert.Nil(t, b.Validate())
+	assert.True(t, IsBinaryAutomaton(b))
+}
+
+func TestAutomaton_Freeze(t *testing.T) {
+	a := NewAutomaton()
+	s0 := a.CreateState()
+	s1 := a.CreateState()
+	a.SetAccept(s1, true)
+	assert.Nil(t, a.AddTransition(s0, s1, 'a', 'c'))
+	assert.Same(t, a, a.Freeze())
+	assert.True(t, a.IsFrozen())
+	assert.Nil(t, a.Validate())
+
+	assert.ErrorIs(t, a.AddTransition(s1, s0, 'x', 'x'), ErrFrozen)
+	assert.ErrorIs(t, a.AddTransitions([]int{s1, s0, 'x', 'x'}), ErrFrozen)
+	assert.PanicsWithValue(t, ErrFrozen, func() { a.CreateState() })
+	assert.PanicsWithValue(t, ErrFrozen, func() { a.SetAccept(s0, true) })
+	assert.PanicsWithValue(t, ErrFrozen, func() { a.AddEpsilon(s0, s1) })
+	assert.PanicsWithValue(t, ErrFrozen, func() { a.Copy(NewAutomaton()) })
+	assert.Equal(t, 2, a.GetNumStates())
+	assert.Equal(t, 1, a.GetNumTransitions())
+
+	t.Run("testClone", func(t *testing.T) {
+		c := a.Clone()
+		assert.False(t, c.IsFrozen())
+		assert.True(t, StructurallyEqual(a, c))
+		c.SetAccept(s0, true)
+		assert.False(t, a.IsAccept(s0))
+	})
+
+	t.Run("testOperationsOnFrozen", func(t *testing.T) {
+		// a is deterministic, so determinize returns it as is; complement must not flip its accept states.
+		comp, err := complement(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, Run(comp, ""))
+		assert.False(t, Run(comp, "b"))
+		assert.False(t, a.IsAccept(s0))
+
+		u, err := union(a, a)
+		assert.Nil(t, err)
+		c, err := concatenate(a, a)
+		assert.Nil(t, err)
+		m, err := Minimize(c, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, runNFA(u, "b"))
+		assert.True(t, Run(m, "ab"))
+		assert.Nil(t, a.Validate())
+	})
+}
+
+func TestAutomaton_Alphabet(t *testing.T) {
+	bin, err := MakeBinary([]byte("\xffab"))
+	assert.Nil(t, err)
+	str, err := MakeString("ab")
+	assert.Nil(t, err)
+	assert.Equal(t, ALPHABET_BINARY, bin.Alphabet())
+	assert.Equal(t, ALPHABET_UNICODE, str.Alphabet())
+
+	empty, err := MakeBinaryInterval(Bound{Value: []byte("b")}, Bound{Value: []byte("a")})
+	assert.Nil(t, err)
+	assert.Equal(t, ALPHABET_BINARY, empty.Alphabet())
+
+	t.Run("testOperations", func(t *testing.T) {
+		anyBinary, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		c, err := concatenate(bin, anyBinary)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, c.Alphabet())
+		m, err := Minimize(c, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, m.Alphabet())
+		o, err := optional(bin)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, o.Alphabet())
+
+		u, err := union(bin, str)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_UNICODE, u.Alphabet())
+		comp, err := complement(bin, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, comp.Alphabet())
+		assert.Nil(t, comp.Validate())
+		assert.True(t, IsBinaryAutomaton(comp))
+	})
+
+	t.Run("testStep", func(t *testing.T) {
+		state, err := bin.StepBytes(0, []byte("\xffab"))
+		assert.Nil(t, err)
+		assert.True(t, bin.IsAccept(state))
+		state, err = bin.StepBytes(0, []byte("\xffb"))
+		assert.Nil(t, err)
+		assert.Equal(t, -1, state)
+		_, err = bin.StepString(0, "\xffab")
+		assert.Error(t, err)
+
+		state, err = str.StepString(0, "ab")
+		assert.Nil(t, err)
+		assert.True(t, str.IsAccept(state))
+		_, err = str.StepBytes(0, []byte("ab"))
+		assert.Error(t, err)
+
+		state, err = empty.StepBytes(0, []byte("a"))
+		assert.Nil(t, err)
+		assert.Equal(t, -1, state)
+	})
+}
+
+func Test_getCommonPrefixBytesRef(t *testing.T) {
+	a, err := MakeBinaryPrefix([]byte{0xc3, 0xff, 0x80, 0x00})
+	assert.Nil(t, err)
+	ref, err := getCommonPrefixBytesRef(a)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xc3, 0xff, 0x80, 0x00}, ref)
+
+	a, err = MakeBinaryInterval(Bound{Value: []byte{0xfe, 0x80}, Inclusive: true}, Bound{Value: []byte{0xfe, 0x90}})
+	assert.Nil(t, err)
+	ref, err = getCommonPrefixBytesRef(a)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xfe}, ref)
+
+	a, err = MakeString("a€")
+	assert.Nil(t, err)
+	_, err = getCommonPrefixBytesRef(a)
+	assert.Error(t, err)
+}
+
+func TestAutomaton_GetTransition(t *testing.T) {
+	a := NewAutomaton()
+	s0 := a.CreateState()
+	s1 := a.CreateState()
+	a.SetAccept(s1, true)
+	assert.Nil(t, a.AddTransition(s0, s1, 'x', 'z'))
+	assert.Nil(t, a.AddTransition(s0, s0, 'a', 'c'))
+	a.FinishState()
+
+	tr := &Transition{}
+	assert.Nil(t, a.GetTransition(s0, 1, tr))
+	assert.Equal(t, Transition{Source: s0, Dest: s1, Min: 'x', Max: 'z'}, *tr)
+	assert.Error(t, a.GetTransition(s0, 2, tr))
+	assert.Error(t, a.GetTransition(s0, -1, tr))
+	assert.Error(t, a.GetTransition(s1, 0, tr))
+	assert.Error(t, a.GetTransition(2, 0, tr))
+
+	assert.Equal(t, []Transition{
+		{Source: s0, Dest: s0, Min: 'a', Max: 'c'},
+		{Source: s0, Dest: s1, Min: 'x', Max: 'z'},
+	}, a.TransitionsSlice(s0))
+	assert.Nil(t, a.TransitionsSlice(s1))
+	assert.Nil(t, a.TransitionsSlice(5))
+}
+
+func TestAutomaton_Introspection(t *testing.T) {
+	// "a[b-d]|e" plus an unreachable state:
+	a := NewAutomaton()
+	for i := 0; i < 4; i++ {
+		a.CreateState()
+	}
+	a.SetAccept(2, true)
+	assert.Nil(t, a.AddTransition(0, 1, 'a', 'a'))
+	assert.Nil(t, a.AddTransition(0, 2, 'e', 'e'))
+	assert.Nil(t, a.AddTransition(1, 2, 'b', 'd'))
+	assert.Nil(t, a.AddTransition(3, 2, 'x', 'z'))
+	a.FinishState()
+
+	assert.Equal(t, 1, a.NumAcceptStates())
+	assert.Equal(t, 3, a.NumReachableStates())
+	assert.Equal(t, 'z', rune(a.MaxLabel()))
+	// [0, a), a, [b, d], e, (e, x), [x, z], (z, max]:
+	assert.Equal(t, 7, a.AlphabetIntervalCount())
+	assert.Equal(t, "4 states (1 accept, 3 reachable), 4 transitions, deterministic, unicode", a.String())
+
+	t.Run("testEmpty", func(t *testing.T) {
+		empty := NewAutomaton()
+		assert.Equal(t, 0, empty.NumAcceptStates())
+		assert.Equal(t, 0, empty.NumReachableStates())
+		assert.Equal(t, -1, empty.MaxLabel())
+		assert.Equal(t, 1, empty.AlphabetIntervalCount())
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		b, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		assert.Equal(t, 0xFF, b.MaxLabel())
+		assert.Equal(t, 1, b.AlphabetIntervalCount())
+		assert.Contains(t, b.String(), "binary")
+	})
+}
@@ -0,0 +1,181 @@
+package automaton
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The JSON encoding of an automaton, as written by MarshalJSON and EncodeJSON:
+//
+//	{
+//	  "alphabet": "unicode",
+//	  "states": [
+//	    {"accept": false, "transitions": [{"min": 97, "max": 122, "dest": 1}]},
+//	    {"accept": true}
+//	  ]
+//	}
+//
+// State 0 is the initial state and "dest" is an index into "states". "alphabet" is "unicode" (labels are code
+// points) or "binary" (labels are bytes); "transitions" is omitted for states without transitions. With
+// WithJSONLabelStrings, labels are written as one character strings instead ("a"), except for those that do not
+// survive a round trip through a JSON string, such as surrogates. Both forms are accepted by UnmarshalJSON.
+type automatonJSON struct {
+	Alphabet string      `json:"alphabet"`
+	States   []stateJSON `json:"states"`
+}
+
+type stateJSON struct {
+	Accept      bool             `json:"accept"`
+	Transitions []transitionJSON `json:"transitions,omitempty"`
+}
+
+type transitionJSON struct {
+	Min  labelJSON `json:"min"`
+	Max  labelJSON `json:"max"`
+	Dest int       `json:"dest"`
+}
+
+// labelJSON A label, written as a number or, if asString is set, as a one character string.
+type labelJSON struct {
+	value    int
+	asString bool
+}
+
+func (l labelJSON) MarshalJSON() ([]byte, error) {
+	if l.asString && utf8.ValidRune(rune(l.value)) {
+		return json.Marshal(string(rune(l.value)))
+	}
+	return json.Marshal(l.value)
+}
+
+func (l *labelJSON) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		c, size := utf8.DecodeRuneInString(s)
+		if size == 0 || size != len(s) {
+			return fmt.Errorf("label %s is not a single character", data)
+		}
+		l.value = int(c)
+		return nil
+	}
+	return json.Unmarshal(data, &l.value)
+}
+
+type encodeJSONOptions struct {
+	labelStrings bool
+}
+
+// EncodeJSONOption Configures EncodeJSON.
+type EncodeJSONOption func(*encodeJSONOptions)
+
+// WithJSONLabelStrings Writes labels as one character strings ("a") instead of numbers (97), which is easier to
+// read for Unicode automata.
+func WithJSONLabelStrings() EncodeJSONOption {
+	return func(o *encodeJSONOptions) {
+		o.labelStrings = true
+	}
+}
+
+// EncodeJSON Returns the JSON encoding of the automaton, see MarshalJSON, configured by the given options.
+// The automaton must be finished.
+func EncodeJSON(a *Automaton, options ...EncodeJSONOption) ([]byte, error) {
+	opts := &encodeJSONOptions{}
+	for _, option := range options {
+		option(opts)
+	}
+	if a.curState != -1 {
+		return nil, fmt.Errorf("state %d is not finished", a.curState)
+	}
+
+	v := automatonJSON{
+		Alphabet: a.alphabet.String(),
+		States:   make([]stateJSON, a.GetNumStates()),
+	}
+	t := NewTransition()
+	for s := range v.States {
+		v.States[s].Accept = a.IsAccept(s)
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			v.States[s].Transitions = append(v.States[s].Transitions, transitionJSON{
+				Min:  labelJSON{value: t.Min, asString: opts.labelStrings},
+				Max:  labelJSON{value: t.Max, asString: opts.labelStrings},
+				Dest: t.Dest,
+			})
+		}
+	}
+	return json.Marshal(v)
+}
+
+// MarshalJSON Encodes the automaton as a list of states with their accept flag and transitions, for
+// debugging and tooling in other languages; see EncodeJSON for more readable labels.
+func (a *Automaton) MarshalJSON() ([]byte, error) {
+	return EncodeJSON(a)
+}
+
+// UnmarshalJSON Replaces the states and transitions of this automaton by those of the given JSON encoding (see
+// MarshalJSON). Returns an error if the encoding is malformed, e.g. has transitions to missing states or
+// labels outside the alphabet, or ErrFrozen if the automaton is frozen.
+func (a *Automaton) UnmarshalJSON(data []byte) error {
+	if a.frozen {
+		return ErrFrozen
+	}
+	var v automatonJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	b := NewAutomaton()
+	maxLabel := int(unicode.MaxRune)
+	switch v.Alphabet {
+	case ALPHABET_UNICODE.String(), "":
+	case ALPHABET_BINARY.String():
+		b.alphabet = ALPHABET_BINARY
+		maxLabel = math.MaxUint8
+	default:
+		return fmt.Errorf("unknown alphabet %q", v.Alphabet)
+	}
+
+	for s, state := range v.States {
+		b.CreateState()
+		b.SetAccept(s, state.Accept)
+	}
+	for s, state := range v.States {
+		for _, t := range state.Transitions {
+			if err := checkTransition(s, t.Dest, t.Min.value, t.Max.value, len(v.States)); err != nil {
+				return err
+			}
+			if t.Max.value > maxLabel {
+				return fmt.Errorf("label %d is out of range for a %s automaton", t.Max.value, b.alphabet)
+			}
+			if err := b.AddTransition(s, t.Dest, t.Min.value, t.Max.value); err != nil {
+				return err
+			}
+		}
+	}
+	b.FinishState()
+
+	a.replace(b)
+	return nil
+}
+
+// replace Replaces the states and transitions of the automaton, and everything known about them, by those of
+// the finished automaton b, which must no longer be used.
+func (a *Automaton) replace(b *Automaton) {
+	a.curState = b.curState
+	a.states = b.states
+	a.isAccept = b.isAccept
+	a.transitions = b.transitions
+	a.deterministic = b.deterministic
+	a.noDeadStates = false
+	a.alphabet = b.alphabet
+	a.stateLabels = b.stateLabels
+	a.normalized = b.normalized
+	a.partition.Store(nil)
+}
@@ -0,0 +1,93 @@
+package automaton
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutomaton_JSON(t *testing.T) {
+	a, err := MakeCharRange('a', 'b')
+	assert.Nil(t, err)
+
+	data, err := json.Marshal(a)
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"alphabet":"unicode","states":[
+		{"accept":false,"transitions":[{"min":97,"max":98,"dest":1}]},
+		{"accept":true}]}`, string(data))
+
+	data, err = EncodeJSON(a, WithJSONLabelStrings())
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"alphabet":"unicode","states":[
+		{"accept":false,"transitions":[{"min":"a","max":"b","dest":1}]},
+		{"accept":true}]}`, string(data))
+
+	t.Run("testRoundTrip", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1582))
+		for i := 0; i < 50; i++ {
+			re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)) + "é?")
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+			surrogates, err := MakeCharRange(0xD800, 0xDFFF)
+			assert.Nil(t, err)
+			a, err = concatenate(a, surrogates)
+			assert.Nil(t, err)
+
+			for _, options := range [][]EncodeJSONOption{nil, {WithJSONLabelStrings()}} {
+				data, err := EncodeJSON(a, options...)
+				assert.Nil(t, err)
+				b := NewAutomaton()
+				assert.Nil(t, json.Unmarshal(data, b))
+				assert.Nil(t, b.Validate())
+				assert.True(t, StructurallyEqual(a, b))
+				assert.Equal(t, a.IsDeterministic(), b.IsDeterministic())
+			}
+		}
+
+		a, err := MakeBinaryRange(0x80, 0xFF)
+		assert.Nil(t, err)
+		data, err := json.Marshal(a)
+		assert.Nil(t, err)
+		var b Automaton
+		assert.Nil(t, json.Unmarshal(data, &b))
+		assert.Equal(t, ALPHABET_BINARY, b.Alphabet())
+		assert.True(t, StructurallyEqual(a, &b))
+	})
+
+	t.Run("testReuse", func(t *testing.T) {
+		b, err := MakeString("abc")
+		assert.Nil(t, err)
+		b.SetStateLabel(1, "stale")
+		assert.Nil(t, json.Unmarshal(data, b))
+		assert.True(t, StructurallyEqual(a, b))
+		assert.Equal(t, "", b.GetStateLabel(1))
+
+		// Validate no longer allows the adjacent ranges of normalized automata:
+		b, err = Normalize(b)
+		assert.Nil(t, err)
+		assert.True(t, b.normalized)
+		assert.Nil(t, json.Unmarshal(data, b))
+		assert.False(t, b.normalized)
+	})
+
+	t.Run("testErrors", func(t *testing.T) {
+		for _, data := range []string{
+			`{"states":[{"transitions":[{"min":1,"max":2,"dest":1}]}]}`,
+			`{"states":[{"transitions":[{"min":2,"max":1,"dest":0}]}]}`,
+			`{"states":[{"transitions":[{"min":"ab","max":"c","dest":0}]}]}`,
+			`{"alphabet":"binary","states":[{"transitions":[{"min":0,"max":256,"dest":0}]}]}`,
+			`{"alphabet":"ebcdic","states":[]}`,
+			`{"states":`,
+		} {
+			assert.NotNil(t, json.Unmarshal([]byte(data), NewAutomaton()), data)
+		}
+
+		a, err := MakeString("frozen")
+		assert.Nil(t, err)
+		a.Freeze()
+		assert.ErrorIs(t, a.UnmarshalJSON([]byte(`{"states":[]}`)), ErrFrozen)
+	})
+}
@@ -0,0 +1,174 @@
+// Package automatontest Provides utilities for testing code built on automata: random automata, regular
+// expressions and strings for property tests, and language equivalence assertions. It mirrors Lucene's
+// AutomatonTestUtil.
+package automatontest
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/geange/automaton"
+)
+
+// Alphabet The characters used by RandomAutomaton, RandomRegexp and RandomString. It is deliberately small so
+// random strings often hit the interesting parts of random automata.
+const Alphabet = "abcd"
+
+// RandomRegexp Returns a random regular expression over Alphabet, in the syntax shared by automaton.RegExp and
+// the standard library's regexp package (where it must be anchored and compiled with the s flag to match
+// the same strings).
+func RandomRegexp(r *rand.Rand) string {
+	return randomRegexp(r, 1+r.Intn(3))
+}
+
+func randomRegexp(r *rand.Rand, depth int) string {
+	if depth <= 0 {
+		switch r.Intn(6) {
+		case 0:
+			return "."
+		case 1:
+			return "[a-b]"
+		case 2:
+			return "[^a]"
+		default:
+			return string(Alphabet[r.Intn(len(Alphabet))])
+		}
+	}
+	switch r.Intn(9) {
+	case 0:
+		return randomRegexp(r, depth-1) + "|" + randomRegexp(r, depth-1)
+	case 1, 2:
+		return randomRegexp(r, depth-1) + randomRegexp(r, depth-1)
+	case 3:
+		return "(" + randomRegexp(r, depth-1) + ")*"
+	case 4:
+		return "(" + randomRegexp(r, depth-1) + ")+"
+	case 5:
+		return "(" + randomRegexp(r, depth-1) + ")?"
+	case 6:
+		n := r.Intn(3)
+		m := n + r.Intn(3)
+		return "(" + randomRegexp(r, depth-1) + "){" + strconv.Itoa(n) + "," + strconv.Itoa(m) + "}"
+	case 7:
+		return "(" + randomRegexp(r, depth-1) + "){" + strconv.Itoa(r.Intn(3)) + "}"
+	default:
+		return "(" + randomRegexp(r, depth-1) + ")"
+	}
+}
+
+// RandomAutomaton Returns a random, usually non-deterministic, automaton with between 1 and maxStates states,
+// whose transitions carry overlapping ranges of Alphabet characters.
+func RandomAutomaton(r *rand.Rand, maxStates int) *automaton.Automaton {
+	numStates := 1 + r.Intn(maxStates)
+	a := automaton.NewAutomaton()
+	for s := 0; s < numStates; s++ {
+		a.CreateState()
+		a.SetAccept(s, r.Intn(3) == 0)
+	}
+	for s := 0; s < numStates; s++ {
+		for i := r.Intn(5); i > 0; i-- {
+			lo := r.Intn(len(Alphabet))
+			hi := lo + r.Intn(len(Alphabet)-lo)
+			if err := a.AddTransition(s, r.Intn(numStates), int(Alphabet[lo]), int(Alphabet[hi])); err != nil {
+				panic(err)
+			}
+		}
+	}
+	a.FinishState()
+	return a
+}
+
+// RandomString Returns a random string of up to maxLength characters of Alphabet, with an occasional character
+// outside of it.
+func RandomString(r *rand.Rand, maxLength int) string {
+	b := new(strings.Builder)
+	for n := r.Intn(maxLength + 1); n > 0; n-- {
+		if r.Intn(20) == 0 {
+			b.WriteRune('é')
+		} else {
+			b.WriteByte(Alphabet[r.Intn(len(Alphabet))])
+		}
+	}
+	return b.String()
+}
+
+// Accepts Returns true if the automaton accepts s. Unlike automaton.Run, this simulates all states in parallel,
+// so it works on non-deterministic automata too.
+func Accepts(a *automaton.Automaton, s string) bool {
+	if a.GetNumStates() == 0 {
+		return false
+	}
+	current := map[int]struct{}{0: {}}
+	t := automaton.NewTransition()
+	for _, c := range s {
+		next := make(map[int]struct{})
+		for state := range current {
+			count := a.InitTransition(state, t)
+			for i := 0; i < count; i++ {
+				a.GetNextTransition(t)
+				if t.Min <= int(c) && int(c) <= t.Max {
+					next[t.Dest] = struct{}{}
+				}
+			}
+		}
+		current = next
+	}
+	for state := range current {
+		if a.IsAccept(state) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubsetOf Returns true if the language of a1 is a subset of the language of a2. This determinizes a2, but
+// does not minimize, so it can check the results of automaton.Minimize.
+func SubsetOf(a1, a2 *automaton.Automaton) (bool, error) {
+	// Complement a2 relative to the labels of both automata, which a1's strings are made of:
+	alphabet := automaton.NewRangeSet(append(labels(a1), labels(a2)...)...)
+	complement, err := automaton.ComplementOver(a2, alphabet, automaton.DEFAULT_DETERMINIZE_WORK_LIMIT)
+	if err != nil {
+		return false, err
+	}
+	intersects, err := automaton.IntersectsNonEmpty(a1, complement)
+	return !intersects, err
+}
+
+// SameLanguage Returns true if the two automata accept the same language.
+func SameLanguage(a1, a2 *automaton.Automaton) (bool, error) {
+	subset, err := SubsetOf(a1, a2)
+	if err != nil || !subset {
+		return false, err
+	}
+	return SubsetOf(a2, a1)
+}
+
+// AssertSameLanguage Fails the test if the two automata do not accept the same language.
+func AssertSameLanguage(tb testing.TB, a1, a2 *automaton.Automaton) bool {
+	tb.Helper()
+	same, err := SameLanguage(a1, a2)
+	if err != nil {
+		tb.Errorf("cannot compare languages: %v", err)
+		return false
+	}
+	if !same {
+		tb.Errorf("automata accept different languages (%d and %d states)", a1.GetNumStates(), a2.GetNumStates())
+	}
+	return same
+}
+
+// Returns the label ranges of all transitions of the automaton.
+func labels(a *automaton.Automaton) [][2]int {
+	ranges := make([][2]int, 0, a.GetNumTransitions())
+	t := automaton.NewTransition()
+	for s := 0; s < a.GetNumStates(); s++ {
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			ranges = append(ranges, [2]int{t.Min, t.Max})
+		}
+	}
+	return ranges
+}
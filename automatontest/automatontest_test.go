@@ -0,0 +1,80 @@
+package automatontest
+
+import (
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/geange/automaton"
+	"github.com/stretchr/testify/assert"
+)
+
+func compile(t *testing.T, pattern string) *automaton.Automaton {
+	re, err := automaton.NewRegExp(pattern)
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+	return a
+}
+
+func TestSameLanguage(t *testing.T) {
+	AssertSameLanguage(t, compile(t, "a+"), compile(t, "aa*"))
+	AssertSameLanguage(t, compile(t, "(a|b)*"), compile(t, "(a*b*)*"))
+	AssertSameLanguage(t, compile(t, "[a-c]"), compile(t, "a|b|c"))
+
+	for _, pair := range [][2]string{{"a+", "a*"}, {"ab", "ba"}, {".", "a"}, {"(a|b)*", "(ab)*"}} {
+		same, err := SameLanguage(compile(t, pair[0]), compile(t, pair[1]))
+		assert.Nil(t, err)
+		assert.False(t, same, "%q %q", pair[0], pair[1])
+	}
+
+	subset, err := SubsetOf(compile(t, "(ab)*"), compile(t, "(a|b)*"))
+	assert.Nil(t, err)
+	assert.True(t, subset)
+	subset, err = SubsetOf(compile(t, "(a|b)*"), compile(t, "(ab)*"))
+	assert.Nil(t, err)
+	assert.False(t, subset)
+
+	AssertSameLanguage(t, automaton.NewAutomaton(), compile(t, "#"))
+}
+
+func TestRandomRegexp(t *testing.T) {
+	r := rand.New(rand.NewSource(1555))
+	for i := 0; i < 100; i++ {
+		pattern := RandomRegexp(r)
+		expected := regexp.MustCompile("^(?s:" + pattern + ")$")
+		a := compile(t, pattern)
+		for j := 0; j < 20; j++ {
+			s := RandomString(r, 6)
+			assert.Equal(t, expected.MatchString(s), Accepts(a, s), "pattern=%q s=%q", pattern, s)
+		}
+	}
+}
+
+// Property tests of exported operations on random automata.
+func TestOperationsPreserveLanguage(t *testing.T) {
+	r := rand.New(rand.NewSource(15552))
+	for i := 0; i < 200; i++ {
+		a := RandomAutomaton(r, 6)
+		assert.Nil(t, a.Validate())
+
+		m, err := automaton.Minimize(a, automaton.DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, m.IsDeterministic())
+		AssertSameLanguage(t, a, m)
+
+		live, err := automaton.RemoveDeadStates(a)
+		assert.Nil(t, err)
+		assert.False(t, automaton.HasDeadStates(live))
+		AssertSameLanguage(t, a, live)
+
+		c, err := automaton.Canonicalize(a)
+		assert.Nil(t, err)
+		AssertSameLanguage(t, a, c)
+
+		for j := 0; j < 20; j++ {
+			s := RandomString(r, 6)
+			assert.Equal(t, Accepts(a, s), automaton.Run(m, s), "s=%q", s)
+		}
+	}
+}
@@ -18,7 +18,15 @@ func mix32(v int) int {
 	return int(k ^ (k >> 16))
 }
 
-//func mixPhi(k int32) int32 {
-//	h := k * int32(PHI_C32)
-//	return (h) ^ int32(uint32(h)>>16)
-//}
+// mix64 The 64 bit finalization step of MurmurHash3.
+func mix64(k uint64) uint64 {
+	k = (k ^ (k >> 33)) * 0xff51afd7ed558ccd
+	k = (k ^ (k >> 33)) * 0xc4ceb9fe1a85ec53
+	return k ^ (k >> 33)
+}
+
+// mixPhi A cheap 32 bit mixer multiplying by the golden ratio, as HPPC's BitMixer.mixPhi.
+func mixPhi(k int) int {
+	h := uint32(k) * PHI_C32
+	return int(h ^ (h >> 16))
+}
@@ -0,0 +1,211 @@
+package automaton
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The dk.brics text format, as written by dk.brics.automaton.Automaton.toString, from which Lucene's automaton
+// package was derived. Lucene itself defines no persistent form for automata, so this is the format existing
+// assets are usually found in:
+//
+//	initial state: 2
+//	state 0 [accept]:
+//	state 2 [reject]:
+//	  a-z -> 0
+//	  \u00e9 -> 0
+//
+// Labels in the printable ASCII range other than '\\' and '"' are written as is, all others as \uXXXX; other
+// unescaped characters are accepted too when reading. Since dk.brics labels are UTF-16 code units,
+// supplementary code points are written as \UXXXXXXXX, as Lucene does in its debugging output. State numbers
+// are arbitrary; the initial state becomes state 0.
+
+// ReadBricsText Parses an automaton written in the dk.brics text format. States are numbered in the order
+// they are listed, after the initial state.
+func ReadBricsText(r io.Reader) (*Automaton, error) {
+	type bricsTransition struct {
+		line, min, max, dest int
+	}
+
+	initial := -1
+	var numbers []int
+	accept := make(map[int]bool)
+	transitions := make(map[int][]bricsTransition)
+
+	scanner := bufio.NewScanner(r)
+	line, current := 0, -1
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		switch {
+		case text == "":
+		case strings.HasPrefix(text, "initial state:"):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(text, "initial state:")))
+			if err != nil || initial != -1 {
+				return nil, fmt.Errorf("line %d: invalid initial state %q", line, text)
+			}
+			initial = n
+		case strings.HasPrefix(text, "state "):
+			var n int
+			var kind string
+			if _, err := fmt.Sscanf(text, "state %d [%6s]:", &n, &kind); err != nil ||
+				(kind != "accept" && kind != "reject") || text != fmt.Sprintf("state %d [%s]:", n, kind) {
+				return nil, fmt.Errorf("line %d: invalid state %q", line, text)
+			}
+			if _, ok := accept[n]; ok {
+				return nil, fmt.Errorf("line %d: duplicate state %d", line, n)
+			}
+			numbers = append(numbers, n)
+			accept[n] = kind == "accept"
+			current = n
+		default:
+			if current == -1 {
+				return nil, fmt.Errorf("line %d: transition outside of a state", line)
+			}
+			min, max, dest, err := parseBricsTransition(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", line, err)
+			}
+			transitions[current] = append(transitions[current], bricsTransition{line: line, min: min, max: max, dest: dest})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if initial == -1 {
+		return nil, fmt.Errorf("missing initial state")
+	}
+	if _, ok := accept[initial]; !ok {
+		return nil, fmt.Errorf("initial state %d is not listed", initial)
+	}
+
+	builder := NewBuilder()
+	states := make(map[int]int, len(numbers))
+	states[initial] = builder.CreateState()
+	builder.SetAccept(0, accept[initial])
+	for _, n := range numbers {
+		if n != initial {
+			states[n] = builder.CreateState()
+			builder.SetAccept(states[n], accept[n])
+		}
+	}
+	for _, n := range numbers {
+		for _, t := range transitions[n] {
+			dest, ok := states[t.dest]
+			if !ok {
+				return nil, fmt.Errorf("line %d: dest state %d is not listed", t.line, t.dest)
+			}
+			builder.AddTransition(states[n], dest, t.min, t.max)
+		}
+	}
+	return builder.Finish(), nil
+}
+
+// parseBricsTransition Parses a transition line ("a-z -> 3") into its label range and dest state number.
+func parseBricsTransition(text string) (min, max, dest int, err error) {
+	labels, to, ok := strings.Cut(text, " -> ")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid transition %q", text)
+	}
+	if dest, err = strconv.Atoi(to); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid transition %q", text)
+	}
+	min, rest, err := parseBricsChar(labels)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	max = min
+	if rest != "" {
+		if rest[0] != '-' {
+			return 0, 0, 0, fmt.Errorf("invalid transition %q", text)
+		}
+		if max, rest, err = parseBricsChar(rest[1:]); err != nil {
+			return 0, 0, 0, err
+		}
+		if rest != "" {
+			return 0, 0, 0, fmt.Errorf("invalid transition %q", text)
+		}
+	}
+	if min > max {
+		return 0, 0, 0, fmt.Errorf("invalid label range [%d, %d]", min, max)
+	}
+	return min, max, dest, nil
+}
+
+// parseBricsChar Parses the label at the start of s and returns it along with the remainder of s.
+func parseBricsChar(s string) (int, string, error) {
+	if s == "" {
+		return 0, "", fmt.Errorf("missing label")
+	}
+	if s[0] != '\\' {
+		// dk.brics escapes everything else, but hand written files may well contain other characters:
+		c, size := utf8.DecodeRuneInString(s)
+		if c == utf8.RuneError || unicode.IsSpace(c) || unicode.IsControl(c) {
+			return 0, "", fmt.Errorf("invalid label %q", s[:size])
+		}
+		return int(c), s[size:], nil
+	}
+	digits := 0
+	switch {
+	case strings.HasPrefix(s, `\u`):
+		digits = 4
+	case strings.HasPrefix(s, `\U`):
+		digits = 8
+	default:
+		return 0, "", fmt.Errorf("invalid escape in %q", s)
+	}
+	if len(s) < 2+digits {
+		return 0, "", fmt.Errorf("invalid escape in %q", s)
+	}
+	c, err := strconv.ParseUint(s[2:2+digits], 16, 32)
+	if err != nil || c > unicode.MaxRune {
+		return 0, "", fmt.Errorf("invalid escape in %q", s)
+	}
+	return int(c), s[2+digits:], nil
+}
+
+// WriteBricsText Writes the automaton in the dk.brics text format, see ReadBricsText. An automaton without
+// states is written as a single reject state, since the format requires an initial state.
+func WriteBricsText(w io.Writer, a *Automaton) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "initial state: 0\n")
+	if a.GetNumStates() == 0 {
+		fmt.Fprintf(bw, "state 0 [reject]:\n")
+	}
+	t := NewTransition()
+	for s := 0; s < a.GetNumStates(); s++ {
+		kind := "reject"
+		if a.IsAccept(s) {
+			kind = "accept"
+		}
+		fmt.Fprintf(bw, "state %d [%s]:\n", s, kind)
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			bw.WriteString("  ")
+			writeBricsChar(bw, t.Min)
+			if t.Min != t.Max {
+				bw.WriteByte('-')
+				writeBricsChar(bw, t.Max)
+			}
+			fmt.Fprintf(bw, " -> %d\n", t.Dest)
+		}
+	}
+	return bw.Flush()
+}
+
+func writeBricsChar(w *bufio.Writer, c int) {
+	switch {
+	case c >= 0x21 && c <= 0x7E && c != '\\' && c != '"':
+		w.WriteByte(byte(c))
+	case c <= 0xFFFF:
+		fmt.Fprintf(w, `\u%04x`, c)
+	default:
+		fmt.Fprintf(w, `\U%08x`, c)
+	}
+}
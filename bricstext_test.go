@@ -0,0 +1,75 @@
+package automaton
+
+import (
+	"bytes"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadBricsText(t *testing.T) {
+	a, err := ReadBricsText(strings.NewReader(`initial state: 2
+state 0 [accept]:
+state 2 [reject]:
+  a-c -> 0
+  é -> 5
+  --/ -> 0
+state 5 [reject]:
+  \U0001f600 -> 0
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, a.GetNumStates())
+	for _, s := range []string{"a", "c", "-", ".", "é😀"} {
+		assert.True(t, Run(a, s), s)
+	}
+	for _, s := range []string{"", "d", "é", "😀", "aa"} {
+		assert.False(t, Run(a, s), s)
+	}
+
+	t.Run("testRoundTrip", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1583))
+		for i := 0; i < 50; i++ {
+			re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)) + "[\"\\\\ é]?")
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+			c, err := MakeChar(0x1F600)
+			assert.Nil(t, err)
+			a, err = concatenate(a, c)
+			assert.Nil(t, err)
+
+			var buf bytes.Buffer
+			assert.Nil(t, WriteBricsText(&buf, a))
+			b, err := ReadBricsText(&buf)
+			assert.Nil(t, err)
+			assert.True(t, StructurallyEqual(a, b))
+		}
+
+		var buf bytes.Buffer
+		assert.Nil(t, WriteBricsText(&buf, MakeEmpty()))
+		b, err := ReadBricsText(&buf)
+		assert.Nil(t, err)
+		assert.True(t, IsEmptyAutomaton(b))
+	})
+
+	t.Run("testInvalid", func(t *testing.T) {
+		for _, text := range []string{
+			"",
+			"state 0 [accept]:\n",
+			"initial state: 1\nstate 0 [accept]:\n",
+			"initial state: 0\nstate 0 [maybe]:\n",
+			"initial state: 0\nstate 0 [accept]:\nstate 0 [reject]:\n",
+			"initial state: 0\n  a -> 0\nstate 0 [accept]:\n",
+			"initial state: 0\nstate 0 [accept]:\n  a -> 1\n",
+			"initial state: 0\nstate 0 [accept]:\n  z-a -> 0\n",
+			"initial state: 0\nstate 0 [accept]:\n  \\u00 -> 0\n",
+			"initial state: 0\nstate 0 [accept]:\n  ab -> 0\n",
+			"initial state: 0\nstate 0 [accept]:\n  a => 0\n",
+		} {
+			_, err := ReadBricsText(strings.NewReader(text))
+			assert.NotNil(t, err, text)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+package automaton
+
+import "errors"
+
+// ErrBudgetExhausted Returned by operations that would spend more work than is left in their Budget.
+var ErrBudgetExhausted = errors.New("work budget exhausted")
+
+// Budget Bounds the total work of a sequence of operations, such as all steps of compiling a RegExp (see
+// WithBudget). A determinizeWorkLimit only bounds each operation on its own, so a pipeline of many operations,
+// e.g. a pattern with many nodes, may spend the limit many times over; operations sharing a Budget stop once
+// their combined work reaches it. Work is measured in the units of determinizeWorkLimit. A Budget must not be
+// used by several goroutines at once.
+type Budget struct {
+	// The determinization effort left; determinize spends up to 10 effort per unit of work limit.
+	effort int
+}
+
+// NewBudget Returns a budget allowing the given amount of work in total, see DEFAULT_DETERMINIZE_WORK_LIMIT.
+func NewBudget(workLimit int) *Budget {
+	return &Budget{effort: 10 * workLimit}
+}
+
+// Remaining Returns how much work is left.
+func (b *Budget) Remaining() int {
+	return b.effort / 10
+}
+
+// effortLimit Returns the effort an operation with the given determinizeWorkLimit may spend, and whether the
+// budget (rather than the limit) bounds it. A nil budget is unlimited.
+func (b *Budget) effortLimit(workLimit int) (int, bool) {
+	effortLimit := 10 * workLimit
+	if b != nil && b.effort <= effortLimit {
+		return b.effort, true
+	}
+	return effortLimit, false
+}
+
+// spend Takes the given effort from the budget, or empties it and returns ErrBudgetExhausted if not enough is
+// left. A nil budget is unlimited.
+func (b *Budget) spend(effort int) error {
+	if b == nil {
+		return nil
+	}
+	if effort > b.effort {
+		return b.exhaust()
+	}
+	b.effort -= effort
+	return nil
+}
+
+// exhaust Empties the budget, after an operation ran out of it, and returns ErrBudgetExhausted.
+func (b *Budget) exhaust() error {
+	b.effort = 0
+	return ErrBudgetExhausted
+}
+
+// Determinize Determinizes the given automaton, spending at most the remaining work of this budget.
+func (b *Budget) Determinize(a *Automaton) (*Automaton, error) {
+	return determinizeBudget(a, b.Remaining(), b)
+}
+
+// Minimize Minimizes (and determinizes) the given automaton, spending at most the remaining work of this
+// budget. Besides determinizing, minimizing costs one unit of effort (a tenth of a unit of work) per state.
+func (b *Budget) Minimize(a *Automaton) (*Automaton, error) {
+	return minimize(a, b.Remaining(), b)
+}
+
+// Complement Returns a (deterministic) automaton accepting the strings the given automaton does not accept,
+// spending at most the remaining work of this budget.
+func (b *Budget) Complement(a *Automaton) (*Automaton, error) {
+	return complementBudget(a, b.Remaining(), b)
+}
@@ -0,0 +1,64 @@
+package automaton
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBudget(t *testing.T) {
+	re, err := NewRegExp("(x|y)*x(x|y){6}|[a-c]*a[a-c]{4}")
+	assert.Nil(t, err)
+
+	budget := NewBudget(DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Equal(t, DEFAULT_DETERMINIZE_WORK_LIMIT, budget.Remaining())
+	a, err := re.ToAutomaton(WithBudget(budget))
+	assert.Nil(t, err)
+	spent := DEFAULT_DETERMINIZE_WORK_LIMIT - budget.Remaining()
+	assert.Greater(t, spent, 0)
+	assert.True(t, Run(a, "xyyyyyy"))
+
+	// Exactly the work spent is enough again, less is not, even though every step is within the work limit:
+	_, err = re.ToAutomaton(WithBudget(NewBudget(spent + 1)))
+	assert.Nil(t, err)
+	budget = NewBudget(spent / 2)
+	_, err = re.ToAutomaton(WithBudget(budget))
+	assert.True(t, errors.Is(err, ErrBudgetExhausted), err)
+	assert.Equal(t, 0, budget.Remaining())
+	_, err = re.ToAutomaton()
+	assert.Nil(t, err)
+
+	t.Run("testShared", func(t *testing.T) {
+		budget := NewBudget(spent + spent/2)
+		_, err := re.ToAutomaton(WithBudget(budget))
+		assert.Nil(t, err)
+		_, err = re.ToAutomaton(WithBudget(budget))
+		assert.True(t, errors.Is(err, ErrBudgetExhausted), err)
+	})
+
+	t.Run("testOperations", func(t *testing.T) {
+		nfa, err := NewRegExp("(a|b)*a(a|b){3}")
+		assert.Nil(t, err)
+		n, err := nfa.ToAutomaton()
+		assert.Nil(t, err)
+		n, err = concatenate(n, n)
+		assert.Nil(t, err)
+		assert.False(t, n.IsDeterministic())
+
+		budget := NewBudget(DEFAULT_DETERMINIZE_WORK_LIMIT)
+		d, err := budget.Determinize(n)
+		assert.Nil(t, err)
+		assert.True(t, d.IsDeterministic())
+		m, err := budget.Minimize(n)
+		assert.Nil(t, err)
+		c, err := budget.Complement(n)
+		assert.Nil(t, err)
+		assert.True(t, Run(m, "aaaaaaaa"))
+		assert.False(t, Run(c, "aaaaaaaa"))
+		assert.Less(t, budget.Remaining(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+
+		_, err = NewBudget(1).Minimize(n)
+		assert.True(t, errors.Is(err, ErrBudgetExhausted), err)
+	})
+}
@@ -1,6 +1,12 @@
 package automaton
 
-import "github.com/bits-and-blooms/bitset"
+import (
+	"maps"
+	"slices"
+	"sync"
+
+	"github.com/bits-and-blooms/bitset"
+)
 
 // Builder Records new states and transitions and then finish creates the Automaton. Use this
 // when you cannot create the Automaton directly because it's too restrictive to have to add all transitions
@@ -8,8 +14,11 @@ import "github.com/bits-and-blooms/bitset"
 type Builder struct {
 	nextState   int
 	isAccept    *bitset.BitSet
-	transitions []int
+	transitions []builderTransition
 	//nextTransition int
+
+	// Labels of states, see Automaton.SetStateLabel.
+	stateLabels map[int]string
 }
 
 func NewBuilder() *Builder {
@@ -20,11 +29,50 @@ func NewBuilderV1(numStates, numTransitions int) *Builder {
 	return &Builder{
 		nextState:   0,
 		isAccept:    bitset.New(uint(numStates)),
-		transitions: make([]int, 0, 4*numTransitions),
+		transitions: make([]builderTransition, 0, numTransitions),
 		//nextTransition: 0,
 	}
 }
 
+// Reset Removes all states and transitions, keeping the allocated storage, so the builder can be reused for
+// another automaton.
+func (r *Builder) Reset() {
+	r.nextState = 0
+	r.isAccept.ClearAll()
+	r.transitions = r.transitions[:0]
+	r.stateLabels = nil
+}
+
+// TransitionCapacity How many transitions the builder can hold before it has to grow its storage.
+func (r *Builder) TransitionCapacity() int {
+	return cap(r.transitions)
+}
+
+// maxPooledTransitions Builders that grew beyond this many transitions are not returned to builderPool, so
+// one huge automaton doesn't pin its memory.
+const maxPooledTransitions = 1 << 16
+
+// builderPool Builders reused by operations, see getBuilder and putBuilder.
+var builderPool = sync.Pool{
+	New: func() any {
+		return NewBuilder()
+	},
+}
+
+// getBuilder Returns an empty builder from the pool. Return it with putBuilder once the automaton it built
+// is finished.
+func getBuilder() *Builder {
+	return builderPool.Get().(*Builder)
+}
+
+func putBuilder(b *Builder) {
+	if b.TransitionCapacity() > maxPooledTransitions || b.isAccept.Len() > 4*maxPooledTransitions {
+		return
+	}
+	b.Reset()
+	builderPool.Put(b)
+}
+
 func (r *Builder) CreateState() int {
 	res := r.nextState
 	r.nextState++
@@ -35,6 +83,11 @@ func (r *Builder) SetAccept(state int, accept bool) {
 	r.isAccept.SetTo(uint(state), accept)
 }
 
+// SetStateLabel Attaches a debugging label to the state, see Automaton.SetStateLabel.
+func (r *Builder) SetStateLabel(state int, label string) {
+	r.stateLabels = setStateLabel(r.stateLabels, state, label)
+}
+
 func (r *Builder) Copy(other *Automaton) {
 	offset := r.GetNumStates()
 	otherNumStates := other.GetNumStates()
@@ -54,13 +107,15 @@ func (r *Builder) Copy(other *Automaton) {
 	}
 }
 
-// CopyStates Copies over all states from other.
+// CopyStates Copies over all states from other, with their labels.
 func (r *Builder) CopyStates(other *Automaton) {
+	offset := r.GetNumStates()
 	otherNumStates := other.GetNumStates()
 	for s := 0; s < otherNumStates; s++ {
 		newState := r.CreateState()
 		r.SetAccept(newState, other.IsAccept(s))
 	}
+	r.stateLabels = copyStateLabels(r.stateLabels, other.stateLabels, offset)
 }
 
 func (r *Builder) AddTransitionLabel(source, dest, label int) {
@@ -71,7 +126,7 @@ func (r *Builder) AddTransition(source, dest, min, max int) {
 	//if len(r.transitions) < r.nextTransition+4 {
 	//	r.transitions = append(r.transitions, make([]int, 4)...)
 	//}
-	r.transitions = append(r.transitions, source, dest, min, max)
+	r.transitions = append(r.transitions, builderTransition{source, dest, min, max})
 	//r.transitions[r.nextTransition] = source
 	//r.nextTransition++
 	//r.transitions[r.nextTransition] = dest
@@ -82,10 +137,24 @@ func (r *Builder) AddTransition(source, dest, min, max int) {
 	//r.nextTransition++
 }
 
+// AddTransitions Add a batch of transitions, given as consecutive (source, dest, min, max) quadruples, in any
+// order. The whole batch is validated before anything is added, so on error the builder is unchanged.
+func (r *Builder) AddTransitions(transitions []int) error {
+	if err := checkTransitions(transitions, r.nextState); err != nil {
+		return err
+	}
+	r.transitions = slices.Grow(r.transitions, len(transitions)/4)
+	for i := 0; i < len(transitions); i += 4 {
+		r.transitions = append(r.transitions,
+			builderTransition{transitions[i], transitions[i+1], transitions[i+2], transitions[i+3]})
+	}
+	return nil
+}
+
 func (r *Builder) Finish() *Automaton {
 	// Create automaton with the correct size.
 	numStates := r.nextState
-	numTransitions := len(r.transitions) / 4
+	numTransitions := len(r.transitions)
 	a := NewAutomatonV1(numStates, numTransitions)
 
 	// Create all states.
@@ -95,15 +164,9 @@ func (r *Builder) Finish() *Automaton {
 	}
 
 	// Create all transitions
-	r.sort(0, numTransitions)
-	for upto := 0; upto < len(r.transitions); upto += 4 {
-		a.AddTransition(r.transitions[upto],
-			r.transitions[upto+1],
-			r.transitions[upto+2],
-			r.transitions[upto+3])
-	}
-
-	a.FinishState()
+	r.sort()
+	a.addSortedTransitions(r.transitions)
+	a.stateLabels = maps.Clone(r.stateLabels)
 
 	return a
 }
@@ -112,10 +175,71 @@ func (r *Builder) GetNumStates() int {
 	return r.nextState
 }
 
+// GetNumTransitions How many transitions have been added so far. Duplicate transitions are counted as many
+// times as they were added; Finish merges them.
+func (r *Builder) GetNumTransitions() int {
+	return len(r.transitions)
+}
+
+// GetNumTransitionsWithState How many transitions have been added so far leaving the given state, counted
+// like GetNumTransitions. This scans all transitions.
+func (r *Builder) GetNumTransitionsWithState(state int) int {
+	count := 0
+	for _, t := range r.transitions {
+		if t.source == state {
+			count++
+		}
+	}
+	return count
+}
+
+// GetNumAcceptStates How many states are currently marked as accept states.
+func (r *Builder) GetNumAcceptStates() int {
+	return int(r.isAccept.Count())
+}
+
+// GetTransition Fill the provided Transition with the index'th transition added so far, in the order they were
+// added; index must be less than GetNumTransitions.
+func (r *Builder) GetTransition(index int, t *Transition) {
+	tr := r.transitions[index]
+	t.Source, t.Dest, t.Min, t.Max = tr.source, tr.dest, tr.min, tr.max
+}
+
+// Append Copies all states and transitions of the given automaton into this builder, like Copy, and returns the
+// offset of its states: state s of a is state offset+s of the builder, so its initial state is offset.
+func (r *Builder) Append(a *Automaton) int {
+	offset := r.GetNumStates()
+	r.Copy(a)
+	return offset
+}
+
+// Connect Adds an epsilon transition from each of the given states to toState: they get copies of the
+// transitions leaving toState, and become accept states if it is one. As with AddEpsilon, only the transitions
+// added so far are copied, so toState must be complete, e.g. the initial state of an automaton just appended
+// with Append; when chaining automata, connect the last ones first. Connecting a new initial state to several
+// appended automata unions them; connecting the accept states of one appended automaton to the initial state of
+// the next concatenates them, once they are no longer accept states themselves (see SetAccept).
+func (r *Builder) Connect(fromStates []int, toState int) {
+	// Scan the transitions added before, so connecting toState to itself doesn't copy its copies:
+	n := len(r.transitions)
+	for upto := 0; upto < n; upto++ {
+		if t := r.transitions[upto]; t.source == toState {
+			for _, from := range fromStates {
+				r.AddTransition(from, t.dest, t.min, t.max)
+			}
+		}
+	}
+	if r.IsAccept(toState) {
+		for _, from := range fromStates {
+			r.SetAccept(from, true)
+		}
+	}
+}
+
 func (r *Builder) AddEpsilon(source, dest int) {
-	for upto := 0; upto < len(r.transitions); upto += 4 {
-		if r.transitions[upto] == dest {
-			r.AddTransition(source, r.transitions[upto+1], r.transitions[upto+2], r.transitions[upto+3])
+	for upto := 0; upto < len(r.transitions); upto++ {
+		if t := r.transitions[upto]; t.source == dest {
+			r.AddTransition(source, t.dest, t.min, t.max)
 		}
 	}
 	if r.IsAccept(dest) {
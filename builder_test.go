@@ -0,0 +1,195 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder_Introspection(t *testing.T) {
+	b := NewBuilder()
+	s0 := b.CreateState()
+	s1 := b.CreateState()
+	s2 := b.CreateState()
+	b.SetAccept(s1, true)
+	b.SetAccept(s2, true)
+
+	b.AddTransition(s1, s2, 'c', 'd')
+	b.AddTransition(s0, s1, 'a', 'b')
+	b.AddTransition(s0, s1, 'a', 'b')
+	b.AddTransitionLabel(s0, s2, 'x')
+
+	assert.Equal(t, 3, b.GetNumStates())
+	assert.Equal(t, 4, b.GetNumTransitions())
+	assert.Equal(t, 3, b.GetNumTransitionsWithState(s0))
+	assert.Equal(t, 1, b.GetNumTransitionsWithState(s1))
+	assert.Equal(t, 0, b.GetNumTransitionsWithState(s2))
+	assert.Equal(t, 2, b.GetNumAcceptStates())
+
+	tr := NewTransition()
+	b.GetTransition(0, tr)
+	assert.Equal(t, []int{s1, s2, 'c', 'd'}, []int{tr.Source, tr.Dest, tr.Min, tr.Max})
+	b.GetTransition(3, tr)
+	assert.Equal(t, []int{s0, s2, 'x', 'x'}, []int{tr.Source, tr.Dest, tr.Min, tr.Max})
+
+	// Finish merges the duplicate transition:
+	a := b.Finish()
+	assert.Equal(t, 3, a.GetNumTransitions())
+	assert.Equal(t, 2, a.GetNumTransitionsWithState(s0))
+	assert.Equal(t, 1, a.GetNumTransitionsWithState(s1))
+	assert.Equal(t, 0, a.GetNumTransitionsWithState(s2))
+}
+
+// Returns random (source, dest, min, max) quadruples, with duplicates and adjacent ranges to the same dest.
+func randomBuilderTransitions(r *rand.Rand, numStates, numTransitions int) []int {
+	transitions := make([]int, 0, 4*numTransitions)
+	for i := 0; i < numTransitions; i++ {
+		min := r.Intn(40)
+		transitions = append(transitions, r.Intn(numStates), r.Intn(numStates), min, min+r.Intn(3))
+	}
+	return transitions
+}
+
+func TestBuilder_Finish(t *testing.T) {
+	r := rand.New(rand.NewSource(1566))
+	for iter := 0; iter < 100; iter++ {
+		numStates := 1 + r.Intn(10)
+		transitions := randomBuilderTransitions(r, numStates, r.Intn(60))
+
+		b := NewBuilder()
+		expected := NewAutomaton()
+		for s := 0; s < numStates; s++ {
+			accept := r.Intn(3) == 0
+			b.SetAccept(b.CreateState(), accept)
+			expected.SetAccept(expected.CreateState(), accept)
+		}
+		assert.Nil(t, b.AddTransitions(transitions))
+		// The reference adds the transitions of each state one by one, in the order they were recorded:
+		for s := 0; s < numStates; s++ {
+			for i := 0; i < len(transitions); i += 4 {
+				if transitions[i] == s {
+					assert.Nil(t, expected.AddTransition(s, transitions[i+1], transitions[i+2], transitions[i+3]))
+				}
+			}
+		}
+		expected.FinishState()
+
+		a := b.Finish()
+		assert.Nil(t, a.Validate())
+		assert.Equal(t, expected.IsDeterministic(), a.IsDeterministic())
+		if !assert.True(t, StructurallyEqual(expected, a)) {
+			return
+		}
+	}
+}
+
+func TestBuilder_Reset(t *testing.T) {
+	b := NewBuilder()
+	for i := 0; i < 3; i++ {
+		b.CreateState()
+	}
+	b.SetAccept(2, true)
+	b.AddTransition(0, 1, 'a', 'a')
+	b.AddTransition(1, 2, 'b', 'c')
+	first := b.Finish()
+
+	capacity := b.TransitionCapacity()
+	b.Reset()
+	assert.Equal(t, 0, b.GetNumStates())
+	assert.Equal(t, 0, b.GetNumTransitions())
+	assert.Equal(t, 0, b.GetNumAcceptStates())
+	assert.Equal(t, capacity, b.TransitionCapacity())
+
+	b.CreateState()
+	b.CreateState()
+	b.SetAccept(1, true)
+	b.AddTransition(0, 1, 'x', 'x')
+	second := b.Finish()
+	assert.Nil(t, second.Validate())
+	assert.True(t, Run(second, "x"))
+	assert.False(t, second.IsAccept(0))
+
+	// the first automaton doesn't share storage with the builder:
+	assert.True(t, Run(first, "ab"))
+	assert.False(t, Run(first, "x"))
+}
+
+func BenchmarkBuilder_Finish(b *testing.B) {
+	r := rand.New(rand.NewSource(1566))
+	const numStates = 2000
+	transitions := randomBuilderTransitions(r, numStates, 20*numStates)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		builder := NewBuilderV1(numStates, len(transitions)/4)
+		for s := 0; s < numStates; s++ {
+			builder.CreateState()
+		}
+		if err := builder.AddTransitions(transitions); err != nil {
+			b.Fatal(err)
+		}
+		builder.Finish()
+	}
+}
+
+func TestBuilder_AppendConnect(t *testing.T) {
+	t.Run("testConcatenate", func(t *testing.T) {
+		a1 := MustNewRegExp("ab|c").MustToAutomaton()
+		a2 := MustNewRegExp("d*").MustToAutomaton()
+		a3 := MustNewRegExp("e").MustToAutomaton()
+
+		b := NewBuilder()
+		assert.Equal(t, 0, b.Append(a1))
+		offset2 := b.Append(a2)
+		assert.Equal(t, a1.GetNumStates(), offset2)
+		offset3 := b.Append(a3)
+
+		// Connect the last automata first, so the epsilons of a2 are in place when a1 is connected to it:
+		for _, s := range acceptStates(a2, offset2) {
+			b.SetAccept(s, false)
+		}
+		b.Connect(acceptStates(a2, offset2), offset3)
+		for _, s := range acceptStates(a1, 0) {
+			b.SetAccept(s, false)
+		}
+		b.Connect(acceptStates(a1, 0), offset2)
+		assert.False(t, b.IsAccept(0))
+
+		diff, err := DiffLanguages(b.Finish(), MustNewRegExp("(ab|c)d*e").MustToAutomaton(), 3)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty(), diff.String())
+	})
+
+	t.Run("testUnion", func(t *testing.T) {
+		b := NewBuilder()
+		initial := b.CreateState()
+		var offsets []int
+		for _, pattern := range []string{"if", "[a-z]+", "[0-9]+"} {
+			offsets = append(offsets, b.Append(MustNewRegExp(pattern).MustToAutomaton()))
+		}
+		for _, offset := range offsets {
+			b.Connect([]int{initial}, offset)
+		}
+
+		diff, err := DiffLanguages(b.Finish(), MustNewRegExp("if|[a-z]+|[0-9]+").MustToAutomaton(), 3)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty(), diff.String())
+	})
+
+	t.Run("testSelf", func(t *testing.T) {
+		// Connecting a state to itself only duplicates its transitions:
+		b := NewBuilder()
+		s0 := b.CreateState()
+		s1 := b.CreateState()
+		b.SetAccept(s1, true)
+		b.AddTransition(s0, s1, 'a', 'a')
+		b.Connect([]int{s0, s1}, s0)
+		assert.Equal(t, 3, b.GetNumTransitions())
+		assert.False(t, b.IsAccept(s0))
+
+		a := b.Finish()
+		assert.Equal(t, 2, a.GetNumTransitions())
+		assert.True(t, runNFA(a, "aaa"))
+		assert.False(t, runNFA(a, ""))
+	})
+}
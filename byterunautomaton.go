@@ -1,34 +1,90 @@
 package automaton
 
-// ByteRunAutomaton Automaton representation for matching UTF-8 byte[].
+const (
+	// DEFAULT_PAIR_TABLE_MAX_BYTES Default memory budget for the pair-byte table of a ByteRunAutomaton.
+	DEFAULT_PAIR_TABLE_MAX_BYTES = 16 << 20
+)
+
+// ByteRunAutomaton Automaton representation for matching UTF-8 byte[]. Like RunAutomaton it may be shared by
+// goroutines, as long as BuildPairTable is called before it is shared.
 type ByteRunAutomaton struct {
 	*RunAutomaton
+
+	// Optional table stepping two bytes at once: pairs[state<<16|b1<<8|b2], see BuildPairTable.
+	pairs []int32
 }
 
-func NewByteRunAutomaton(a *Automaton, isBinary bool, determinizeWorkLimit int) *ByteRunAutomaton {
+// NewByteRunAutomaton Builds a ByteRunAutomaton from the given automaton. If isBinary is true its labels are
+// taken as bytes and an error is returned if any label is above 255 (see IsBinaryAutomaton); otherwise the
+// automaton is taken over code points and converted to match their UTF-8 encoding (see UTF32ToUTF8).
+func NewByteRunAutomaton(a *Automaton, isBinary bool, determinizeWorkLimit int) (*ByteRunAutomaton, error) {
 	var auto *Automaton
 
 	if isBinary {
+		if err := checkBinary(a); err != nil {
+			return nil, err
+		}
 		auto = a
 	} else {
-
+		utf8, err := UTF32ToUTF8(a)
+		if err != nil {
+			return nil, err
+		}
+		auto = utf8
 	}
 
 	return &ByteRunAutomaton{
-		NewRunAutomaton(auto, 256, determinizeWorkLimit),
-	}
+		RunAutomaton: NewRunAutomaton(auto, 256, determinizeWorkLimit),
+	}, nil
 }
 
-func (a *Automaton) NewByteRunAutomaton() *ByteRunAutomaton {
-	return &ByteRunAutomaton{
-		NewRunAutomaton(a, 256, 10000),
+// NewByteRunAutomaton Builds a ByteRunAutomaton from this binary automaton, see NewByteRunAutomaton.
+func (a *Automaton) NewByteRunAutomaton() (*ByteRunAutomaton, error) {
+	return NewByteRunAutomaton(a, true, DefaultWorkLimit())
+}
+
+// BuildPairTable Builds a transition table indexed by state and two consecutive bytes, so Run consumes two
+// bytes per step. The table takes 256KB per state; it is only built if it fits in maxBytes, and the return
+// value tells whether it was built. It must not be called while other goroutines use the automaton.
+func (r *ByteRunAutomaton) BuildPairTable(maxBytes int) bool {
+	if r.size > maxBytes/(4<<16) {
+		return false
+	}
+
+	pairs := make([]int32, r.size<<16)
+	for state := 0; state < r.size; state++ {
+		for b1 := 0; b1 < 256; b1++ {
+			base := state<<16 | b1<<8
+			p := r.Step(state, b1)
+			for b2 := 0; b2 < 256; b2++ {
+				if p == -1 {
+					pairs[base|b2] = -1
+				} else {
+					pairs[base|b2] = int32(r.Step(p, b2))
+				}
+			}
+		}
 	}
+	r.pairs = pairs
+	return true
 }
 
 // Run Returns true if the given byte array is accepted by this automaton
 func (r *ByteRunAutomaton) Run(s []byte) bool {
+	if counter := r.counter.Load(); counter != nil {
+		return r.runCounting(s, counter)
+	}
 	p := 0
-	for i := 0; i < len(s); i++ {
+	i := 0
+	if r.pairs != nil {
+		for ; i+1 < len(s); i += 2 {
+			p = int(r.pairs[p<<16|int(s[i])<<8|int(s[i+1])])
+			if p == -1 {
+				return false
+			}
+		}
+	}
+	for ; i < len(s); i++ {
 		p = r.Step(p, int(s[i]&0xFF))
 		if p == -1 {
 			return false
@@ -36,3 +92,16 @@ func (r *ByteRunAutomaton) Run(s []byte) bool {
 	}
 	return r.accept[p]
 }
+
+// runCounting Runs the automaton one byte at a time, counting the visited states.
+func (r *ByteRunAutomaton) runCounting(s []byte, counter *StateCounter) bool {
+	p := 0
+	counter.Visit(p)
+	for _, b := range s {
+		if p = r.Step(p, int(b)); p == -1 {
+			return false
+		}
+		counter.Visit(p)
+	}
+	return r.accept[p]
+}
@@ -0,0 +1,124 @@
+package automaton
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newLogScanner(tb testing.TB) *ByteRunAutomaton {
+	re, err := NewRegExp("@(ERROR|WARN) [0-9]+@")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	a, err := re.ToAutomaton()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	// Scan raw bytes: "@" matches any byte rather than any code point.
+	anyByte, err := MakeAnyBinary()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if a, err = intersection(a, anyByte); err != nil {
+		tb.Fatal(err)
+	}
+	r, err := NewByteRunAutomaton(a, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return r
+}
+
+func newLogLines(r *rand.Rand, n int) [][]byte {
+	levels := []string{"INFO", "DEBUG", "WARN", "ERROR"}
+	lines := make([][]byte, n)
+	for i := range lines {
+		b := new(bytes.Buffer)
+		fmt.Fprintf(b, "2025-06-18T12:%02d:%02d %s %d request served path=/api/v1/items/%d status=200",
+			r.Intn(60), r.Intn(60), levels[r.Intn(len(levels))], r.Intn(1000), r.Intn(100000))
+		lines[i] = b.Bytes()
+	}
+	return lines
+}
+
+func TestByteRunAutomaton_BuildPairTable(t *testing.T) {
+	r := newLogScanner(t)
+	assert.False(t, r.BuildPairTable(1024))
+	assert.Nil(t, r.pairs)
+
+	lines := newLogLines(rand.New(rand.NewSource(1526)), 200)
+	lines = append(lines, []byte(""), []byte("E"), []byte("ERROR 1"), []byte("xERROR 12"), []byte("WARN x"))
+	expected := make([]bool, len(lines))
+	for i, line := range lines {
+		expected[i] = r.Run(line)
+	}
+	assert.Contains(t, expected, true)
+	assert.Contains(t, expected, false)
+
+	assert.True(t, r.BuildPairTable(DEFAULT_PAIR_TABLE_MAX_BYTES))
+	for i, line := range lines {
+		assert.Equal(t, expected[i], r.Run(line), "%q", line)
+	}
+}
+
+func BenchmarkByteRunAutomaton_Run(b *testing.B) {
+	lines := newLogLines(rand.New(rand.NewSource(1526)), 1000)
+
+	b.Run("single", func(b *testing.B) {
+		r := newLogScanner(b)
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Run(lines[i%len(lines)])
+		}
+	})
+
+	b.Run("pairs", func(b *testing.B) {
+		r := newLogScanner(b)
+		if !r.BuildPairTable(DEFAULT_PAIR_TABLE_MAX_BYTES) {
+			b.Skip("pair table exceeds budget")
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r.Run(lines[i%len(lines)])
+		}
+	})
+}
+
+func TestNewByteRunAutomaton_Binary(t *testing.T) {
+	binary, err := MakeBinary([]byte("caf\xc3"))
+	assert.Nil(t, err)
+	assert.True(t, IsBinaryAutomaton(binary))
+	unicodeAutomaton, err := MakeString("日本")
+	assert.Nil(t, err)
+	assert.False(t, IsBinaryAutomaton(unicodeAutomaton))
+	// Latin-1 labels are bytes too, whatever they are meant to be:
+	latin1, err := MakeString("café")
+	assert.Nil(t, err)
+	assert.True(t, IsBinaryAutomaton(latin1))
+	assert.True(t, IsBinaryAutomaton(NewAutomaton()))
+
+	t.Run("binary", func(t *testing.T) {
+		r, err := NewByteRunAutomaton(binary, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, r.Run([]byte("caf\xc3")))
+
+		_, err = NewByteRunAutomaton(unicodeAutomaton, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.EqualError(t, err, "automaton is not binary: state 0 has a transition [26085, 26085] above byte 255")
+	})
+
+	t.Run("unicode", func(t *testing.T) {
+		r, err := NewByteRunAutomaton(unicodeAutomaton, false, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, r.Run([]byte("日本")))
+		assert.False(t, r.Run([]byte("日")))
+	})
+
+	t.Run("compiled", func(t *testing.T) {
+		_, err := NewCompiledAutomaton(unicodeAutomaton, nil, true, DEFAULT_DETERMINIZE_WORK_LIMIT, true)
+		assert.ErrorContains(t, err, "automaton is not binary")
+	})
+}
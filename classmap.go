@@ -0,0 +1,116 @@
+package automaton
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"unicode"
+)
+
+const maxBMP = 0xFFFF
+
+// ClassMap Maps code points to label equivalence classes: two code points are in the same class if every
+// transition of the automata the map was built from either accepts both or rejects both. Classes are numbered
+// in order of their first code point, so class 0 always starts at code point 0.
+//
+// Code points in the BMP are looked up in a dense []uint16 table; supplementary code points are resolved by
+// binary search over the (usually very small) exceptions table of class start points above the BMP.
+// Tokenizers can classify every input character once and then drive several automata with class ids.
+type ClassMap struct {
+	// Class start points, sorted.
+	points []int
+
+	// bmp[c] is the class of code point c, for c <= 0xFFFF.
+	bmp []uint16
+
+	// Class start points above the BMP together with their classes; the class of a supplementary code
+	// point c is supClasses[i] for the largest i with supPoints[i] <= c.
+	supPoints  []int
+	supClasses []uint16
+}
+
+// NewClassMap Computes the label equivalence classes shared by the given automata from their interval start
+// points. Returns an error if there are more classes than fit in an uint16.
+func NewClassMap(automata ...*Automaton) (*ClassMap, error) {
+	points := NewAlphabetPartition(automata...).points
+
+	if len(points) > math.MaxUint16+1 {
+		return nil, errors.New("too many label classes")
+	}
+
+	c := &ClassMap{
+		points: points,
+		bmp:    make([]uint16, maxBMP+1),
+	}
+
+	class := 0
+	for cp := 0; cp <= maxBMP; cp++ {
+		if class+1 < len(points) && cp == points[class+1] {
+			class++
+		}
+		c.bmp[cp] = uint16(class)
+	}
+
+	// The class of the first supplementary code point may have started inside the BMP:
+	c.supPoints = append(c.supPoints, maxBMP+1)
+	c.supClasses = append(c.supClasses, uint16(class))
+	for class++; class < len(points); class++ {
+		if points[class] == maxBMP+1 {
+			c.supClasses[0] = uint16(class)
+			continue
+		}
+		c.supPoints = append(c.supPoints, points[class])
+		c.supClasses = append(c.supClasses, uint16(class))
+	}
+	return c, nil
+}
+
+// NumClasses Returns the number of label classes.
+func (c *ClassMap) NumClasses() int {
+	return len(c.points)
+}
+
+// Class Returns the class of the given code point.
+func (c *ClassMap) Class(codepoint int) int {
+	if codepoint <= maxBMP {
+		return int(c.bmp[codepoint])
+	}
+	i := sort.SearchInts(c.supPoints, codepoint+1) - 1
+	return int(c.supClasses[i])
+}
+
+// Start Returns the smallest code point of the given class.
+func (c *ClassMap) Start(class int) int {
+	return c.points[class]
+}
+
+// End Returns the largest code point of the given class.
+func (c *ClassMap) End(class int) int {
+	if class+1 < len(c.points) {
+		return c.points[class+1] - 1
+	}
+	return unicode.MaxRune
+}
+
+// BMP Returns the class of every code point of the BMP, indexed by code point. The slice should not be
+// modified by the caller.
+func (c *ClassMap) BMP() []uint16 {
+	return c.bmp
+}
+
+// Supplementary Returns the exceptions table for code points above the BMP: a code point c belongs to
+// classes[i] for the largest i with points[i] <= c. The slices should not be modified by the caller.
+func (c *ClassMap) Supplementary() (points []int, classes []uint16) {
+	return c.supPoints, c.supClasses
+}
+
+// ClassesFor Returns a table translating the classes of this map into the character classes of the given
+// RunAutomaton, for use with RunAutomaton.StepClass. The RunAutomaton must have been built from one of the
+// automata this map was computed from (or from an automaton with a subset of its start points).
+func (c *ClassMap) ClassesFor(r *RunAutomaton) []int {
+	table := make([]int, len(c.points))
+	for class, start := range c.points {
+		table[class] = r.GetCharClass(start)
+	}
+	return table
+}
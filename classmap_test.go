@@ -0,0 +1,62 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassMap(t *testing.T) {
+	letters, err := defaultAutomata.MakeCharRange('a', 'z')
+	assert.Nil(t, err)
+	emoji, err := defaultAutomata.MakeCharRange(0x1F600, 0x1F64F)
+	assert.Nil(t, err)
+
+	c, err := NewClassMap(letters, emoji)
+	assert.Nil(t, err)
+
+	// [0,'a'), ['a','z'], ('z',0x1F600), [0x1F600,0x1F64F], (0x1F64F,max]
+	assert.Equal(t, 5, c.NumClasses())
+	assert.Equal(t, 0, c.Class('0'))
+	assert.Equal(t, 1, c.Class('a'))
+	assert.Equal(t, 1, c.Class('z'))
+	assert.Equal(t, 2, c.Class('{'))
+	assert.Equal(t, 2, c.Class(0xFFFF))
+	assert.Equal(t, 2, c.Class(0x10000))
+	assert.Equal(t, 3, c.Class(0x1F600))
+	assert.Equal(t, 4, c.Class(0x1F650))
+	assert.Equal(t, 4, c.Class(0x10FFFF))
+
+	assert.Equal(t, 'a', rune(c.Start(1)))
+	assert.Equal(t, 'z', rune(c.End(1)))
+	assert.Equal(t, 0x10FFFF, c.End(4))
+
+	assert.Len(t, c.BMP(), 0x10000)
+	points, classes := c.Supplementary()
+	assert.Equal(t, []int{0x10000, 0x1F600, 0x1F650}, points)
+	assert.Equal(t, []uint16{2, 3, 4}, classes)
+
+	t.Run("testStepClass", func(t *testing.T) {
+		word, err := defaultAutomata.MakeString("ab")
+		assert.Nil(t, err)
+		c, err := NewClassMap(word, letters)
+		assert.Nil(t, err)
+
+		r := NewRunAutomaton(word, 0x110000, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		table := c.ClassesFor(r)
+
+		run := func(s string) bool {
+			state := 0
+			for _, ch := range s {
+				state = r.StepClass(state, table[c.Class(int(ch))])
+				if state == -1 {
+					return false
+				}
+			}
+			return r.IsAccept(state)
+		}
+		assert.True(t, run("ab"))
+		assert.False(t, run("ac"))
+		assert.False(t, run("a"))
+	})
+}
@@ -0,0 +1,286 @@
+// Command automaton Compiles and inspects patterns with the automaton package, to debug them outside of an
+// application.
+//
+// Usage:
+//
+//	automaton compile [flags] pattern           print the size and shape of the automaton of a pattern
+//	automaton dot [flags] pattern               print the automaton of a pattern in Graphviz DOT format
+//	automaton test [flags] pattern [string...]  test strings (or the lines of stdin) against a pattern
+//	automaton convert -from f -to f [file]      convert an automaton (or pattern) between formats
+//
+// Formats are regexp (input only), json, brics (the dk.brics text format) and dot (output only). test exits
+// with status 1 if some string does not match, and every command exits with status 2 on errors.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/geange/automaton"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+const usage = `usage:
+	automaton compile [flags] pattern
+	automaton dot [flags] pattern
+	automaton test [flags] pattern [string...]
+	automaton convert -from regexp|json|brics -to json|brics|dot [file]
+`
+
+// errMismatch Returned by test when some string does not match.
+var errMismatch = errors.New("some strings do not match")
+
+// run Runs the command with the given arguments and returns its exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func(*command) error{
+		"compile": compile,
+		"dot":     dot,
+		"test":    test,
+		"convert": convert,
+	}
+	fn, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n%s", args[0], usage)
+		return 2
+	}
+
+	c := &command{
+		flags:  flag.NewFlagSet(args[0], flag.ContinueOnError),
+		stdin:  stdin,
+		stdout: stdout,
+	}
+	c.flags.SetOutput(stderr)
+	c.flags.BoolVar(&c.minimize, "minimize", false, "minimize the automaton")
+	c.flags.BoolVar(&c.binary, "binary", false, "convert the automaton to UTF-8 bytes")
+	c.flags.IntVar(&c.workLimit, "work-limit", automaton.DefaultWorkLimit(), "determinization work limit")
+	c.flags.IntVar(&c.maxStates, "max-states", automaton.DefaultMaxStates(),
+		"limit on the states of the automaton of a sub expression of the pattern, <= 0 for none")
+	c.flags.StringVar(&c.from, "from", "regexp", "input format of convert: regexp, json or brics")
+	c.flags.StringVar(&c.to, "to", "json", "output format of convert: json, brics or dot")
+
+	err := fn(c.parse(args[1:]))
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errMismatch):
+		return 1
+	case errors.Is(err, flag.ErrHelp):
+		return 2
+	default:
+		fmt.Fprintf(stderr, "automaton %s: %v\n", args[0], err)
+		return 2
+	}
+}
+
+// command The flags and arguments of a command.
+type command struct {
+	flags     *flag.FlagSet
+	minimize  bool
+	binary    bool
+	workLimit int
+	maxStates int
+	from, to  string
+	args      []string
+	err       error
+
+	stdin  io.Reader
+	stdout io.Writer
+}
+
+func (c *command) parse(args []string) *command {
+	c.err = c.flags.Parse(args)
+	c.args = c.flags.Args()
+	return c
+}
+
+// pattern Returns the pattern, the first argument, compiled with the flags.
+func (c *command) pattern() (*automaton.RegExp, *automaton.Automaton, error) {
+	if c.err != nil {
+		return nil, nil, c.err
+	}
+	if len(c.args) == 0 {
+		return nil, nil, errors.New("missing pattern")
+	}
+	re, err := automaton.NewRegExp(c.args[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := c.toAutomaton(re)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err = c.transform(a)
+	return re, a, err
+}
+
+// toAutomaton Compiles the pattern with the -work-limit and -max-states flags.
+func (c *command) toAutomaton(re *automaton.RegExp) (*automaton.Automaton, error) {
+	defer automaton.SetDefaultWorkLimit(automaton.SetDefaultWorkLimit(c.workLimit))
+	return re.ToAutomaton(automaton.WithMaxStates(c.maxStates))
+}
+
+// transform Applies the -minimize and -binary flags to the given automaton.
+func (c *command) transform(a *automaton.Automaton) (*automaton.Automaton, error) {
+	var err error
+	if c.minimize {
+		if a, err = automaton.Minimize(a, c.workLimit); err != nil {
+			return nil, err
+		}
+	}
+	if c.binary && a.Alphabet() != automaton.ALPHABET_BINARY {
+		if a, err = automaton.UTF32ToUTF8(a); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// compile Prints the size and shape of the automaton of a pattern.
+func compile(c *command) error {
+	re, a, err := c.pattern()
+	if err != nil {
+		return err
+	}
+	complexity := automaton.EstimateComplexity(re)
+	fmt.Fprintf(c.stdout, "pattern: %s\n", c.args[0])
+	fmt.Fprintf(c.stdout, "automaton: %s\n", a)
+	fmt.Fprintln(c.stdout, automaton.Profile(a))
+	fmt.Fprintf(c.stdout, "estimated states: %d (NFA), %d (DFA)\n", complexity.NFAStates, complexity.DFAStates)
+	return nil
+}
+
+// dot Prints the automaton of a pattern in DOT format.
+func dot(c *command) error {
+	_, a, err := c.pattern()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(c.stdout, a.ToDot())
+	return err
+}
+
+// test Tests the strings after the pattern, or the lines of stdin, against the pattern.
+func test(c *command) error {
+	_, a, err := c.pattern()
+	if err != nil {
+		return err
+	}
+	if !a.IsDeterministic() {
+		if a, err = automaton.Minimize(a, c.workLimit); err != nil {
+			return err
+		}
+	}
+	r, err := automaton.NewByteRunAutomaton(a, a.Alphabet() == automaton.ALPHABET_BINARY, c.workLimit)
+	if err != nil {
+		return err
+	}
+
+	mismatch := false
+	check := func(s string) {
+		result := "match"
+		if !r.Run([]byte(s)) {
+			result = "no match"
+			mismatch = true
+		}
+		fmt.Fprintf(c.stdout, "%s\t%q\n", result, s)
+	}
+	if len(c.args) > 1 {
+		for _, s := range c.args[1:] {
+			check(s)
+		}
+	} else {
+		scanner := bufio.NewScanner(c.stdin)
+		for scanner.Scan() {
+			check(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	if mismatch {
+		return errMismatch
+	}
+	return nil
+}
+
+// convert Reads an automaton, or a pattern, from the file argument or stdin and writes it in another format.
+func convert(c *command) error {
+	if c.err != nil {
+		return c.err
+	}
+	in := c.stdin
+	if len(c.args) > 0 {
+		f, err := os.Open(c.args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	var a *automaton.Automaton
+	switch c.from {
+	case "regexp":
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		re, err := automaton.NewRegExp(strings.TrimRight(string(data), "\r\n"))
+		if err != nil {
+			return err
+		}
+		if a, err = c.toAutomaton(re); err != nil {
+			return err
+		}
+	case "json":
+		data, err := io.ReadAll(in)
+		if err != nil {
+			return err
+		}
+		a = automaton.NewAutomaton()
+		if err := a.UnmarshalJSON(data); err != nil {
+			return err
+		}
+	case "brics":
+		var err error
+		if a, err = automaton.ReadBricsText(in); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown input format %q", c.from)
+	}
+
+	a, err := c.transform(a)
+	if err != nil {
+		return err
+	}
+	switch c.to {
+	case "json":
+		data, err := automaton.EncodeJSON(a, automaton.WithJSONLabelStrings())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(c.stdout, "%s\n", data)
+		return err
+	case "brics":
+		return automaton.WriteBricsText(c.stdout, a)
+	case "dot":
+		_, err = io.WriteString(c.stdout, a.ToDot())
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q", c.to)
+	}
+}
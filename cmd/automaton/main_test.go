@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/geange/automaton"
+	"github.com/stretchr/testify/assert"
+)
+
+func runCommand(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("testCompile", func(t *testing.T) {
+		status, out, _ := runCommand("", "compile", "-minimize", "(ab|c)*d")
+		assert.Equal(t, 0, status)
+		assert.Contains(t, out, "pattern: (ab|c)*d\n")
+		assert.Contains(t, out, "automaton: 3 states (1 accept, 3 reachable), 4 transitions, deterministic, unicode")
+		assert.Contains(t, out, "out-degree histogram:")
+		assert.Contains(t, out, "estimated states:")
+	})
+
+	t.Run("testWorkLimit", func(t *testing.T) {
+		// The DFA has 2^17 states:
+		status, _, errOut := runCommand("", "compile", "-work-limit", "1000", "[ab]*a[ab]{16}")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, "too Complex To Determinize")
+
+		status, out, _ := runCommand("", "compile", "-work-limit", "1000000000", "-max-states", "0", "[ab]*a[ab]{16}")
+		assert.Equal(t, 0, status)
+		assert.Contains(t, out, "automaton: 131072 states")
+		assert.Equal(t, automaton.DEFAULT_DETERMINIZE_WORK_LIMIT, automaton.DefaultWorkLimit())
+
+		status, _, errOut = runCommand("[ab]*a[ab]{16}", "convert", "-work-limit", "1000", "-to", "brics")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, "too Complex To Determinize")
+	})
+
+	t.Run("testDot", func(t *testing.T) {
+		status, out, _ := runCommand("", "dot", "-binary", "é")
+		assert.Equal(t, 0, status)
+		assert.True(t, strings.HasPrefix(out, "digraph Automaton {"))
+		assert.Contains(t, out, `\\Uc3`)
+	})
+
+	t.Run("testTest", func(t *testing.T) {
+		status, out, _ := runCommand("", "test", "[a-c]+é?", "abé", "cc")
+		assert.Equal(t, 0, status)
+		assert.Equal(t, "match\t\"abé\"\nmatch\t\"cc\"\n", out)
+
+		status, out, _ = runCommand("a\nd\n", "test", "[a-c]+")
+		assert.Equal(t, 1, status)
+		assert.Equal(t, "match\t\"a\"\nno match\t\"d\"\n", out)
+	})
+
+	t.Run("testConvert", func(t *testing.T) {
+		status, json, _ := runCommand("x[0-9]\n", "convert", "-to", "json")
+		assert.Equal(t, 0, status)
+		assert.Contains(t, json, `"alphabet":"unicode"`)
+
+		status, brics, _ := runCommand(json, "convert", "-from", "json", "-to", "brics")
+		assert.Equal(t, 0, status)
+		assert.Contains(t, brics, "0-9 ->")
+
+		// Back from a file, to UTF-8:
+		path := filepath.Join(t.TempDir(), "a.txt")
+		assert.Nil(t, os.WriteFile(path, []byte(brics), 0o600))
+		status, out, _ := runCommand("", "convert", "-from", "brics", "-to", "json", "-binary", path)
+		assert.Equal(t, 0, status)
+		assert.Contains(t, out, `"alphabet":"binary"`)
+	})
+
+	t.Run("testErrors", func(t *testing.T) {
+		status, _, errOut := runCommand("")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, "usage:")
+
+		status, _, errOut = runCommand("", "bogus")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, `unknown command "bogus"`)
+
+		status, _, errOut = runCommand("", "compile", "a(?=b)")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, "automaton compile: lookahead")
+
+		status, _, errOut = runCommand("", "dot")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, "missing pattern")
+
+		status, _, errOut = runCommand("a", "convert", "-to", "png")
+		assert.Equal(t, 2, status)
+		assert.Contains(t, errOut, `unknown output format "png"`)
+
+		status, _, _ = runCommand("", "compile", "-nope", "a")
+		assert.Equal(t, 2, status)
+	})
+}
@@ -44,6 +44,13 @@ func NewCompiledAutomaton(automaton *Automaton, finite *atomic.Bool, simplify bo
 
 	this := &CompiledAutomaton{}
 
+	if isBinary {
+		// Labels above 255 would silently never match (or match garbage in the common prefix and suffix):
+		if err := checkBinary(automaton); err != nil {
+			return nil, err
+		}
+	}
+
 	if automaton.GetNumStates() == 0 {
 		automaton = NewAutomaton()
 		automaton.CreateState()
@@ -127,7 +134,11 @@ func NewCompiledAutomaton(automaton *Automaton, finite *atomic.Bool, simplify bo
 		binary = automaton
 	} else {
 		// Incoming automaton is unicode, and we must convert to UTF8 to match what's in the index:
-		// FIXME
+		utf8, err := UTF32ToUTF8(automaton)
+		if err != nil {
+			return nil, err
+		}
+		binary = utf8
 	}
 
 	// compute a common suffix for infinite DFAs, this is an optimization for "leading wildcard"
@@ -135,7 +146,7 @@ func NewCompiledAutomaton(automaton *Automaton, finite *atomic.Bool, simplify bo
 	if this.finite.Load() || automaton.GetNumStates()+automaton.GetNumTransitions() > 1000 {
 		this.commonSuffixRef = nil
 	} else {
-		suffix, err := getCommonSuffixBytesRef(binary)
+		suffix, err := getCommonSuffixBytesRef(binary, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -146,8 +157,14 @@ func NewCompiledAutomaton(automaton *Automaton, finite *atomic.Bool, simplify bo
 		}
 	}
 
-	// This will determinize the binary automaton for us:
-	this.runAutomaton = NewByteRunAutomaton(binary, true, determinizeWorkLimit)
+	binary, err := determinize(binary, determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
+	this.runAutomaton, err = NewByteRunAutomaton(binary, true, determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
 	this.automaton = this.runAutomaton.automaton
 
 	// TODO: this is a bit fragile because if the automaton is not minimized there could be more than 1 sink state but this-prefix will fail
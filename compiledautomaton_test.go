@@ -0,0 +1,34 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCompiledAutomaton(t *testing.T) {
+	t.Run("testUnicode", func(t *testing.T) {
+		r, err := NewRegExp("(é|ab)+€")
+		assert.Nil(t, err)
+		a, err := r.ToAutomaton()
+		assert.Nil(t, err)
+
+		// The unicode automaton runs on its UTF-8 bytes:
+		c, err := NewCompiledAutomaton(a, nil, true, DEFAULT_DETERMINIZE_WORK_LIMIT, false)
+		assert.Nil(t, err)
+		assert.Equal(t, AUTOMATON_TYPE_NORMAL, c.Type())
+		run := c.RunAutomaton()
+		assert.True(t, run.Run([]byte("éab€")))
+		assert.False(t, run.Run([]byte("é")))
+		assert.False(t, run.Run([]byte("\xe9€")))
+	})
+
+	t.Run("testSingle", func(t *testing.T) {
+		a, err := MakeString("né")
+		assert.Nil(t, err)
+		c, err := NewCompiledAutomaton(a, nil, true, DEFAULT_DETERMINIZE_WORK_LIMIT, false)
+		assert.Nil(t, err)
+		assert.Equal(t, AUTOMATON_TYPE_SINGLE, c.Type())
+		assert.Equal(t, []byte("né"), c.Term())
+	})
+}
@@ -0,0 +1,298 @@
+package automaton
+
+import (
+	"math"
+	"slices"
+	"strconv"
+	"unicode"
+)
+
+// Complexity An estimate of the size of the automata a RegExp compiles to, computed from its syntax tree
+// without building them, see EstimateComplexity.
+type Complexity struct {
+	// Estimated number of states of the nondeterministic automaton, before any determinization. This is close
+	// to the number of states ToAutomaton allocates when nothing blows up.
+	NFAStates int
+
+	// Upper bound of the number of states of the deterministic automaton. It saturates at math.MaxInt. It is
+	// linear in the length of the pattern unless a repetition can overlap with what follows it, as in
+	// "(a|b)*a(a|b){20}", where it grows exponentially like the real automaton may.
+	DFAStates int
+
+	// Largest count of a bounded repetition, e.g. 1000 for "a{3,1000}".
+	MaxRepeat int
+
+	// Largest number of alternatives of a single alternation, e.g. 3 for "a|b|c".
+	MaxAlternation int
+
+	// Deepest nesting of repetition operators, e.g. 2 for "(a*b)+".
+	Nesting int
+}
+
+// complexityNode The estimate of one sub expression, with over-approximations of the characters of its
+// language, used to tell when combining automata can not blow up.
+type complexityNode struct {
+	nfa, dfa int
+
+	// Whether the empty string is accepted.
+	nullable bool
+
+	// The characters that can start a string.
+	first *RangeSet
+
+	// The characters that can follow an accepted string, i.e. those that continue an accept state.
+	follow *RangeSet
+
+	// All characters of the strings.
+	chars *RangeSet
+}
+
+var (
+	noChars  = NewRangeSet()
+	anyChars = NewRangeSet([2]int{0, unicode.MaxRune})
+)
+
+// EstimateComplexity Estimates the size of the automata the given expression compiles to, so that services can
+// reject abusive patterns, e.g. "(a|b)*a(a|b){40}", before spending any effort compiling them, independent of the
+// determinization work limit. Named automata ("<name>") are counted as a single state accepting any string,
+// since they are not known before compiling.
+func EstimateComplexity(r *RegExp) Complexity {
+	var c Complexity
+	n := c.estimate(r, 0)
+	c.NFAStates = n.nfa
+	c.DFAStates = n.dfa
+	return c
+}
+
+func (c *Complexity) estimate(r *RegExp, depth int) complexityNode {
+	if set, ok := r.ToRangeSet(); ok {
+		// A character class, including negated ones:
+		if r.kind == REGEXP_UNION {
+			c.MaxAlternation = max(c.MaxAlternation, alternationWidth(r))
+		}
+		return complexityNode{nfa: 2, dfa: 2, first: set, follow: noChars, chars: set}
+	}
+
+	var n complexityNode
+	switch r.kind {
+	case REGEXP_UNION:
+		c.MaxAlternation = max(c.MaxAlternation, alternationWidth(r))
+		n = unionComplexity(c.estimate(r.exp1, depth), c.estimate(r.exp2, depth))
+	case REGEXP_CONCATENATION:
+		n = concatenationComplexity(c.estimate(r.exp1, depth), c.estimate(r.exp2, depth))
+	case REGEXP_INTERSECTION:
+		n1, n2 := c.estimate(r.exp1, depth), c.estimate(r.exp2, depth)
+		n = complexityNode{
+			nfa:      satMul(n1.nfa, n2.nfa),
+			dfa:      productStates(n1.dfa, n2.dfa),
+			nullable: n1.nullable && n2.nullable,
+			first:    n1.first.intersection(n2.first),
+			follow:   n1.follow.intersection(n2.follow),
+			chars:    n1.chars.intersection(n2.chars),
+		}
+	case REGEXP_OPTIONAL:
+		n = optionalComplexity(c.estimate(r.exp1, depth))
+	case REGEXP_REPEAT:
+		c.Nesting = max(c.Nesting, depth+1)
+		n = starComplexity(c.estimate(r.exp1, depth+1))
+	case REGEXP_REPEAT_MIN:
+		c.Nesting = max(c.Nesting, depth+1)
+		c.MaxRepeat = max(c.MaxRepeat, r.min)
+		n1 := c.estimate(r.exp1, depth+1)
+		// e{min,} is e...e e*:
+		n = repeatComplexity(n1, starComplexity(n1), r.min, false)
+	case REGEXP_REPEAT_MINMAX:
+		c.Nesting = max(c.Nesting, depth+1)
+		c.MaxRepeat = max(c.MaxRepeat, r.max)
+		n1 := c.estimate(r.exp1, depth+1)
+		// e{min,max} is e...e (e (e ...)?)?, which does not blow up when e can not overlap with itself:
+		n = complexityNode{nfa: 1, dfa: 1, nullable: true, first: noChars, follow: noChars, chars: noChars}
+		n = repeatComplexity(n1, n, r.max-max(r.min, 0), true)
+		n = repeatComplexity(n1, n, min(r.min, r.max), false)
+	case REGEXP_COMPLEMENT:
+		// The complement is taken of the determinized automaton:
+		n1 := c.estimate(r.exp1, depth)
+		n = complexityNode{
+			nfa:      satAdd(n1.dfa, 1),
+			dfa:      satAdd(n1.dfa, 1),
+			nullable: !n1.nullable,
+			first:    anyChars,
+			follow:   anyChars,
+			chars:    anyChars,
+		}
+	case REGEXP_STRING:
+		n = complexityNode{nfa: 1, dfa: 1, nullable: true, first: noChars, follow: noChars, chars: noChars}
+		for _, ch := range []rune(*r.s) {
+			var ranges [][2]int
+			for _, v := range r.applyCaseFolding(ch) {
+				ranges = append(ranges, [2]int{int(v), int(v)})
+			}
+			set := NewRangeSet(ranges...)
+			if n.nullable {
+				n.first = set
+			}
+			n.nfa++
+			n.dfa++
+			n.nullable = false
+			n.chars = n.chars.union(set)
+		}
+	case REGEXP_EMPTY:
+		n = complexityNode{nfa: 1, dfa: 1, first: noChars, follow: noChars, chars: noChars}
+	case REGEXP_ANYSTRING, REGEXP_AUTOMATON:
+		n = complexityNode{nfa: 1, dfa: 1, nullable: true, first: anyChars, follow: anyChars, chars: anyChars}
+	case REGEXP_INTERVAL:
+		// A decimal interval has a few states per digit:
+		digits := max(r.digits, len(strconv.Itoa(r.max)))
+		set := NewRangeSet([2]int{'0', '9'})
+		n = complexityNode{nfa: 3*digits + 1, dfa: 3*digits + 1, first: set, follow: set, chars: set}
+		if r.digits > 0 {
+			n.follow = noChars
+		}
+	}
+
+	// Determinizing n.nfa states never makes more than 2^n.nfa states:
+	n.dfa = min(n.dfa, pow2(n.nfa))
+	return n
+}
+
+// alternationWidth Returns the number of alternatives of the alternation rooted at r.
+func alternationWidth(r *RegExp) int {
+	if r.kind != REGEXP_UNION {
+		return 1
+	}
+	return alternationWidth(r.exp1) + alternationWidth(r.exp2)
+}
+
+func unionComplexity(n1, n2 complexityNode) complexityNode {
+	n := complexityNode{
+		nfa:      satAdd(satAdd(n1.nfa, n2.nfa), 1),
+		nullable: n1.nullable || n2.nullable,
+		first:    n1.first.union(n2.first),
+		follow:   n1.follow.union(n2.follow),
+		chars:    n1.chars.union(n2.chars),
+	}
+	// A string of one language may continue into the other:
+	if n1.nullable {
+		n.follow = n.follow.union(n2.first)
+	}
+	if n2.nullable {
+		n.follow = n.follow.union(n1.first)
+	}
+	if overlaps(n1.first, n2.first) {
+		n.follow = n.follow.union(n.chars)
+		n.dfa = productStates(n1.dfa, n2.dfa)
+	} else {
+		// After the first character only one of them goes on:
+		n.dfa = satAdd(n1.dfa, n2.dfa)
+	}
+	return n
+}
+
+func concatenationComplexity(n1, n2 complexityNode) complexityNode {
+	n := complexityNode{
+		nfa:      satAdd(n1.nfa, n2.nfa),
+		nullable: n1.nullable && n2.nullable,
+		first:    n1.first,
+		follow:   n2.follow,
+		chars:    n1.chars.union(n2.chars),
+	}
+	if n1.nullable {
+		n.first = n.first.union(n2.first)
+	}
+	if n2.nullable {
+		n.follow = n.follow.union(n1.follow).union(n2.first)
+	}
+	if overlaps(n1.follow, n2.first) {
+		// Both can go on after an accept state of n1, so the determinized automaton tracks a set of states of n2:
+		n.follow = n.follow.union(n.chars)
+		n.dfa = satMul(n1.dfa, pow2(n2.dfa))
+	} else {
+		n.dfa = satAdd(n1.dfa, n2.dfa)
+	}
+	return n
+}
+
+// repeatComplexity Returns the estimate of count times n1 followed by n, each made optional if optional is set.
+// Once the characters stop changing every step adds the same number of states, so long repetitions are
+// extrapolated rather than stepped through.
+func repeatComplexity(n1, n complexityNode, count int, optional bool) complexityNode {
+	for i := 0; i < count; i++ {
+		next := concatenationComplexity(n1, n)
+		if optional {
+			next = optionalComplexity(next)
+		}
+		if sameChars(n, next) && (next.dfa == math.MaxInt || !overlaps(n1.follow, n.first)) {
+			remaining := count - i - 1
+			next.nfa = satAdd(next.nfa, satMul(remaining, next.nfa-n.nfa))
+			next.dfa = satAdd(next.dfa, satMul(remaining, next.dfa-n.dfa))
+			return next
+		}
+		n = next
+	}
+	return n
+}
+
+func optionalComplexity(n1 complexityNode) complexityNode {
+	n1.nfa = satAdd(n1.nfa, 1)
+	n1.dfa = satAdd(n1.dfa, 1)
+	n1.nullable = true
+	n1.follow = n1.follow.union(n1.first)
+	return n1
+}
+
+func starComplexity(n1 complexityNode) complexityNode {
+	n := complexityNode{
+		nfa:      satAdd(n1.nfa, 1),
+		nullable: true,
+		first:    n1.first,
+		follow:   n1.follow.union(n1.first),
+		chars:    n1.chars,
+	}
+	if overlaps(n1.follow, n1.first) {
+		n.follow = n.chars
+		n.dfa = pow2(n1.dfa)
+	} else {
+		n.dfa = satAdd(n1.dfa, 1)
+	}
+	return n
+}
+
+// productStates Returns the number of states of the product of two automata with d1 and d2 states, counting
+// the dead states the product pairs with the states of the other automaton.
+func productStates(d1, d2 int) int {
+	product := satMul(satAdd(d1, 1), satAdd(d2, 1))
+	if product == math.MaxInt {
+		return product
+	}
+	return product - 1
+}
+
+func sameChars(n1, n2 complexityNode) bool {
+	return n1.nullable == n2.nullable && slices.Equal(n1.first.ranges, n2.first.ranges) &&
+		slices.Equal(n1.follow.ranges, n2.follow.ranges) && slices.Equal(n1.chars.ranges, n2.chars.ranges)
+}
+
+func overlaps(s1, s2 *RangeSet) bool {
+	return len(s1.intersection(s2).ranges) > 0
+}
+
+func satAdd(x, y int) int {
+	if x > math.MaxInt-y {
+		return math.MaxInt
+	}
+	return x + y
+}
+
+func satMul(x, y int) int {
+	if x != 0 && y > math.MaxInt/x {
+		return math.MaxInt
+	}
+	return x * y
+}
+
+func pow2(n int) int {
+	if n >= 62 {
+		return math.MaxInt
+	}
+	return 1 << n
+}
@@ -0,0 +1,66 @@
+package automaton
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateComplexity(t *testing.T) {
+	t.Run("testLinear", func(t *testing.T) {
+		c := EstimateComplexity(MustNewRegExp("abc"))
+		assert.Equal(t, Complexity{NFAStates: 4, DFAStates: 4}, c)
+
+		for _, pattern := range []string{
+			"[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}\\.[0-9]{1,3}",
+			"[a-z]{1,64}",
+			"(ab)*c",
+			"[^,]*,x",
+			"select|insert|update|delete",
+		} {
+			c := EstimateComplexity(MustNewRegExp(pattern))
+			assert.Less(t, c.DFAStates, 200, pattern)
+		}
+	})
+
+	t.Run("testBlowUp", func(t *testing.T) {
+		c := EstimateComplexity(MustNewRegExp("(a|b)*a(a|b){20}"))
+		assert.Less(t, c.NFAStates, 100)
+		assert.GreaterOrEqual(t, c.DFAStates, 1<<20)
+
+		c = EstimateComplexity(MustNewRegExp("(a|b)*a(a|b){100}"))
+		assert.Equal(t, math.MaxInt, c.DFAStates)
+	})
+
+	t.Run("testShape", func(t *testing.T) {
+		c := EstimateComplexity(MustNewRegExp("(a|b|c)(d|e){2,7}"))
+		assert.Equal(t, 3, c.MaxAlternation)
+		assert.Equal(t, 7, c.MaxRepeat)
+		assert.Equal(t, 1, c.Nesting)
+
+		c = EstimateComplexity(MustNewRegExp("((a*b)+c){3,}"))
+		assert.Equal(t, 3, c.MaxRepeat)
+		assert.Equal(t, 3, c.Nesting)
+	})
+
+	t.Run("testLongRepeat", func(t *testing.T) {
+		c := EstimateComplexity(MustNewRegExp("a{0,999999999}"))
+		assert.Greater(t, c.NFAStates, 999999999)
+		assert.Equal(t, 999999999, c.MaxRepeat)
+	})
+
+	t.Run("testUpperBound", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1617))
+		for i := 0; i < 200; i++ {
+			pattern := randomRegexp(r, 1+r.Intn(3))
+			re := MustNewRegExp(pattern)
+			a, err := re.ToAutomaton()
+			if err != nil {
+				continue
+			}
+			assert.GreaterOrEqual(t, EstimateComplexity(re).DFAStates, a.GetNumStates(), pattern)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package automaton
+
+import "sync/atomic"
+
+// Config The package-wide default limits, used by the operations that are not given a limit of their own, e.g.
+// RegExp.ToAutomaton, Automaton.NewByteRunAutomaton or NewLevenshteinAutomaton. Operations taking a limit, and
+// options such as WithMaxStates, override them per call.
+type Config struct {
+	// Maximum work of determinization, see DEFAULT_DETERMINIZE_WORK_LIMIT.
+	WorkLimit int
+
+	// Maximum number of states of the automaton built for a sub expression of a RegExp, see DEFAULT_MAX_STATES.
+	// A limit <= 0 disables the check.
+	MaxStates int
+}
+
+var (
+	defaultWorkLimit atomic.Int64
+	defaultMaxStates atomic.Int64
+)
+
+func init() {
+	defaultWorkLimit.Store(DEFAULT_DETERMINIZE_WORK_LIMIT)
+	defaultMaxStates.Store(DEFAULT_MAX_STATES)
+}
+
+// DefaultConfig Returns the current default limits.
+func DefaultConfig() Config {
+	return Config{
+		WorkLimit: DefaultWorkLimit(),
+		MaxStates: DefaultMaxStates(),
+	}
+}
+
+// DefaultWorkLimit Returns the default determinization work limit, DEFAULT_DETERMINIZE_WORK_LIMIT unless
+// changed with SetDefaultWorkLimit.
+func DefaultWorkLimit() int {
+	return int(defaultWorkLimit.Load())
+}
+
+// SetDefaultWorkLimit Sets the default determinization work limit and returns the previous one. A limit <= 0
+// restores DEFAULT_DETERMINIZE_WORK_LIMIT. It is safe to call while other goroutines run operations; those
+// already running keep the limit they started with.
+func SetDefaultWorkLimit(workLimit int) int {
+	if workLimit <= 0 {
+		workLimit = DEFAULT_DETERMINIZE_WORK_LIMIT
+	}
+	return int(defaultWorkLimit.Swap(int64(workLimit)))
+}
+
+// DefaultMaxStates Returns the default limit on the number of states of the automaton built for a sub
+// expression of a RegExp, DEFAULT_MAX_STATES unless changed with SetDefaultMaxStates.
+func DefaultMaxStates() int {
+	return int(defaultMaxStates.Load())
+}
+
+// SetDefaultMaxStates Sets the default limit on the number of states of the automaton built for a sub
+// expression of a RegExp and returns the previous one. A limit <= 0 disables the check, as with WithMaxStates.
+// It is safe to call while other goroutines run operations.
+func SetDefaultMaxStates(maxStates int) int {
+	return int(defaultMaxStates.Swap(int64(maxStates)))
+}
@@ -0,0 +1,53 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	assert.Equal(t, Config{WorkLimit: DEFAULT_DETERMINIZE_WORK_LIMIT, MaxStates: DEFAULT_MAX_STATES}, DefaultConfig())
+
+	t.Run("testWorkLimit", func(t *testing.T) {
+		defer SetDefaultWorkLimit(SetDefaultWorkLimit(1))
+		assert.Equal(t, 1, DefaultWorkLimit())
+
+		r := MustNewRegExp("[ac]*a[ac]{5}")
+		_, err := r.ToAutomaton()
+		assert.Error(t, err)
+
+		_, err = MakeNGrams("abcd", 2)
+		assert.Error(t, err)
+
+		// a limit passed explicitly overrides the default:
+		anyString, err := MakeAnyString()
+		assert.Nil(t, err)
+		suffix, err := MakeString("aab")
+		assert.Nil(t, err)
+		a, err := concatenate(anyString, suffix)
+		assert.Nil(t, err)
+		_, err = Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+
+		assert.Equal(t, 1, SetDefaultWorkLimit(0))
+		assert.Equal(t, DEFAULT_DETERMINIZE_WORK_LIMIT, DefaultWorkLimit())
+		_, err = r.ToAutomaton()
+		assert.Nil(t, err)
+	})
+
+	t.Run("testMaxStates", func(t *testing.T) {
+		defer SetDefaultMaxStates(SetDefaultMaxStates(5))
+
+		r := MustNewRegExp("(a|b)c{2,4}")
+		_, err := r.ToAutomaton()
+		assert.ErrorIs(t, err, ErrTooManyStates)
+
+		// options override the default:
+		a, err := r.ToAutomaton(WithMaxStates(6))
+		assert.Nil(t, err)
+		assert.Equal(t, 6, a.GetNumStates())
+	})
+
+	assert.Equal(t, Config{WorkLimit: DEFAULT_DETERMINIZE_WORK_LIMIT, MaxStates: DEFAULT_MAX_STATES}, DefaultConfig())
+}
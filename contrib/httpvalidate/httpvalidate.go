@@ -0,0 +1,173 @@
+// Package httpvalidate Validates HTTP request fields against regular expressions compiled into automata.
+//
+// Patterns use the syntax of automaton.RegExp. Every pattern is compiled once, when the Validator is created,
+// into a minimal deterministic automaton and then into a automaton.ByteRunAutomaton, so validating a field
+// costs a single table lookup per byte with no allocations and no backtracking, however the pattern is
+// written. Create one Validator per endpoint at startup and share it between requests; it is safe for
+// concurrent use.
+//
+// Patterns are matched against the UTF-8 encoding of the field values: their automata are converted with
+// automaton.UTF32ToUTF8, so "." and negated classes match any single (possibly multi-byte) character, and
+// values that are not valid UTF-8 never match a character.
+package httpvalidate
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/geange/automaton"
+)
+
+const (
+	// DefaultMaxFieldBytes Default limit on the length of a single field value.
+	DefaultMaxFieldBytes = 4096
+
+	// DefaultMaxStates Default limit on the number of states of a compiled pattern.
+	DefaultMaxStates = 10000
+)
+
+// Validator Validates the fields of a request against a fixed set of patterns.
+type Validator struct {
+	fields        map[string]*automaton.ByteRunAutomaton
+	names         []string
+	maxFieldBytes int
+}
+
+type options struct {
+	determinizeWorkLimit int
+	maxStates            int
+	maxFieldBytes        int
+}
+
+// Option Configures a Validator.
+type Option func(*options)
+
+// WithDeterminizeWorkLimit Limits the effort spent determinizing each pattern, in total over all steps of
+// compiling it (see automaton.WithBudget); patterns that need more work are rejected by New. Defaults to
+// automaton.DefaultWorkLimit().
+func WithDeterminizeWorkLimit(limit int) Option {
+	return func(o *options) {
+		o.determinizeWorkLimit = limit
+	}
+}
+
+// WithMaxStates Limits the number of states of each compiled pattern, bounding the memory a Validator holds;
+// patterns that compile to more states are rejected by New. Defaults to DefaultMaxStates.
+func WithMaxStates(maxStates int) Option {
+	return func(o *options) {
+		o.maxStates = maxStates
+	}
+}
+
+// WithMaxFieldBytes Limits the length of every field value; longer values fail validation without being
+// scanned. Defaults to DefaultMaxFieldBytes.
+func WithMaxFieldBytes(maxFieldBytes int) Option {
+	return func(o *options) {
+		o.maxFieldBytes = maxFieldBytes
+	}
+}
+
+// New Compiles a map from field name to pattern into a Validator.
+func New(patterns map[string]string, opts ...Option) (*Validator, error) {
+	o := &options{
+		determinizeWorkLimit: automaton.DefaultWorkLimit(),
+		maxStates:            DefaultMaxStates,
+		maxFieldBytes:        DefaultMaxFieldBytes,
+	}
+	for _, fn := range opts {
+		fn(o)
+	}
+
+	v := &Validator{
+		fields:        make(map[string]*automaton.ByteRunAutomaton, len(patterns)),
+		names:         make([]string, 0, len(patterns)),
+		maxFieldBytes: o.maxFieldBytes,
+	}
+	for name, pattern := range patterns {
+		r, err := compile(pattern, o)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		v.fields[name] = r
+		v.names = append(v.names, name)
+	}
+	sort.Strings(v.names)
+	return v, nil
+}
+
+func compile(pattern string, o *options) (*automaton.ByteRunAutomaton, error) {
+	re, err := automaton.NewRegExp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	budget := automaton.NewBudget(o.determinizeWorkLimit)
+	a, err := re.ToAutomaton(automaton.WithMaxStates(o.maxStates), automaton.WithBudget(budget))
+	if err != nil {
+		return nil, err
+	}
+	// Field values are matched byte by byte, so match the UTF-8 encoding of the code points of the pattern:
+	a, err = automaton.UTF32ToUTF8(a)
+	if err != nil {
+		return nil, err
+	}
+	a, err = budget.Minimize(a)
+	if err != nil {
+		return nil, err
+	}
+	if a.GetNumStates() > o.maxStates {
+		return nil, fmt.Errorf("pattern needs %d states, more than the limit of %d", a.GetNumStates(), o.maxStates)
+	}
+	return automaton.NewByteRunAutomaton(a, true, o.determinizeWorkLimit)
+}
+
+// FieldError Reports a field whose value does not match its pattern.
+type FieldError struct {
+	Field string
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("invalid value for field %q", e.Field)
+}
+
+// Validate Checks every value of every field that has a pattern; a missing field is validated as the empty
+// string, so a pattern decides itself whether its field is required. Fields without a pattern are ignored.
+// Returns nil if all values match, otherwise the errors of the failing fields (as *FieldError) joined with
+// errors.Join, in field name order.
+func (v *Validator) Validate(values url.Values) error {
+	var errs []error
+	for _, name := range v.names {
+		r := v.fields[name]
+		vals, ok := values[name]
+		if !ok {
+			vals = []string{""}
+		}
+		for _, val := range vals {
+			if len(val) > v.maxFieldBytes || !r.Run([]byte(val)) {
+				errs = append(errs, &FieldError{Field: name, Value: val})
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Middleware Returns a handler validating the query and form fields of every request before passing it on to
+// next. Invalid requests are answered with 400 Bad Request listing the failing fields.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := v.Validate(req.Form); err != nil {
+			http.Error(w, strings.ReplaceAll(err.Error(), "\n", "; "), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
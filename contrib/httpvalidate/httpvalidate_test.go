@@ -0,0 +1,111 @@
+package httpvalidate
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newValidator(t *testing.T, opts ...Option) *Validator {
+	v, err := New(map[string]string{
+		"user":  "[a-z][a-z0-9_]{2,15}",
+		"id":    "[0-9]+",
+		"sort":  "(asc|desc)?",
+		"email": "[a-z0-9.]+@[a-z0-9]+(\\.[a-z0-9]+)+",
+	}, opts...)
+	assert.Nil(t, err)
+	return v
+}
+
+func TestValidator_Validate(t *testing.T) {
+	v := newValidator(t)
+
+	valid := url.Values{
+		"user":  {"alice_01"},
+		"id":    {"42", "7"},
+		"email": {"alice@example.com"},
+		"other": {"ignored ☃"},
+	}
+	assert.Nil(t, v.Validate(valid))
+
+	invalid := url.Values{
+		"user":  {"Alice"},
+		"id":    {"42", "x"},
+		"sort":  {"up"},
+		"email": {"alice@example.com"},
+	}
+	err := v.Validate(invalid)
+	assert.Error(t, err)
+	var fieldErr *FieldError
+	assert.True(t, errors.As(err, &fieldErr))
+	assert.Equal(t, "id", fieldErr.Field)
+	assert.Equal(t, "x", fieldErr.Value)
+	assert.Contains(t, err.Error(), `"sort"`)
+	assert.Contains(t, err.Error(), `"user"`)
+	assert.NotContains(t, err.Error(), `"email"`)
+
+	// Missing fields are validated as the empty string:
+	err = v.Validate(url.Values{"user": {"bob"}, "id": {"1"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"email"`)
+	assert.NotContains(t, err.Error(), `"sort"`)
+}
+
+func TestValidator_Limits(t *testing.T) {
+	v := newValidator(t, WithMaxFieldBytes(8))
+	values := url.Values{"user": {"bob"}, "id": {"123456789"}, "email": {"a@b.c"}}
+	assert.Error(t, v.Validate(values))
+	values["id"] = []string{"12345678"}
+	assert.Nil(t, v.Validate(values))
+
+	_, err := New(map[string]string{"code": "[a-z]{20}"}, WithMaxStates(10))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `field "code"`)
+
+	_, err = New(map[string]string{"bad": "[a-"})
+	assert.Error(t, err)
+
+	patterns := map[string]string{"code": "(a|b)*a(a|b){12}"}
+	_, err = New(patterns)
+	assert.Nil(t, err)
+	_, err = New(patterns, WithDeterminizeWorkLimit(10))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `field "code"`)
+}
+
+func TestValidator_NonASCII(t *testing.T) {
+	v, err := New(map[string]string{"n": "é", "m": "世", "any": ".{2}"})
+	assert.Nil(t, err)
+
+	assert.Nil(t, v.Validate(url.Values{"n": {"é"}, "m": {"世"}, "any": {"a世"}}))
+
+	err = v.Validate(url.Values{"n": {"\xe9"}, "m": {"\xe4\xb8"}, "any": {"世"}})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `"n"`)
+	assert.Contains(t, err.Error(), `"m"`)
+	assert.Contains(t, err.Error(), `"any"`)
+}
+
+func TestValidator_Middleware(t *testing.T) {
+	v := newValidator(t)
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?user=carol&id=9&email=c@d.io&sort=asc", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	w = httptest.NewRecorder()
+	body := strings.NewReader("user=carol&id=nine&email=c@d.io")
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `invalid value for field "id"`)
+}
@@ -0,0 +1,53 @@
+package automaton
+
+import "fmt"
+
+// operation Names an operation producing an automaton; see done.
+type operation string
+
+const (
+	opUnion            = operation("union")
+	opConcatenate      = operation("concatenate")
+	opOptional         = operation("optional")
+	opRepeat           = operation("repeat")
+	opRepeatRange      = operation("repeatRange")
+	opComplement       = operation("complement")
+	opComplementOver   = operation("complementOver")
+	opTotalize         = operation("totalize")
+	opIntersection     = operation("intersection")
+	opProduct          = operation("product")
+	opShuffle          = operation("shuffle")
+	opConstrainLengths = operation("constrainLengths")
+	opMapLabels        = operation("mapLabels")
+	opCaseFold         = operation("caseFold")
+	opReverse          = operation("reverse")
+	opRemoveDeadStates = operation("removeDeadStates")
+	opDeterminize      = operation("determinize")
+	opMinimize         = operation("minimize")
+	opCanonicalize     = operation("canonicalize")
+	opNormalize        = operation("normalize")
+)
+
+// done Is called with the result of an operation. It validates the result when debug assertions are enabled
+// (build with the automaton_debug tag), so invariant violations are caught by the operation that introduced
+// them rather than by whatever consumes the automaton later, and reports the result to the operation observer,
+// if any (see SetOperationObserver).
+func (op operation) done(a *Automaton, err error) (*Automaton, error) {
+	if err != nil || a == nil {
+		return a, err
+	}
+	if debugAssertions {
+		if verr := a.Validate(); verr != nil {
+			panic(fmt.Sprintf("automaton: invalid %s result: %v", op, verr))
+		}
+	}
+	if observer := operationObserver.Load(); observer != nil {
+		(*observer)(OperationStats{
+			Operation:   string(op),
+			States:      a.GetNumStates(),
+			Transitions: a.GetNumTransitions(),
+			Bytes:       a.RamBytesUsed(),
+		})
+	}
+	return a, err
+}
@@ -0,0 +1,5 @@
+//go:build !automaton_debug
+
+package automaton
+
+const debugAssertions = false
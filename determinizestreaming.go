@@ -0,0 +1,207 @@
+package automaton
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// DEFAULT_DETERMINIZE_MEMORY_BUDGET Default number of bytes the sets of states found by DeterminizeStreaming
+	// may take in memory before they are spilled to its DeterminizeStore.
+	DEFAULT_DETERMINIZE_MEMORY_BUDGET = 64 << 20
+
+	// DEFAULT_PROGRESS_INTERVAL Default number of sets DeterminizeStreaming expands between progress reports.
+	DEFAULT_PROGRESS_INTERVAL = 10000
+)
+
+// DeterminizeStore A key-value store, typically on disk, holding the sets of states that DeterminizeStreaming
+// could not keep in memory. Keys are at most a few bytes per state of the set; a store is used by one
+// determinization at a time and only ever sees each key put once.
+type DeterminizeStore interface {
+	// Get Returns the state stored under the given key, and whether there is one. The key must not be retained.
+	Get(key []byte) (int, bool, error)
+
+	// Put Stores the state under the given key. The key must not be retained.
+	Put(key []byte, state int) error
+}
+
+// DeterminizeProgress Reports the progress of DeterminizeStreaming.
+type DeterminizeProgress struct {
+	// Number of states of the result created so far.
+	States int
+
+	// Number of states created whose transitions are still to be computed. The determinization is done when it
+	// drops to zero.
+	Pending int
+
+	// Number of sets of states held in memory, and written to the store.
+	InMemory int
+	Spilled  int
+
+	// Number of times the sets in memory were written to the store.
+	Spills int
+
+	// Effort spent so far, in the units of the work limit times ten.
+	Effort int
+}
+
+func (p DeterminizeProgress) String() string {
+	return fmt.Sprintf("states: %d, pending: %d, in memory: %d, spilled: %d (%d spills), effort: %d",
+		p.States, p.Pending, p.InMemory, p.Spilled, p.Spills, p.Effort)
+}
+
+type determinizeStreamingOptions struct {
+	memoryBudget     int
+	progress         func(DeterminizeProgress)
+	progressInterval int
+}
+
+type DeterminizeStreamingOption func(*determinizeStreamingOptions)
+
+// WithMemoryBudget Sets the number of bytes the sets of states may take in memory before they are spilled to the
+// store, DEFAULT_DETERMINIZE_MEMORY_BUDGET by default. A budget <= 0 spills every set as soon as it is found.
+func WithMemoryBudget(bytes int) DeterminizeStreamingOption {
+	return func(o *determinizeStreamingOptions) {
+		o.memoryBudget = bytes
+	}
+}
+
+// WithProgress Sets a function called with the progress of the determinization every interval expanded sets
+// (DEFAULT_PROGRESS_INTERVAL if interval <= 0), after every spill, and once at the end.
+func WithProgress(progress func(DeterminizeProgress), interval int) DeterminizeStreamingOption {
+	return func(o *determinizeStreamingOptions) {
+		o.progress = progress
+		o.progressInterval = interval
+		if o.progressInterval <= 0 {
+			o.progressInterval = DEFAULT_PROGRESS_INTERVAL
+		}
+	}
+}
+
+// DeterminizeStreaming Determinizes the given automaton, for automata whose result is still needed but too large
+// to determinize in memory. The map from sets of states of the automaton to states of the result, which
+// dominates the memory of the powerset construction, is kept in memory up to the memory budget (see
+// WithMemoryBudget) and then written to the store, where later lookups find it; this is much slower, but bounds
+// memory to the result itself, the sets still to be expanded and the budget. It fails if the construction would
+// spend more than determinizeWorkLimit, see DEFAULT_DETERMINIZE_WORK_LIMIT; errors of the store are returned
+// wrapped.
+func DeterminizeStreaming(a *Automaton, determinizeWorkLimit int, store DeterminizeStore,
+	options ...DeterminizeStreamingOption) (*Automaton, error) {
+
+	opts := &determinizeStreamingOptions{memoryBudget: DEFAULT_DETERMINIZE_MEMORY_BUDGET}
+	for _, option := range options {
+		option(opts)
+	}
+
+	if a.IsDeterministic() || a.GetNumStates() <= 1 {
+		// Already determinized
+		return a, nil
+	}
+
+	subsets := &spillingSubsets{
+		memory: newMemorySubsets(),
+		budget: opts.memoryBudget,
+		store:  store,
+	}
+	report := func(states, pending, effort int) {
+		opts.progress(DeterminizeProgress{
+			States:   states,
+			Pending:  pending,
+			InMemory: subsets.memory.sets.Size(),
+			Spilled:  subsets.spilled,
+			Spills:   subsets.spills,
+			Effort:   effort,
+		})
+	}
+
+	var progress func(states, pending, effort int)
+	if opts.progress != nil {
+		expanded := 0
+		progress = func(states, pending, effort int) {
+			expanded++
+			if expanded%opts.progressInterval == 0 || subsets.spillReported < subsets.spills {
+				subsets.spillReported = subsets.spills
+				report(states, pending, effort)
+			}
+		}
+	}
+
+	result, effortSpent, err := subsetConstruction(a, 10*determinizeWorkLimit, subsets, progress)
+	if errors.Is(err, errEffortLimit) {
+		return nil, errors.New("too Complex To Determinize")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("determinize: %w", err)
+	}
+	if opts.progress != nil {
+		report(result.GetNumStates(), 0, effortSpent)
+	}
+	return opDeterminize.done(result, nil)
+}
+
+// spillingSubsets A subsetMap keeping sets in memory up to a budget of bytes, and writing them all to a store
+// whenever it is exceeded.
+type spillingSubsets struct {
+	memory *memorySubsets
+
+	// Estimated bytes of the sets in memory, and the budget for them.
+	bytes  int
+	budget int
+
+	store DeterminizeStore
+
+	// Number of sets written to the store, and how often they were.
+	spilled int
+	spills  int
+
+	// Number of spills already reported as progress.
+	spillReported int
+
+	// Reused buffer of encoded keys.
+	key []byte
+}
+
+func (s *spillingSubsets) get(set *StateSet) (int, bool, error) {
+	if q, ok, _ := s.memory.get(set); ok || s.spilled == 0 {
+		return q, ok, nil
+	}
+	s.key = encodeSubset(s.key[:0], set.GetArray())
+	return s.store.Get(s.key)
+}
+
+func (s *spillingSubsets) put(set *FrozenIntSet, state int) error {
+	s.memory.sets.Set(set, state)
+	s.bytes += subsetBytes(len(set.values))
+	if s.bytes <= s.budget {
+		return nil
+	}
+
+	for set, state := range s.memory.sets.Iterator() {
+		s.key = encodeSubset(s.key[:0], set.(*FrozenIntSet).values)
+		if err := s.store.Put(s.key, state); err != nil {
+			return err
+		}
+		s.spilled++
+	}
+	s.memory = newMemorySubsets()
+	s.bytes = 0
+	s.spills++
+	return nil
+}
+
+// subsetBytes Estimates the bytes a set of the given size takes in memory, including its map entry.
+func subsetBytes(size int) int {
+	return 96 + 8*size
+}
+
+// encodeSubset Appends the key of the given sorted set of states to dst: the varint encoded differences
+// between consecutive states.
+func encodeSubset(dst []byte, states []int) []byte {
+	last := 0
+	for _, state := range states {
+		dst = binary.AppendUvarint(dst, uint64(state-last))
+		last = state
+	}
+	return dst
+}
@@ -0,0 +1,99 @@
+package automaton
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mapDeterminizeStore struct {
+	states map[string]int
+	gets   int
+	err    error
+}
+
+func (m *mapDeterminizeStore) Get(key []byte) (int, bool, error) {
+	m.gets++
+	state, ok := m.states[string(key)]
+	return state, ok, m.err
+}
+
+func (m *mapDeterminizeStore) Put(key []byte, state int) error {
+	if _, ok := m.states[string(key)]; ok {
+		return errors.New("duplicate key")
+	}
+	m.states[string(key)] = state
+	return m.err
+}
+
+func TestDeterminizeStreaming(t *testing.T) {
+	// [ab]*a[ab]{8}, whose DFA has 2^9 states:
+	a, err := Reverse(MustNewRegExp("[ab]{8}a[ab]*").MustToAutomaton())
+	assert.Nil(t, err)
+	assert.False(t, a.IsDeterministic())
+	expected, err := determinize(a, math.MaxInt/10)
+	assert.Nil(t, err)
+
+	t.Run("testSpilling", func(t *testing.T) {
+		store := &mapDeterminizeStore{states: map[string]int{}}
+		var reports []DeterminizeProgress
+		progress := func(p DeterminizeProgress) {
+			reports = append(reports, p)
+		}
+		d, err := DeterminizeStreaming(a, math.MaxInt/10, store, WithMemoryBudget(4096), WithProgress(progress, 100))
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(expected, d))
+		assert.NotEmpty(t, store.states)
+		assert.Greater(t, store.gets, 0)
+
+		last := reports[len(reports)-1]
+		assert.Equal(t, d.GetNumStates(), last.States)
+		assert.Equal(t, 0, last.Pending)
+		assert.Equal(t, d.GetNumStates(), last.InMemory+last.Spilled)
+		assert.Equal(t, len(store.states), last.Spilled)
+		assert.Greater(t, last.Spills, 1)
+		assert.Contains(t, last.String(), "pending: 0")
+		for i := 1; i < len(reports); i++ {
+			assert.GreaterOrEqual(t, reports[i].States, reports[i-1].States)
+		}
+	})
+
+	t.Run("testSpillEverySet", func(t *testing.T) {
+		store := &mapDeterminizeStore{states: map[string]int{}}
+		d, err := DeterminizeStreaming(a, math.MaxInt/10, store, WithMemoryBudget(0))
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(expected, d))
+		assert.Equal(t, d.GetNumStates(), len(store.states))
+	})
+
+	t.Run("testInMemory", func(t *testing.T) {
+		store := &mapDeterminizeStore{states: map[string]int{}}
+		d, err := DeterminizeStreaming(a, math.MaxInt/10, store)
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(expected, d))
+		assert.Empty(t, store.states)
+		assert.Equal(t, 0, store.gets)
+	})
+
+	t.Run("testStoreError", func(t *testing.T) {
+		errFull := errors.New("disk full")
+		store := &mapDeterminizeStore{states: map[string]int{}, err: errFull}
+		_, err := DeterminizeStreaming(a, math.MaxInt/10, store, WithMemoryBudget(0))
+		assert.ErrorIs(t, err, errFull)
+	})
+
+	t.Run("testWorkLimit", func(t *testing.T) {
+		store := &mapDeterminizeStore{states: map[string]int{}}
+		_, err := DeterminizeStreaming(a, 10, store)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("testDeterministic", func(t *testing.T) {
+		d := MustNewRegExp("ab*").MustToAutomaton()
+		result, err := DeterminizeStreaming(d, DEFAULT_DETERMINIZE_WORK_LIMIT, nil)
+		assert.Nil(t, err)
+		assert.Same(t, d, result)
+	})
+}
@@ -0,0 +1,62 @@
+package automaton
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToDot Returns the automaton in the Graphviz dot format, like Lucene's Automaton.toDot, e.g. to render it with
+// "dot -Tpng". Accept states are drawn as double circles, and states with a label (see SetStateLabel) show it
+// under their number. Printable ASCII labels are written as is, all others as \U followed by their hex value.
+func (a *Automaton) ToDot() string {
+	b := new(strings.Builder)
+	b.WriteString("digraph Automaton {\n")
+	b.WriteString("  rankdir = LR\n")
+	b.WriteString("  node [width=0.2, height=0.2, fontsize=8]\n")
+	numStates := a.GetNumStates()
+	if numStates > 0 {
+		b.WriteString("  initial [shape=plaintext,label=\"\"]\n")
+		b.WriteString("  initial -> 0\n")
+	}
+
+	t := NewTransition()
+	for state := 0; state < numStates; state++ {
+		shape := "circle"
+		if a.IsAccept(state) {
+			shape = "doublecircle"
+		}
+		label := strconv.Itoa(state)
+		if stateLabel := a.GetStateLabel(state); stateLabel != "" {
+			label += "\\n" + dotEscape(stateLabel)
+		}
+		fmt.Fprintf(b, "  %d [shape=%s,label=\"%s\"]\n", state, shape, label)
+
+		count := a.InitTransition(state, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			fmt.Fprintf(b, "  %d -> %d [label=\"", state, t.Dest)
+			appendDotLabel(b, t.Min)
+			if t.Max != t.Min {
+				b.WriteByte('-')
+				appendDotLabel(b, t.Max)
+			}
+			b.WriteString("\"]\n")
+		}
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func appendDotLabel(b *strings.Builder, c int) {
+	if c >= 0x21 && c <= 0x7e && c != '"' && c != '\\' {
+		b.WriteByte(byte(c))
+	} else {
+		fmt.Fprintf(b, "\\\\U%x", c)
+	}
+}
+
+// dotEscape Escapes the quotes and backslashes of s for a quoted dot string, and writes newlines as "\n".
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
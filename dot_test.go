@@ -0,0 +1,109 @@
+package automaton
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToDot(t *testing.T) {
+	a := NewAutomaton()
+	s0 := a.CreateState()
+	s1 := a.CreateState()
+	a.SetAccept(s1, true)
+	assert.Nil(t, a.AddTransition(s0, s1, 'a', 'z'))
+	assert.Nil(t, a.AddTransition(s1, s1, '"', '"'))
+	a.FinishState()
+	a.SetStateLabel(s1, `word "end"`)
+
+	assert.Equal(t, `digraph Automaton {
+  rankdir = LR
+  node [width=0.2, height=0.2, fontsize=8]
+  initial [shape=plaintext,label=""]
+  initial -> 0
+  0 [shape=circle,label="0"]
+  0 -> 1 [label="a-z"]
+  1 [shape=doublecircle,label="1\nword \"end\""]
+  1 -> 1 [label="\\U22"]
+}
+`, a.ToDot())
+
+	assert.Equal(t, "digraph Automaton {\n  rankdir = LR\n  node [width=0.2, height=0.2, fontsize=8]\n}\n",
+		MakeEmpty().ToDot())
+}
+
+func TestStateLabels(t *testing.T) {
+	a, err := MakeString("ab")
+	assert.Nil(t, err)
+	a.SetStateLabel(0, "first")
+	a.SetStateLabel(2, "last")
+	assert.Equal(t, "first", a.GetStateLabel(0))
+	assert.Equal(t, "", a.GetStateLabel(1))
+
+	a.SetStateLabel(2, "")
+	assert.Equal(t, "", a.GetStateLabel(2))
+
+	clone := a.Clone()
+	clone.SetStateLabel(0, "changed")
+	assert.Equal(t, "first", a.GetStateLabel(0))
+
+	// Labels follow the states into composed automata:
+	c := NewAutomaton()
+	c.CreateState()
+	c.Copy(a)
+	assert.Equal(t, "first", c.GetStateLabel(1))
+
+	b := NewBuilder()
+	b.CreateState()
+	b.Copy(a)
+	b.SetStateLabel(0, "start")
+	built := b.Finish()
+	assert.Equal(t, "start", built.GetStateLabel(0))
+	assert.Equal(t, "first", built.GetStateLabel(1))
+
+	a.Freeze()
+	assert.PanicsWithValue(t, ErrFrozen, func() {
+		a.SetStateLabel(0, "frozen")
+	})
+
+	t.Run("testOperations", func(t *testing.T) {
+		labeled := func(s, name string) *Automaton {
+			a, err := MakeString(s)
+			assert.Nil(t, err)
+			for state := 0; state < a.GetNumStates(); state++ {
+				a.SetStateLabel(state, fmt.Sprintf("%s%d", name, state))
+			}
+			return a
+		}
+		labels := func(a *Automaton) []string {
+			labels := make([]string, a.GetNumStates())
+			for state := range labels {
+				labels[state] = a.GetStateLabel(state)
+			}
+			return labels
+		}
+
+		u, err := Union(labeled("ab", "x"), labeled("cd", "y"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"", "x1", "x2", "y1", "y2"}, labels(u))
+
+		// Without dead states, the states are copied without a cleanup:
+		x, err := RemoveDeadStates(labeled("ab", "x"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"x0", "x1", "x2"}, labels(x))
+		y, err := RemoveDeadStates(labeled("cd", "y"))
+		assert.Nil(t, err)
+		u, err = Union(x, y)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"", "x1", "x2", "y1", "y2"}, labels(u))
+
+		c, err := concatenate(labeled("ab", "x"), labeled("c", "y"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"x0", "x1", "x2", "y1"}, labels(c))
+
+		c, err = concatenate(labeled("ab", "x"), MustNewRegExp("c*").MustToAutomaton(), labeled("d", "y"))
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"x0", "x1", "x2", "", "y0", "y1"}, labels(c))
+	})
+}
@@ -0,0 +1,118 @@
+package automaton_test
+
+import (
+	"fmt"
+
+	"github.com/geange/automaton"
+)
+
+func ExampleRegExp_ToAutomaton() {
+	re, err := automaton.NewRegExp("a(b+|c+)d")
+	if err != nil {
+		panic(err)
+	}
+	a, err := re.ToAutomaton()
+	if err != nil {
+		panic(err)
+	}
+
+	for _, s := range []string{"abbbd", "acd", "ad"} {
+		fmt.Println(s, automaton.Run(a, s))
+	}
+	// Output:
+	// abbbd true
+	// acd true
+	// ad false
+}
+
+func ExampleRun() {
+	a, err := automaton.MakeString("hello")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(automaton.Run(a, "hello"))
+	fmt.Println(automaton.Run(a, "help"))
+	// Output:
+	// true
+	// false
+}
+
+func ExampleExportTrie() {
+	re, err := automaton.NewRegExp("dog|ca(r|t)")
+	if err != nil {
+		panic(err)
+	}
+	a, err := re.ToAutomaton()
+	if err != nil {
+		panic(err)
+	}
+
+	root, err := automaton.ExportTrie(a, false)
+	if err != nil {
+		panic(err)
+	}
+
+	// Enumerate the accepted strings in sorted order:
+	var walk func(node *automaton.TrieNode, prefix []byte)
+	walk = func(node *automaton.TrieNode, prefix []byte) {
+		if node.Accept {
+			fmt.Println(string(prefix))
+		}
+		for _, e := range node.Children {
+			walk(e.Node, append(prefix, e.Label))
+		}
+	}
+	walk(root, nil)
+	// Output:
+	// car
+	// cat
+	// dog
+}
+
+func ExampleByteRunAutomaton_Run() {
+	// All byte strings in ["b", "d"):
+	a, err := automaton.MakeBinaryInterval(
+		automaton.Bound{Value: []byte("b"), Inclusive: true},
+		automaton.Bound{Value: []byte("d")})
+	if err != nil {
+		panic(err)
+	}
+	r, err := automaton.NewByteRunAutomaton(a, true, automaton.DEFAULT_DETERMINIZE_WORK_LIMIT)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, s := range []string{"a", "b", "bzz", "c", "d"} {
+		fmt.Println(s, r.Run([]byte(s)))
+	}
+	// Output:
+	// a false
+	// b true
+	// bzz true
+	// c true
+	// d false
+}
+
+func ExampleSuggester() {
+	s, err := automaton.NewSuggester([]string{"apple", "apricot", "banana", "application", "apply"})
+	if err != nil {
+		panic(err)
+	}
+
+	completions, err := s.Suggest("app", 2)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(completions)
+
+	// Allow one edit (insertion, deletion or substitution) in the typed prefix:
+	completions, err = s.SuggestFuzzy("apl", 10)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(completions)
+	// Output:
+	// [apple application]
+	// [apple application apply apricot]
+}
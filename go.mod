@@ -5,6 +5,7 @@ go 1.24.3
 require (
 	github.com/bits-and-blooms/bitset v1.22.0
 	github.com/stretchr/testify v1.10.0
+	golang.org/x/text v0.26.0
 )
 
 require (
@@ -0,0 +1,52 @@
+package automaton
+
+// Hash64 Returns a fingerprint of the structure of this automaton: its alphabet, accept states and transitions,
+// state by state. Automata that are StructurallyEqual (and have the same alphabet) have the same hash, so it can
+// key a cache of composed automata, with StructurallyEqual resolving collisions. Automata accepting the same
+// language but numbered differently usually hash differently, see LanguageHash. The hash is stable across
+// processes. It is computed on each call while the automaton can still change, and only once after Freeze.
+func (a *Automaton) Hash64() uint64 {
+	if h := a.hash.Load(); h != nil {
+		return *h
+	}
+	numStates := a.GetNumStates()
+	h := mix64(uint64(a.alphabet) + PHI_C64)
+	combine := func(v int32) {
+		h = mix64(h*PHI_C64 + uint64(uint32(v)))
+	}
+	combine(int32(numStates))
+	for s := 0; s < numStates; s++ {
+		offset, count := a.states[2*s], a.states[2*s+1]
+		if a.IsAccept(s) {
+			combine(-1 - count)
+		} else {
+			combine(count)
+		}
+		for i := int32(0); i < count; i++ {
+			t := a.transitions[offset+i]
+			combine(t.dest)
+			combine(t.min)
+			combine(t.max)
+		}
+	}
+	if a.frozen {
+		a.hash.Store(&h)
+	}
+	return h
+}
+
+// LanguageHash Returns a fingerprint of the language of the automaton: the Hash64 of its minimal deterministic
+// automaton with the states numbered canonically (see Canonicalize). Automata accepting the same strings, with
+// the same alphabet, have the same hash however they were built, so it can deduplicate automata by language. It
+// determinizes and minimizes the automaton, with the given work limit, so it is much slower than Hash64.
+func LanguageHash(a *Automaton, determinizeWorkLimit int) (uint64, error) {
+	m, err := Minimize(a, determinizeWorkLimit)
+	if err != nil {
+		return 0, err
+	}
+	m, err = Canonicalize(m)
+	if err != nil {
+		return 0, err
+	}
+	return m.Hash64(), nil
+}
@@ -0,0 +1,49 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash64(t *testing.T) {
+	a1 := MustNewRegExp("ab+c").MustToAutomaton()
+	a2 := MustNewRegExp("ab+c").MustToAutomaton()
+	assert.Equal(t, a1.Hash64(), a2.Hash64())
+	assert.NotEqual(t, a1.Hash64(), MustNewRegExp("ab+d").MustToAutomaton().Hash64())
+	assert.NotEqual(t, MakeEmpty().Hash64(), MakeEmptyString().Hash64())
+
+	// The alphabet is part of the structure:
+	b, err := MakeBinary([]byte("a"))
+	assert.Nil(t, err)
+	s, err := MakeString("a")
+	assert.Nil(t, err)
+	assert.True(t, StructurallyEqual(b, s))
+	assert.NotEqual(t, b.Hash64(), s.Hash64())
+
+	// The hash is cached once frozen:
+	h := a1.Hash64()
+	a1.Freeze()
+	assert.Equal(t, h, a1.Hash64())
+	assert.Equal(t, h, a1.Hash64())
+
+	// States without transitions are hashed too:
+	a := NewAutomaton()
+	a.CreateState()
+	a.CreateState()
+	assert.NotEqual(t, MakeEmpty().Hash64(), a.Hash64())
+}
+
+func TestLanguageHash(t *testing.T) {
+	h1, err := LanguageHash(MustNewRegExp("(a|b)*").MustToAutomaton(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	nfa, err := Repeat(MustNewRegExp("a*b*").MustToAutomaton())
+	assert.Nil(t, err)
+	h2, err := LanguageHash(nfa, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.Equal(t, h1, h2)
+
+	h3, err := LanguageHash(MustNewRegExp("(a|b)+").MustToAutomaton(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.NotEqual(t, h1, h3)
+}
@@ -0,0 +1,394 @@
+package automaton
+
+import (
+	"iter"
+	"math"
+	"sync/atomic"
+)
+
+const (
+	// Minimum length of the slot arrays of an IntIntHashMap.
+	MIN_HASH_ARRAY_LENGTH = 4
+
+	// Maximum length of the slot arrays of an IntIntHashMap.
+	MAX_HASH_ARRAY_LENGTH = 1 << 30
+
+	// Default number of entries an IntIntHashMap is sized for.
+	DEFAULT_EXPECTED_ELEMENTS = 4
+
+	// Default load factor of an IntIntHashMap.
+	DEFAULT_LOAD_FACTOR = 0.75
+)
+
+// iterationSeeds The sequence the iteration seeds of the maps are drawn from. It is not random, so a program
+// iterates its maps in the same orders from run to run, but consecutive maps get different seeds.
+var iterationSeeds atomic.Int64
+
+// nextIterationSeed Returns the seed of the iteration order of a new map.
+func nextIterationSeed() int {
+	return mixPhi(int(iterationSeeds.Add(1)))
+}
+
+// IntIntHashMap A hash map of int keys to int values using open addressing with linear probing, ported from
+// HPPC's IntIntHashMap (through Lucene). The entries are kept in two flat arrays, so the map does not allocate
+// per entry, and Clear keeps them for reuse.
+//
+// The iteration order of Keys, Values, Iterator and ForEach depends on a seed drawn for every map, so two maps
+// with the same entries usually iterate them in different orders; callers must not depend on it.
+type IntIntHashMap struct {
+	// The keys and values of the slots; the key 0 marks an empty slot, so the entry of key 0 is stored at the
+	// extra slot mask+1.
+	keys, values []int
+
+	// Number of slots in use, not counting the one of key 0.
+	assigned int
+
+	// Number of slots - 1.
+	mask int
+
+	// Value of assigned at which the arrays are grown.
+	resizeAt int
+
+	hasEmptyKey   bool
+	loadFactor    float64
+	iterationSeed int
+}
+
+// NewIntIntHashMap Creates a map sized for the number of entries set by WithCapacity, DEFAULT_EXPECTED_ELEMENTS
+// by default, with the load factor set by WithLoadFactory, DEFAULT_LOAD_FACTOR by default.
+func NewIntIntHashMap(options ...OptionsHashMap) *IntIntHashMap {
+	opt := &optionsHashMap{
+		capacity:    DEFAULT_EXPECTED_ELEMENTS,
+		loadFactory: DEFAULT_LOAD_FACTOR,
+	}
+	for _, fn := range options {
+		fn(opt)
+	}
+	if opt.loadFactory <= 0 || opt.loadFactory >= 1 {
+		panic("load factor must be in (0, 1)")
+	}
+
+	m := &IntIntHashMap{
+		loadFactor:    opt.loadFactory,
+		iterationSeed: nextIterationSeed(),
+	}
+	m.EnsureCapacity(opt.capacity)
+	return m
+}
+
+// Put Sets the value of key and returns its previous value, or 0 if there was none.
+func (m *IntIntHashMap) Put(key, value int) int {
+	if key == 0 {
+		previous := m.values[m.mask+1]
+		m.hasEmptyKey = true
+		m.values[m.mask+1] = value
+		return previous
+	}
+
+	slot := m.hashKey(key) & m.mask
+	for existing := m.keys[slot]; existing != 0; existing = m.keys[slot] {
+		if existing == key {
+			previous := m.values[slot]
+			m.values[slot] = value
+			return previous
+		}
+		slot = (slot + 1) & m.mask
+	}
+
+	if m.assigned == m.resizeAt {
+		m.allocateThenInsertThenRehash(slot, key, value)
+	} else {
+		m.keys[slot] = key
+		m.values[slot] = value
+	}
+	m.assigned++
+	return 0
+}
+
+// PutIfAbsent Sets the value of key if it has none, and returns true if it did.
+func (m *IntIntHashMap) PutIfAbsent(key, value int) bool {
+	if m.ContainsKey(key) {
+		return false
+	}
+	m.Put(key, value)
+	return true
+}
+
+// AddTo Adds increment to the value of key, which is 0 if it has none, and returns the new value.
+func (m *IntIntHashMap) AddTo(key, increment int) int {
+	return m.PutOrAdd(key, increment, increment)
+}
+
+// PutOrAdd Sets the value of key to putValue if it has none, and adds incrementValue to it otherwise. Returns
+// the new value.
+func (m *IntIntHashMap) PutOrAdd(key, putValue, incrementValue int) int {
+	if index := m.indexOf(key); index >= 0 {
+		m.values[index] += incrementValue
+		return m.values[index]
+	}
+	m.Put(key, putValue)
+	return putValue
+}
+
+// Get Returns the value of key, or 0 if it has none.
+func (m *IntIntHashMap) Get(key int) int {
+	return m.GetOrDefault(key, 0)
+}
+
+// GetOrDefault Returns the value of key, or defaultValue if it has none.
+func (m *IntIntHashMap) GetOrDefault(key, defaultValue int) int {
+	if index := m.indexOf(key); index >= 0 {
+		return m.values[index]
+	}
+	return defaultValue
+}
+
+// ContainsKey Returns true if key has a value.
+func (m *IntIntHashMap) ContainsKey(key int) bool {
+	return m.indexOf(key) >= 0
+}
+
+// Remove Removes the value of key and returns it, or 0 if it had none.
+func (m *IntIntHashMap) Remove(key int) int {
+	if key == 0 {
+		if !m.hasEmptyKey {
+			return 0
+		}
+		previous := m.values[m.mask+1]
+		m.hasEmptyKey = false
+		m.values[m.mask+1] = 0
+		return previous
+	}
+
+	slot := m.indexOf(key)
+	if slot < 0 {
+		return 0
+	}
+	previous := m.values[slot]
+	m.shiftConflictingKeys(slot)
+	return previous
+}
+
+// Size Returns the number of entries.
+func (m *IntIntHashMap) Size() int {
+	if m.hasEmptyKey {
+		return m.assigned + 1
+	}
+	return m.assigned
+}
+
+// IsEmpty Returns true if the map has no entries.
+func (m *IntIntHashMap) IsEmpty() bool {
+	return m.Size() == 0
+}
+
+// Clear Removes all entries, keeping the arrays for reuse.
+func (m *IntIntHashMap) Clear() {
+	clear(m.keys)
+	clear(m.values)
+	m.assigned = 0
+	m.hasEmptyKey = false
+}
+
+// EnsureCapacity Grows the map so that it holds expectedElements entries without growing again.
+func (m *IntIntHashMap) EnsureCapacity(expectedElements int) {
+	if m.keys != nil && expectedElements <= m.resizeAt {
+		return
+	}
+	keys, values, hasEmptyKey := m.keys, m.values, m.hasEmptyKey
+	m.allocateBuffers(minBufferSize(expectedElements, m.loadFactor))
+	if keys != nil {
+		m.rehash(keys, values)
+	}
+	if hasEmptyKey {
+		m.hasEmptyKey = true
+		m.values[m.mask+1] = values[len(values)-1]
+	}
+}
+
+// Clone Returns a copy of the map, with an iteration seed of its own.
+func (m *IntIntHashMap) Clone() *IntIntHashMap {
+	c := *m
+	c.keys = append([]int(nil), m.keys...)
+	c.values = append([]int(nil), m.values...)
+	c.iterationSeed = nextIterationSeed()
+	return &c
+}
+
+// Iterator Returns the entries of the map in the order of its iteration seed. The map must not be modified
+// during the iteration.
+func (m *IntIntHashMap) Iterator() iter.Seq2[int, int] {
+	return func(yield func(int, int) bool) {
+		seed := m.iterationSeed
+		increment := iterationIncrement(seed)
+		// increment is odd, so it visits every slot once:
+		slot := seed & m.mask
+		for i := 0; i <= m.mask; i++ {
+			slot = (slot + increment) & m.mask
+			if m.keys[slot] != 0 && !yield(m.keys[slot], m.values[slot]) {
+				return
+			}
+		}
+		if m.hasEmptyKey {
+			yield(0, m.values[m.mask+1])
+		}
+	}
+}
+
+// Keys Returns the keys of the map, in the order of Iterator.
+func (m *IntIntHashMap) Keys() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for key := range m.Iterator() {
+			if !yield(key) {
+				return
+			}
+		}
+	}
+}
+
+// Values Returns the values of the map, in the order of Iterator.
+func (m *IntIntHashMap) Values() iter.Seq[int] {
+	return func(yield func(int) bool) {
+		for _, value := range m.Iterator() {
+			if !yield(value) {
+				return
+			}
+		}
+	}
+}
+
+// ForEach Calls fn with every entry of the map, in the order of Iterator, until it returns false.
+func (m *IntIntHashMap) ForEach(fn func(key, value int) bool) {
+	for key, value := range m.Iterator() {
+		if !fn(key, value) {
+			return
+		}
+	}
+}
+
+// indexOf Returns the slot of key, or -1 if it has no value.
+func (m *IntIntHashMap) indexOf(key int) int {
+	if key == 0 {
+		if m.hasEmptyKey {
+			return m.mask + 1
+		}
+		return -1
+	}
+	slot := m.hashKey(key) & m.mask
+	for existing := m.keys[slot]; existing != 0; existing = m.keys[slot] {
+		if existing == key {
+			return slot
+		}
+		slot = (slot + 1) & m.mask
+	}
+	return -1
+}
+
+func (m *IntIntHashMap) hashKey(key int) int {
+	return mixPhi(key)
+}
+
+// allocateBuffers Replaces the arrays with empty ones of arraySize slots, plus the one of key 0.
+func (m *IntIntHashMap) allocateBuffers(arraySize int) {
+	m.keys = make([]int, arraySize+1)
+	m.values = make([]int, arraySize+1)
+	m.assigned = 0
+	m.hasEmptyKey = false
+	m.resizeAt = expandAtCount(arraySize, m.loadFactor)
+	m.mask = arraySize - 1
+}
+
+// rehash Inserts the entries of the old arrays, except the one of key 0, into the current arrays.
+func (m *IntIntHashMap) rehash(keys, values []int) {
+	for i := len(keys) - 2; i >= 0; i-- {
+		if key := keys[i]; key != 0 {
+			slot := m.hashKey(key) & m.mask
+			for m.keys[slot] != 0 {
+				slot = (slot + 1) & m.mask
+			}
+			m.keys[slot] = key
+			m.values[slot] = values[i]
+			m.assigned++
+		}
+	}
+}
+
+// allocateThenInsertThenRehash Grows the arrays when inserting key in the free slot would exceed resizeAt.
+func (m *IntIntHashMap) allocateThenInsertThenRehash(slot, key, value int) {
+	keys, values, hasEmptyKey := m.keys, m.values, m.hasEmptyKey
+	keys[slot] = key
+	values[slot] = value
+	m.allocateBuffers(nextBufferSize(m.mask + 1))
+	m.rehash(keys, values)
+	// rehash counted the inserted key, which Put counts again:
+	m.assigned--
+	if hasEmptyKey {
+		m.hasEmptyKey = true
+		m.values[m.mask+1] = values[len(values)-1]
+	}
+}
+
+// shiftConflictingKeys Removes the entry at gapSlot, moving the entries of the following slots that probed
+// past it back, so that lookups don't stop at the gap.
+func (m *IntIntHashMap) shiftConflictingKeys(gapSlot int) {
+	distance := 0
+	for {
+		distance++
+		slot := (gapSlot + distance) & m.mask
+		existing := m.keys[slot]
+		if existing == 0 {
+			break
+		}
+		idealSlot := m.hashKey(existing) & m.mask
+		shift := (slot - idealSlot) & m.mask
+		if shift >= distance {
+			// The entry at slot probed past the gap, move it there:
+			m.keys[gapSlot] = existing
+			m.values[gapSlot] = m.values[slot]
+			gapSlot = slot
+			distance = 0
+		}
+	}
+	m.keys[gapSlot] = 0
+	m.values[gapSlot] = 0
+	m.assigned--
+}
+
+// iterationIncrement Returns the odd step of the iteration order of seed, between 29 and 43.
+func iterationIncrement(seed int) int {
+	return 29 + ((seed & 7) << 1)
+}
+
+// minBufferSize Returns the number of slots needed to hold elements entries at the load factor.
+func minBufferSize(elements int, loadFactor float64) int {
+	length := int(math.Ceil(float64(elements) / loadFactor))
+	if length == elements {
+		length++
+	}
+	length = max(MIN_HASH_ARRAY_LENGTH, nextHighestPowerOfTwo(length))
+	if length > MAX_HASH_ARRAY_LENGTH {
+		panic("maximum array size exceeded for this load factor")
+	}
+	return length
+}
+
+// nextBufferSize Returns the number of slots after growing arraySize slots.
+func nextBufferSize(arraySize int) int {
+	if arraySize == MAX_HASH_ARRAY_LENGTH {
+		panic("maximum array size exceeded for this load factor")
+	}
+	return arraySize << 1
+}
+
+// expandAtCount Returns the number of entries of arraySize slots at which they are grown.
+func expandAtCount(arraySize int, loadFactor float64) int {
+	return min(arraySize-1, int(math.Ceil(float64(arraySize)*loadFactor)))
+}
+
+func nextHighestPowerOfTwo(v int) int {
+	n := 1
+	for n < v {
+		n <<= 1
+	}
+	return n
+}
@@ -0,0 +1,210 @@
+package automaton
+
+import (
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntIntHashMap(t *testing.T) {
+	t.Run("testPutGet", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		assert.True(t, m.IsEmpty())
+		assert.Equal(t, 0, m.Put(1, 10))
+		assert.Equal(t, 10, m.Put(1, 11))
+		assert.Equal(t, 0, m.Put(-7, 70))
+		assert.Equal(t, 2, m.Size())
+
+		assert.Equal(t, 11, m.Get(1))
+		assert.Equal(t, 70, m.Get(-7))
+		assert.Equal(t, 0, m.Get(2))
+		assert.Equal(t, 5, m.GetOrDefault(2, 5))
+		assert.True(t, m.ContainsKey(-7))
+		assert.False(t, m.ContainsKey(2))
+
+		assert.False(t, m.PutIfAbsent(1, 12))
+		assert.True(t, m.PutIfAbsent(2, 20))
+		assert.Equal(t, 11, m.Get(1))
+		assert.Equal(t, 20, m.Get(2))
+	})
+
+	t.Run("testEmptyKey", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		assert.False(t, m.ContainsKey(0))
+		assert.Equal(t, 0, m.Put(0, 5))
+		assert.True(t, m.ContainsKey(0))
+		assert.Equal(t, 5, m.Get(0))
+		assert.Equal(t, 1, m.Size())
+
+		// The entry of key 0 survives growing:
+		for i := 1; i <= 100; i++ {
+			m.Put(i, i)
+		}
+		assert.Equal(t, 5, m.Get(0))
+		assert.Equal(t, 101, m.Size())
+
+		assert.Equal(t, 5, m.Remove(0))
+		assert.False(t, m.ContainsKey(0))
+		assert.Equal(t, 0, m.Remove(0))
+		assert.Equal(t, 100, m.Size())
+	})
+
+	t.Run("testAddTo", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		assert.Equal(t, 3, m.AddTo(1, 3))
+		assert.Equal(t, 5, m.AddTo(1, 2))
+		assert.Equal(t, 7, m.PutOrAdd(2, 7, 1))
+		assert.Equal(t, 8, m.PutOrAdd(2, 7, 1))
+		assert.Equal(t, 1, m.AddTo(0, 1))
+		assert.Equal(t, 2, m.AddTo(0, 1))
+	})
+
+	t.Run("testRemoveConflicting", func(t *testing.T) {
+		// Keys sharing a slot of a small map must stay reachable when one before them is removed:
+		m := NewIntIntHashMap(WithCapacity(16))
+		var keys []int
+		for key := 1; len(keys) < 6; key++ {
+			if m.hashKey(key)&m.mask == m.hashKey(1)&m.mask {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			m.Put(key, -key)
+		}
+		assert.Equal(t, -keys[0], m.Remove(keys[0]))
+		assert.Equal(t, -keys[3], m.Remove(keys[3]))
+		for i, key := range keys {
+			assert.Equal(t, i != 0 && i != 3, m.ContainsKey(key))
+			if i != 0 && i != 3 {
+				assert.Equal(t, -key, m.Get(key))
+			}
+		}
+		assert.Equal(t, 4, m.Size())
+	})
+
+	t.Run("testEnsureCapacity", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		m.Put(1, 1)
+		m.Put(0, 2)
+		m.EnsureCapacity(1000)
+		assert.GreaterOrEqual(t, m.resizeAt, 1000)
+		keys := m.keys
+		for i := 1; i <= 1000; i++ {
+			m.AddTo(i, 1)
+		}
+		// No growing while filling:
+		assert.Equal(t, &keys[0], &m.keys[0])
+		assert.Equal(t, 2, m.Get(1))
+		assert.Equal(t, 2, m.Get(0))
+		assert.Equal(t, 1001, m.Size())
+	})
+
+	t.Run("testClear", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		for i := 0; i < 50; i++ {
+			m.Put(i, i)
+		}
+		m.Clear()
+		assert.True(t, m.IsEmpty())
+		assert.False(t, m.ContainsKey(0))
+		assert.False(t, m.ContainsKey(10))
+		assert.Empty(t, slices.Collect(m.Keys()))
+		m.Put(10, 1)
+		assert.Equal(t, 1, m.Get(10))
+	})
+
+	t.Run("testClone", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		for i := 0; i < 50; i++ {
+			m.Put(i, i)
+		}
+		c := m.Clone()
+		c.Put(1, 100)
+		c.Remove(2)
+		assert.Equal(t, 1, m.Get(1))
+		assert.True(t, m.ContainsKey(2))
+		assert.Equal(t, 100, c.Get(1))
+		assert.False(t, c.ContainsKey(2))
+		assert.NotEqual(t, m.iterationSeed, c.iterationSeed)
+	})
+
+	t.Run("testIteration", func(t *testing.T) {
+		m := NewIntIntHashMap()
+		expected := map[int]int{}
+		for i := -50; i <= 50; i++ {
+			m.Put(i*7, i)
+			expected[i*7] = i
+		}
+		assert.Equal(t, expected, maps.Collect(m.Iterator()))
+
+		// Keys and Values follow the order of Iterator, which doesn't change between iterations:
+		var keys, values []int
+		for key, value := range m.Iterator() {
+			keys = append(keys, key)
+			values = append(values, value)
+		}
+		assert.Equal(t, keys, slices.Collect(m.Keys()))
+		assert.Equal(t, values, slices.Collect(m.Values()))
+
+		var forEachKeys []int
+		m.ForEach(func(key, value int) bool {
+			forEachKeys = append(forEachKeys, key)
+			return len(forEachKeys) < 10
+		})
+		assert.Equal(t, keys[:10], forEachKeys)
+
+		for key := range m.Keys() {
+			assert.Equal(t, keys[0], key)
+			break
+		}
+	})
+
+	t.Run("testIterationOrder", func(t *testing.T) {
+		// Maps with the same entries iterate them in different orders, mostly:
+		orders := map[string]bool{}
+		for i := 0; i < 10; i++ {
+			m := NewIntIntHashMap()
+			for key := 1; key <= 20; key++ {
+				m.Put(key, key)
+			}
+			orders[fmt.Sprint(slices.Collect(m.Keys()))] = true
+		}
+		assert.Greater(t, len(orders), 1)
+	})
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1619))
+		m := NewIntIntHashMap(WithLoadFactory(0.5))
+		expected := map[int]int{}
+		for i := 0; i < 20000; i++ {
+			key := r.Intn(500) - 100
+			switch r.Intn(4) {
+			case 0:
+				assert.Equal(t, expected[key], m.Put(key, i))
+				expected[key] = i
+			case 1:
+				expected[key] += 3
+				assert.Equal(t, expected[key], m.AddTo(key, 3))
+			case 2:
+				assert.Equal(t, expected[key], m.Remove(key))
+				delete(expected, key)
+			default:
+				_, ok := expected[key]
+				assert.Equal(t, ok, m.ContainsKey(key))
+				assert.Equal(t, expected[key], m.Get(key))
+			}
+			assert.Equal(t, len(expected), m.Size())
+		}
+		assert.Equal(t, expected, maps.Collect(m.Iterator()))
+	})
+
+	t.Run("testInvalidLoadFactor", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewIntIntHashMap(WithLoadFactory(1))
+		})
+	})
+}
@@ -61,11 +61,17 @@ func (f *FrozenIntSet) Equals(other Hashable) bool {
 		}
 	}
 
-	is, ok := other.(IntSet)
-	if !ok {
+	switch o := other.(type) {
+	case *FrozenIntSet:
+		if o == nil {
+			return false
+		}
+		return f.hashCode == o.hashCode && f.state == o.state && slices.Equal(f.values, o.values)
+	case IntSet:
+		return f.Hash() == o.Hash() && slices.Equal(f.values, o.GetArray())
+	default:
 		return false
 	}
-	return is.Hash() == f.Hash()
 }
 
 func NewFrozenIntSet(values []int, hashCode uint64, state int) *FrozenIntSet {
@@ -83,14 +89,14 @@ func (f *FrozenIntSet) Size() int {
 var _ IntSet = &StateSet{}
 
 type StateSet struct {
-	inner       map[int]int
+	inner       *IntIntHashMap
 	hashUpdated bool
 	hashCode    uint64
 }
 
 func NewStateSet() *StateSet {
 	return &StateSet{
-		inner: make(map[int]int),
+		inner: NewIntIntHashMap(),
 	}
 }
 
@@ -98,8 +104,8 @@ func (s *StateSet) Hash() uint64 {
 	if s.hashUpdated {
 		return s.hashCode
 	}
-	s.hashCode = uint64(len(s.inner))
-	for key := range s.inner {
+	s.hashCode = uint64(s.inner.Size())
+	for key := range s.inner.Keys() {
 		s.hashCode += uint64(mix(key))
 	}
 	s.hashUpdated = true
@@ -111,13 +117,13 @@ func (s *StateSet) Equals(other Hashable) bool {
 	if !ok {
 		return false
 	}
-	return s.Hash() == is.Hash()
+	return s.Hash() == is.Hash() && slices.Equal(s.GetArray(), is.GetArray())
 }
 
 func (s *StateSet) GetArray() []int {
-	keys := make([]int, 0, len(s.inner))
+	keys := make([]int, 0, s.inner.Size())
 
-	for k := range s.inner {
+	for k := range s.inner.Keys() {
 		keys = append(keys, k)
 	}
 	slices.Sort(keys)
@@ -125,7 +131,7 @@ func (s *StateSet) GetArray() []int {
 }
 
 func (s *StateSet) Size() int {
-	return len(s.inner)
+	return s.inner.Size()
 }
 
 func (s *StateSet) keyChanged() {
@@ -134,22 +140,21 @@ func (s *StateSet) keyChanged() {
 }
 
 func (s *StateSet) Incr(state int) {
-	s.inner[state]++
-	if s.inner[state] == 1 {
+	if s.inner.AddTo(state, 1) == 1 {
 		s.keyChanged()
 	}
 }
 
 func (s *StateSet) Decr(state int) {
-	count, ok := s.inner[state]
-	if !ok {
+	count := s.inner.GetOrDefault(state, 0)
+	if count == 0 {
 		return
 	}
 	if count == 1 {
-		delete(s.inner, state)
+		s.inner.Remove(state)
 		s.keyChanged()
 	} else {
-		s.inner[state]--
+		s.inner.AddTo(state, -1)
 	}
 }
 
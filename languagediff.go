@@ -0,0 +1,131 @@
+package automaton
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bits-and-blooms/bitset"
+)
+
+// LanguageDiff Example strings accepted by only one of two automata, see DiffLanguages.
+type LanguageDiff struct {
+	// Strings accepted by the first automaton but not the second, shortest first.
+	OnlyInFirst []string
+
+	// Strings accepted by the second automaton but not the first, shortest first.
+	OnlyInSecond []string
+}
+
+// Empty Returns true if no differences were found, i.e. both automata accept the same language.
+func (d *LanguageDiff) Empty() bool {
+	return len(d.OnlyInFirst) == 0 && len(d.OnlyInSecond) == 0
+}
+
+// String Describes the differences, e.g. for a test failure message.
+func (d *LanguageDiff) String() string {
+	if d.Empty() {
+		return "same language"
+	}
+	b := new(strings.Builder)
+	if len(d.OnlyInFirst) > 0 {
+		fmt.Fprintf(b, "only in first: %q", d.OnlyInFirst)
+	}
+	if len(d.OnlyInSecond) > 0 {
+		if b.Len() > 0 {
+			b.WriteString("; ")
+		}
+		fmt.Fprintf(b, "only in second: %q", d.OnlyInSecond)
+	}
+	return b.String()
+}
+
+// DiffLanguages Returns up to maxExamples of the shortest strings accepted by a1 but not a2, and up to
+// maxExamples accepted by a2 but not a1; the diff is empty if and only if both accept the same language. Labels
+// of a binary automaton (see Alphabet) become bytes, other labels code points. Both differences are determinized
+// with DefaultWorkLimit().
+func DiffLanguages(a1, a2 *Automaton, maxExamples int) (*LanguageDiff, error) {
+	if maxExamples <= 0 {
+		return nil, errors.New("maxExamples must be > 0")
+	}
+	onlyInFirst, err := minusExamples(a1, a2, maxExamples)
+	if err != nil {
+		return nil, err
+	}
+	onlyInSecond, err := minusExamples(a2, a1, maxExamples)
+	if err != nil {
+		return nil, err
+	}
+	return &LanguageDiff{OnlyInFirst: onlyInFirst, OnlyInSecond: onlyInSecond}, nil
+}
+
+// minusExamples Returns up to n of the shortest strings accepted by a1 but not a2.
+func minusExamples(a1, a2 *Automaton, n int) ([]string, error) {
+	// A binary automaton complements over the bytes only, which would miss the code points of a1 above them:
+	if a2.alphabet == ALPHABET_BINARY && a1.alphabet != ALPHABET_BINARY {
+		a2 = a2.Clone()
+		a2.alphabet = ALPHABET_UNICODE
+	}
+	notA2, err := complement(a2, DefaultWorkLimit())
+	if err != nil {
+		return nil, err
+	}
+	minus, err := intersection(a1, notA2)
+	if err != nil {
+		return nil, err
+	}
+	minus, err = determinize(minus, DefaultWorkLimit())
+	if err != nil {
+		return nil, err
+	}
+	// A loop through a dead state would make a finite difference look infinite:
+	minus, err = RemoveDeadStates(minus)
+	if err != nil {
+		return nil, err
+	}
+	return shortestAcceptedStrings(minus, a1.Alphabet(), n), nil
+}
+
+// shortestAcceptedStrings Returns up to n strings accepted by the given deterministic automaton without dead
+// states, ordered by length and then by labels. Strings of each length are enumerated with a depth first
+// search that follows the smallest label of every transition, pruned to the states from which an accept state
+// is reachable in exactly the remaining number of steps, so every string costs O(length * transitions).
+func shortestAcceptedStrings(a *Automaton, alphabet Alphabet, n int) []string {
+	var result []string
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return result
+	}
+	finite := IsFiniteAutomaton(a).Load()
+
+	// exact[k] holds the states from which an accept state is reached in exactly k steps:
+	exact := []*bitset.BitSet{a.getAcceptStates().Clone()}
+	labels := make([]int, 0)
+	var enumerate func(state, remaining int)
+	enumerate = func(state, remaining int) {
+		if remaining == 0 {
+			result = append(result, labelsToString(labels, alphabet))
+			return
+		}
+		for _, t := range a.TransitionsSlice(state) {
+			if len(result) == n {
+				return
+			}
+			if exact[remaining-1].Test(uint(t.Dest)) {
+				labels = append(labels, t.Min)
+				enumerate(t.Dest, remaining-1)
+				labels = labels[:len(labels)-1]
+			}
+		}
+	}
+	// A finite language has no string longer than the number of states:
+	for length := 0; len(result) < n && (!finite || length < numStates); length++ {
+		if length > 0 {
+			exact = append(exact, statesWithTransitionTo(a, exact[length-1]))
+		}
+		if exact[length].Test(0) {
+			enumerate(0, length)
+		}
+	}
+	return result
+}
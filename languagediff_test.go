@@ -0,0 +1,47 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffLanguages(t *testing.T) {
+	t.Run("testSameLanguage", func(t *testing.T) {
+		a1 := MustNewRegExp("(a|b)*").MustToAutomaton()
+		a2 := MustNewRegExp("(a*b*)*").MustToAutomaton()
+		diff, err := DiffLanguages(a1, a2, 3)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty())
+		assert.Equal(t, "same language", diff.String())
+	})
+
+	t.Run("testExamples", func(t *testing.T) {
+		a1 := MustNewRegExp("a+b?").MustToAutomaton()
+		a2 := MustNewRegExp("a*|c").MustToAutomaton()
+		diff, err := DiffLanguages(a1, a2, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"ab", "aab", "aaab"}, diff.OnlyInFirst)
+		assert.Equal(t, []string{"", "c"}, diff.OnlyInSecond)
+		assert.Equal(t, `only in first: ["ab" "aab" "aaab"]; only in second: ["" "c"]`, diff.String())
+
+		// Strings are found without enumerating all paths of their length:
+		a1 = MustNewRegExp("[ac]{12}d").MustToAutomaton()
+		diff, err = DiffLanguages(a1, MakeEmpty(), 2)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"aaaaaaaaaaaad", "aaaaaaaaaaacd"}, diff.OnlyInFirst)
+		assert.Empty(t, diff.OnlyInSecond)
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		a1, err := MakeBinary([]byte{0xff})
+		assert.Nil(t, err)
+		diff, err := DiffLanguages(a1, MakeEmptyString(), 5)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"\xff"}, diff.OnlyInFirst)
+		assert.Equal(t, []string{""}, diff.OnlyInSecond)
+	})
+
+	_, err := DiffLanguages(MakeEmpty(), MakeEmpty(), 0)
+	assert.Error(t, err)
+}
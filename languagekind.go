@@ -0,0 +1,73 @@
+package automaton
+
+// LanguageKind Classifies the language accepted by an automaton, see Classify.
+type LanguageKind int
+
+const (
+	LANGUAGE_EMPTY         = LanguageKind(iota) // Accepts no strings
+	LANGUAGE_ANY_STRING                         // Accepts all strings over the alphabet
+	LANGUAGE_EMPTY_STRING                       // Accepts only the empty string
+	LANGUAGE_SINGLE_STRING                      // Accepts exactly one non-empty string
+	LANGUAGE_SINGLE_CHAR                        // Accepts the one character strings of a range of more than one label
+	LANGUAGE_OTHER                              // Any other language
+)
+
+// String Returns the name of the kind.
+func (k LanguageKind) String() string {
+	switch k {
+	case LANGUAGE_EMPTY:
+		return "empty"
+	case LANGUAGE_ANY_STRING:
+		return "anyString"
+	case LANGUAGE_EMPTY_STRING:
+		return "emptyString"
+	case LANGUAGE_SINGLE_STRING:
+		return "singleString"
+	case LANGUAGE_SINGLE_CHAR:
+		return "singleChar"
+	}
+	return "other"
+}
+
+// Classify Returns the kind of the language accepted by the given automaton, which must be deterministic and
+// minimal (see Minimize); otherwise simple languages may be classified as LANGUAGE_OTHER. This is cheap: apart
+// from the emptiness check, at most the states along a single string are visited.
+func Classify(a *Automaton) LanguageKind {
+	switch {
+	case IsEmptyAutomaton(a):
+		return LANGUAGE_EMPTY
+	case IsAnyString(a):
+		return LANGUAGE_ANY_STRING
+	case a.IsAccept(0) && a.GetNumTransitionsWithState(0) == 0:
+		return LANGUAGE_EMPTY_STRING
+	}
+	if min, max, ok := IsSingleChar(a); ok && min < max {
+		return LANGUAGE_SINGLE_CHAR
+	}
+	if a.IsDeterministic() {
+		if singleton, _ := GetSingletonAutomaton(a); singleton != nil {
+			return LANGUAGE_SINGLE_STRING
+		}
+	}
+	return LANGUAGE_OTHER
+}
+
+// IsAnyString Returns true if the given automaton accepts all strings over its alphabet (all bytes for binary
+// automata). Like IsTotalAutomaton the automaton must be minimal.
+func IsAnyString(a *Automaton) bool {
+	return a.GetNumStates() > 0 && IsTotalAutomatonRange(a, 0, a.alphabet.maxLabel())
+}
+
+// IsSingleChar Returns the range of labels [min, max] if the given automaton accepts exactly the strings of
+// one label within that range. The automaton must be minimal.
+func IsSingleChar(a *Automaton) (min, max int, ok bool) {
+	if a.GetNumStates() != 2 || a.IsAccept(0) || a.GetNumTransitionsWithState(0) != 1 {
+		return 0, 0, false
+	}
+	t := NewTransition()
+	a.getTransition(0, 0, t)
+	if t.Dest != 1 || !a.IsAccept(1) || a.GetNumTransitionsWithState(1) != 0 {
+		return 0, 0, false
+	}
+	return t.Min, t.Max, true
+}
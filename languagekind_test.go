@@ -0,0 +1,60 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	for pattern, kind := range map[string]LanguageKind{
+		"#":          LANGUAGE_EMPTY,
+		"a&b":        LANGUAGE_EMPTY,
+		"@":          LANGUAGE_ANY_STRING,
+		".*":         LANGUAGE_ANY_STRING,
+		"()":         LANGUAGE_EMPTY_STRING,
+		"a{0}":       LANGUAGE_EMPTY_STRING,
+		"a":          LANGUAGE_SINGLE_STRING,
+		"abc":        LANGUAGE_SINGLE_STRING,
+		"ab(c|c)":    LANGUAGE_SINGLE_STRING,
+		"[a-z]":      LANGUAGE_SINGLE_CHAR,
+		".":          LANGUAGE_SINGLE_CHAR,
+		"a|b":        LANGUAGE_SINGLE_CHAR,
+		"[ac]":       LANGUAGE_OTHER,
+		"a?":         LANGUAGE_OTHER,
+		"a*":         LANGUAGE_OTHER,
+		"[a-z][0-9]": LANGUAGE_OTHER,
+	} {
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		a, err = Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, kind, Classify(a), pattern)
+	}
+
+	t.Run("testIsSingleChar", func(t *testing.T) {
+		a, err := MakeCharRange('a', 'f')
+		assert.Nil(t, err)
+		min, max, ok := IsSingleChar(a)
+		assert.True(t, ok)
+		assert.Equal(t, 'a', rune(min))
+		assert.Equal(t, 'f', rune(max))
+
+		_, _, ok = IsSingleChar(MakeEmptyString())
+		assert.False(t, ok)
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		a, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		assert.True(t, IsAnyString(a))
+		assert.Equal(t, LANGUAGE_ANY_STRING, Classify(a))
+		assert.False(t, IsAnyString(MakeEmpty()))
+
+		// all bytes are not all code points:
+		a.alphabet = ALPHABET_UNICODE
+		assert.False(t, IsAnyString(a))
+	})
+}
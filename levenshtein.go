@@ -1 +1,112 @@
 package automaton
+
+import (
+	"errors"
+	"unicode"
+)
+
+// Returns a (non-deterministic) automaton accepting all strings within maxEdits insertions, deletions or
+// substitutions of term, and, if transpositions is true, swaps of two adjacent characters. State (i, e) means
+// that the first i characters of term were consumed using e edits; deletions, which consume a character of term
+// without reading input, are folded into the states they skip from, so the automaton needs no epsilon
+// transitions. A transposition of term[j] and term[j+1] goes through an extra state after reading term[j+1].
+func makeLevenshteinNFA(term []rune, maxEdits int, transpositions bool) (*Automaton, error) {
+	n := len(term)
+	state := func(i, e int) int {
+		return i*(maxEdits+1) + e
+	}
+	transposing := func(j, e int) int {
+		return (n+1+j)*(maxEdits+1) + e
+	}
+
+	a := NewAutomaton()
+	for i := 0; i <= n; i++ {
+		for e := 0; e <= maxEdits; e++ {
+			a.CreateState()
+			a.SetAccept(state(i, e), n-i <= maxEdits-e)
+		}
+	}
+	if transpositions {
+		for j := 0; j+1 < n; j++ {
+			for e := 0; e <= maxEdits; e++ {
+				a.CreateState()
+			}
+		}
+	}
+
+	for i := 0; i <= n; i++ {
+		for e := 0; e <= maxEdits; e++ {
+			// Delete d characters of term, then read one character:
+			for d := 0; e+d <= maxEdits && i+d <= n; d++ {
+				j, f := i+d, e+d
+				if j < n {
+					if err := a.AddTransitionLabel(state(i, e), state(j+1, f), int(term[j])); err != nil {
+						return nil, err
+					}
+				}
+				if f < maxEdits {
+					// Insertion:
+					if err := a.AddTransition(state(i, e), state(j, f+1), 0, unicode.MaxRune); err != nil {
+						return nil, err
+					}
+					if j < n {
+						// Substitution:
+						if err := a.AddTransition(state(i, e), state(j+1, f+1), 0, unicode.MaxRune); err != nil {
+							return nil, err
+						}
+					}
+					if transpositions && j+1 < n && term[j] != term[j+1] {
+						if err := a.AddTransitionLabel(state(i, e), transposing(j, f), int(term[j+1])); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	if transpositions {
+		for j := 0; j+1 < n; j++ {
+			for e := 0; e < maxEdits; e++ {
+				if err := a.AddTransitionLabel(transposing(j, e), state(j+2, e+1), int(term[j])); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	a.FinishState()
+	return a, nil
+}
+
+// CompileFuzzy
+// Returns the compiled automaton of a fuzzy term query, as Lucene's FuzzyQuery: it accepts the strings whose
+// first prefixLength code points equal those of term and whose remainder is within maxEdits insertions,
+// deletions or substitutions (and swaps of two adjacent code points, if transpositions is true) of the rest of
+// term. The result matches UTF-8 encoded terms.
+func CompileFuzzy(term string, maxEdits, prefixLength int, transpositions bool) (*CompiledAutomaton, error) {
+	if maxEdits < 0 {
+		return nil, errors.New("maxEdits must be >= 0")
+	}
+	if prefixLength < 0 {
+		return nil, errors.New("prefixLength must be >= 0")
+	}
+	runes := []rune(term)
+	prefixLength = min(prefixLength, len(runes))
+
+	prefix, err := defaultAutomata.MakeString(string(runes[:prefixLength]))
+	if err != nil {
+		return nil, err
+	}
+	lev, err := makeLevenshteinNFA(runes[prefixLength:], maxEdits, transpositions)
+	if err != nil {
+		return nil, err
+	}
+	a, err := concatenate(prefix, lev)
+	if err != nil {
+		return nil, err
+	}
+	a, err = Minimize(a, DefaultWorkLimit())
+	if err != nil {
+		return nil, err
+	}
+	return NewCompiledAutomaton(a, nil, true, DefaultWorkLimit(), false)
+}
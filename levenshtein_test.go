@@ -0,0 +1,120 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func editDistance(s1, s2 []rune) int {
+	prev := make([]int, len(s2)+1)
+	cur := make([]int, len(s2)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		cur[0] = i
+		for j := 1; j <= len(s2); j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(s2)]
+}
+
+// Returns the optimal string alignment distance, which also counts a swap of adjacent characters as one edit.
+func transpositionDistance(s1, s2 []rune) int {
+	d := make([][]int, len(s1)+1)
+	for i := range d {
+		d[i] = make([]int, len(s2)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(s1); i++ {
+		for j := 1; j <= len(s2); j++ {
+			cost := 1
+			if s1[i-1] == s2[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s1[i-1] == s2[j-2] && s1[i-2] == s2[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(s1)][len(s2)]
+}
+
+func TestMakeLevenshteinNFA(t *testing.T) {
+	r := rand.New(rand.NewSource(1543))
+	for i := 0; i < 50; i++ {
+		term := randomString(r, 5)
+		maxEdits := r.Intn(3)
+		a, err := makeLevenshteinNFA([]rune(term), maxEdits, false)
+		assert.Nil(t, err)
+
+		for j := 0; j < 50; j++ {
+			s := randomString(r, 7)
+			want := editDistance([]rune(term), []rune(s)) <= maxEdits
+			assert.Equal(t, want, runNFA(a, s), "term=%q maxEdits=%d s=%q", term, maxEdits, s)
+		}
+	}
+}
+
+func TestMakeLevenshteinNFA_Transpositions(t *testing.T) {
+	r := rand.New(rand.NewSource(1576))
+	for i := 0; i < 50; i++ {
+		term := randomString(r, 5)
+		maxEdits := r.Intn(3)
+		a, err := makeLevenshteinNFA([]rune(term), maxEdits, true)
+		assert.Nil(t, err)
+
+		for j := 0; j < 50; j++ {
+			s := randomString(r, 7)
+			want := transpositionDistance([]rune(term), []rune(s)) <= maxEdits
+			assert.Equal(t, want, runNFA(a, s), "term=%q maxEdits=%d s=%q", term, maxEdits, s)
+		}
+	}
+}
+
+func TestCompileFuzzy(t *testing.T) {
+	c, err := CompileFuzzy("héllo", 1, 2, true)
+	assert.Nil(t, err)
+	assert.Equal(t, AUTOMATON_TYPE_NORMAL, c.Type())
+	run := c.RunAutomaton()
+	for _, s := range []string{"héllo", "hélo", "hélloo", "héllp", "hélol", "hélxo"} {
+		assert.True(t, run.Run([]byte(s)), s)
+	}
+	// The prefix "hé" must match exactly:
+	for _, s := range []string{"hello", "éllo", "ehllo", "héxxo", "h"} {
+		assert.False(t, run.Run([]byte(s)), s)
+	}
+
+	t.Run("noTranspositions", func(t *testing.T) {
+		c, err := CompileFuzzy("abcd", 1, 0, false)
+		assert.Nil(t, err)
+		assert.False(t, c.RunAutomaton().Run([]byte("acbd")))
+		assert.True(t, c.RunAutomaton().Run([]byte("abxd")))
+	})
+
+	t.Run("single", func(t *testing.T) {
+		c, err := CompileFuzzy("abc", 0, 1, true)
+		assert.Nil(t, err)
+		assert.Equal(t, AUTOMATON_TYPE_SINGLE, c.Type())
+		assert.Equal(t, []byte("abc"), c.Term())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := CompileFuzzy("abc", -1, 0, false)
+		assert.NotNil(t, err)
+		_, err = CompileFuzzy("abc", 1, -1, false)
+		assert.NotNil(t, err)
+	})
+}
@@ -0,0 +1,626 @@
+package automaton
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// NgramQueryOp The operator of an NgramQuery.
+type NgramQueryOp int
+
+const (
+	NGRAM_QUERY_ALL  = NgramQueryOp(iota) // Every string matches
+	NGRAM_QUERY_NONE                      // No string matches
+	NGRAM_QUERY_AND                       // Strings containing all Ngrams and matching all Sub queries
+	NGRAM_QUERY_OR                        // Strings containing one of the Ngrams or matching one of the Sub queries
+)
+
+// NgramQuery A boolean query over the trigrams (substrings of three code points) a string must contain to
+// match a pattern, see ExtractLiterals. It can be run against a trigram index to select the candidate
+// documents before matching them with the automaton.
+type NgramQuery struct {
+	Op NgramQueryOp
+	// Sorted, without duplicates.
+	Ngrams []string
+	// Each of the opposite operator (an OR within an AND and the other way around).
+	Sub []*NgramQuery
+}
+
+var (
+	ngramQueryAll  = &NgramQuery{Op: NGRAM_QUERY_ALL}
+	ngramQueryNone = &NgramQuery{Op: NGRAM_QUERY_NONE}
+)
+
+// Eval Returns true if a string containing exactly the trigrams for which contains returns true may match.
+func (q *NgramQuery) Eval(contains func(ngram string) bool) bool {
+	switch q.Op {
+	case NGRAM_QUERY_ALL:
+		return true
+	case NGRAM_QUERY_NONE:
+		return false
+	case NGRAM_QUERY_AND:
+		for _, ngram := range q.Ngrams {
+			if !contains(ngram) {
+				return false
+			}
+		}
+		for _, sub := range q.Sub {
+			if !sub.Eval(contains) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, ngram := range q.Ngrams {
+		if contains(ngram) {
+			return true
+		}
+	}
+	for _, sub := range q.Sub {
+		if sub.Eval(contains) {
+			return true
+		}
+	}
+	return false
+}
+
+// String Returns the query with AND written as a space and OR as "|", e.g. "abc" ("bcd"|"xyz"); "+" matches
+// every string and "-" none.
+func (q *NgramQuery) String() string {
+	switch q.Op {
+	case NGRAM_QUERY_ALL:
+		return "+"
+	case NGRAM_QUERY_NONE:
+		return "-"
+	}
+	sep := " "
+	if q.Op == NGRAM_QUERY_OR {
+		sep = "|"
+	}
+	var sb strings.Builder
+	for i, ngram := range q.Ngrams {
+		if i > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString(quoteNgram(ngram))
+	}
+	for i, sub := range q.Sub {
+		if i > 0 || len(q.Ngrams) > 0 {
+			sb.WriteString(sep)
+		}
+		sb.WriteString("(")
+		sb.WriteString(sub.String())
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+func quoteNgram(ngram string) string {
+	return `"` + strings.ReplaceAll(ngram, `"`, `\"`) + `"`
+}
+
+func (q *NgramQuery) and(r *NgramQuery) *NgramQuery {
+	return q.andOr(r, NGRAM_QUERY_AND)
+}
+
+func (q *NgramQuery) or(r *NgramQuery) *NgramQuery {
+	return q.andOr(r, NGRAM_QUERY_OR)
+}
+
+// andOr Returns q op r, flattening nested queries of the same operator. Neither query is modified.
+func (q *NgramQuery) andOr(r *NgramQuery, op NgramQueryOp) *NgramQuery {
+	identity, absorbing := ngramQueryAll, ngramQueryNone
+	if op == NGRAM_QUERY_OR {
+		identity, absorbing = ngramQueryNone, ngramQueryAll
+	}
+	if q.Op == absorbing.Op || r.Op == identity.Op {
+		return q
+	}
+	if r.Op == absorbing.Op || q.Op == identity.Op {
+		return r
+	}
+
+	result := &NgramQuery{Op: op}
+	result.addOperand(q)
+	result.addOperand(r)
+	slices.Sort(result.Ngrams)
+	result.Ngrams = slices.Compact(result.Ngrams)
+	return result
+}
+
+func (q *NgramQuery) addOperand(operand *NgramQuery) {
+	switch {
+	case operand.Op == q.Op:
+		q.Ngrams = append(q.Ngrams, operand.Ngrams...)
+		for _, sub := range operand.Sub {
+			q.addSub(sub)
+		}
+	case len(operand.Ngrams) == 1 && len(operand.Sub) == 0:
+		// A single trigram is the same under either operator:
+		q.Ngrams = append(q.Ngrams, operand.Ngrams[0])
+	default:
+		q.addSub(operand)
+	}
+}
+
+func (q *NgramQuery) addSub(sub *NgramQuery) {
+	s := sub.String()
+	for _, other := range q.Sub {
+		if other.String() == s {
+			return
+		}
+	}
+	q.Sub = append(q.Sub, sub)
+}
+
+// andTrigrams Returns q AND (the OR over the strings of set of the AND of their trigrams): every string
+// matching must contain one of the strings of set. If one of them is shorter than a trigram, nothing is
+// known, and q is returned.
+func (q *NgramQuery) andTrigrams(set literalSet) *NgramQuery {
+	if set.minLen() < 3 {
+		return q
+	}
+	or := ngramQueryNone
+	for _, s := range set {
+		runes := []rune(s)
+		and := &NgramQuery{Op: NGRAM_QUERY_AND}
+		for i := 0; i+3 <= len(runes); i++ {
+			and.Ngrams = append(and.Ngrams, string(runes[i:i+3]))
+		}
+		slices.Sort(and.Ngrams)
+		and.Ngrams = slices.Compact(and.Ngrams)
+		or = or.or(and)
+	}
+	return q.and(or)
+}
+
+// Literals What ExtractLiterals knows about the strings matching a pattern.
+type Literals struct {
+	// Every matching string starts with Prefix and ends with Suffix.
+	Prefix, Suffix string
+	// Every matching string satisfies Query.
+	Query *NgramQuery
+}
+
+const (
+	// Bounds keeping the sets of strings tracked by ExtractLiterals small, as in Russ Cox's trigram index.
+	literalsMaxExact = 7
+	literalsMaxSet   = 20
+	// Character ranges with more characters are taken as any character.
+	literalsMaxRange = 100
+)
+
+// ExtractLiterals
+// Returns the common prefix and suffix of the strings matching the given pattern, along with a query over
+// the trigrams they must contain, following Russ Cox's "Regular Expression Matching with a Trigram Index".
+// An index can then prefilter the candidates of a pattern cheaply, and only the candidates are matched
+// against the automaton of the pattern. The result is an over-approximation: every matching string satisfies
+// it, but not every string satisfying it matches. Named automata, intervals and complements are taken as
+// matching any string.
+func ExtractLiterals(r *RegExp) *Literals {
+	info := analyzeLiterals(r)
+	info.simplify(true)
+	info.addExact()
+
+	prefix, _ := commonAffix(r, false)
+	suffix, _ := commonAffix(r, true)
+	return &Literals{
+		Prefix: prefix,
+		Suffix: suffix,
+		Query:  info.match,
+	}
+}
+
+// literalSet A set of strings; nil if the set is unknown.
+type literalSet []string
+
+func (s literalSet) have() bool {
+	return s != nil
+}
+
+// minLen The length in code points of the shortest string, 0 for the empty set.
+func (s literalSet) minLen() int {
+	if len(s) == 0 {
+		return 0
+	}
+	n := utf8.RuneCountInString(s[0])
+	for _, str := range s[1:] {
+		n = min(n, utf8.RuneCountInString(str))
+	}
+	return n
+}
+
+// clean Sorts the set, by the reversed strings for a set of suffixes so that strings sharing a suffix are
+// adjacent, and removes duplicates.
+func (s literalSet) clean(isSuffix bool) literalSet {
+	if isSuffix {
+		slices.SortFunc(s, compareReversed)
+	} else {
+		slices.Sort(s)
+	}
+	return slices.Compact(s)
+}
+
+func compareReversed(a, b string) int {
+	for len(a) > 0 && len(b) > 0 {
+		ra, na := utf8.DecodeLastRuneInString(a)
+		rb, nb := utf8.DecodeLastRuneInString(b)
+		if ra != rb {
+			return int(ra) - int(rb)
+		}
+		a, b = a[:len(a)-na], b[:len(b)-nb]
+	}
+	return len(a) - len(b)
+}
+
+func (s literalSet) union(t literalSet, isSuffix bool) literalSet {
+	u := make(literalSet, 0, len(s)+len(t))
+	u = append(append(u, s...), t...)
+	return u.clean(isSuffix)
+}
+
+func (s literalSet) cross(t literalSet, isSuffix bool) literalSet {
+	p := make(literalSet, 0, len(s)*len(t))
+	for _, a := range s {
+		for _, b := range t {
+			p = append(p, a+b)
+		}
+	}
+	return p.clean(isSuffix)
+}
+
+// literalsInfo What is known about the strings matching a subexpression: either exact, the set of all of
+// them, or the sets of their prefixes and suffixes; plus a query they all satisfy.
+type literalsInfo struct {
+	canEmpty bool
+	exact    literalSet
+	prefix   literalSet
+	suffix   literalSet
+	match    *NgramQuery
+}
+
+func anyMatchLiterals() *literalsInfo {
+	return &literalsInfo{canEmpty: true, prefix: literalSet{""}, suffix: literalSet{""}, match: ngramQueryAll}
+}
+
+func anyCharLiterals() *literalsInfo {
+	return &literalsInfo{prefix: literalSet{""}, suffix: literalSet{""}, match: ngramQueryAll}
+}
+
+func noMatchLiterals() *literalsInfo {
+	return &literalsInfo{exact: literalSet{}, match: ngramQueryNone}
+}
+
+func emptyStringLiterals() *literalsInfo {
+	return &literalsInfo{canEmpty: true, exact: literalSet{""}, match: ngramQueryAll}
+}
+
+func exactLiterals(strs ...string) *literalsInfo {
+	return &literalsInfo{exact: literalSet(strs).clean(false), match: ngramQueryAll}
+}
+
+// caseVariants Returns the characters matching c, which has more than one if the expression is case
+// insensitive (see applyCaseFolding).
+func (r *RegExp) caseVariants(c rune) []string {
+	variants := r.applyCaseFolding(c)
+	strs := make(literalSet, len(variants))
+	for i, v := range variants {
+		strs[i] = string(v)
+	}
+	return strs.clean(false)
+}
+
+func analyzeLiterals(r *RegExp) *literalsInfo {
+	var info *literalsInfo
+	switch r.kind {
+	case REGEXP_UNION:
+		info = alternateLiterals(analyzeLiterals(r.exp1), analyzeLiterals(r.exp2))
+	case REGEXP_CONCATENATION:
+		info = concatLiterals(analyzeLiterals(r.exp1), analyzeLiterals(r.exp2))
+	case REGEXP_INTERSECTION:
+		x, y := analyzeLiterals(r.exp1), analyzeLiterals(r.exp2)
+		x.simplify(true)
+		x.addExact()
+		y.simplify(true)
+		y.addExact()
+		info = anyMatchLiterals()
+		info.canEmpty = x.canEmpty && y.canEmpty
+		info.match = x.match.and(y.match)
+	case REGEXP_OPTIONAL:
+		info = alternateLiterals(analyzeLiterals(r.exp1), emptyStringLiterals())
+	case REGEXP_REPEAT_MIN:
+		info = repeatLiterals(r.exp1, r.min, -1)
+	case REGEXP_REPEAT_MINMAX:
+		info = repeatLiterals(r.exp1, r.min, r.max)
+	case REGEXP_CHAR:
+		info = exactLiterals(r.caseVariants(rune(r.c))...)
+	case REGEXP_CHAR_RANGE:
+		if r.to-r.from >= literalsMaxRange {
+			info = anyCharLiterals()
+			break
+		}
+		strs := make([]string, 0, r.to-r.from+1)
+		for c := r.from; c <= r.to; c++ {
+			strs = append(strs, string(rune(c)))
+		}
+		info = exactLiterals(strs...)
+	case REGEXP_ANYCHAR:
+		info = anyCharLiterals()
+	case REGEXP_EMPTY:
+		info = noMatchLiterals()
+	case REGEXP_STRING:
+		info = emptyStringLiterals()
+		for _, c := range *r.s {
+			info = concatLiterals(info, exactLiterals(r.caseVariants(c)...))
+		}
+	default:
+		// REGEXP_REPEAT, REGEXP_COMPLEMENT, REGEXP_ANYSTRING, REGEXP_AUTOMATON and REGEXP_INTERVAL:
+		info = anyMatchLiterals()
+	}
+	info.simplify(false)
+	return info
+}
+
+// literalsMaxRepeat How many copies of a repeated expression are analyzed at most; longer repetitions are
+// taken as that many copies, the last one repeated.
+const literalsMaxRepeat = 3
+
+// repeatLiterals Returns the info of e repeated minCount to maxCount times, or at least minCount times if
+// maxCount is -1.
+func repeatLiterals(e *RegExp, minCount, maxCount int) *literalsInfo {
+	if maxCount == 0 {
+		return emptyStringLiterals()
+	}
+	if minCount == 0 {
+		if maxCount == -1 {
+			return anyMatchLiterals()
+		}
+		return alternateLiterals(plusLiterals(analyzeLiterals(e)), emptyStringLiterals())
+	}
+
+	if minCount == maxCount && minCount <= literalsMaxRepeat {
+		info := analyzeLiterals(e)
+		for i := 1; i < minCount; i++ {
+			info = concatLiterals(info, analyzeLiterals(e))
+		}
+		return info
+	}
+	info := plusLiterals(analyzeLiterals(e))
+	for i := 1; i < min(minCount, literalsMaxRepeat); i++ {
+		info = concatLiterals(analyzeLiterals(e), info)
+	}
+	return info
+}
+
+// plusLiterals Returns the info of x repeated at least once: every match starts and ends with a match of x.
+func plusLiterals(x *literalsInfo) *literalsInfo {
+	if x.exact.have() {
+		x.prefix = x.exact
+		x.suffix = slices.Clone(x.exact).clean(true)
+		x.exact = nil
+	}
+	return x
+}
+
+func concatLiterals(x, y *literalsInfo) *literalsInfo {
+	xy := &literalsInfo{}
+	xy.match = x.match.and(y.match)
+	if x.exact.have() && y.exact.have() {
+		xy.exact = x.exact.cross(y.exact, false)
+	} else {
+		if x.exact.have() {
+			xy.prefix = x.exact.cross(y.prefix, false)
+		} else {
+			xy.prefix = x.prefix
+			if x.canEmpty {
+				xy.prefix = xy.prefix.union(y.prefix, false)
+			}
+		}
+		if y.exact.have() {
+			xy.suffix = x.suffix.cross(y.exact, true)
+		} else {
+			xy.suffix = y.suffix
+			if y.canEmpty {
+				xy.suffix = xy.suffix.union(x.suffix, true)
+			}
+		}
+	}
+
+	// A trigram spanning the boundary is in neither the prefixes of y nor the suffixes of x, so if every
+	// suffix of x followed by every prefix of y is long enough, one of them must be present:
+	if !x.exact.have() && !y.exact.have() && len(x.suffix) <= literalsMaxSet && len(y.prefix) <= literalsMaxSet &&
+		x.suffix.minLen()+y.prefix.minLen() >= 3 {
+		xy.match = xy.match.andTrigrams(x.suffix.cross(y.prefix, false))
+	}
+
+	xy.canEmpty = x.canEmpty && y.canEmpty
+	xy.simplify(false)
+	return xy
+}
+
+func alternateLiterals(x, y *literalsInfo) *literalsInfo {
+	xy := &literalsInfo{}
+	switch {
+	case x.exact.have() && y.exact.have():
+		xy.exact = x.exact.union(y.exact, false)
+	case x.exact.have():
+		xy.prefix = x.exact.union(y.prefix, false)
+		xy.suffix = x.exact.union(y.suffix, true)
+		x.addExact()
+	case y.exact.have():
+		xy.prefix = x.prefix.union(y.exact, false)
+		xy.suffix = x.suffix.union(y.exact, true)
+		y.addExact()
+	default:
+		xy.prefix = x.prefix.union(y.prefix, false)
+		xy.suffix = x.suffix.union(y.suffix, true)
+	}
+	xy.canEmpty = x.canEmpty || y.canEmpty
+	xy.match = x.match.or(y.match)
+	xy.simplify(false)
+	return xy
+}
+
+// addExact Adds the trigrams of the exact set to the query.
+func (info *literalsInfo) addExact() {
+	if info.exact.have() {
+		info.match = info.match.andTrigrams(info.exact)
+	}
+}
+
+// simplify Keeps the sets small: too many or too long exact strings are moved into the query and replaced by
+// their prefixes and suffixes, which are in turn moved into the query and trimmed. If force is true, exact
+// sets long enough to yield trigrams are moved as well.
+func (info *literalsInfo) simplify(force bool) {
+	if info.exact.have() {
+		info.exact = info.exact.clean(false)
+		minLen := info.exact.minLen()
+		if len(info.exact) > literalsMaxExact || (minLen >= 3 && force) || minLen >= 4 {
+			info.addExact()
+			for _, s := range info.exact {
+				runes := []rune(s)
+				if len(runes) < 3 {
+					info.prefix = append(info.prefix, s)
+					info.suffix = append(info.suffix, s)
+				} else {
+					info.prefix = append(info.prefix, string(runes[:2]))
+					info.suffix = append(info.suffix, string(runes[len(runes)-2:]))
+				}
+			}
+			info.exact = nil
+		}
+	}
+	if !info.exact.have() {
+		info.prefix = info.simplifySet(info.prefix, false)
+		info.suffix = info.simplifySet(info.suffix, true)
+	}
+}
+
+// simplifySet Adds the trigrams of a set of prefixes (or suffixes) to the query, then trims the strings to two
+// code points, fewer while there are too many of them, and drops those extending another.
+func (info *literalsInfo) simplifySet(set literalSet, isSuffix bool) literalSet {
+	set = slices.Clone(set).clean(isSuffix)
+	info.match = info.match.andTrigrams(set)
+
+	for n := 2; n == 2 || (len(set) > literalsMaxSet && n >= 0); n-- {
+		for i, s := range set {
+			runes := []rune(s)
+			if len(runes) > n {
+				if isSuffix {
+					set[i] = string(runes[len(runes)-n:])
+				} else {
+					set[i] = string(runes[:n])
+				}
+			}
+		}
+		set = set.clean(isSuffix)
+	}
+
+	// Knowing that "ab" is a possible prefix makes "abc" useless:
+	hasAffix := strings.HasPrefix
+	if isSuffix {
+		hasAffix = strings.HasSuffix
+	}
+	w := 0
+	for _, s := range set {
+		if w == 0 || !hasAffix(s, set[w-1]) {
+			set[w] = s
+			w++
+		}
+	}
+	return set[:w]
+}
+
+// commonAffix Returns the longest string all strings matching r start with (end with if suffix is true), and
+// whether r matches only that string.
+func commonAffix(r *RegExp, suffix bool) (string, bool) {
+	switch r.kind {
+	case REGEXP_UNION:
+		s1, complete1 := commonAffix(r.exp1, suffix)
+		s2, complete2 := commonAffix(r.exp2, suffix)
+		if complete1 && complete2 && s1 == s2 {
+			return s1, true
+		}
+		return commonAffixOf(s1, s2, suffix), false
+	case REGEXP_CONCATENATION:
+		first, second := r.exp1, r.exp2
+		if suffix {
+			first, second = second, first
+		}
+		s1, complete1 := commonAffix(first, suffix)
+		if !complete1 {
+			return s1, false
+		}
+		s2, complete2 := commonAffix(second, suffix)
+		if suffix {
+			return s2 + s1, complete2
+		}
+		return s1 + s2, complete2
+	case REGEXP_INTERSECTION:
+		// Both are affixes of every match:
+		s1, complete1 := commonAffix(r.exp1, suffix)
+		s2, complete2 := commonAffix(r.exp2, suffix)
+		if len(s1) >= len(s2) {
+			return s1, complete1
+		}
+		return s2, complete2
+	case REGEXP_REPEAT_MIN:
+		if r.min > 0 {
+			s, _ := commonAffix(r.exp1, suffix)
+			return s, false
+		}
+	case REGEXP_REPEAT_MINMAX:
+		if r.max == 0 {
+			return "", true
+		}
+		if r.min > 0 {
+			s, complete := commonAffix(r.exp1, suffix)
+			if complete && r.min == r.max {
+				return strings.Repeat(s, r.min), true
+			}
+			return s, false
+		}
+	case REGEXP_CHAR:
+		if variants := r.caseVariants(rune(r.c)); len(variants) == 1 {
+			return variants[0], true
+		}
+	case REGEXP_CHAR_RANGE:
+		if r.from == r.to {
+			return string(rune(r.from)), true
+		}
+	case REGEXP_STRING:
+		runes := []rune(*r.s)
+		if suffix {
+			for i := len(runes) - 1; i >= 0; i-- {
+				if len(r.caseVariants(runes[i])) > 1 {
+					return string(runes[i+1:]), false
+				}
+			}
+		} else {
+			for i, c := range runes {
+				if len(r.caseVariants(c)) > 1 {
+					return string(runes[:i]), false
+				}
+			}
+		}
+		return *r.s, true
+	}
+	return "", false
+}
+
+// commonAffixOf Returns the longest common prefix (suffix if suffix is true) of s1 and s2.
+func commonAffixOf(s1, s2 string, suffix bool) string {
+	r1, r2 := []rune(s1), []rune(s2)
+	n := 0
+	if suffix {
+		for n < len(r1) && n < len(r2) && r1[len(r1)-1-n] == r2[len(r2)-1-n] {
+			n++
+		}
+		return string(r1[len(r1)-n:])
+	}
+	for n < len(r1) && n < len(r2) && r1[n] == r2[n] {
+		n++
+	}
+	return string(r1[:n])
+}
@@ -0,0 +1,79 @@
+package automaton
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractLiterals(t *testing.T) {
+	tests := []struct {
+		pattern        string
+		prefix, suffix string
+		query          string
+	}{
+		{"abc", "abc", "abc", `"abc"`},
+		{"ab", "ab", "ab", "+"},
+		{"hello.*world", "hello", "world", `"ell" "hel" "llo" "orl" "rld" "wor"`},
+		{"(abc|abd)e", "ab", "e", `("abc" "bce")|("abd" "bde")`},
+		{"a[bc]d", "a", "d", `"abd"|"acd"`},
+		{"x(yz)+w", "xyz", "yzw", `"xyz" "yzw"`},
+		{"(yz){2}", "yzyz", "yzyz", `"yzy" "zyz"`},
+		{"(ab)+c", "ab", "abc", `"abc"`},
+		{"a(bcd)?e", "a", "e", "+"},
+		{"ab*c", "a", "c", "+"},
+		{"[a-z]+abc", "", "abc", `"abc"`},
+		{".*", "", "", "+"},
+		{"#", "", "", "-"},
+		{"~(abc)", "", "", "+"},
+	}
+	for _, test := range tests {
+		t.Run(test.pattern, func(t *testing.T) {
+			literals := ExtractLiterals(MustNewRegExp(test.pattern))
+			assert.Equal(t, test.prefix, literals.Prefix)
+			assert.Equal(t, test.suffix, literals.Suffix)
+			assert.Equal(t, test.query, literals.Query.String())
+		})
+	}
+
+	t.Run("caseInsensitive", func(t *testing.T) {
+		literals := ExtractLiterals(MustNewRegExp("xgo", WithMatchFlags(ASCII_CASE_INSENSITIVE)))
+		assert.Equal(t, "", literals.Prefix)
+		assert.Equal(t, `"XGO"|"XGo"|"XgO"|"Xgo"|"xGO"|"xGo"|"xgO"|"xgo"`, literals.Query.String())
+		literals = ExtractLiterals(MustNewRegExp("1xgo", WithMatchFlags(ASCII_CASE_INSENSITIVE)))
+		assert.Equal(t, "1", literals.Prefix)
+		assert.Equal(t, "", literals.Suffix)
+	})
+
+	t.Run("codePoints", func(t *testing.T) {
+		literals := ExtractLiterals(MustNewRegExp("日本語.*"))
+		assert.Equal(t, "日本語", literals.Prefix)
+		assert.Equal(t, `"日本語"`, literals.Query.String())
+	})
+}
+
+func TestExtractLiterals_Random(t *testing.T) {
+	r := rand.New(rand.NewSource(1598))
+	contains := func(s string) func(string) bool {
+		return func(ngram string) bool {
+			return strings.Contains(s, ngram)
+		}
+	}
+	for i := 0; i < 300; i++ {
+		pattern := randomRegexp(r, 4)
+		re := MustNewRegExp(pattern)
+		a := re.MustToAutomaton()
+		literals := ExtractLiterals(re)
+		for j := 0; j < 100; j++ {
+			s := randomString(r, 10)
+			if !runNFA(a, s) {
+				continue
+			}
+			assert.True(t, strings.HasPrefix(s, literals.Prefix), "%s: %q, prefix %q", pattern, s, literals.Prefix)
+			assert.True(t, strings.HasSuffix(s, literals.Suffix), "%s: %q, suffix %q", pattern, s, literals.Suffix)
+			assert.True(t, literals.Query.Eval(contains(s)), "%s: %q, query %s", pattern, s, literals.Query)
+		}
+	}
+}
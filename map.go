@@ -17,6 +17,7 @@ type HashMap[T any] struct {
 	size        int
 	mask        uint64
 	mutex       sync.RWMutex // 可选并发控制
+	locking     bool         // false: 单线程使用，不加锁 (see WithoutLocking)
 	emptyValue  T
 	loadFactory float64
 }
@@ -31,12 +32,14 @@ type Entry[T any] struct {
 type optionsHashMap struct {
 	capacity    int     // 默认4
 	loadFactory float64 // 负载因子，默认0.75
+	locking     bool    // 默认true
 }
 
 func newOptionsHashMap(opts ...OptionsHashMap) *optionsHashMap {
 	options := &optionsHashMap{
 		capacity:    1,
 		loadFactory: 0.75,
+		locking:     true,
 	}
 
 	for _, opt := range opts {
@@ -66,6 +69,14 @@ func WithLoadFactory(loadFactory float64) OptionsHashMap {
 	}
 }
 
+// WithoutLocking Skips the mutex for maps only used by a single goroutine, such as the state maps of
+// determinize and product; such a map must not be shared.
+func WithoutLocking() OptionsHashMap {
+	return func(hashMap *optionsHashMap) {
+		hashMap.locking = false
+	}
+}
+
 // NewHashMap 创建哈希表
 // 参数：capacity 初始容量（自动调整为2的幂）
 func NewHashMap[T any](options ...OptionsHashMap) *HashMap[T] {
@@ -75,13 +86,38 @@ func NewHashMap[T any](options ...OptionsHashMap) *HashMap[T] {
 		buckets:     make([]*Entry[T], opt.capacity),
 		mask:        uint64(opt.capacity - 1),
 		loadFactory: opt.loadFactory,
+		locking:     opt.locking,
+	}
+}
+
+func (m *HashMap[T]) lock() {
+	if m.locking {
+		m.mutex.Lock()
+	}
+}
+
+func (m *HashMap[T]) unlock() {
+	if m.locking {
+		m.mutex.Unlock()
+	}
+}
+
+func (m *HashMap[T]) rlock() {
+	if m.locking {
+		m.mutex.RLock()
+	}
+}
+
+func (m *HashMap[T]) runlock() {
+	if m.locking {
+		m.mutex.RUnlock()
 	}
 }
 
 // Set 插入键值对
 func (m *HashMap[T]) Set(key Hashable, value T) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	m.lock()
+	defer m.unlock()
 
 	hash := key.Hash()
 	index := hash & m.mask
@@ -110,8 +146,8 @@ func (m *HashMap[T]) Set(key Hashable, value T) {
 
 // Get 获取值
 func (m *HashMap[T]) Get(key Hashable) (T, bool) {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	m.rlock()
+	defer m.runlock()
 
 	hash := key.Hash()
 	index := hash & m.mask
@@ -126,8 +162,8 @@ func (m *HashMap[T]) Get(key Hashable) (T, bool) {
 
 // Delete 删除键
 func (m *HashMap[T]) Delete(key Hashable) {
-	m.mutex.Lock()
-	defer m.mutex.Unlock()
+	m.lock()
+	defer m.unlock()
 
 	hash := key.Hash()
 	index := hash & m.mask
@@ -170,8 +206,8 @@ func (m *HashMap[T]) resize() {
 
 // Size 获取元素数量
 func (m *HashMap[T]) Size() int {
-	m.mutex.RLock()
-	defer m.mutex.RUnlock()
+	m.rlock()
+	defer m.runlock()
 	return m.size
 }
 
@@ -157,6 +157,20 @@ func TestConcurrency(t *testing.T) {
 	wg.Wait()
 }
 
+func TestWithoutLocking(t *testing.T) {
+	hm := NewHashMap[int](WithoutLocking())
+	assert.False(t, hm.locking)
+	for i := 0; i < 100; i++ {
+		hm.Set(TestKey{i, "test"}, i)
+	}
+	assert.Equal(t, 100, hm.Size())
+	val, exists := hm.Get(TestKey{42, "test"})
+	assert.True(t, exists)
+	assert.Equal(t, 42, val)
+	hm.Delete(TestKey{42, "test"})
+	assert.Equal(t, 99, hm.Size())
+}
+
 func TestTypeSafety(t *testing.T) {
 	hm := NewHashMap[string](WithCapacity(8))
 
@@ -0,0 +1,58 @@
+package automaton
+
+// Matcher Tests whether whole strings are accepted.
+type Matcher interface {
+	Run(s string) bool
+}
+
+// CompileMatcher Compiles a regular expression into a Matcher. Patterns that are a single character class
+// (like "[a-z0-9_]" or "[^,;]") are compiled into a RangeSet, without building an automaton at all; any other
+// pattern is compiled into a minimal deterministic automaton and run with a RunAutomaton.
+func CompileMatcher(pattern string, options ...RegExpOption) (Matcher, error) {
+	re, err := NewRegExp(pattern, options...)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := re.ToRangeSet(); ok {
+		return s, nil
+	}
+
+	a, err := re.ToAutomaton()
+	if err != nil {
+		return nil, err
+	}
+	a, err = Minimize(a, DefaultWorkLimit())
+	if err != nil {
+		return nil, err
+	}
+	if a.GetNumStates() == 0 {
+		return &runMatcher{}, nil
+	}
+	return &runMatcher{r: NewRunAutomaton(a, 0x110000, DefaultWorkLimit())}, nil
+}
+
+// runMatcher Runs a RunAutomaton over the code points of a string; a nil RunAutomaton accepts nothing.
+type runMatcher struct {
+	r *RunAutomaton
+}
+
+func (m *runMatcher) Run(s string) bool {
+	if m.r == nil {
+		return false
+	}
+	counter := m.r.counter.Load()
+	p := 0
+	if counter != nil {
+		counter.Visit(p)
+	}
+	for _, c := range s {
+		p = m.r.Step(p, int(c))
+		if p == -1 {
+			return false
+		}
+		if counter != nil {
+			counter.Visit(p)
+		}
+	}
+	return m.r.IsAccept(p)
+}
@@ -0,0 +1,119 @@
+package automaton
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// OperationStats Describes the automaton produced by one operation, as reported to the operation observer.
+type OperationStats struct {
+	// Name of the operation, e.g. "union" or "determinize".
+	Operation string
+
+	// Number of states of the result.
+	States int
+
+	// Number of transitions of the result.
+	Transitions int
+
+	// Estimated bytes allocated for the states, transitions and accept states of the result.
+	Bytes int
+}
+
+var operationObserver atomic.Pointer[func(OperationStats)]
+
+// SetOperationObserver Registers a function that is called, from the calling goroutine, with the stats of the
+// result of every operation (union, concatenate, determinize, minimize, ...), including operations run
+// internally by other operations. Pass nil to remove the observer. This is meant for capacity planning, e.g.
+// budgeting memory for compiling user supplied patterns; see MemoryTracker.
+func SetOperationObserver(observer func(OperationStats)) {
+	if observer == nil {
+		operationObserver.Store(nil)
+		return
+	}
+	operationObserver.Store(&observer)
+}
+
+// AutomatonStats Describes the size of an automaton.
+type AutomatonStats struct {
+	// Number of states.
+	States int
+
+	// Number of transitions.
+	Transitions int
+
+	// Whether the automaton is deterministic.
+	Deterministic bool
+
+	// Number of accept states.
+	AcceptStates int
+
+	// Estimated bytes allocated for the states, transitions and accept states.
+	Bytes int
+}
+
+// Stats Returns the size of this automaton.
+func (a *Automaton) Stats() AutomatonStats {
+	return AutomatonStats{
+		States:        a.GetNumStates(),
+		Transitions:   a.GetNumTransitions(),
+		Deterministic: a.IsDeterministic(),
+		AcceptStates:  a.NumAcceptStates(),
+		Bytes:         a.RamBytesUsed(),
+	}
+}
+
+// RamBytesUsed Returns the estimated number of bytes allocated by this automaton: its states, transitions and
+// accept states. States and transitions are packed as int32, so this is roughly 8 bytes per state plus 12 bytes
+// per transition.
+func (a *Automaton) RamBytesUsed() int {
+	const int32Size = int(unsafe.Sizeof(int32(0)))
+	return int(unsafe.Sizeof(*a)) +
+		int32Size*cap(a.states) + int(unsafe.Sizeof(packedTransition{}))*cap(a.transitions) +
+		8*len(a.isAccept.Bytes())
+}
+
+// MemoryTracker An operation observer recording, per operation, the largest result seen (its high-water mark).
+// It is safe for concurrent use.
+type MemoryTracker struct {
+	mu        sync.Mutex
+	highWater map[string]OperationStats
+}
+
+func NewMemoryTracker() *MemoryTracker {
+	return &MemoryTracker{
+		highWater: make(map[string]OperationStats),
+	}
+}
+
+// Observe Records the given stats; pass this method to SetOperationObserver.
+func (m *MemoryTracker) Observe(stats OperationStats) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if stats.Bytes > m.highWater[stats.Operation].Bytes {
+		m.highWater[stats.Operation] = stats
+	}
+}
+
+// HighWater Returns the stats of the largest result observed for the given operation.
+func (m *MemoryTracker) HighWater(operation string) (OperationStats, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats, ok := m.highWater[operation]
+	return stats, ok
+}
+
+// Peak Returns the stats of the largest result observed across all operations; ties go to the operation
+// name that sorts first.
+func (m *MemoryTracker) Peak() OperationStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	peak := OperationStats{}
+	for _, stats := range m.highWater {
+		if stats.Bytes > peak.Bytes || (stats.Bytes == peak.Bytes && stats.Operation < peak.Operation) {
+			peak = stats
+		}
+	}
+	return peak
+}
@@ -0,0 +1,53 @@
+package automaton
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryTracker(t *testing.T) {
+	tracker := NewMemoryTracker()
+	SetOperationObserver(tracker.Observe)
+	defer SetOperationObserver(nil)
+
+	re, err := NewRegExp("[ac]*a[ac]{5}")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+
+	stats, ok := tracker.HighWater("determinize")
+	assert.True(t, ok)
+	assert.Greater(t, stats.States, 0)
+	assert.Greater(t, stats.Bytes, 0)
+	assert.GreaterOrEqual(t, stats.States, a.GetNumStates())
+
+	_, ok = tracker.HighWater("union")
+	assert.True(t, ok)
+	_, ok = tracker.HighWater("complement")
+	assert.False(t, ok)
+
+	peak := tracker.Peak()
+	assert.GreaterOrEqual(t, peak.Bytes, stats.Bytes)
+
+	SetOperationObserver(nil)
+	before := tracker.Peak()
+	_, err = union(a, a)
+	assert.Nil(t, err)
+	assert.Equal(t, before, tracker.Peak())
+}
+
+func TestAutomaton_RamBytesUsed(t *testing.T) {
+	small, err := defaultAutomata.MakeString("a")
+	assert.Nil(t, err)
+	large, err := defaultAutomata.MakeString("abcdefghijklmnopqrstuvwxyz")
+	assert.Nil(t, err)
+	assert.Greater(t, large.RamBytesUsed(), small.RamBytesUsed())
+
+	// Packed as int32: about half of what 64-bit ints would take.
+	term := strings.Repeat("abcdefghij", 100)
+	long, err := defaultAutomata.MakeString(term)
+	assert.Nil(t, err)
+	assert.Less(t, long.RamBytesUsed(), 8*(2*long.GetNumStates()+3*long.GetNumTransitions()))
+}
@@ -1,15 +1,272 @@
 package automaton
 
+import "github.com/bits-and-blooms/bitset"
+
 // Minimize
 // Minimizes (and determinizes if not already deterministic) the given automaton using Hopcroft's algorithm.
+// The states of the result are numbered in breadth-first order from the initial state, so minimizing is
+// idempotent: Minimize(Minimize(a)) is structurally equal to Minimize(a).
 func Minimize(a *Automaton, determinizeWorkLimit int) (*Automaton, error) {
+	return minimize(a, determinizeWorkLimit, nil)
+}
+
+// MinimizeStats Describes the reduction achieved by MinimizeWithStats.
+type MinimizeStats struct {
+	// Size of the automaton given to MinimizeWithStats.
+	Before AutomatonStats
+
+	// Size of the minimal automaton.
+	After AutomatonStats
+}
+
+// StatesRemoved Returns the number of states removed by minimization. It is negative when determinizing the
+// input added more states than merging equivalent states removed.
+func (s MinimizeStats) StatesRemoved() int {
+	return s.Before.States - s.After.States
+}
+
+// TransitionsRemoved Returns the number of transitions removed by minimization, negative if it added some.
+func (s MinimizeStats) TransitionsRemoved() int {
+	return s.Before.Transitions - s.After.Transitions
+}
+
+// MinimizeWithStats Minimizes the given automaton like Minimize, and also returns the size of the automaton
+// before and after.
+func MinimizeWithStats(a *Automaton, determinizeWorkLimit int) (*Automaton, MinimizeStats, error) {
+	stats := MinimizeStats{Before: a.Stats()}
+	result, err := Minimize(a, determinizeWorkLimit)
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.After = result.Stats()
+	return result, stats, nil
+}
+
+// IsMinimal Returns true if the given automaton is deterministic and minimizing it does not reduce its number
+// of states or transitions. This minimizes the automaton, so it is meant for tests and assertions rather than
+// hot paths.
+func IsMinimal(a *Automaton, determinizeWorkLimit int) (bool, error) {
+	if !a.IsDeterministic() {
+		return false, nil
+	}
+	m, err := Minimize(a, determinizeWorkLimit)
+	if err != nil {
+		return false, err
+	}
+	return m.GetNumStates() == a.GetNumStates() && m.GetNumTransitions() == a.GetNumTransitions(), nil
+}
+
+// minimize Minimizes the automaton, taking the effort spent from budget, if any (see Budget.Minimize).
+func minimize(a *Automaton, determinizeWorkLimit int, budget *Budget) (*Automaton, error) {
+	alphabet := a.alphabet
 	if a.GetNumStates() == 0 || (a.IsAccept(0) == false && a.GetNumTransitionsWithState(0) == 0) {
 		// Fastmatch for common case
-		return NewAutomaton(), nil
+		result := NewAutomaton()
+		result.alphabet = alphabet
+		return result, nil
+	}
+
+	a, err := determinizeBudget(a, determinizeWorkLimit, budget)
+	if err != nil {
+		return nil, err
+	}
+	if err := budget.spend(a.GetNumStates()); err != nil {
+		return nil, err
+	}
+	if a.GetNumTransitionsWithState(0) == 1 {
+		t := NewTransition()
+		a.getTransition(0, 0, t)
+		if t.Dest == 0 && t.Min == 0 && t.Max == a.alphabet.maxLabel() {
+			// Accepts all strings
+			return opMinimize.done(a, nil)
+		}
+	}
+	// Totalizing only fills the gaps between transitions, which keeps the partition of the labels:
+	labels := a.AlphabetPartition()
+	a, _, err = Totalize(a)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize data structures
+	sigma := labels.Points()
+	sigmaLen, statesLen := len(sigma), a.GetNumStates()
+
+	reverse := make([][][]int, statesLen)
+	partition := make([]map[int]struct{}, statesLen)
+	splitblock := make([][]int, statesLen)
+	block := make([]int, statesLen)
+	active := make([][]*StateList, statesLen)
+	active2 := make([][]*StateListNode, statesLen)
+	pending := make([]IntPair, 0)
+	pending2 := bitset.New(uint(sigmaLen * statesLen))
+	split := bitset.New(uint(statesLen))
+	refine := bitset.New(uint(statesLen))
+	refine2 := bitset.New(uint(statesLen))
+	for q := 0; q < statesLen; q++ {
+		reverse[q] = make([][]int, sigmaLen)
+		partition[q] = make(map[int]struct{})
+		active[q] = make([]*StateList, sigmaLen)
+		active2[q] = make([]*StateListNode, sigmaLen)
+		for x := 0; x < sigmaLen; x++ {
+			active[q][x] = emptyStateList
+		}
+	}
+
+	// find initial partition and reverse edges
+	t := NewTransition()
+	for q := 0; q < statesLen; q++ {
+		j := 1
+		if a.IsAccept(q) {
+			j = 0
+		}
+		partition[j][q] = struct{}{}
+		block[q] = j
+		count := a.InitTransition(q, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			for x := labels.Class(t.Min); x <= labels.Class(t.Max); x++ {
+				r := reverse[t.Dest]
+				r[x] = append(r[x], q)
+			}
+		}
+	}
+
+	// initialize active sets
+	for j := 0; j <= 1; j++ {
+		for x := 0; x < sigmaLen; x++ {
+			for q := range partition[j] {
+				if reverse[q][x] != nil {
+					stateList := active[j][x]
+					if stateList == emptyStateList {
+						stateList = &StateList{}
+						active[j][x] = stateList
+					}
+					active2[q][x] = stateList.add(q)
+				}
+			}
+		}
+	}
+
+	// initialize pending
+	for x := 0; x < sigmaLen; x++ {
+		j := 1
+		if active[0][x].size <= active[1][x].size {
+			j = 0
+		}
+		pending = append(pending, IntPair{n1: j, n2: x})
+		pending2.Set(uint(x*statesLen + j))
+	}
+
+	// process pending until fixed point
+	k := 2
+	for len(pending) > 0 {
+		ip := pending[0]
+		pending = pending[1:]
+		p, x := ip.n1, ip.n2
+		pending2.Clear(uint(x*statesLen + p))
+
+		// find states that need to be split off their blocks
+		for m := active[p][x].first; m != nil; m = m.next {
+			for _, i := range reverse[m.q][x] {
+				if !split.Test(uint(i)) {
+					split.Set(uint(i))
+					j := block[i]
+					splitblock[j] = append(splitblock[j], i)
+					if !refine2.Test(uint(j)) {
+						refine2.Set(uint(j))
+						refine.Set(uint(j))
+					}
+				}
+			}
+		}
+
+		// refine blocks
+		for uj, ok := refine.NextSet(0); ok; uj, ok = refine.NextSet(uj + 1) {
+			j := int(uj)
+			sb := splitblock[j]
+			if len(sb) < len(partition[j]) {
+				b1 := partition[j]
+				b2 := partition[k]
+				for _, s := range sb {
+					delete(b1, s)
+					b2[s] = struct{}{}
+					block[s] = k
+					for c := 0; c < sigmaLen; c++ {
+						sn := active2[s][c]
+						if sn != nil && sn.sl == active[j][c] {
+							sn.remove()
+							stateList := active[k][c]
+							if stateList == emptyStateList {
+								stateList = &StateList{}
+								active[k][c] = stateList
+							}
+							active2[s][c] = stateList.add(s)
+						}
+					}
+				}
+
+				// update pending
+				for c := 0; c < sigmaLen; c++ {
+					aj, ak, ofs := active[j][c].size, active[k][c].size, c*statesLen
+					if !pending2.Test(uint(ofs+j)) && 0 < aj && aj <= ak {
+						pending2.Set(uint(ofs + j))
+						pending = append(pending, IntPair{n1: j, n2: c})
+					} else {
+						pending2.Set(uint(ofs + k))
+						pending = append(pending, IntPair{n1: k, n2: c})
+					}
+				}
+				k++
+			}
+			refine2.Clear(uj)
+			for _, s := range sb {
+				split.Clear(uint(s))
+			}
+			splitblock[j] = sb[:0]
+		}
+		refine.ClearAll()
+	}
+
+	result := NewAutomaton()
+
+	// make a new state for each equivalence class, numbered in breadth-first order from the class of the
+	// initial state, so that the result does not depend on the numbering of the input and minimizing a minimal
+	// automaton returns it unchanged
+	classState := make([]int, k)
+	for n := range classState {
+		classState[n] = -1
 	}
+	stateRep := make([]int, 0, k)
+	classState[block[0]] = result.CreateState()
+	stateRep = append(stateRep, 0)
+	for n := 0; n < len(stateRep); n++ {
+		numTransitions := a.InitTransition(stateRep[n], t)
+		for i := 0; i < numTransitions; i++ {
+			a.GetNextTransition(t)
+			if c := block[t.Dest]; classState[c] == -1 {
+				// select representative
+				classState[c] = result.CreateState()
+				stateRep = append(stateRep, t.Dest)
+			}
+		}
+	}
+
+	// build transitions and set acceptance
+	for n, q := range stateRep {
+		result.SetAccept(n, a.IsAccept(q))
+		numTransitions := a.InitTransition(q, t)
+		for i := 0; i < numTransitions; i++ {
+			a.GetNextTransition(t)
+			if err := result.AddTransition(n, classState[block[t.Dest]], t.Min, t.Max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.FinishState()
+	result.alphabet = alphabet
 
-	// TODO: fix it
-	return determinize(a, determinizeWorkLimit)
+	return opMinimize.done(RemoveDeadStates(result))
 }
 
 type IntPair struct {
@@ -17,8 +274,51 @@ type IntPair struct {
 	n2 int
 }
 
+// emptyStateList Empty list that should never be mutated, used instead of nil so the read path of Minimize
+// doesn't need to branch.
+var emptyStateList = &StateList{}
+
 type StateList struct {
+	size  int
+	first *StateListNode
+	last  *StateListNode
+}
+
+func (s *StateList) add(q int) *StateListNode {
+	return newStateListNode(q, s)
 }
 
 type StateListNode struct {
+	q    int
+	next *StateListNode
+	prev *StateListNode
+	sl   *StateList
+}
+
+func newStateListNode(q int, sl *StateList) *StateListNode {
+	n := &StateListNode{q: q, sl: sl}
+	if sl.size == 0 {
+		sl.first = n
+		sl.last = n
+	} else {
+		sl.last.next = n
+		n.prev = sl.last
+		sl.last = n
+	}
+	sl.size++
+	return n
+}
+
+func (n *StateListNode) remove() {
+	n.sl.size--
+	if n.sl.first == n {
+		n.sl.first = n.next
+	} else {
+		n.prev.next = n.next
+	}
+	if n.sl.last == n {
+		n.sl.last = n.prev
+	} else {
+		n.next.prev = n.prev
+	}
 }
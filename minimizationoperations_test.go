@@ -0,0 +1,229 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Patterns with a known number of states in their minimal DFA.
+var minimizeCorpus = []struct {
+	pattern string
+	states  int
+}{
+	{"(a|b)*abb", 4},
+	{"abc|abd|abe", 4},
+	{"(a|aa|aaa)*", 1},
+	{"[0-9]+(\\.[0-9]+)?", 4},
+	{"(ab|ac|ad)(ab|ac|ad)", 5},
+	{"[ac]*a[ac]{5}", 64},
+	{"(foo|bar|baz)+qux", 9},
+	{".*", 1},
+}
+
+func TestMinimize(t *testing.T) {
+	for _, tc := range minimizeCorpus {
+		re, err := NewRegExp(tc.pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, m.IsDeterministic(), tc.pattern)
+		assert.Equal(t, tc.states, m.GetNumStates(), tc.pattern)
+		assert.False(t, HasDeadStatesFromInitial(m), tc.pattern)
+	}
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1539))
+		for i := 0; i < 200; i++ {
+			pattern := randomRegexp(r, 1+r.Intn(3))
+			re, err := NewRegExp(pattern)
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+
+			m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+			d, err := determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+			assert.LessOrEqual(t, m.GetNumStates(), d.GetNumStates(), pattern)
+
+			for j := 0; j < 30; j++ {
+				s := randomString(r, 8)
+				assert.Equal(t, runNFA(a, s), Run(m, s), "pattern=%q s=%q", pattern, s)
+			}
+		}
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		m, err := Minimize(NewAutomaton(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, m.GetNumStates())
+	})
+}
+
+func TestMinimizeWithStats(t *testing.T) {
+	a := MustNewRegExp("abc|abd|abe").MustToAutomaton()
+	nfa := unionOfStrings(t, "abc", "abd", "abe")
+
+	m, stats, err := MinimizeWithStats(nfa, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.True(t, StructurallyEqual(a, m))
+	assert.Equal(t, nfa.Stats(), stats.Before)
+	assert.Equal(t, m.Stats(), stats.After)
+	assert.Equal(t, nfa.GetNumStates()-4, stats.StatesRemoved())
+	assert.Greater(t, stats.TransitionsRemoved(), 0)
+
+	// The reverse of "[ac]{12}a[ac]*" determinizes to thousands of states:
+	reversed, err := Reverse(MustNewRegExp("[ac]{12}a[ac]*").MustToAutomaton())
+	assert.Nil(t, err)
+	_, _, err = MinimizeWithStats(reversed, 10)
+	assert.NotNil(t, err)
+}
+
+// Returns the union of the automata of the strings, which is not deterministic when they share a prefix.
+func unionOfStrings(t *testing.T, strs ...string) *Automaton {
+	automata := make([]*Automaton, 0, len(strs))
+	for _, s := range strs {
+		a, err := MakeString(s)
+		assert.Nil(t, err)
+		automata = append(automata, a)
+	}
+	a, err := union(automata...)
+	assert.Nil(t, err)
+	return a
+}
+
+func TestIsMinimal(t *testing.T) {
+	for _, tc := range minimizeCorpus {
+		a := MustNewRegExp(tc.pattern).MustToAutomaton()
+		ok, err := IsMinimal(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, ok, tc.pattern)
+	}
+
+	nfa := unionOfStrings(t, "ab", "ac")
+	ok, err := IsMinimal(nfa, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// Deterministic, but both accept states are equivalent:
+	d, err := determinize(nfa, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, d.GetNumStates())
+	ok, err = IsMinimal(d, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestMinimizeIdempotent(t *testing.T) {
+	r := rand.New(rand.NewSource(1618))
+	for i := 0; i < 500; i++ {
+		// Random automata, whose states are numbered unlike the breadth-first order of the minimal automaton:
+		numStates := 1 + r.Intn(8)
+		a := NewAutomaton()
+		for s := 0; s < numStates; s++ {
+			a.CreateState()
+			a.SetAccept(s, r.Intn(3) == 0)
+		}
+		for s := 0; s < numStates; s++ {
+			for j := r.Intn(4); j > 0; j-- {
+				c := 'a' + r.Intn(3)
+				assert.Nil(t, a.AddTransition(s, r.Intn(numStates), c, c+r.Intn(2)))
+			}
+		}
+		a.FinishState()
+
+		m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		m2, err := Minimize(m, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(m, m2))
+
+		ok, err := IsMinimal(m, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	}
+}
+
+func TestAutomaton_Stats(t *testing.T) {
+	re, err := NewRegExp("ab|ac")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+	m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+
+	stats := m.Stats()
+	assert.Equal(t, 3, stats.States)
+	assert.Equal(t, 2, stats.Transitions)
+	assert.True(t, stats.Deterministic)
+	assert.Equal(t, 1, stats.AcceptStates)
+	assert.Greater(t, stats.Bytes, 0)
+}
+
+// Word lists whose tries share many suffixes, so minimization merges a large part of their states.
+var minimizeWordLists = [][]string{
+	{"talk", "walk", "chalk", "talked", "walked", "chalked", "talking", "walking", "chalking"},
+	{"nation", "station", "ration", "nations", "stations", "rations", "national", "rational"},
+	{"january", "february", "march", "april", "may", "june", "july", "august", "september", "october",
+		"november", "december"},
+}
+
+// Reports the size of every corpus automaton before minimization (after determinization) and after, so
+// regressions in either the regexp compilation or the minimization show up in benchmark comparisons.
+func BenchmarkMinimize(b *testing.B) {
+	run := func(b *testing.B, a *Automaton) {
+		d, err := determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var m *Automaton
+		for i := 0; i < b.N; i++ {
+			m, err = Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+		before, after := d.Stats(), m.Stats()
+		b.ReportMetric(float64(before.States), "states-before")
+		b.ReportMetric(float64(after.States), "states-after")
+		b.ReportMetric(float64(before.Transitions), "transitions-before")
+		b.ReportMetric(float64(after.Transitions), "transitions-after")
+	}
+
+	for _, tc := range minimizeCorpus {
+		b.Run(tc.pattern, func(b *testing.B) {
+			re, err := NewRegExp(tc.pattern)
+			if err != nil {
+				b.Fatal(err)
+			}
+			a, err := re.ToAutomaton()
+			if err != nil {
+				b.Fatal(err)
+			}
+			run(b, a)
+		})
+	}
+
+	for _, words := range minimizeWordLists {
+		b.Run(words[0]+"...", func(b *testing.B) {
+			automata := make([]*Automaton, len(words))
+			for i, word := range words {
+				a, err := defaultAutomata.MakeString(word)
+				if err != nil {
+					b.Fatal(err)
+				}
+				automata[i] = a
+			}
+			a, err := union(automata...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			run(b, a)
+		})
+	}
+}
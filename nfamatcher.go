@@ -0,0 +1,85 @@
+package automaton
+
+import "github.com/bits-and-blooms/bitset"
+
+// NFAMatcher Runs an automaton, deterministic or not, by tracking the set of states it may be in after each
+// label, so it never needs to be determinized. Each step costs time proportional to the transitions of the
+// active states rather than O(1) like RunAutomaton, but it works for automata whose determinization exceeds
+// any reasonable work limit, e.g. "(a|b)*a(a|b){30}". Labels are fed one at a time with Step, which allows
+// matching input as it arrives. A matcher must not be used by several goroutines at once; the automaton may be
+// shared.
+type NFAMatcher struct {
+	a *Automaton
+
+	// The active states, and the states active after the current step:
+	active, next []int32
+	// The states added to next, so each state is added once per step:
+	seen *bitset.BitSet
+}
+
+// NewNFAMatcher Returns a matcher positioned at the initial state of the given automaton.
+func NewNFAMatcher(a *Automaton) *NFAMatcher {
+	m := &NFAMatcher{
+		a:    a,
+		seen: bitset.New(uint(a.GetNumStates())),
+	}
+	m.Reset()
+	return m
+}
+
+// Reset Returns the matcher to the initial state, to match another input.
+func (m *NFAMatcher) Reset() {
+	m.active = m.active[:0]
+	if m.a.GetNumStates() > 0 {
+		m.active = append(m.active, 0)
+	}
+}
+
+// Step Consumes one label (a code point, or a byte for a binary automaton) and returns false if no state is
+// active anymore, so no continuation of the input can be accepted.
+func (m *NFAMatcher) Step(label int) bool {
+	a := m.a
+	m.next = m.next[:0]
+	for _, state := range m.active {
+		offset, count := int(a.states[2*state]), int(a.states[2*state+1])
+		// Transitions are sorted by min:
+		for i := 0; i < count; i++ {
+			t := a.transitions[offset+i]
+			if int(t.min) > label {
+				break
+			}
+			dest := t.dest
+			if label <= int(t.max) && !m.seen.Test(uint(dest)) {
+				m.seen.Set(uint(dest))
+				m.next = append(m.next, dest)
+			}
+		}
+	}
+	for _, state := range m.next {
+		m.seen.Clear(uint(state))
+	}
+	m.active, m.next = m.next, m.active
+	return len(m.active) > 0
+}
+
+// IsAccept Returns true if the labels consumed since the last Reset are accepted.
+func (m *NFAMatcher) IsAccept() bool {
+	for _, state := range m.active {
+		if m.a.IsAccept(int(state)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunNFA Returns true if the automaton, which need not be deterministic, accepts s, by simulating it over the
+// code points of s with an NFAMatcher. Unlike Run it works for any automaton, at a higher cost per character.
+func RunNFA(a *Automaton, s string) bool {
+	m := NewNFAMatcher(a)
+	for _, c := range s {
+		if !m.Step(int(c)) {
+			return false
+		}
+	}
+	return m.IsAccept()
+}
@@ -0,0 +1,62 @@
+package automaton
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunNFA(t *testing.T) {
+	t.Run("testTooComplexToDeterminize", func(t *testing.T) {
+		// The reverse of "(a|b){30}a(a|b)*" is "(a|b)*a(a|b){30}", whose deterministic automaton has 2^31 states:
+		forward := MustNewRegExp("(a|b){30}a(a|b)*").MustToAutomaton()
+		a, err := Reverse(forward)
+		assert.Nil(t, err)
+		_, err = determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Error(t, err)
+
+		assert.True(t, RunNFA(a, "bb"+"a"+strings.Repeat("b", 30)))
+		assert.False(t, RunNFA(a, "a"+strings.Repeat("b", 31)))
+		assert.False(t, RunNFA(a, ""))
+	})
+
+	t.Run("testMatcher", func(t *testing.T) {
+		m := NewNFAMatcher(MustNewRegExp("ab*").MustToAutomaton())
+		assert.False(t, m.IsAccept())
+		assert.True(t, m.Step('a'))
+		assert.True(t, m.IsAccept())
+		assert.True(t, m.Step('b'))
+		assert.True(t, m.IsAccept())
+		assert.False(t, m.Step('a'))
+		assert.False(t, m.IsAccept())
+
+		m.Reset()
+		assert.True(t, m.Step('a'))
+		assert.True(t, m.IsAccept())
+
+		assert.False(t, RunNFA(MakeEmpty(), ""))
+		assert.True(t, RunNFA(MakeEmptyString(), ""))
+	})
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1613))
+		for i := 0; i < 100; i++ {
+			pattern := randomRegexp(r, 3)
+			a, err := MustNewRegExp(pattern).ToAutomaton()
+			if err != nil {
+				continue
+			}
+			// Reversing twice undoes the determinization:
+			nfa, err := Reverse(a)
+			assert.Nil(t, err)
+			nfa, err = Reverse(nfa)
+			assert.Nil(t, err)
+			for j := 0; j < 20; j++ {
+				s := randomString(r, 6)
+				assert.Equal(t, runNFA(a, s), RunNFA(nfa, s), "%q %q", pattern, s)
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package automaton
+
+import (
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// RunNormalized Returns true if the given string, normalized to the given form, is accepted by the
+// (deterministic) automaton. The input is normalized segment by segment while stepping, so automata built from
+// normalized dictionaries or patterns (see WithNormalization) match denormalized input consistently.
+func RunNormalized(a *Automaton, s string, form norm.Form) bool {
+	if a.GetNumStates() == 0 {
+		return false
+	}
+	var it norm.Iter
+	it.InitString(form, s)
+	state := 0
+	for !it.Done() {
+		for _, v := range string(it.Next()) {
+			state = a.Step(state, int(v))
+			if state == -1 {
+				return false
+			}
+		}
+	}
+	return a.IsAccept(state)
+}
+
+// RunNormalized Returns true if the given UTF-8 input, normalized to the given form, is accepted by this
+// automaton. See RunNormalized.
+func (r *ByteRunAutomaton) RunNormalized(s []byte, form norm.Form) bool {
+	var it norm.Iter
+	it.Init(form, s)
+	p := 0
+	for !it.Done() {
+		for _, b := range it.Next() {
+			p = r.Step(p, int(b))
+			if p == -1 {
+				return false
+			}
+		}
+	}
+	return r.accept[p]
+}
+
+// Normalizes the literal strings and characters of the expression tree to the given form. Characters whose
+// normal form is longer than one code point become strings.
+func normalizeLiterals(e *RegExp, form norm.Form) {
+	if e == nil {
+		return
+	}
+	switch e.kind {
+	case REGEXP_STRING:
+		s := form.String(*e.s)
+		e.s = &s
+	case REGEXP_CHAR:
+		s := form.String(string(rune(e.c)))
+		if utf8.RuneCountInString(s) == 1 {
+			r, _ := utf8.DecodeRuneInString(s)
+			e.c = int(r)
+		} else {
+			e.kind = REGEXP_STRING
+			e.s = &s
+		}
+	default:
+		normalizeLiterals(e.exp1, form)
+		normalizeLiterals(e.exp2, form)
+	}
+}
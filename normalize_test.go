@@ -0,0 +1,64 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestRunNormalized(t *testing.T) {
+	const composed = "café"
+	const decomposed = "café"
+
+	a, err := defaultAutomata.MakeString(composed)
+	assert.Nil(t, err)
+
+	assert.False(t, Run(a, decomposed))
+	assert.True(t, RunNormalized(a, decomposed, norm.NFC))
+	assert.True(t, RunNormalized(a, composed, norm.NFC))
+	assert.False(t, RunNormalized(a, "cafe", norm.NFC))
+	assert.False(t, RunNormalized(defaultAutomata.MakeEmpty(), "", norm.NFC))
+
+	r, err := NewByteRunAutomaton(utf8Automaton(t, composed), true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.False(t, r.Run([]byte(decomposed)))
+	assert.True(t, r.RunNormalized([]byte(decomposed), norm.NFC))
+	assert.False(t, r.RunNormalized([]byte("cafe"), norm.NFC))
+}
+
+// Returns a binary automaton accepting the UTF-8 encoding of s.
+func utf8Automaton(t *testing.T, s string) *Automaton {
+	a, err := defaultAutomata.MakeBinary([]byte(s))
+	assert.Nil(t, err)
+	return a
+}
+
+func TestWithNormalization(t *testing.T) {
+	t.Run("testDecomposedPattern", func(t *testing.T) {
+		re, err := NewRegExp("café|tea", WithNormalization(norm.NFC))
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		assert.True(t, Run(a, "café"))
+		assert.True(t, RunNormalized(a, "café", norm.NFC))
+	})
+
+	t.Run("testCompatibilityCharBecomesString", func(t *testing.T) {
+		re, err := NewRegExp("ﬁx|y", WithNormalization(norm.NFKC))
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		assert.True(t, Run(a, "fix"))
+		assert.True(t, RunNormalized(a, "ﬁx", norm.NFKC))
+		assert.False(t, Run(a, "ﬁx"))
+	})
+
+	t.Run("testWithoutNormalization", func(t *testing.T) {
+		re, err := NewRegExp("café")
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		assert.False(t, Run(a, "café"))
+	})
+}
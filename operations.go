@@ -1,10 +1,14 @@
 package automaton
 
 import (
-	"bytes"
 	"cmp"
 	"errors"
+	"fmt"
+	"maps"
+	"math"
 	"slices"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"unicode"
 
@@ -42,7 +46,7 @@ func DeterminizeAutomaton(a *Automaton, workLimit int) *Automaton {
 	b.CreateState()
 
 	worklist := make([]*FrozenIntSet, 0)
-	newState := NewHashMap[int](WithCapacity(1))
+	newState := NewHashMap[int](WithCapacity(1), WithoutLocking())
 
 	worklist = append(worklist, initialSet)
 	b.SetAccept(0, a.IsAccept(0))
@@ -129,7 +133,7 @@ func GetSingletonAutomaton(a *Automaton) ([]int, error) {
 		if a.IsAccept(s) == false {
 			if a.GetNumTransitionsWithState(s) == 1 {
 				a.getTransition(s, 0, t)
-				if _, ok := visited[t.Dest]; t.Min == t.Max && ok {
+				if _, ok := visited[t.Dest]; t.Min == t.Max && !ok {
 					ints = append(ints, t.Min)
 					s = t.Dest
 					continue
@@ -183,20 +187,21 @@ func isFinite(scratch *Transition, a *Automaton, state int, path, visited *bitse
 }
 
 // getCommonSuffixBytesRef
-// Returns the longest BytesRef that is a suffix of all accepted strings. Worst case complexity: quadratic with the number of states+transitions.
+// Returns the longest BytesRef that is a suffix of all accepted strings. At most workLimit states are visited
+// while looking for the suffix; if that is not enough, the empty suffix is returned, which is always correct.
 // Returns: common suffix, which can be an empty (length 0) BytesRef (never null)
-func getCommonSuffixBytesRef(a *Automaton) ([]byte, error) {
+func getCommonSuffixBytesRef(a *Automaton, workLimit int) ([]byte, error) {
 	// reverse the language of the automaton, then reverse its common prefix.
 	ra, err := reverse(a)
 	if err != nil {
 		return nil, err
 	}
-	r, err := removeDeadStates(ra)
+	r, err := RemoveDeadStates(ra)
 	if err != nil {
 		return nil, err
 	}
 
-	ref, err := getCommonPrefixBytesRef(r)
+	ref, err := getCommonPrefixBytesRefWithLimit(r, workLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -204,34 +209,70 @@ func getCommonSuffixBytesRef(a *Automaton) ([]byte, error) {
 	return ref, nil
 }
 
-// Returns true if there are dead states reachable from an initial state.
-func hasDeadStatesFromInitial(a *Automaton) bool {
+// HasDeadStates Returns true if the automaton has any states that cannot be reached from the initial state or
+// cannot reach an accept state. Cost is O(numTransitions+numStates).
+func HasDeadStates(a *Automaton) bool {
+	return int(getLiveStates(a).Count()) < a.GetNumStates()
+}
+
+// HasDeadStatesFromInitial Returns true if there are dead states reachable from an initial state.
+func HasDeadStatesFromInitial(a *Automaton) bool {
+	reachableFromInitial := getLiveStatesFromInitial(a)
+	reachableFromAccept := getLiveStatesToAccept(a)
+	reachableFromInitial.InPlaceDifference(reachableFromAccept)
+	return reachableFromInitial.Any()
+}
+
+// HasDeadStatesToAccept Returns true if there are dead states that reach an accept state.
+func HasDeadStatesToAccept(a *Automaton) bool {
 	reachableFromInitial := getLiveStatesFromInitial(a)
 	reachableFromAccept := getLiveStatesToAccept(a)
-	reachableFromInitial = reachableFromInitial.Difference(reachableFromAccept)
-	return reachableFromInitial.Count() > 0
+	reachableFromAccept.InPlaceDifference(reachableFromInitial)
+	return reachableFromAccept.Any()
 }
 
+// getCommonPrefix
+// Returns the longest string that is a prefix of all accepted strings. The automaton must not have dead states
+// reachable from the initial state.
 func getCommonPrefix(a *Automaton) (string, error) {
+	labels, err := getCommonPrefixLabels(a, math.MaxInt)
+	if err != nil {
+		return "", err
+	}
+	builder := new(strings.Builder)
+	for _, label := range labels {
+		builder.WriteRune(rune(label))
+	}
+	return builder.String(), nil
+}
 
-	if hasDeadStatesFromInitial(a) {
-		return "", errors.New("input automaton has dead states")
+// Returns the labels of the longest common prefix of all accepted strings, see getCommonPrefix. Gives up and
+// returns the empty prefix once more than workLimit states were visited, so long cycles in non-deterministic
+// automata can not make it quadratic.
+func getCommonPrefixLabels(a *Automaton, workLimit int) ([]int, error) {
+	if HasDeadStatesFromInitial(a) {
+		return nil, errors.New("input automaton has dead states")
 	}
 	if isEmpty(a) {
-		return "", nil
+		return nil, nil
 	}
-	builder := new(bytes.Buffer)
+	var prefix []int
 	scratch := NewTransition()
 	visited := bitset.New(uint(a.GetNumStates()))
 	current := bitset.New(uint(a.GetNumStates()))
 	next := bitset.New(uint(a.GetNumStates()))
 	current.Set(0) // start with initial state
+	work := 0
 OUT:
 	for {
 		label := -1
 		// do a pass, stepping all current paths forward once
 		state, ok := current.NextSet(0)
 		for ok {
+			work++
+			if work > workLimit {
+				return nil, nil
+			}
 			visited.Set(state)
 			// if it is an accept state, we are done
 			if a.IsAccept(int(state)) {
@@ -257,15 +298,19 @@ OUT:
 			}
 		}
 
+		if label == -1 {
+			// Only possible with dead states, which were checked up front
+			return nil, errors.New("input automaton has dead states")
+		}
 		// add the label to the prefix
-		builder.WriteRune(rune(label))
+		prefix = append(prefix, label)
 		// swap "current" with "next", clear "next"
 		tmp := current
 		current = next
 		next = tmp
 		next.ClearAll()
 	}
-	return builder.String(), nil
+	return prefix, nil
 }
 
 func isEmpty(a *Automaton) bool {
@@ -308,21 +353,32 @@ func isEmpty(a *Automaton) bool {
 	return true
 }
 
+// getCommonPrefixBytesRef
+// Returns the longest BytesRef that is a prefix of all accepted strings of a binary automaton. Returns an
+// error if a label of the prefix does not fit in a byte.
 func getCommonPrefixBytesRef(a *Automaton) ([]byte, error) {
-	prefix, err := getCommonPrefix(a)
+	return getCommonPrefixBytesRefWithLimit(a, math.MaxInt)
+}
+
+func getCommonPrefixBytesRefWithLimit(a *Automaton, workLimit int) ([]byte, error) {
+	labels, err := getCommonPrefixLabels(a, workLimit)
 	if err != nil {
 		return nil, err
 	}
-	builder := new(bytes.Buffer)
-
-	for _, ch := range prefix {
-		if ch > 255 {
-			return nil, errors.New("automaton is not binary")
+	ref := make([]byte, len(labels))
+	for i, label := range labels {
+		if label > 255 {
+			return nil, fmt.Errorf("automaton is not binary: common prefix label %d is above byte 255", label)
 		}
-		builder.WriteRune(ch)
+		ref[i] = byte(label)
 	}
+	return ref, nil
+}
 
-	return builder.Bytes(), nil
+// Reverse Returns an automaton accepting the reverse of every string accepted by a. The result is not
+// deterministic in general.
+func Reverse(a *Automaton) (*Automaton, error) {
+	return reverse(a)
 }
 
 func reverse(a *Automaton) (*Automaton, error) {
@@ -338,7 +394,8 @@ func reverseStates(a *Automaton, initialStates map[int]struct{}) (*Automaton, er
 	numStates := a.GetNumStates()
 
 	// Build a new automaton with all edges reversed
-	builder := NewBuilder()
+	builder := getBuilder()
+	defer putBuilder(builder)
 
 	// Initial node; we'll add epsilon transitions in the end:
 	builder.CreateState()
@@ -379,25 +436,43 @@ func reverseStates(a *Automaton, initialStates map[int]struct{}) (*Automaton, er
 	}
 
 	result.FinishState()
+	result.alphabet = a.alphabet
 
-	return result, nil
+	return opReverse.done(result, nil)
 }
 
 func reverseAutomaton(a *Automaton) *Automaton {
 	return reverseAutomatonIntSet(a, nil)
 }
 
-func removeDeadStates(a *Automaton) (*Automaton, error) {
+// RemoveDeadStates Removes transitions to dead states (a state is "dead" if it is not reachable from the initial
+// state or no accept state is reachable from it), and renumbers the remaining states.
+func RemoveDeadStates(a *Automaton) (*Automaton, error) {
 	numStates := a.GetNumStates()
 	liveSet := getLiveStates(a)
 
 	mp := make([]int, numStates)
 
-	result := NewAutomaton()
+	// Size the result up front; transitions are kept if they join two live states:
+	numTransitions := 0
+	for i := 0; i < numStates; i++ {
+		if liveSet.Test(uint(i)) {
+			offset, count := int(a.states[2*i]), int(a.states[2*i+1])
+			for j := 0; j < count; j++ {
+				if liveSet.Test(uint(a.transitions[offset+j].dest)) {
+					numTransitions++
+				}
+			}
+		}
+	}
+	result := NewAutomatonV1(int(liveSet.Count()), numTransitions)
 	for i := 0; i < numStates; i++ {
 		if liveSet.Test(uint(i)) {
 			mp[i] = result.CreateState()
 			result.SetAccept(mp[i], a.IsAccept(i))
+			if label, ok := a.stateLabels[i]; ok {
+				result.stateLabels = setStateLabel(result.stateLabels, mp[i], label)
+			}
 		}
 	}
 
@@ -420,13 +495,15 @@ func removeDeadStates(a *Automaton) (*Automaton, error) {
 	}
 
 	result.FinishState()
-	//assert hasDeadStates(result) == false;
-	return result, nil
+	result.alphabet = a.alphabet
+	result.noDeadStates = true
+	return opRemoveDeadStates.done(result, nil)
 }
 
+// Returns the states that are both reachable from the initial state and can reach an accept state.
 func getLiveStates(a *Automaton) *bitset.BitSet {
 	live := getLiveStatesFromInitial(a)
-	live.Union(getLiveStatesToAccept(a))
+	live.InPlaceIntersection(getLiveStatesToAccept(a))
 	return live
 }
 
@@ -436,20 +513,17 @@ func getLiveStatesFromInitial(a *Automaton) *bitset.BitSet {
 	if numStates == 0 {
 		return live
 	}
-	workList := make([]int, 0)
+	workList := []int32{0}
 	live.Set(0)
-	workList = append(workList, 0)
-
-	t := NewTransition()
 	for len(workList) > 0 {
-		s := workList[0]
-		workList = workList[1:]
-		count := a.InitTransition(s, t)
+		s := workList[len(workList)-1]
+		workList = workList[:len(workList)-1]
+		offset, count := int(a.states[2*s]), int(a.states[2*s+1])
 		for i := 0; i < count; i++ {
-			a.GetNextTransition(t)
-			if live.Test(uint(t.Dest)) == false {
-				live.Set(uint(t.Dest))
-				workList = append(workList, t.Dest)
+			dest := a.transitions[offset+i].dest
+			if !live.Test(uint(dest)) {
+				live.Set(uint(dest))
+				workList = append(workList, dest)
 			}
 		}
 	}
@@ -457,53 +531,51 @@ func getLiveStatesFromInitial(a *Automaton) *bitset.BitSet {
 	return live
 }
 
+// getLiveStatesToAccept Returns the states from which an accept state can be reached, by a search from the
+// accept states over the reversed transitions. The predecessors of every state are gathered straight
+// from the packed transitions into one array indexed by destination, without building a reversed automaton.
 func getLiveStatesToAccept(a *Automaton) *bitset.BitSet {
-	builder := NewBuilder()
-
-	// NOTE: not quite the same thing as what SpecialOperations.reverse does:
-	t := NewTransition()
 	numStates := a.GetNumStates()
+
+	// The predecessors of state s are preds[starts[s]:starts[s+1]]:
+	starts := make([]int32, numStates+1)
 	for s := 0; s < numStates; s++ {
-		builder.CreateState()
+		offset, count := int(a.states[2*s]), int(a.states[2*s+1])
+		for i := 0; i < count; i++ {
+			starts[a.transitions[offset+i].dest+1]++
+		}
 	}
 	for s := 0; s < numStates; s++ {
-		count := a.InitTransition(s, t)
+		starts[s+1] += starts[s]
+	}
+	preds := make([]int32, starts[numStates])
+	next := slices.Clone(starts[:numStates])
+	for s := 0; s < numStates; s++ {
+		offset, count := int(a.states[2*s]), int(a.states[2*s+1])
 		for i := 0; i < count; i++ {
-			a.GetNextTransition(t)
-			builder.AddTransition(t.Dest, s, t.Min, t.Max)
+			dest := a.transitions[offset+i].dest
+			preds[next[dest]] = int32(s)
+			next[dest]++
 		}
 	}
-	a2 := builder.Finish()
 
-	workList := make([]int, 0)
 	live := bitset.New(uint(numStates))
-	acceptBits := a.getAcceptStates()
-	s := uint(0)
-	ok := false
-	for int(s) < numStates {
-		s, ok = acceptBits.NextSet(s)
-		if !ok {
-			break
-		}
-
+	workList := make([]int32, 0)
+	acceptStates := a.getAcceptStates()
+	for s, ok := acceptStates.NextSet(0); ok && int(s) < numStates; s, ok = acceptStates.NextSet(s + 1) {
 		live.Set(s)
-		workList = append(workList, int(s))
-		s++
+		workList = append(workList, int32(s))
 	}
-
 	for len(workList) > 0 {
-		state := workList[0]
-		workList = workList[1:]
-		count := a2.InitTransition(state, t)
-		for i := 0; i < count; i++ {
-			a2.GetNextTransition(t)
-			if live.Test(uint(t.Dest)) == false {
-				live.Set(uint(t.Dest))
-				workList = append(workList, t.Dest)
+		state := workList[len(workList)-1]
+		workList = workList[:len(workList)-1]
+		for _, pred := range preds[starts[state]:starts[state+1]] {
+			if !live.Test(uint(pred)) {
+				live.Set(uint(pred))
+				workList = append(workList, pred)
 			}
 		}
 	}
-
 	return live
 }
 
@@ -515,7 +587,8 @@ func reverseAutomatonIntSet(a *Automaton, initialStates map[int]struct{}) *Autom
 	numStates := a.GetNumStates()
 
 	// Build a new automaton with all edges reversed
-	builder := NewBuilder()
+	builder := getBuilder()
+	defer putBuilder(builder)
 
 	// Initial node; we'll add epsilon transitions in the end:
 	builder.CreateState()
@@ -558,7 +631,17 @@ func reverseAutomatonIntSet(a *Automaton, initialStates map[int]struct{}) *Autom
 	return result
 }
 
+// Union Returns an automaton accepting the strings accepted by a1 or a2. The result is not necessarily
+// deterministic, but has no dead states.
+func Union(a1, a2 *Automaton) (*Automaton, error) {
+	return union(a1, a2)
+}
+
 func union(automatons ...*Automaton) (*Automaton, error) {
+	if allWithoutDeadStates(automatons) {
+		return opUnion.done(unionWithoutDeadStates(automatons))
+	}
+
 	result := NewAutomaton()
 
 	// Create initial state:
@@ -581,19 +664,213 @@ func union(automatons ...*Automaton) (*Automaton, error) {
 
 	result.FinishState()
 
-	return removeDeadStates(result)
+	result.alphabet = commonAlphabet(automatons...)
+	return opUnion.done(RemoveDeadStates(result))
 }
 
-func concatenate(automatons ...*Automaton) (*Automaton, error) {
+// Returns true if some automaton has states and none is known to have dead states.
+func allWithoutDeadStates(automatons []*Automaton) bool {
+	hasStates := false
+	for _, a := range automatons {
+		if !a.noDeadStates {
+			return false
+		}
+		hasStates = hasStates || a.GetNumStates() > 0
+	}
+	return hasStates
+}
+
+// unionWithoutDeadStates Unions automata without dead states without creating any, so the result needs no
+// cleanup: the transitions of the initial states are copied to the new initial state, and the initial state of
+// an automaton is only kept if some transition leads back to it.
+func unionWithoutDeadStates(automatons []*Automaton) (*Automaton, error) {
 	result := NewAutomaton()
+	result.CreateState()
 
-	// First pass: create all states
+	// The state of the result of each state of each automaton, -1 for dropped initial states:
+	mapping := make([][]int, len(automatons))
+	for i, a := range automatons {
+		numStates := a.GetNumStates()
+		if numStates == 0 {
+			continue
+		}
+		mapping[i] = make([]int, numStates)
+		mapping[i][0] = -1
+		if hasTransitionTo(a, 0) {
+			mapping[i][0] = result.CreateState()
+		}
+		for s := 1; s < numStates; s++ {
+			mapping[i][s] = result.CreateState()
+		}
+		for s := 0; s < numStates; s++ {
+			if mapping[i][s] >= 0 {
+				result.SetAccept(mapping[i][s], a.IsAccept(s))
+				if label, ok := a.stateLabels[s]; ok {
+					result.stateLabels = setStateLabel(result.stateLabels, mapping[i][s], label)
+				}
+			}
+		}
+		if a.IsAccept(0) {
+			result.SetAccept(0, true)
+		}
+	}
+
+	// Transitions must be added state by state, the new initial state first:
+	t := NewTransition()
+	for i, a := range automatons {
+		if mapping[i] == nil {
+			continue
+		}
+		count := a.InitTransition(0, t)
+		for j := 0; j < count; j++ {
+			a.GetNextTransition(t)
+			if err := result.AddTransition(0, mapping[i][t.Dest], t.Min, t.Max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for i, a := range automatons {
+		for s, state := range mapping[i] {
+			if state < 0 {
+				continue
+			}
+			count := a.InitTransition(s, t)
+			for j := 0; j < count; j++ {
+				a.GetNextTransition(t)
+				if err := result.AddTransition(state, mapping[i][t.Dest], t.Min, t.Max); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	result.FinishState()
+
+	result.alphabet = commonAlphabet(automatons...)
+	result.noDeadStates = true
+	return result, nil
+}
+
+// Returns true if some transition of the automaton leads to the given state.
+func hasTransitionTo(a *Automaton, state int) bool {
+	for _, t := range a.transitions {
+		if int(t.dest) == state {
+			return true
+		}
+	}
+	return false
+}
+
+func concatenate(automatons ...*Automaton) (*Automaton, error) {
+	// The empty string is the identity of concatenation, and the empty language absorbs everything:
+	operands := make([]*Automaton, 0, len(automatons))
 	for _, a := range automatons {
 		if a.GetNumStates() == 0 {
-			result.FinishState()
-			return result, nil
+			result := defaultAutomata.MakeEmpty()
+			result.alphabet = commonAlphabet(automatons...)
+			return opConcatenate.done(result, nil)
+		}
+		if !isEmptyStringOnly(a) {
+			operands = append(operands, a)
+		}
+	}
+
+	var result *Automaton
+	var err error
+	switch {
+	case len(operands) == 0:
+		result = defaultAutomata.MakeEmptyString()
+		result.FinishState()
+	case allLinear(operands):
+		result, err = concatenateLinear(operands)
+	default:
+		result, err = concatenateGeneral(operands...)
+	}
+	if result != nil {
+		result.alphabet = commonAlphabet(automatons...)
+	}
+	return opConcatenate.done(result, err)
+}
+
+// Returns true if the automaton accepts only the empty string because its initial state is an accept state
+// without transitions, as built by MakeEmptyString.
+func isEmptyStringOnly(a *Automaton) bool {
+	return a.GetNumStates() > 0 && a.IsAccept(0) && a.GetNumTransitionsWithState(0) == 0
+}
+
+// Returns true if every automaton is a linear chain, see isLinear.
+func allLinear(automatons []*Automaton) bool {
+	for _, a := range automatons {
+		if !isLinear(a) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the automaton is a linear chain as built by MakeString, MakeChar or MakeBinary: state s
+// has a single transition to state s+1, and the last state is the only accept state and has no transitions.
+func isLinear(a *Automaton) bool {
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return false
+	}
+	last := numStates - 1
+	for s := 0; s < last; s++ {
+		if a.IsAccept(s) || a.GetNumTransitionsWithState(s) != 1 {
+			return false
+		}
+		if int(a.transitions[a.states[2*s]].dest) != s+1 {
+			return false
 		}
+	}
+	return a.IsAccept(last) && a.GetNumTransitionsWithState(last) == 0
+}
+
+// Concatenates linear chains by appending their transitions to a single chain, which avoids the virtual
+// epsilon transitions of the general case. The initial state of each chain is merged with the last state of the
+// previous one, and keeps its label only if that state has none.
+func concatenateLinear(automatons []*Automaton) (*Automaton, error) {
+	numTransitions := 0
+	for _, a := range automatons {
+		numTransitions += a.GetNumStates() - 1
+	}
+
+	result := NewAutomatonV1(numTransitions+1, numTransitions)
+	lastState := result.CreateState()
+
+	t := NewTransition()
+	for _, a := range automatons {
+		if label, ok := a.stateLabels[0]; ok && result.stateLabels[lastState] == "" {
+			result.stateLabels = setStateLabel(result.stateLabels, lastState, label)
+		}
+		last := a.GetNumStates() - 1
+		for s := 0; s < last; s++ {
+			a.getTransition(s, 0, t)
+			state := result.CreateState()
+			if err := result.AddTransition(lastState, state, t.Min, t.Max); err != nil {
+				return nil, err
+			}
+			if label, ok := a.stateLabels[s+1]; ok {
+				result.stateLabels = setStateLabel(result.stateLabels, state, label)
+			}
+			lastState = state
+		}
+	}
+
+	result.SetAccept(lastState, true)
+	result.FinishState()
+	return result, nil
+}
+
+// Concatenates automata of any shape, which must all have states, linking the accept states of each to the
+// initial transitions of the next.
+func concatenateGeneral(automatons ...*Automaton) (*Automaton, error) {
+	result := NewAutomaton()
+
+	// First pass: create all states
+	for _, a := range automatons {
 		numStates := a.GetNumStates()
+		result.stateLabels = copyStateLabels(result.stateLabels, a.stateLabels, result.GetNumStates())
 		for s := 0; s < numStates; s++ {
 			result.CreateState()
 		}
@@ -673,7 +950,13 @@ func concatenate(automatons ...*Automaton) (*Automaton, error) {
 	return result, nil
 }
 
-func totalize(a *Automaton) (*Automaton, error) {
+// Totalize
+// Returns an automaton accepting the same strings as the given deterministic automaton, in which every state
+// has a transition for every label of its alphabet (every code point, or every byte of a binary automaton): the
+// missing transitions lead to an added rejecting sink state, which is returned too (it is numbered
+// a.GetNumStates()). Flipping the accept states of the result complements it, see IsTotalized.
+func Totalize(a *Automaton) (*Automaton, int, error) {
+	maxLabel := a.alphabet.maxLabel()
 	result := NewAutomaton()
 	numStates := a.GetNumStates()
 	for i := 0; i < numStates; i++ {
@@ -682,9 +965,9 @@ func totalize(a *Automaton) (*Automaton, error) {
 	}
 
 	deadState := result.CreateState()
-	err := result.AddTransition(deadState, deadState, 0, unicode.MaxRune)
+	err := result.AddTransition(deadState, deadState, 0, maxLabel)
 	if err != nil {
-		return nil, err
+		return nil, -1, err
 	}
 
 	t := NewTransition()
@@ -695,12 +978,12 @@ func totalize(a *Automaton) (*Automaton, error) {
 			a.GetNextTransition(t)
 			err := result.AddTransition(i, t.Dest, t.Min, t.Max)
 			if err != nil {
-				return nil, err
+				return nil, -1, err
 			}
 			if t.Min > maxi {
 				err := result.AddTransition(i, deadState, maxi, t.Min-1)
 				if err != nil {
-					return nil, err
+					return nil, -1, err
 				}
 			}
 			if t.Max+1 > maxi {
@@ -708,35 +991,127 @@ func totalize(a *Automaton) (*Automaton, error) {
 			}
 		}
 
-		if maxi <= unicode.MaxRune {
-			err := result.AddTransition(i, deadState, maxi, unicode.MaxRune)
+		if maxi <= maxLabel {
+			err := result.AddTransition(i, deadState, maxi, maxLabel)
 			if err != nil {
-				return nil, err
+				return nil, -1, err
 			}
 		}
 	}
 
 	result.FinishState()
-	return result, nil
+	result.alphabet = a.alphabet
+	result, err = opTotalize.done(result, nil)
+	return result, deadState, err
+}
+
+// IsTotalized Returns true if every state of the given automaton has a transition for every label of its
+// alphabet, as in the result of Totalize.
+func IsTotalized(a *Automaton) bool {
+	numStates := a.GetNumStates()
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		// Transitions are sorted by min, so they cover all labels if they leave no gap:
+		next := 0
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			if t.Min > next {
+				return false
+			}
+			next = max(next, t.Max+1)
+		}
+		if next <= a.alphabet.maxLabel() {
+			return false
+		}
+	}
+	return true
 }
 
 func complement(a *Automaton, determinizeWorkLimit int) (*Automaton, error) {
-	a, err := determinize(a, determinizeWorkLimit)
+	return complementBudget(a, determinizeWorkLimit, nil)
+}
+
+// complementBudget Complements the automaton, taking the effort spent determinizing it from budget, if any. The
+// complement is taken over the alphabet of the automaton: the complement of a binary automaton is binary too,
+// and contains only strings of bytes.
+func complementBudget(a *Automaton, determinizeWorkLimit int, budget *Budget) (*Automaton, error) {
+	a, err := determinizeBudget(a, determinizeWorkLimit, budget)
 	if err != nil {
 		return nil, err
 	}
-	a, err = totalize(a)
+	// determinize returns a itself when it is already deterministic, but Totalize always builds a new
+	// automaton, so flipping the accept states below never modifies the caller's automaton.
+	a, _, err = Totalize(a)
 	if err != nil {
 		return nil, err
 	}
+	if debugAssertions && !IsTotalized(a) {
+		panic("automaton: totalize result is missing transitions")
+	}
 	numStates := a.GetNumStates()
 	for p := 0; p < numStates; p++ {
 		a.SetAccept(p, !a.IsAccept(p))
 	}
-	return removeDeadStates(a)
+	return opComplement.done(RemoveDeadStates(a))
+}
+
+// ComplementOver Returns a (deterministic) automaton accepting the strings over the given alphabet that the
+// automaton does not accept: alphabet* minus the language of a. Unlike complement, which adds the strings
+// containing any other code point, transitions are only created for the alphabet, so the result stays small
+// when the domain is known, e.g. lowercase ASCII words. Transitions of a outside the alphabet are dropped.
+func ComplementOver(a *Automaton, alphabet *RangeSet, determinizeWorkLimit int) (*Automaton, error) {
+	a, err := determinize(a, determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := NewAutomaton()
+	numStates := a.GetNumStates()
+	for s := 0; s < numStates; s++ {
+		result.CreateState()
+		result.SetAccept(s, !a.IsAccept(s))
+	}
+	deadState := result.CreateState()
+	result.SetAccept(deadState, true)
+
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		count := a.InitTransition(s, t)
+		labels := make([][2]int, 0, count)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			labels = append(labels, [2]int{t.Min, t.Max})
+			for _, r := range alphabet.intersection(NewRangeSet([2]int{t.Min, t.Max})).Ranges() {
+				if err := result.AddTransition(s, t.Dest, r[0], r[1]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		// The rest of the alphabet leads to the (accepting) dead state:
+		for _, r := range alphabet.intersection(NewRangeSet(labels...).complement()).Ranges() {
+			if err := result.AddTransition(s, deadState, r[0], r[1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for _, r := range alphabet.Ranges() {
+		if err := result.AddTransition(deadState, deadState, r[0], r[1]); err != nil {
+			return nil, err
+		}
+	}
+	result.FinishState()
+
+	return opComplementOver.done(RemoveDeadStates(result))
 }
 
 func determinize(a *Automaton, workLimit int) (*Automaton, error) {
+	return determinizeBudget(a, workLimit, nil)
+}
+
+// determinizeBudget Determinizes the automaton, spending at most workLimit and the remaining work of budget,
+// if any; the effort spent is taken from budget.
+func determinizeBudget(a *Automaton, workLimit int, budget *Budget) (*Automaton, error) {
 	if a.IsDeterministic() {
 		// Already determinized
 		return a, nil
@@ -746,8 +1121,67 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 		return a, nil
 	}
 
+	// LUCENE-9981: approximate conversion from what used to be a limit on number of states, to
+	// maximum "effort":
+	effortLimit, budgetLimited := budget.effortLimit(workLimit)
+
+	result, effortSpent, err := subsetConstruction(a, effortLimit, newMemorySubsets(), nil)
+	if errors.Is(err, errEffortLimit) {
+		if budgetLimited {
+			return nil, budget.exhaust()
+		}
+		return nil, errors.New("too Complex To Determinize")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := budget.spend(effortSpent); err != nil {
+		return nil, err
+	}
+	return opDeterminize.done(result, nil)
+}
+
+// errEffortLimit Returned by subsetConstruction when it would spend more than its effort limit.
+var errEffortLimit = errors.New("effort limit reached")
+
+// subsetMap Maps the sets of states of the automaton being determinized to the states of the result.
+type subsetMap interface {
+	// get Returns the state of the result for the given set, and whether there is one.
+	get(s *StateSet) (int, bool, error)
+
+	// put Records the state of the result for the given set.
+	put(s *FrozenIntSet, state int) error
+}
+
+// memorySubsets A subsetMap keeping all sets in memory.
+type memorySubsets struct {
+	sets *HashMap[int]
+}
+
+func newMemorySubsets() *memorySubsets {
+	return &memorySubsets{sets: NewHashMap[int](WithoutLocking())}
+}
+
+func (m *memorySubsets) get(s *StateSet) (int, bool, error) {
+	q, ok := m.sets.Get(s)
+	return q, ok, nil
+}
+
+func (m *memorySubsets) put(s *FrozenIntSet, state int) error {
+	m.sets.Set(s, state)
+	return nil
+}
+
+// subsetConstruction Determinizes the automaton with the powerset construction, recording the sets of states
+// found in subsets, and returns the result with the effort spent. It fails with errEffortLimit once the effort
+// reaches effortLimit. progress, if not nil, is called before each set is expanded, with the number of states
+// created, the number of sets left to expand and the effort spent so far.
+func subsetConstruction(a *Automaton, effortLimit int, subsets subsetMap,
+	progress func(states, pending, effort int)) (*Automaton, int, error) {
+
 	// subset construction
-	b := NewBuilder()
+	b := getBuilder()
+	defer putBuilder(b)
 
 	//System.out.println("DET:");
 	//a.writeDot("/l/la/lucene/core/detin.dot");
@@ -759,12 +1193,13 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 	b.CreateState()
 
 	worklist := make([]*FrozenIntSet, 0)
-	newstate := NewHashMap[int]()
 
 	worklist = append(worklist, initialset)
 
 	b.SetAccept(0, a.IsAccept(0))
-	newstate.Set(initialset, 0)
+	if err := subsets.put(initialset, 0); err != nil {
+		return nil, 0, err
+	}
 
 	// like Set<Integer,PointTransitions>
 	points := NewPointTransitionSet()
@@ -776,10 +1211,6 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 
 	effortSpent := 0
 
-	// LUCENE-9981: approximate conversion from what used to be a limit on number of states, to
-	// maximum "effort":
-	effortLimit := workLimit * 10
-
 	for len(worklist) > 0 {
 		// TODO (LUCENE-9983): these int sets really do not need to be sorted, and we are paying
 		// a high (unecessary) price for that!  really we just need a low-overhead Map<int,int>
@@ -793,7 +1224,10 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 		// of determinized states:
 		effortSpent += len(s.values)
 		if effortSpent >= effortLimit {
-			return nil, errors.New("too Complex To Determinize")
+			return nil, effortSpent, errEffortLimit
+		}
+		if progress != nil {
+			progress(b.GetNumStates(), len(worklist), effortSpent)
 		}
 
 		// Collate all outgoing transitions by min/1+max:
@@ -812,8 +1246,6 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 			continue
 		}
 
-		points.Sort()
-
 		lastPoint := -1
 		accCount := 0
 
@@ -825,14 +1257,19 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 
 			if statesSet.Size() > 0 {
 
-				q, ok := newstate.Get(statesSet)
+				q, ok, err := subsets.get(statesSet)
+				if err != nil {
+					return nil, effortSpent, err
+				}
 				if !ok {
 					q = b.CreateState()
 					p := statesSet.Freeze(q)
 					//System.out.println("  make new state=" + q + " -> " + p + " accCount=" + accCount);
 					worklist = append(worklist, p)
 					b.SetAccept(q, accCount > 0)
-					newstate.Set(p, q)
+					if err := subsets.put(p, q); err != nil {
+						return nil, effortSpent, err
+					}
 				}
 
 				// System.out.println("  add trans src=" + r + " dest=" + q + " min=" + lastPoint + " max=" + (point-1));
@@ -872,7 +1309,8 @@ func determinize(a *Automaton, workLimit int) (*Automaton, error) {
 	}
 
 	result := b.Finish()
-	return result, nil
+	result.alphabet = a.alphabet
+	return result, effortSpent, nil
 }
 
 type TransitionList struct {
@@ -915,23 +1353,45 @@ func (p *PointTransitions) reset(point int) {
 	p.ends.reset()
 }
 
+// PointTransitionSet Collates the transitions leaving a set of states by the points where they start or end.
+// Points are kept sorted by insertion into a slice, and PointTransitions are pooled and reused across Reset,
+// since determinize fills and empties the set once per new state.
 type PointTransitionSet struct {
+	// Points in use, sorted by point.
 	points []*PointTransitions
-	imap   map[int]*PointTransitions
+
+	// Free PointTransitions, reused by next.
+	pool []*PointTransitions
 }
 
 func (s *PointTransitionSet) find(point int) *PointTransitions {
-	p, ok := s.imap[point]
-	if !ok {
-		p = s.next(point)
-		s.imap[point] = p
+	// Most points are added in increasing order, so check the last one first:
+	n := len(s.points)
+	if n == 0 || s.points[n-1].point < point {
+		p := s.next(point)
+		s.points = append(s.points, p)
+		return p
 	}
+
+	i, ok := slices.BinarySearchFunc(s.points, point, func(p *PointTransitions, point int) int {
+		return cmp.Compare(p.point, point)
+	})
+	if ok {
+		return s.points[i]
+	}
+	p := s.next(point)
+	s.points = slices.Insert(s.points, i, p)
 	return p
 }
 
 func (s *PointTransitionSet) next(point int) *PointTransitions {
-	points0 := NewPointTransitions()
-	s.points = append(s.points, points0)
+	var points0 *PointTransitions
+	if n := len(s.pool); n > 0 {
+		points0 = s.pool[n-1]
+		s.pool = s.pool[:n-1]
+	} else {
+		points0 = NewPointTransitions()
+	}
 	points0.reset(point)
 	return points0
 }
@@ -941,39 +1401,31 @@ func (s *PointTransitionSet) Add(t *Transition) {
 	s.find(1 + t.Max).ends.Add(t)
 }
 
-func (s *PointTransitionSet) Sort() {
-	slices.SortStableFunc(s.points, func(e, e2 *PointTransitions) int {
-		return cmp.Compare(e.point, e2.point)
-		//if e.point < e2.point {
-		//	return -1
-		//} else if e.point == e2.point {
-		//	return 0
-		//}
-		//return 1
-	})
-}
-
 func (s *PointTransitionSet) Reset() {
-	clear(s.imap)
+	s.pool = append(s.pool, s.points...)
 	s.points = s.points[:0]
 }
 
 func NewPointTransitionSet() *PointTransitionSet {
 	return &PointTransitionSet{
 		points: make([]*PointTransitions, 0),
-		imap:   make(map[int]*PointTransitions),
 	}
 }
 
-func repeat(a *Automaton) (*Automaton, error) {
+// Repeat
+// Returns an automaton that accepts the Kleene star (zero or more concatenated repetitions) of the language of
+// the given automaton. Never modifies the input automaton language.
+// Complexity: linear in number of states.
+func Repeat(a *Automaton) (*Automaton, error) {
 	if a.GetNumStates() == 0 {
-		// Repeating the empty automata will still only accept the empty automata.
-		return a, nil
+		// The Kleene star of the empty language is the empty string.
+		return defaultAutomata.MakeEmptyString(), nil
 	}
-	builder := NewBuilder()
+	builder := getBuilder()
+	defer putBuilder(builder)
 	builder.CreateState()
 	builder.SetAccept(0, true)
-	builder.CopyStates(a)
+	builder.Copy(a)
 
 	t := NewTransition()
 	count := a.InitTransition(0, t)
@@ -993,20 +1445,25 @@ func repeat(a *Automaton) (*Automaton, error) {
 		}
 	}
 
-	return builder.Finish(), nil
+	result := builder.Finish()
+	result.alphabet = a.alphabet
+	return opRepeat.done(result, nil)
 }
 
-func repeatCount(a *Automaton, count int) (*Automaton, error) {
-	if count == 0 {
-		return repeat(a)
+// RepeatMin
+// Returns an automaton that accepts min or more concatenated repetitions of the language of the given
+// automaton.
+// Complexity: linear in number of states and in min.
+func RepeatMin(a *Automaton, min int) (*Automaton, error) {
+	if min == 0 {
+		return Repeat(a)
 	}
-	as := make([]*Automaton, 0)
-	for count > 0 {
-		count--
+	as := make([]*Automaton, 0, min+1)
+	for i := 0; i < min; i++ {
 		as = append(as, a)
 	}
 
-	ra, err := repeat(a)
+	ra, err := Repeat(a)
 	if err != nil {
 		return nil, err
 	}
@@ -1015,7 +1472,11 @@ func repeatCount(a *Automaton, count int) (*Automaton, error) {
 	return concatenate(as...)
 }
 
-func repeatRange(a *Automaton, min, max int) (*Automaton, error) {
+// RepeatRange
+// Returns an automaton that accepts between min and max (including both) concatenated repetitions of the
+// language of the given automaton. If min > max, an automaton accepting the empty language is returned.
+// Complexity: linear in number of states and in min and max.
+func RepeatRange(a *Automaton, min, max int) (*Automaton, error) {
 	if min > max {
 		return defaultAutomata.MakeEmpty(), nil
 	}
@@ -1038,36 +1499,29 @@ func repeatRange(a *Automaton, min, max int) (*Automaton, error) {
 		}
 	}
 
-	prevAcceptStates := toSet(b, 0)
-	builder := NewBuilder()
-	builder.Copy(b)
+	builder := getBuilder()
+	defer putBuilder(builder)
+	prevAcceptStates := acceptStates(b, builder.Append(b))
 	for i := min; i < max; i++ {
-		numStates := builder.GetNumStates()
-		builder.Copy(a)
-		for s := range prevAcceptStates {
-			builder.AddEpsilon(s, numStates)
-		}
-		prevAcceptStates = toSet(a, numStates)
+		offset := builder.Append(a)
+		builder.Connect(prevAcceptStates, offset)
+		prevAcceptStates = acceptStates(a, offset)
 	}
 
-	return builder.Finish(), nil
+	result := builder.Finish()
+	result.alphabet = a.alphabet
+	return opRepeatRange.done(result, nil)
 }
 
-func toSet(a *Automaton, offset int) map[int]struct{} {
+// acceptStates Returns the accept states of the given automaton, numbered from offset as in a Builder it was
+// appended to (see Builder.Append).
+func acceptStates(a *Automaton, offset int) []int {
 	numStates := uint(a.GetNumStates())
 	isAccept := a.getAcceptStates()
-	result := make(map[int]struct{})
-	upto := uint(0)
-	var ok bool
-	for upto < numStates {
-		upto, ok = isAccept.NextSet(upto)
-		if !ok {
-			break
-		}
-		result[offset+int(upto)] = struct{}{}
-		upto++
+	result := make([]int, 0, isAccept.Count())
+	for s, ok := isAccept.NextSet(0); ok && s < numStates; s, ok = isAccept.NextSet(s + 1) {
+		result = append(result, offset+int(s))
 	}
-
 	return result
 }
 
@@ -1105,68 +1559,393 @@ func intersection(a1, a2 *Automaton) (*Automaton, error) {
 	if a2.GetNumStates() == 0 {
 		return a2, nil
 	}
+	return opIntersection.done(product(a1, a2, nil))
+}
+
+// IntersectsNonEmpty
+// Returns true if a1 and a2 accept at least one common string. This explores the same pairs of states as the
+// intersection, but never builds the product automaton and stops as soon as a pair of accept states is reached.
+// Complexity: quadratic in number of states in the worst case.
+func IntersectsNonEmpty(a1, a2 *Automaton) (bool, error) {
+	if a1.GetNumStates() == 0 || a2.GetNumStates() == 0 {
+		return false, nil
+	}
+	found := false
+	expand := func(p *statePair) bool {
+		found = a1.IsAccept(p.s1) && a2.IsAccept(p.s2)
+		return !found
+	}
+	err := walkProduct(a1, a2, nil, nil, expand, nil)
+	return found, err
+}
+
+// ProductWithPruner
+// Returns the product automaton of a1 and a2, which accepts the intersection of their languages, skipping
+// every pair of states (s1 from a1, s2 from a2) for which prune returns true: such pairs, and the transitions
+// leading to them, are left out of the construction entirely. A nil prune keeps all pairs, which makes this
+// exactly the intersection. This allows domain-specific knowledge (length parity, state metadata, ...) to cut
+// the product early without forking the construction loop. prune may be called more than once for the same pair.
+// Complexity: quadratic in number of states.
+func ProductWithPruner(a1, a2 *Automaton, prune func(s1, s2 int) bool) (*Automaton, error) {
+	if a1.GetNumStates() == 0 || a2.GetNumStates() == 0 || (prune != nil && prune(0, 0)) {
+		return defaultAutomata.MakeEmpty(), nil
+	}
+	return opProduct.done(product(a1, a2, prune))
+}
+
+// product Builds the product automaton of a1 and a2, which must both have states, without the pairs pruned
+// by prune (if not nil) and without dead states. The state of each pair is its number in walkProduct.
+func product(a1, a2 *Automaton, prune func(s1, s2 int) bool) (*Automaton, error) {
+	c := NewAutomaton()
+	add := func(*statePair) {
+		c.CreateState()
+	}
+	expand := func(p *statePair) bool {
+		c.SetAccept(p.s, a1.IsAccept(p.s1) && a2.IsAccept(p.s2))
+		return true
+	}
+	transition := func(p, q *statePair, min, max int) error {
+		return c.AddTransition(p.s, q.s, min, max)
+	}
+	if err := walkProduct(a1, a2, prune, add, expand, transition); err != nil {
+		return nil, err
+	}
+	c.FinishState()
+
+	c.alphabet = commonAlphabet(a1, a2)
+	return RemoveDeadStates(c)
+}
+
+// walkProduct Walks the pairs of states (s1 from a1, s2 from a2) reachable from the initial pair in the product
+// of a1 and a2, which must both have states, breadth first. Pairs are numbered (in statePair.s) in the order they
+// are found, starting with the initial pair, and passed to add, if not nil, when found. Then each pair is passed
+// to expand, which stops the walk by returning false, before transition, if not nil, is called for each
+// transition leaving it: the overlap of a transition of s1 and one of s2, leading to the pair of their dests.
+// The pairs for which prune (if not nil) returns true are skipped, and so are the transitions leading to them;
+// prune may be called more than once for the same pair, but never for the initial pair.
+func walkProduct(a1, a2 *Automaton, prune func(s1, s2 int) bool, add func(p *statePair),
+	expand func(p *statePair) bool, transition func(p, q *statePair, min, max int) error) error {
+
 	transitions1 := a1.getSortedTransitions()
 	transitions2 := a2.getSortedTransitions()
-	c := NewAutomaton()
-	c.CreateState()
 	worklist := make([]*statePair, 0)
-	estates := NewHashMap[*statePair]()
+	pairs := NewHashMap[*statePair](WithoutLocking())
 
 	p := newStatePair(0, 0, 0)
 	worklist = append(worklist, p)
-	estates.Set(p, p)
+	pairs.Set(p, p)
+	if add != nil {
+		add(p)
+	}
 	for len(worklist) > 0 {
 		p = worklist[0]
 		worklist = worklist[1:]
-		c.SetAccept(p.s, a1.IsAccept(p.s1) && a2.IsAccept(p.s2))
+		if !expand(p) {
+			return nil
+		}
 		t1 := transitions1[p.s1]
 		t2 := transitions2[p.s2]
-		n1 := 0
-		b2 := 0
-		for ; n1 < len(t1); n1++ {
+		for n1, b2 := 0, 0; n1 < len(t1); n1++ {
 			for b2 < len(t2) && t2[b2].Max < t1[n1].Min {
 				b2++
 			}
+			for n2 := b2; n2 < len(t2) && t1[n1].Max >= t2[n2].Min; n2++ {
+				if t2[n2].Max < t1[n1].Min {
+					continue
+				}
 
-			n2 := b2
-			for ; n2 < len(t2) && t1[n1].Max >= t2[n2].Min; n2++ {
-
-			}
-			if t2[n2].Max >= t1[n1].Min {
 				q := newStatePair(-1, t1[n1].Dest, t2[n2].Dest)
-				r, ok := estates.Get(q)
+				r, ok := pairs.Get(q)
 				if !ok {
-					q.s = c.CreateState()
+					if prune != nil && prune(q.s1, q.s2) {
+						continue
+					}
+					q.s = pairs.Size()
 					worklist = append(worklist, q)
-					estates.Set(q, q)
+					pairs.Set(q, q)
+					if add != nil {
+						add(q)
+					}
 					r = q
 				}
-				var minI, maxI int
-
-				if t1[n1].Min > t2[n2].Min {
-					minI = t1[n1].Min
-				} else {
-					minI = t2[n2].Min
+				if transition != nil {
+					minI := max(t1[n1].Min, t2[n2].Min)
+					maxI := min(t1[n1].Max, t2[n2].Max)
+					if err := transition(p, r, minI, maxI); err != nil {
+						return err
+					}
 				}
+			}
+		}
+	}
+	return nil
+}
 
-				if t1[n1].Max < t2[n2].Max {
-					maxI = t1[n1].Max
-				} else {
-					maxI = t2[n2].Max
-				}
+// Shuffle
+// Returns an automaton accepting every interleaving of a string accepted by a1 with a string accepted by a2,
+// as in dk.brics' ShuffleOperations. States of the result are pairs of states (s1 from a1, s2 from a2), each
+// following a transition of either side. The result is generally not deterministic. Returns an error if more
+// than workLimit pairs are reached.
+// Complexity: quadratic in number of states.
+func Shuffle(a1, a2 *Automaton, workLimit int) (*Automaton, error) {
+	if a1.GetNumStates() == 0 || a2.GetNumStates() == 0 {
+		return defaultAutomata.MakeEmpty(), nil
+	}
 
-				err := c.AddTransition(p.s, r.s, minI, maxI)
-				if err != nil {
-					return nil, err
-				}
+	transitions1 := a1.getSortedTransitions()
+	transitions2 := a2.getSortedTransitions()
+	c := NewAutomaton()
+	c.CreateState()
+	worklist := make([]*statePair, 0)
+	estates := NewHashMap[*statePair](WithoutLocking())
+
+	p := newStatePair(0, 0, 0)
+	worklist = append(worklist, p)
+	estates.Set(p, p)
+	getOrAdd := func(s1, s2 int) (int, error) {
+		q := newStatePair(-1, s1, s2)
+		if r, ok := estates.Get(q); ok {
+			return r.s, nil
+		}
+		if c.GetNumStates() >= workLimit {
+			return -1, fmt.Errorf("too complex to shuffle: more than %d states", workLimit)
+		}
+		q.s = c.CreateState()
+		worklist = append(worklist, q)
+		estates.Set(q, q)
+		return q.s, nil
+	}
+	for len(worklist) > 0 {
+		p = worklist[0]
+		worklist = worklist[1:]
+		c.SetAccept(p.s, a1.IsAccept(p.s1) && a2.IsAccept(p.s2))
+		for _, t := range transitions1[p.s1] {
+			dest, err := getOrAdd(t.Dest, p.s2)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.AddTransition(p.s, dest, t.Min, t.Max); err != nil {
+				return nil, err
+			}
+		}
+		for _, t := range transitions2[p.s2] {
+			dest, err := getOrAdd(p.s1, t.Dest)
+			if err != nil {
+				return nil, err
+			}
+			if err := c.AddTransition(p.s, dest, t.Min, t.Max); err != nil {
+				return nil, err
 			}
 		}
 	}
 	c.FinishState()
 
-	return removeDeadStates(c)
+	c.alphabet = commonAlphabet(a1, a2)
+	return opShuffle.done(RemoveDeadStates(c))
+}
+
+// ConstrainLengths
+// Returns an automaton accepting the strings of the given automaton whose length, in labels, is between minLen
+// and maxLen (inclusive); a negative maxLen means no upper bound. This walks the product of the automaton with
+// a length counter bounded by maxLen (or saturating at minLen when unbounded) directly, which is smaller and
+// faster than building a separate length automaton and intersecting it.
+// Complexity: linear in number of states times maxLen.
+func ConstrainLengths(a *Automaton, minLen, maxLen int) (*Automaton, error) {
+	if minLen < 0 {
+		return nil, errors.New("minLen must be >= 0")
+	}
+	if (maxLen >= 0 && minLen > maxLen) || a.GetNumStates() == 0 {
+		return defaultAutomata.MakeEmpty(), nil
+	}
+
+	// Counter values are in [0, bound]; when unbounded, the counter stops at minLen since any longer string
+	// is accepted as well:
+	bound := maxLen
+	if maxLen < 0 {
+		bound = minLen
+	}
+	key := func(state, n int) int {
+		return state*(bound+1) + n
+	}
+
+	result := NewAutomaton()
+	newStates := make(map[int]int)
+	worklist := make([][2]int, 0)
+
+	result.CreateState()
+	newStates[key(0, 0)] = 0
+	worklist = append(worklist, [2]int{0, 0})
+
+	t := NewTransition()
+	for len(worklist) > 0 {
+		s, n := worklist[0][0], worklist[0][1]
+		worklist = worklist[1:]
+		q := newStates[key(s, n)]
+		result.SetAccept(q, a.IsAccept(s) && n >= minLen)
+
+		next := n + 1
+		if next > bound {
+			if maxLen >= 0 {
+				// Any further label exceeds maxLen:
+				continue
+			}
+			next = bound
+		}
+
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			dest, ok := newStates[key(t.Dest, next)]
+			if !ok {
+				dest = result.CreateState()
+				newStates[key(t.Dest, next)] = dest
+				worklist = append(worklist, [2]int{t.Dest, next})
+			}
+			if err := result.AddTransition(q, dest, t.Min, t.Max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.FinishState()
+
+	result.alphabet = a.alphabet
+	return opConstrainLengths.done(RemoveDeadStates(result))
+}
+
+// WithMaxLength Returns an automaton accepting the strings of the given automaton that are at most n labels
+// (code points, or bytes for binary automata) long, e.g. to bound the expansion of a fuzzy or wildcard query.
+func WithMaxLength(a *Automaton, n int) (*Automaton, error) {
+	if n < 0 {
+		return nil, errors.New("n must be >= 0")
+	}
+	return ConstrainLengths(a, 0, n)
+}
+
+// WithExactLength Returns an automaton accepting the strings of the given automaton that are exactly n labels
+// long.
+func WithExactLength(a *Automaton, n int) (*Automaton, error) {
+	if n < 0 {
+		return nil, errors.New("n must be >= 0")
+	}
+	return ConstrainLengths(a, n, n)
+}
+
+// MapLabels
+// Returns a copy of the given automaton with the label range [min, max] of every transition replaced by f(min,
+// max); a transition is dropped if f returns an empty range (min > max). The mapped ranges may overlap, so the
+// result is not necessarily deterministic. Returns an error if f returns labels outside [0, 0x10FFFF].
+func MapLabels(a *Automaton, f func(min, max int) (int, int)) (*Automaton, error) {
+	result := NewAutomaton()
+	numStates := a.GetNumStates()
+	for s := 0; s < numStates; s++ {
+		result.CreateState()
+		result.SetAccept(s, a.IsAccept(s))
+	}
+
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			min, max := f(t.Min, t.Max)
+			if min > max {
+				continue
+			}
+			if min < 0 || max > unicode.MaxRune {
+				return nil, fmt.Errorf("mapped label range [%d, %d] is out of bounds", min, max)
+			}
+			if err := result.AddTransition(s, t.Dest, min, max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.FinishState()
+
+	result.alphabet = a.alphabet
+	return opMapLabels.done(result, nil)
+}
+
+// Relabel
+// Returns a copy of the given automaton whose labels are the classes of c instead of code points, collapsing
+// the alphabet into its equivalence classes: the result accepts the class ids of the strings the automaton
+// accepts. Every transition must span whole classes, which holds if c was built from this automaton (see
+// NewClassMap). A deterministic automaton stays deterministic.
+func Relabel(a *Automaton, c *ClassMap) (*Automaton, error) {
+	var err error
+	result, mapErr := MapLabels(a, func(min, max int) (int, int) {
+		first, last := c.Class(min), c.Class(max)
+		if err == nil && (c.Start(first) != min || c.End(last) != max) {
+			err = fmt.Errorf("transition [%d, %d] does not span whole classes", min, max)
+		}
+		return first, last
+	})
+	if mapErr != nil {
+		return nil, mapErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.alphabet = ALPHABET_UNICODE
+	return result, nil
+}
+
+// ToCaseFold
+// Returns an automaton accepting the strings accepted by the given automaton along with all their variants
+// under simple case folding (see unicode.SimpleFold), e.g. "Go", "GO", "gO" and "go" if it accepts "go": every
+// transition is extended with the other members of the case orbits of its labels. This makes an existing
+// automaton case insensitive without recompiling it. The result is not necessarily deterministic. Returns an
+// error for binary automata, whose labels are not code points.
+func ToCaseFold(a *Automaton) (*Automaton, error) {
+	if a.alphabet == ALPHABET_BINARY {
+		return nil, errors.New("case folding requires a unicode automaton")
+	}
+	folding := caseFoldingPoints()
+
+	result := NewAutomaton()
+	numStates := a.GetNumStates()
+	for s := 0; s < numStates; s++ {
+		result.CreateState()
+		result.SetAccept(s, a.IsAccept(s))
+	}
+
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			if err := result.AddTransition(s, t.Dest, t.Min, t.Max); err != nil {
+				return nil, err
+			}
+			// only code points with other case variants add labels:
+			j, _ := slices.BinarySearch(folding, rune(t.Min))
+			for ; j < len(folding) && int(folding[j]) <= t.Max; j++ {
+				for c := unicode.SimpleFold(folding[j]); c != folding[j]; c = unicode.SimpleFold(c) {
+					if int(c) < t.Min || int(c) > t.Max {
+						if err := result.AddTransition(s, t.Dest, int(c), int(c)); err != nil {
+							return nil, err
+						}
+					}
+				}
+			}
+		}
+	}
+	result.FinishState()
+	return opCaseFold.done(result, nil)
 }
 
+// caseFoldingPoints The sorted code points that simple case folding maps to another code point. Some orbits
+// (e.g. U+0390 and U+1FD3) have no case mappings, so they are not all found in unicode.CaseRanges; instead all
+// code points are scanned once, which takes a few milliseconds.
+var caseFoldingPoints = sync.OnceValue(func() []rune {
+	var points []rune
+	for c := rune(0); c <= unicode.MaxRune; c++ {
+		if unicode.SimpleFold(c) != c {
+			points = append(points, c)
+		}
+	}
+	return points
+})
+
 func optional(a *Automaton) (*Automaton, error) {
 	result := NewAutomaton()
 	result.CreateState()
@@ -1176,5 +1955,161 @@ func optional(a *Automaton) (*Automaton, error) {
 		result.AddEpsilon(0, 1)
 	}
 	result.FinishState()
-	return result, nil
+	result.alphabet = a.alphabet
+	return opOptional.done(result, nil)
+}
+
+// StructurallyEqual Returns true if the two automata are identical: same number of states, same accept states
+// and the same transitions leaving every state. This is much cheaper than checking that they accept the same
+// language, but automata that differ only in how their states are numbered are not equal; pass both through
+// Canonicalize first to compare deterministic automata independently of numbering. Both automata must be
+// finished.
+func StructurallyEqual(a1, a2 *Automaton) bool {
+	numStates := a1.GetNumStates()
+	if numStates != a2.GetNumStates() || a1.GetNumTransitions() != a2.GetNumTransitions() {
+		return false
+	}
+
+	t1 := NewTransition()
+	t2 := NewTransition()
+	for s := 0; s < numStates; s++ {
+		if a1.IsAccept(s) != a2.IsAccept(s) {
+			return false
+		}
+		count := a1.InitTransition(s, t1)
+		if count != a2.InitTransition(s, t2) {
+			return false
+		}
+		for i := 0; i < count; i++ {
+			a1.GetNextTransition(t1)
+			a2.GetNextTransition(t2)
+			if t1.Dest != t2.Dest || t1.Min != t2.Min || t1.Max != t2.Max {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Canonicalize Returns a copy of the automaton with its states renumbered in breadth-first order from the
+// initial state, following the transitions of each state in their sorted order. States that are not reachable
+// from the initial state are dropped. Two deterministic automata that differ only in the numbering of their
+// states canonicalize to structurally equal automata; for non-deterministic automata this is not guaranteed.
+func Canonicalize(a *Automaton) (*Automaton, error) {
+	result := NewAutomaton()
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return result, nil
+	}
+
+	newStates := make([]int, numStates)
+	for i := range newStates {
+		newStates[i] = -1
+	}
+	order := []int{0}
+	newStates[0] = result.CreateState()
+
+	t := NewTransition()
+	for i := 0; i < len(order); i++ {
+		count := a.InitTransition(order[i], t)
+		for j := 0; j < count; j++ {
+			a.GetNextTransition(t)
+			if newStates[t.Dest] == -1 {
+				newStates[t.Dest] = result.CreateState()
+				order = append(order, t.Dest)
+			}
+		}
+	}
+
+	for q, s := range order {
+		result.SetAccept(q, a.IsAccept(s))
+		count := a.InitTransition(s, t)
+		for j := 0; j < count; j++ {
+			a.GetNextTransition(t)
+			if err := result.AddTransition(q, newStates[t.Dest], t.Min, t.Max); err != nil {
+				return nil, err
+			}
+		}
+	}
+	result.FinishState()
+	result.alphabet = a.alphabet
+
+	return opCanonicalize.done(result, nil)
+}
+
+// Normalize Returns a copy of the automaton whose transitions are split at the points where ranges leaving the
+// same state start or end, so that two ranges leaving a state are either equal or disjoint, each range leading
+// to a set of states; ranges are as wide as possible, i.e. adjacent ranges lead to different sets. Reduced
+// transitions (see FinishState) merge ranges per dest, so ranges to different dests may partially overlap; the
+// normalized transitions instead tell for each range which states it leads to, the view of determinization and
+// AlphabetPartition. Both forms are canonical: automata whose states lead to the same states label by label
+// normalize to structurally equal automata (see StructurallyEqual, Hash64). Deterministic automata are already
+// normalized.
+func Normalize(a *Automaton) (*Automaton, error) {
+	numStates := a.GetNumStates()
+	result := NewAutomatonV1(numStates, a.GetNumTransitions())
+	for s := 0; s < numStates; s++ {
+		result.CreateState()
+		result.SetAccept(s, a.IsAccept(s))
+	}
+	result.normalized = true
+
+	// Ranges open (+1) and close (-1) at their points:
+	type event struct {
+		point, dest, delta int32
+	}
+	var events []event
+	active := make(map[int32]int32)
+	var dests, next []int32
+
+	for s := 0; s < numStates; s++ {
+		offset := int(a.states[2*s])
+		count := int(a.states[2*s+1])
+		events = events[:0]
+		for i := 0; i < count; i++ {
+			t := a.transitions[offset+i]
+			events = append(events, event{t.min, t.dest, 1}, event{t.max + 1, t.dest, -1})
+		}
+		slices.SortFunc(events, func(e1, e2 event) int {
+			return cmp.Compare(e1.point, e2.point)
+		})
+
+		start := len(result.transitions)
+		lo := int32(-1)
+		dests = dests[:0]
+		for i := 0; i < len(events); {
+			point := events[i].point
+			for ; i < len(events) && events[i].point == point; i++ {
+				if active[events[i].dest] += events[i].delta; active[events[i].dest] == 0 {
+					delete(active, events[i].dest)
+				}
+			}
+			next = next[:0]
+			for dest := range active {
+				next = append(next, dest)
+			}
+			slices.Sort(next)
+			if slices.Equal(dests, next) {
+				continue
+			}
+
+			// The labels [lo, point-1] lead to dests:
+			for _, dest := range dests {
+				result.transitions = append(result.transitions, packedTransition{dest, lo, point - 1})
+			}
+			dests, next = next, dests
+			lo = point
+		}
+
+		if n := len(result.transitions) - start; n > 0 {
+			result.states[2*s] = int32(start)
+			result.states[2*s+1] = int32(n)
+			result.checkDeterministic(start, n)
+		}
+	}
+	result.noDeadStates = a.noDeadStates
+	result.alphabet = a.alphabet
+	result.stateLabels = maps.Clone(a.stateLabels)
+
+	return opNormalize.done(result, nil)
 }
@@ -1,6 +1,12 @@
 package automaton
 
 import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -33,3 +39,1185 @@ func Test_concatenate(t *testing.T) {
 		t.Skip()
 	}
 }
+
+func Test_concatenateLinear(t *testing.T) {
+	automata := &Automata{}
+
+	a1, err := automata.MakeString("foo")
+	assert.Nil(t, err)
+	a2 := automata.MakeEmptyString()
+	a3, err := automata.MakeCharRange('a', 'c')
+	assert.Nil(t, err)
+	a4, err := automata.MakeString("bar")
+	assert.Nil(t, err)
+
+	assert.True(t, isLinear(a1))
+	assert.True(t, isLinear(a2))
+	assert.True(t, isLinear(a3))
+
+	a, err := concatenate(a1, a2, a3, a4)
+	assert.Nil(t, err)
+	assert.True(t, a.IsDeterministic())
+	assert.Equal(t, 8, a.GetNumStates())
+	assert.True(t, Run(a, "fooabar"))
+	assert.True(t, Run(a, "foocbar"))
+	assert.False(t, Run(a, "foobar"))
+	assert.False(t, Run(a, "foodbar"))
+
+	anyString, err := automata.MakeAnyString()
+	assert.Nil(t, err)
+	assert.False(t, isLinear(anyString))
+	assert.False(t, isLinear(automata.MakeEmpty()))
+}
+
+func newPhraseAutomata(b *testing.B) []*Automaton {
+	words := []string{"the", "quick", "brown", "fox", "jumps", "over", "the", "lazy", "dog"}
+	list := make([]*Automaton, 0, len(words)*10)
+	for i := 0; i < 10; i++ {
+		for _, word := range words {
+			a, err := defaultAutomata.MakeString(word)
+			if err != nil {
+				b.Fatal(err)
+			}
+			list = append(list, a)
+		}
+	}
+	return list
+}
+
+func BenchmarkConcatenateStrings(b *testing.B) {
+	list := newPhraseAutomata(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := concatenate(list...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConcatenateStringsGeneral(b *testing.B) {
+	list := newPhraseAutomata(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := concatenateGeneral(list...); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetSingletonAutomaton(t *testing.T) {
+	a, err := MakeString("abc")
+	assert.Nil(t, err)
+	singleton, err := GetSingletonAutomaton(a)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{'a', 'b', 'c'}, singleton)
+
+	// More than one string, or a cycle:
+	for _, pattern := range []string{"ab|ac", "(ab)*"} {
+		r, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := r.ToAutomaton()
+		assert.Nil(t, err)
+		singleton, err := GetSingletonAutomaton(a)
+		assert.Nil(t, err)
+		assert.Nil(t, singleton, pattern)
+	}
+}
+
+func Test_concatenateEmptyOperands(t *testing.T) {
+	empty := func() *Automaton { return MakeEmpty() }
+	emptyString := func() *Automaton { return MakeEmptyString() }
+	ab := func() *Automaton { return MustNewRegExp("ab").MustToAutomaton() }
+	star := func() *Automaton { return MustNewRegExp("(ab)*").MustToAutomaton() }
+
+	tests := []struct {
+		name     string
+		operands []func() *Automaton
+		accepted []string
+	}{
+		{"none", nil, []string{""}},
+		{"empty", []func() *Automaton{empty}, nil},
+		{"emptyString", []func() *Automaton{emptyString}, []string{""}},
+		{"empty,empty", []func() *Automaton{empty, empty}, nil},
+		{"empty,emptyString", []func() *Automaton{empty, emptyString}, nil},
+		{"emptyString,empty", []func() *Automaton{emptyString, empty}, nil},
+		{"emptyString,emptyString", []func() *Automaton{emptyString, emptyString}, []string{""}},
+		{"ab,empty", []func() *Automaton{ab, empty}, nil},
+		{"empty,ab", []func() *Automaton{empty, ab}, nil},
+		{"star,empty", []func() *Automaton{star, empty}, nil},
+		{"ab,emptyString", []func() *Automaton{ab, emptyString}, []string{"ab"}},
+		{"emptyString,ab,emptyString", []func() *Automaton{emptyString, ab, emptyString}, []string{"ab"}},
+		{"star,emptyString,ab", []func() *Automaton{star, emptyString, ab}, []string{"ab", "abab", "ababab"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operands := make([]*Automaton, len(test.operands))
+			for i, operand := range test.operands {
+				operands[i] = operand()
+			}
+			a, err := concatenate(operands...)
+			assert.Nil(t, err)
+			for _, s := range []string{"", "a", "ab", "abab", "ababab", "b"} {
+				assert.Equal(t, slices.Contains(test.accepted, s), runNFA(a, s), "%q", s)
+			}
+			assert.Equal(t, len(test.accepted) == 0, IsEmptyAutomaton(a))
+		})
+	}
+}
+
+func TestRepeat(t *testing.T) {
+	t.Run("testEmptyLanguage", func(t *testing.T) {
+		a, err := Repeat(defaultAutomata.MakeEmpty())
+		assert.Nil(t, err)
+		assert.True(t, Run(a, ""))
+		assert.False(t, Run(a, "a"))
+
+		a, err = RepeatMin(defaultAutomata.MakeEmpty(), 1)
+		assert.Nil(t, err)
+		assert.False(t, runNFA(a, ""))
+
+		a, err = RepeatRange(defaultAutomata.MakeEmpty(), 0, 2)
+		assert.Nil(t, err)
+		assert.True(t, runNFA(a, ""))
+	})
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1525))
+		for i := 0; i < 100; i++ {
+			pattern := randomRegexp(r, 1+r.Intn(2))
+			re, err := NewRegExp(pattern)
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+
+			n := r.Intn(3)
+			m := n + r.Intn(3)
+
+			star, err := Repeat(a)
+			assert.Nil(t, err)
+			atLeast, err := RepeatMin(a, n)
+			assert.Nil(t, err)
+			between, err := RepeatRange(a, n, m)
+			assert.Nil(t, err)
+
+			cases := []struct {
+				a       *Automaton
+				pattern string
+			}{
+				{star, "(" + pattern + ")*"},
+				{atLeast, "(" + pattern + "){" + strconv.Itoa(n) + ",}"},
+				{between, "(" + pattern + "){" + strconv.Itoa(n) + "," + strconv.Itoa(m) + "}"},
+			}
+			for _, c := range cases {
+				expected := regexp.MustCompile("^(?s:" + c.pattern + ")$")
+				for j := 0; j < 20; j++ {
+					s := randomString(r, 8)
+					assert.Equal(t, expected.MatchString(s), runNFA(c.a, s), "pattern=%q s=%q", c.pattern, s)
+				}
+			}
+		}
+	})
+}
+
+func TestProductWithPruner(t *testing.T) {
+	newAutomaton := func(pattern string) *Automaton {
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		return a
+	}
+
+	t.Run("testNilPrunerIsIntersection", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1527))
+		for i := 0; i < 50; i++ {
+			p1 := randomRegexp(r, 1+r.Intn(3))
+			p2 := randomRegexp(r, 1+r.Intn(3))
+			a1 := newAutomaton(p1)
+			a2 := newAutomaton(p2)
+			a, err := ProductWithPruner(a1, a2, nil)
+			assert.Nil(t, err)
+			for j := 0; j < 30; j++ {
+				s := randomString(r, 8)
+				assert.Equal(t, runNFA(a1, s) && runNFA(a2, s), runNFA(a, s), "p1=%q p2=%q s=%q", p1, p2, s)
+			}
+		}
+	})
+
+	t.Run("testPrune", func(t *testing.T) {
+		// a1 counts the length of the input in its state numbers:
+		a1 := NewAutomaton()
+		for s := 0; s <= 4; s++ {
+			a1.CreateState()
+			a1.SetAccept(s, true)
+		}
+		for s := 0; s < 4; s++ {
+			assert.Nil(t, a1.AddTransition(s, s+1, 'a', 'b'))
+		}
+		a1.FinishState()
+		a2 := newAutomaton("a*b*")
+
+		pruned := make(map[[2]int]struct{})
+		a, err := ProductWithPruner(a1, a2, func(s1, s2 int) bool {
+			if a1.GetNumTransitionsWithState(s1) == 0 {
+				pruned[[2]int{s1, s2}] = struct{}{}
+				return true
+			}
+			return false
+		})
+		assert.Nil(t, err)
+		assert.NotEmpty(t, pruned)
+		assert.True(t, runNFA(a, "aab"))
+		assert.True(t, runNFA(a, ""))
+		assert.False(t, runNFA(a, "aabb"))
+		assert.False(t, runNFA(a, "ba"))
+
+		a, err = ProductWithPruner(a1, a2, func(s1, s2 int) bool { return true })
+		assert.Nil(t, err)
+		assert.True(t, IsEmptyAutomaton(a))
+	})
+}
+
+// A language as a membership predicate, used to compute expected results.
+type language func(s string) bool
+
+func concatLanguages(l1, l2 language) language {
+	return func(s string) bool {
+		for k := 0; k <= len(s); k++ {
+			if l1(s[:k]) && l2(s[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func powerLanguage(l language, n int) language {
+	result := language(func(s string) bool { return s == "" })
+	for i := 0; i < n; i++ {
+		result = concatLanguages(result, l)
+	}
+	return result
+}
+
+func starLanguage(l language) language {
+	var star language
+	star = func(s string) bool {
+		if s == "" {
+			return true
+		}
+		for k := 1; k <= len(s); k++ {
+			if l(s[:k]) && star(s[k:]) {
+				return true
+			}
+		}
+		return false
+	}
+	return star
+}
+
+func TestEmptyStringConformance(t *testing.T) {
+	makeEmptyOneState := func() *Automaton {
+		a := NewAutomaton()
+		a.CreateState()
+		a.FinishState()
+		return a
+	}
+	makeA := func() *Automaton {
+		a, err := defaultAutomata.MakeChar('a')
+		assert.Nil(t, err)
+		return a
+	}
+	makeEmptyStringOrA := func() *Automaton {
+		a := NewAutomaton()
+		s0 := a.CreateState()
+		s1 := a.CreateState()
+		a.SetAccept(s0, true)
+		a.SetAccept(s1, true)
+		assert.Nil(t, a.AddTransitionLabel(s0, s1, 'a'))
+		a.FinishState()
+		return a
+	}
+
+	type operand struct {
+		name string
+		make func() *Automaton
+		lang language
+	}
+	operands := []operand{
+		{"∅", defaultAutomata.MakeEmpty, func(s string) bool { return false }},
+		{"∅ (one state)", makeEmptyOneState, func(s string) bool { return false }},
+		{"{ε}", defaultAutomata.MakeEmptyString, func(s string) bool { return s == "" }},
+		{"{a}", makeA, func(s string) bool { return s == "a" }},
+		{"{ε,a}", makeEmptyStringOrA, func(s string) bool { return s == "" || s == "a" }},
+	}
+	probes := []string{"", "a", "aa", "aaa", "b", "ab"}
+
+	check := func(t *testing.T, name string, a *Automaton, err error, expected language) {
+		t.Helper()
+		if !assert.Nil(t, err, name) {
+			return
+		}
+		for _, s := range probes {
+			assert.Equal(t, expected(s), runNFA(a, s), "%s s=%q", name, s)
+			if a.IsDeterministic() {
+				assert.Equal(t, expected(s), Run(a, s), "%s s=%q", name, s)
+			}
+		}
+		assert.Equal(t, !expected("") && !expected("a") && !expected("aa"), IsEmptyAutomaton(a), "%s isEmpty", name)
+	}
+
+	for _, x := range operands {
+		a, err := optional(x.make())
+		check(t, "optional "+x.name, a, err, func(s string) bool { return s == "" || x.lang(s) })
+
+		a, err = Repeat(x.make())
+		check(t, "repeat "+x.name, a, err, starLanguage(x.lang))
+
+		for n := 0; n <= 2; n++ {
+			a, err = RepeatMin(x.make(), n)
+			check(t, fmt.Sprintf("repeatMin(%d) %s", n, x.name), a, err,
+				concatLanguages(powerLanguage(x.lang, n), starLanguage(x.lang)))
+			for m := n; m <= 3; m++ {
+				expected := func(s string) bool {
+					for k := n; k <= m; k++ {
+						if powerLanguage(x.lang, k)(s) {
+							return true
+						}
+					}
+					return false
+				}
+				a, err = RepeatRange(x.make(), n, m)
+				check(t, fmt.Sprintf("repeatRange(%d,%d) %s", n, m, x.name), a, err, expected)
+			}
+		}
+
+		a, err = complement(x.make(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		check(t, "complement "+x.name, a, err, func(s string) bool { return !x.lang(s) })
+
+		a, err = reverse(x.make())
+		check(t, "reverse "+x.name, a, err, x.lang)
+
+		a, err = determinize(x.make(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		check(t, "determinize "+x.name, a, err, x.lang)
+
+		a, err = Minimize(x.make(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		check(t, "minimize "+x.name, a, err, x.lang)
+
+		a, err = RemoveDeadStates(x.make())
+		check(t, "removeDeadStates "+x.name, a, err, x.lang)
+
+		for _, y := range operands {
+			names := x.name + ", " + y.name
+
+			a, err = union(x.make(), y.make())
+			check(t, "union "+names, a, err, func(s string) bool { return x.lang(s) || y.lang(s) })
+
+			a, err = concatenate(x.make(), y.make())
+			check(t, "concatenate "+names, a, err, concatLanguages(x.lang, y.lang))
+
+			a, err = ProductWithPruner(x.make(), y.make(), nil)
+			check(t, "product "+names, a, err, func(s string) bool { return x.lang(s) && y.lang(s) })
+		}
+	}
+}
+
+func TestConstrainLengths(t *testing.T) {
+	r := rand.New(rand.NewSource(1532))
+	for i := 0; i < 50; i++ {
+		pattern := randomRegexp(r, 1+r.Intn(3))
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		minLen := r.Intn(4)
+		maxLen := minLen + r.Intn(4) - 1
+		c, err := ConstrainLengths(a, minLen, maxLen)
+		assert.Nil(t, err)
+
+		for j := 0; j < 30; j++ {
+			s := randomString(r, 8)
+			inRange := len(s) >= minLen && (maxLen < 0 || len(s) <= maxLen)
+			assert.Equal(t, inRange && runNFA(a, s), runNFA(c, s),
+				"pattern=%q min=%d max=%d s=%q", pattern, minLen, maxLen, s)
+		}
+	}
+
+	t.Run("testErrors", func(t *testing.T) {
+		a, err := defaultAutomata.MakeAnyString()
+		assert.Nil(t, err)
+		_, err = ConstrainLengths(a, -1, 2)
+		assert.Error(t, err)
+
+		c, err := ConstrainLengths(a, 3, 2)
+		assert.Nil(t, err)
+		assert.True(t, IsEmptyAutomaton(c))
+	})
+
+	t.Run("testStateCount", func(t *testing.T) {
+		a, err := defaultAutomata.MakeAnyString()
+		assert.Nil(t, err)
+		c, err := ConstrainLengths(a, 2, 4)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, c.GetNumStates())
+		assert.True(t, c.IsDeterministic())
+
+		c, err = ConstrainLengths(a, 2, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, c.GetNumStates())
+		assert.True(t, Run(c, "abcdefgh"))
+		assert.False(t, Run(c, "a"))
+	})
+
+	t.Run("testWithLength", func(t *testing.T) {
+		re, err := NewRegExp("a*é")
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		c, err := WithMaxLength(a, 3)
+		assert.Nil(t, err)
+		assert.True(t, Run(c, "é"))
+		assert.True(t, Run(c, "aaé"))
+		assert.False(t, Run(c, "aaaé"))
+
+		c, err = WithExactLength(a, 3)
+		assert.Nil(t, err)
+		assert.False(t, Run(c, "aé"))
+		assert.True(t, Run(c, "aaé"))
+		assert.False(t, Run(c, "aaaé"))
+
+		_, err = WithMaxLength(a, -1)
+		assert.Error(t, err)
+		_, err = WithExactLength(a, -1)
+		assert.Error(t, err)
+	})
+}
+
+func TestStructurallyEqual(t *testing.T) {
+	// "ab" with the states numbered 0, 1, 2 and 0, 2, 1 respectively, plus an unreachable state in a2:
+	a1 := NewAutomaton()
+	a1.CreateState()
+	a1.CreateState()
+	a1.CreateState()
+	a1.SetAccept(2, true)
+	assert.Nil(t, a1.AddTransitionLabel(0, 1, 'a'))
+	assert.Nil(t, a1.AddTransitionLabel(1, 2, 'b'))
+	a1.FinishState()
+
+	a2 := NewAutomaton()
+	a2.CreateState()
+	a2.CreateState()
+	a2.CreateState()
+	a2.CreateState()
+	a2.SetAccept(1, true)
+	assert.Nil(t, a2.AddTransitionLabel(0, 2, 'a'))
+	assert.Nil(t, a2.AddTransitionLabel(2, 1, 'b'))
+	assert.Nil(t, a2.AddTransitionLabel(3, 0, 'c'))
+	a2.FinishState()
+
+	assert.True(t, StructurallyEqual(a1, a1))
+	assert.False(t, StructurallyEqual(a1, a2))
+
+	c1, err := Canonicalize(a1)
+	assert.Nil(t, err)
+	c2, err := Canonicalize(a2)
+	assert.Nil(t, err)
+	assert.True(t, StructurallyEqual(a1, c1))
+	assert.True(t, StructurallyEqual(c1, c2))
+	assert.Equal(t, 3, c2.GetNumStates())
+
+	t.Run("testDifferentLabels", func(t *testing.T) {
+		b1, err := defaultAutomata.MakeString("ab")
+		assert.Nil(t, err)
+		b2, err := defaultAutomata.MakeString("ac")
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(b1, a1))
+		assert.False(t, StructurallyEqual(b1, b2))
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		empty, err := Canonicalize(NewAutomaton())
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(empty, NewAutomaton()))
+		assert.False(t, StructurallyEqual(empty, a1))
+	})
+}
+
+func TestComplementOver(t *testing.T) {
+	alphabet := NewRangeSet([2]int{'a', 'c'})
+	inAlphabet := func(s string) bool {
+		for _, c := range s {
+			if !alphabet.Contains(int(c)) {
+				return false
+			}
+		}
+		return true
+	}
+
+	r := rand.New(rand.NewSource(1542))
+	for i := 0; i < 100; i++ {
+		pattern := randomRegexp(r, 1+r.Intn(3))
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		c, err := ComplementOver(a, alphabet, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, c.IsDeterministic())
+		for j := 0; j < 30; j++ {
+			s := randomString(r, 8)
+			assert.Equal(t, inAlphabet(s) && !runNFA(a, s), Run(c, s), "pattern=%q s=%q", pattern, s)
+		}
+	}
+
+	t.Run("testSize", func(t *testing.T) {
+		a, err := defaultAutomata.MakeString("cat")
+		assert.Nil(t, err)
+		lower := NewRangeSet([2]int{'a', 'z'})
+		c, err := ComplementOver(a, lower, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		full, err := complement(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Less(t, c.GetNumTransitions(), full.GetNumTransitions())
+
+		assert.True(t, Run(c, ""))
+		assert.True(t, Run(c, "ca"))
+		assert.False(t, Run(c, "cat"))
+		assert.True(t, Run(c, "cats"))
+		assert.False(t, Run(c, "CAT"))
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		c, err := ComplementOver(NewAutomaton(), alphabet, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, Run(c, ""))
+		assert.True(t, Run(c, "abcabc"))
+		assert.False(t, Run(c, "abd"))
+	})
+}
+
+// Returns a random NFA whose states have many transitions with overlapping label ranges, the worst case for
+// collating transitions by point in determinize.
+func randomDenseNFA(r *rand.Rand, numStates, numTransitions, maxLabel int) *Automaton {
+	a := NewAutomaton()
+	for s := 0; s < numStates; s++ {
+		a.CreateState()
+		a.SetAccept(s, r.Intn(4) == 0)
+	}
+	for s := 0; s < numStates; s++ {
+		for i := 0; i < numTransitions; i++ {
+			lo := r.Intn(maxLabel)
+			hi := lo + r.Intn(maxLabel/4+1)
+			if err := a.AddTransition(s, r.Intn(numStates), lo, hi); err != nil {
+				panic(err)
+			}
+		}
+	}
+	a.FinishState()
+	return a
+}
+
+func BenchmarkDeterminize(b *testing.B) {
+	b.Run("dense", func(b *testing.B) {
+		a := randomDenseNFA(rand.New(rand.NewSource(1544)), 8, 40, 1000)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := determinize(a, 1_000_000); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("regexp", func(b *testing.B) {
+		re, err := NewRegExp("[ac]*a[ac]{8}")
+		if err != nil {
+			b.Fatal(err)
+		}
+		a, err := re.ToAutomaton()
+		if err != nil {
+			b.Fatal(err)
+		}
+		// Undo the minimization done while parsing:
+		a, err = reverse(a)
+		if err != nil {
+			b.Fatal(err)
+		}
+		a, err = reverse(a)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestRemoveDeadStates(t *testing.T) {
+	// 0 -a-> 1 (accept), 0 -b-> 2 (cannot reach an accept state), 3 (unreachable) -c-> 1:
+	b := NewBuilder()
+	for i := 0; i < 4; i++ {
+		b.CreateState()
+	}
+	b.SetAccept(1, true)
+	b.AddTransitionLabel(0, 1, 'a')
+	b.AddTransitionLabel(0, 2, 'b')
+	b.AddTransitionLabel(3, 1, 'c')
+	a := b.Finish()
+
+	assert.True(t, HasDeadStates(a))
+	assert.True(t, HasDeadStatesFromInitial(a))
+	assert.True(t, HasDeadStatesToAccept(a))
+
+	live, err := RemoveDeadStates(a)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, live.GetNumStates())
+	assert.Equal(t, 1, live.GetNumTransitions())
+	assert.False(t, HasDeadStates(live))
+	assert.False(t, HasDeadStatesFromInitial(live))
+	assert.False(t, HasDeadStatesToAccept(live))
+	assert.True(t, Run(live, "a"))
+	assert.False(t, Run(live, "b"))
+
+	t.Run("testOnlyUnreachable", func(t *testing.T) {
+		b := NewBuilder()
+		for i := 0; i < 3; i++ {
+			b.CreateState()
+		}
+		b.SetAccept(1, true)
+		b.AddTransitionLabel(0, 1, 'a')
+		b.AddTransitionLabel(2, 1, 'c')
+		a := b.Finish()
+		assert.True(t, HasDeadStates(a))
+		assert.False(t, HasDeadStatesFromInitial(a))
+		assert.True(t, HasDeadStatesToAccept(a))
+	})
+
+	t.Run("testEmptyLanguage", func(t *testing.T) {
+		a, err := defaultAutomata.MakeString("abc")
+		assert.Nil(t, err)
+		a.SetAccept(3, false)
+		assert.True(t, HasDeadStatesFromInitial(a))
+		live, err := RemoveDeadStates(a)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, live.GetNumStates())
+	})
+}
+
+func TestIntersectsNonEmpty(t *testing.T) {
+	r := rand.New(rand.NewSource(1553))
+	nonEmpty := 0
+	for i := 0; i < 200; i++ {
+		p1 := randomRegexp(r, 1+r.Intn(3))
+		p2 := randomRegexp(r, 1+r.Intn(3))
+		re1, err := NewRegExp(p1)
+		assert.Nil(t, err)
+		a1, err := re1.ToAutomaton()
+		assert.Nil(t, err)
+		re2, err := NewRegExp(p2)
+		assert.Nil(t, err)
+		a2, err := re2.ToAutomaton()
+		assert.Nil(t, err)
+		// Also run on a non-deterministic automaton:
+		a2, err = union(a2, a2)
+		assert.Nil(t, err)
+
+		product, err := ProductWithPruner(a1, a2, nil)
+		assert.Nil(t, err)
+		got, err := IntersectsNonEmpty(a1, a2)
+		assert.Nil(t, err)
+		assert.Equal(t, !IsEmptyAutomaton(product), got, "p1=%q p2=%q", p1, p2)
+		if got {
+			nonEmpty++
+		}
+	}
+	assert.Greater(t, nonEmpty, 0)
+	assert.Less(t, nonEmpty, 200)
+
+	got, err := IntersectsNonEmpty(NewAutomaton(), defaultAutomata.MakeEmptyString())
+	assert.Nil(t, err)
+	assert.False(t, got)
+}
+
+func FuzzIntersection(f *testing.F) {
+	for _, seed := range []int64{0, 1, 1554, 987654321} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, seed int64) {
+		r := rand.New(rand.NewSource(seed))
+		randomAutomaton := func() *Automaton {
+			if r.Intn(2) == 0 {
+				// A non-deterministic automaton with overlapping ranges of labels 'a'..'d':
+				numStates := 1 + r.Intn(5)
+				a := NewAutomaton()
+				for s := 0; s < numStates; s++ {
+					a.CreateState()
+					a.SetAccept(s, r.Intn(3) == 0)
+				}
+				for s := 0; s < numStates; s++ {
+					for i := r.Intn(5); i > 0; i-- {
+						lo := 'a' + r.Intn(4)
+						hi := lo + r.Intn('d'-lo+1)
+						if err := a.AddTransition(s, r.Intn(numStates), lo, hi); err != nil {
+							t.Fatal(err)
+						}
+					}
+				}
+				a.FinishState()
+				return a
+			}
+			re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			a, err := re.ToAutomaton()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return a
+		}
+
+		a1 := randomAutomaton()
+		a2 := randomAutomaton()
+		a, err := intersection(a1, a2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 50; i++ {
+			s := randomString(r, 6)
+			if want := runNFA(a1, s) && runNFA(a2, s); want != runNFA(a, s) {
+				t.Fatalf("seed=%d s=%q: expected %v", seed, s, want)
+			}
+		}
+	})
+}
+
+func TestShuffle(t *testing.T) {
+	a1, err := MakeString("ab")
+	assert.Nil(t, err)
+	a2, err := MakeString("cd")
+	assert.Nil(t, err)
+
+	a, err := Shuffle(a1, a2, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.Nil(t, a.Validate())
+	for _, s := range []string{"abcd", "acbd", "acdb", "cabd", "cadb", "cdab"} {
+		assert.True(t, runNFA(a, s), s)
+	}
+	for _, s := range []string{"", "ab", "badc", "abdc", "abcdx"} {
+		assert.False(t, runNFA(a, s), s)
+	}
+	count, err := CountAcceptedStrings(a, 4)
+	assert.Nil(t, err)
+	assert.EqualValues(t, 6, count.Int64())
+
+	t.Run("empty", func(t *testing.T) {
+		a, err := Shuffle(a1, MakeEmpty(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, IsEmptyAutomaton(a))
+
+		a, err = Shuffle(a1, MakeEmptyString(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, runNFA(a, "ab"))
+		assert.False(t, runNFA(a, ""))
+	})
+
+	t.Run("workLimit", func(t *testing.T) {
+		_, err := Shuffle(a1, a2, 3)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		r := rand.New(rand.NewSource(11))
+		for i := 0; i < 50; i++ {
+			p1, p2 := randomRegexp(r, 1+r.Intn(2)), randomRegexp(r, 1+r.Intn(2))
+			re1, err := NewRegExp(p1)
+			assert.Nil(t, err)
+			a1, err := re1.ToAutomaton()
+			assert.Nil(t, err)
+			re2, err := NewRegExp(p2)
+			assert.Nil(t, err)
+			a2, err := re2.ToAutomaton()
+			assert.Nil(t, err)
+			a, err := Shuffle(a1, a2, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+
+			for j := 0; j < 20; j++ {
+				s := randomString(r, 6)
+				assert.Equal(t, isShuffle(a1, a2, s, "", ""), runNFA(a, s), "p1=%q p2=%q s=%q", p1, p2, s)
+			}
+		}
+	})
+}
+
+// Returns true if s can be split into two interleaved subsequences accepted by a1 and a2, by brute force.
+func isShuffle(a1, a2 *Automaton, s, s1, s2 string) bool {
+	if s == "" {
+		return runNFA(a1, s1) && runNFA(a2, s2)
+	}
+	return isShuffle(a1, a2, s[1:], s1+s[:1], s2) || isShuffle(a1, a2, s[1:], s1, s2+s[:1])
+}
+
+func TestMapLabels(t *testing.T) {
+	re, err := NewRegExp("[A-C]x*|D")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+
+	// Lowercase the upper case ranges, drop 'x':
+	lower, err := MapLabels(a, func(min, max int) (int, int) {
+		switch {
+		case min == 'x':
+			return 1, 0
+		case 'A' <= min && max <= 'Z':
+			return min + 'a' - 'A', max + 'a' - 'A'
+		}
+		return min, max
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, lower.Validate())
+	assert.True(t, Run(lower, "b"))
+	assert.True(t, Run(lower, "d"))
+	assert.False(t, Run(lower, "B"))
+	assert.False(t, Run(lower, "bx"))
+
+	// Overlapping ranges make the result non-deterministic:
+	merged, err := MapLabels(a, func(min, max int) (int, int) { return 'a', 'a' })
+	assert.Nil(t, err)
+	assert.False(t, merged.IsDeterministic())
+	assert.True(t, runNFA(merged, "aaa"))
+
+	_, err = MapLabels(a, func(min, max int) (int, int) { return -1, max })
+	assert.NotNil(t, err)
+
+	t.Run("testRelabel", func(t *testing.T) {
+		c, err := NewClassMap(a)
+		assert.Nil(t, err)
+		r, err := Relabel(a, c)
+		assert.Nil(t, err)
+		assert.True(t, r.IsDeterministic())
+		assert.Equal(t, a.GetNumStates(), r.GetNumStates())
+
+		classes := func(s string) string {
+			ids := make([]rune, 0, len(s))
+			for _, ch := range s {
+				ids = append(ids, rune(c.Class(int(ch))))
+			}
+			return string(ids)
+		}
+		for _, s := range []string{"A", "Bxx", "D", "Dx", "E", "", "xA"} {
+			assert.Equal(t, Run(a, s), Run(r, classes(s)), s)
+		}
+
+		other, err := MakeCharRange('B', 'B')
+		assert.Nil(t, err)
+		_, err = Relabel(other, c)
+		assert.NotNil(t, err)
+	})
+}
+
+func TestToCaseFold(t *testing.T) {
+	re, err := NewRegExp("go[a-c]*|straße|k")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+
+	folded, err := ToCaseFold(a)
+	assert.Nil(t, err)
+	assert.Nil(t, folded.Validate())
+	for _, s := range []string{"go", "GO", "gO", "GoAbC", "STRAẞE", "straße", "k", "K", "K"} {
+		assert.True(t, runNFA(folded, s), s)
+	}
+	for _, s := range []string{"", "gd", "STRASSE", "kk"} {
+		assert.False(t, runNFA(folded, s), s)
+	}
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1587))
+		for i := 0; i < 50; i++ {
+			re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)))
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+			folded, err := ToCaseFold(a)
+			assert.Nil(t, err)
+			for j := 0; j < 20; j++ {
+				s := randomString(r, 6)
+				if runNFA(a, s) {
+					assert.True(t, runNFA(folded, s), s)
+					assert.True(t, runNFA(folded, strings.ToUpper(s)), s)
+				}
+			}
+		}
+	})
+
+	// an orbit without case mappings:
+	iota, err := MakeChar(0x390)
+	assert.Nil(t, err)
+	folded, err = ToCaseFold(iota)
+	assert.Nil(t, err)
+	assert.True(t, runNFA(folded, "\u1FD3"))
+
+	binary, err := MakeBinaryRange(0, 10)
+	assert.Nil(t, err)
+	_, err = ToCaseFold(binary)
+	assert.NotNil(t, err)
+}
+
+func TestTotalize(t *testing.T) {
+	a, err := MakeString("ab")
+	assert.Nil(t, err)
+	assert.False(t, IsTotalized(a))
+
+	total, sink, err := Totalize(a)
+	assert.Nil(t, err)
+	assert.Equal(t, a.GetNumStates(), sink)
+	assert.Equal(t, a.GetNumStates()+1, total.GetNumStates())
+	assert.True(t, IsTotalized(total))
+	assert.False(t, total.IsAccept(sink))
+	assert.Equal(t, sink, total.Step(sink, 'x'))
+	assert.Equal(t, sink, total.Step(0, 'b'))
+	assert.Equal(t, sink, total.Step(2, 'a'))
+	for _, s := range []string{"", "a", "ab", "abc", "b"} {
+		assert.Equal(t, Run(a, s), Run(total, s), s)
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		total, sink, err := Totalize(NewAutomaton())
+		assert.Nil(t, err)
+		assert.Equal(t, 0, sink)
+		assert.True(t, IsTotalized(total))
+		assert.False(t, Run(total, ""))
+	})
+
+	t.Run("complement", func(t *testing.T) {
+		c, err := complement(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.False(t, Run(c, "ab"))
+		assert.True(t, Run(c, "abc"))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		b, err := MakeBinary([]byte("ab"))
+		assert.Nil(t, err)
+		total, sink, err := Totalize(b)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, total.Alphabet())
+		assert.True(t, IsTotalized(total))
+		assert.Equal(t, 0xFF, total.MaxLabel())
+		assert.Equal(t, sink, total.Step(0, 0xFF))
+
+		// The complement has the strings of bytes only:
+		c, err := complement(b, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, c.Alphabet())
+		for s, want := range map[string]bool{"ab": false, "": true, "abc": true, "ÿ": true} {
+			state, err := c.StepBytes(0, []byte(s))
+			assert.Nil(t, err)
+			assert.Equal(t, want, state != -1 && c.IsAccept(state), s)
+		}
+		anyBinary, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		both, err := union(c, b)
+		assert.Nil(t, err)
+		diff, err := DiffLanguages(both, anyBinary, 3)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty(), diff.String())
+
+		// A binary automaton differs from a unicode one by the strings with code points above the bytes:
+		s, err := MakeString("ab")
+		assert.Nil(t, err)
+		diff, err = DiffLanguages(s, b, 3)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty(), diff.String())
+		u, err := MakeString("aĀ")
+		assert.Nil(t, err)
+		diff, err = DiffLanguages(u, b, 3)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"aĀ"}, diff.OnlyInFirst)
+	})
+}
+
+func TestUnion(t *testing.T) {
+	ab, err := MakeString("ab")
+	assert.Nil(t, err)
+	prefix, err := MakePrefix("x")
+	assert.Nil(t, err)
+	// The initial state of a minimal (ab)* has a transition back to it:
+	star := MustNewRegExp("(ab)*").MustToAutomaton()
+	assert.True(t, ab.noDeadStates)
+	assert.True(t, star.noDeadStates)
+
+	check := func(t *testing.T, a *Automaton, accepted ...string) {
+		assert.False(t, HasDeadStates(a))
+		for _, s := range []string{"", "a", "ab", "abab", "x", "xyz", "b", "ba"} {
+			assert.Equal(t, slices.Contains(accepted, s), runNFA(a, s), "%q", s)
+		}
+	}
+
+	t.Run("withoutDeadStates", func(t *testing.T) {
+		a, err := Union(ab, prefix)
+		assert.Nil(t, err)
+		assert.True(t, a.noDeadStates)
+		// The initial states of both operands are dropped:
+		assert.Equal(t, ab.GetNumStates()+prefix.GetNumStates()-1, a.GetNumStates())
+		check(t, a, "ab", "x", "xyz")
+
+		a, err = union(star, prefix, MakeEmpty())
+		assert.Nil(t, err)
+		assert.True(t, a.noDeadStates)
+		check(t, a, "", "ab", "abab", "x", "xyz")
+	})
+
+	t.Run("withDeadStates", func(t *testing.T) {
+		// 0 -a-> 1 -b-> 2 (accept), 0 -b-> 3 (cannot reach an accept state):
+		b := NewBuilder()
+		for i := 0; i < 4; i++ {
+			b.CreateState()
+		}
+		b.SetAccept(2, true)
+		b.AddTransitionLabel(0, 1, 'a')
+		b.AddTransitionLabel(1, 2, 'b')
+		b.AddTransitionLabel(0, 3, 'b')
+		dead := b.Finish()
+		assert.False(t, dead.noDeadStates)
+
+		a, err := Union(dead, prefix)
+		assert.Nil(t, err)
+		assert.True(t, a.noDeadStates)
+		check(t, a, "ab", "x", "xyz")
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		a, err := MakeString("ab")
+		assert.Nil(t, err)
+		a.CreateState()
+		assert.False(t, a.noDeadStates)
+		assert.False(t, a.Clone().noDeadStates)
+	})
+}
+
+func BenchmarkRemoveDeadStates(b *testing.B) {
+	// A chain of 200k states with a dead branch at every state, plus unreachable states:
+	const n = 200_000
+	a := NewAutomaton()
+	for i := 0; i < 2*n+1; i++ {
+		a.CreateState()
+	}
+	a.SetAccept(n, true)
+	for s := 0; s < n; s++ {
+		if err := a.AddTransition(s, s+1, 'a', 'a'); err != nil {
+			b.Fatal(err)
+		}
+		if err := a.AddTransition(s, n+1+s, 'b', 'b'); err != nil {
+			b.Fatal(err)
+		}
+	}
+	a.FinishState()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result, err := RemoveDeadStates(a)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if result.GetNumStates() != n+1 {
+			b.Fatalf("got %d states", result.GetNumStates())
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Overlapping ranges to different dests, added in two different ways:
+	build := func(transitions ...[3]int) *Automaton {
+		a := NewAutomaton()
+		for i := 0; i < 3; i++ {
+			a.CreateState()
+		}
+		a.SetAccept(1, true)
+		a.SetAccept(2, true)
+		for _, tr := range transitions {
+			assert.Nil(t, a.AddTransition(0, tr[0], tr[1], tr[2]))
+		}
+		a.FinishState()
+		return a
+	}
+	a1 := build([3]int{1, 'a', 'c'}, [3]int{1, 'd', 'f'}, [3]int{2, 'c', 'h'})
+	a2 := build([3]int{2, 'f', 'h'}, [3]int{1, 'a', 'f'}, [3]int{2, 'c', 'e'})
+
+	n1, err := Normalize(a1)
+	assert.Nil(t, err)
+	assert.Nil(t, n1.Validate())
+	assert.False(t, n1.IsDeterministic())
+	var transitions [][3]int
+	tr := NewTransition()
+	count := n1.InitTransition(0, tr)
+	for i := 0; i < count; i++ {
+		n1.GetNextTransition(tr)
+		transitions = append(transitions, [3]int{tr.Dest, tr.Min, tr.Max})
+	}
+	assert.Equal(t, [][3]int{{1, 'a', 'b'}, {1, 'c', 'f'}, {2, 'c', 'f'}, {2, 'g', 'h'}}, transitions)
+
+	n2, err := Normalize(a2)
+	assert.Nil(t, err)
+	assert.True(t, StructurallyEqual(n1, n2))
+	assert.Equal(t, n1.Hash64(), n2.Hash64())
+
+	// Copies keep the split transitions:
+	c := NewAutomaton()
+	c.Copy(n1)
+	assert.Nil(t, c.Validate())
+	assert.True(t, StructurallyEqual(n1, c.Clone()))
+
+	t.Run("testDeterministic", func(t *testing.T) {
+		a := MustNewRegExp("[a-m]x|[n-z]+").MustToAutomaton()
+		n, err := Normalize(a)
+		assert.Nil(t, err)
+		assert.True(t, n.IsDeterministic())
+		assert.True(t, StructurallyEqual(a, n))
+	})
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1629))
+		for i := 0; i < 100; i++ {
+			pattern := randomRegexp(r, 1+r.Intn(3))
+			a, err := Reverse(MustNewRegExp(pattern).MustToAutomaton())
+			assert.Nil(t, err)
+			n, err := Normalize(a)
+			assert.Nil(t, err)
+			assert.Nil(t, n.Validate(), pattern)
+			assert.Equal(t, a.IsDeterministic(), n.IsDeterministic(), pattern)
+
+			// Ranges leaving a state are equal or disjoint:
+			for s := 0; s < n.GetNumStates(); s++ {
+				var ranges [][2]int
+				count := n.InitTransition(s, tr)
+				for j := 0; j < count; j++ {
+					n.GetNextTransition(tr)
+					for _, rng := range ranges {
+						if rng != [2]int{tr.Min, tr.Max} {
+							assert.True(t, tr.Min > rng[1] || tr.Max < rng[0], pattern)
+						}
+					}
+					ranges = append(ranges, [2]int{tr.Min, tr.Max})
+				}
+			}
+
+			for j := 0; j < 20; j++ {
+				s := randomString(r, 6)
+				assert.Equal(t, runNFA(a, s), runNFA(n, s), pattern, s)
+			}
+		}
+	})
+}
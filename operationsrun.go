@@ -1,13 +1,126 @@
 package automaton
 
+import (
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+// Run Returns true if the given deterministic automaton accepts s. Runs of ASCII characters are stepped with a
+// dense table once the automaton is frozen (see Freeze), which is several times faster than looking up the
+// transitions of every character.
 func Run(a *Automaton, s string) bool {
+	if a.GetNumStates() == 0 {
+		// The empty automaton accepts nothing, not even the empty string
+		return false
+	}
+	table := a.asciiTable()
 	state := 0
-	for _, v := range s {
-		nextState := a.Step(state, int(v))
-		if nextState == -1 {
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf && table != nil {
+			state = int(table.steps[state<<7|int(c)])
+			i++
+		} else {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			state = a.Step(state, int(r))
+			i += size
+		}
+		if state == -1 {
 			return false
 		}
-		state = nextState
 	}
 	return a.IsAccept(state)
 }
+
+// RunReader Returns true if the given deterministic automaton accepts the code points read from r, e.g. a
+// bufio.Reader over a file or a network stream, without holding the input in memory. Reading stops at io.EOF,
+// or as soon as the automaton rejects every continuation, leaving the rest of the input unread. Invalid UTF-8
+// reads as utf8.RuneError, as when ranging over a string. Returns any other error of r.
+func RunReader(a *Automaton, r io.RuneReader) (bool, error) {
+	if a.GetNumStates() == 0 {
+		return false, nil
+	}
+	table := a.asciiTable()
+	state := 0
+	for {
+		c, _, err := r.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return a.IsAccept(state), nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if c < utf8.RuneSelf && table != nil {
+			state = int(table.steps[state<<7|int(c)])
+		} else {
+			state = a.Step(state, int(c))
+		}
+		if state == -1 {
+			return false, nil
+		}
+	}
+}
+
+// maxASCIITableStates Automata with more states don't get an asciiTable, which takes 512 bytes per state.
+const maxASCIITableStates = 1 << 13
+
+// asciiTable The steps of all states for the ASCII labels: steps[state<<7|label].
+type asciiTable struct {
+	steps []int32
+}
+
+// asciiTable Returns the ASCII table of this automaton, building it on first use, or nil if the automaton is
+// not frozen or has too many states.
+func (a *Automaton) asciiTable() *asciiTable {
+	if !a.frozen || a.GetNumStates() > maxASCIITableStates {
+		return nil
+	}
+	if table := a.ascii.Load(); table != nil {
+		return table
+	}
+
+	numStates := a.GetNumStates()
+	table := &asciiTable{steps: make([]int32, numStates<<7)}
+	for i := range table.steps {
+		table.steps[i] = -1
+	}
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			if t.Min >= utf8.RuneSelf {
+				break
+			}
+			for c := t.Min; c <= t.Max && c < utf8.RuneSelf; c++ {
+				table.steps[s<<7|c] = int32(t.Dest)
+			}
+		}
+	}
+	a.ascii.Store(table)
+	return table
+}
+
+// RunAllPrefixes Returns, in increasing order, every byte index i such that s[:i] is accepted by the automaton,
+// stepping through s only once. Scanning stops as soon as the automaton rejects every extension of the
+// current prefix. The automaton must be deterministic.
+func RunAllPrefixes(a *Automaton, s string) []int {
+	var ends []int
+	if a.GetNumStates() == 0 {
+		return ends
+	}
+	state := 0
+	for i, v := range s {
+		if a.IsAccept(state) {
+			ends = append(ends, i)
+		}
+		state = a.Step(state, int(v))
+		if state == -1 {
+			return ends
+		}
+	}
+	if a.IsAccept(state) {
+		ends = append(ends, len(s))
+	}
+	return ends
+}
@@ -1,8 +1,14 @@
 package automaton
 
 import (
-	"github.com/stretchr/testify/assert"
+	"bufio"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestRun(t *testing.T) {
@@ -23,3 +29,146 @@ func TestRun(t *testing.T) {
 		})
 	}
 }
+
+func TestRun_ASCIITable(t *testing.T) {
+	r := rand.New(rand.NewSource(1594))
+	for i := 0; i < 50; i++ {
+		re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)) + "(é|[^a-c])?")
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		a, err = determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		frozen := a.Clone().Freeze()
+
+		for j := 0; j < 50; j++ {
+			s := randomString(r, 6)
+			switch r.Intn(4) {
+			case 0:
+				s += "é"
+			case 1:
+				s += "\xff"
+			}
+			assert.Equal(t, Run(a, s), Run(frozen, s), "%q", s)
+		}
+		assert.NotNil(t, frozen.ascii.Load())
+		assert.Nil(t, a.ascii.Load())
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	re, err := NewRegExp("@(ERROR|WARN) [0-9]+@")
+	if err != nil {
+		b.Fatal(err)
+	}
+	a, err := re.ToAutomaton()
+	if err != nil {
+		b.Fatal(err)
+	}
+	lines := newLogLines(rand.New(rand.NewSource(1594)), 1000)
+	strs := make([]string, len(lines))
+	for i, line := range lines {
+		strs[i] = strings.Repeat(string(line), 4)
+	}
+
+	b.Run("unfrozen", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			Run(a, strs[i%len(strs)])
+		}
+	})
+
+	b.Run("frozen", func(b *testing.B) {
+		frozen := a.Clone().Freeze()
+		for i := 0; i < b.N; i++ {
+			Run(frozen, strs[i%len(strs)])
+		}
+	})
+}
+
+func TestRunAllPrefixes(t *testing.T) {
+	re, err := NewRegExp("a|ab|abcd|é")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+	a, err = determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		s    string
+		want []int
+	}{
+		{"", nil},
+		{"a", []int{1}},
+		{"abcde", []int{1, 2, 4}},
+		{"abx", []int{1, 2}},
+		{"x", nil},
+		{"éa", []int{2}},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, RunAllPrefixes(a, tt.s), "RunAllPrefixes(%q)", tt.s)
+	}
+
+	optional, err := optional(a)
+	assert.Nil(t, err)
+	assert.Equal(t, []int{0, 1, 2}, RunAllPrefixes(optional, "ab"))
+	assert.Nil(t, RunAllPrefixes(NewAutomaton(), "ab"))
+}
+
+// countingRuneReader Counts the runes read, and fails with err after n of them if err is set.
+type countingRuneReader struct {
+	r    io.RuneReader
+	read int
+	n    int
+	err  error
+}
+
+func (c *countingRuneReader) ReadRune() (rune, int, error) {
+	if c.err != nil && c.read == c.n {
+		return 0, 0, c.err
+	}
+	c.read++
+	return c.r.ReadRune()
+}
+
+func TestRunReader(t *testing.T) {
+	a := MustNewRegExp("(ab|é)*c").MustToAutomaton()
+	for _, frozen := range []bool{false, true} {
+		if frozen {
+			a.Freeze()
+		}
+		for _, s := range []string{"", "c", "ababc", "éabéc", "abab", "abé", "x" + strings.Repeat("ab", 100) + "c"} {
+			ok, err := RunReader(a, strings.NewReader(s))
+			assert.Nil(t, err)
+			assert.Equal(t, Run(a, s), ok, s)
+		}
+	}
+
+	t.Run("testLargeStream", func(t *testing.T) {
+		input := strings.Repeat("abé", 1<<16) + "c"
+		ok, err := RunReader(a, bufio.NewReader(strings.NewReader(input)))
+		assert.Nil(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("testStopsEarly", func(t *testing.T) {
+		r := &countingRuneReader{r: strings.NewReader("abx" + strings.Repeat("ab", 1000))}
+		ok, err := RunReader(a, r)
+		assert.Nil(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 3, r.read)
+	})
+
+	t.Run("testError", func(t *testing.T) {
+		errBroken := errors.New("broken pipe")
+		r := &countingRuneReader{r: strings.NewReader("ababc"), n: 2, err: errBroken}
+		ok, err := RunReader(a, r)
+		assert.ErrorIs(t, err, errBroken)
+		assert.False(t, ok)
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		ok, err := RunReader(MakeEmpty(), strings.NewReader(""))
+		assert.Nil(t, err)
+		assert.False(t, ok)
+	})
+}
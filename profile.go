@@ -0,0 +1,145 @@
+package automaton
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// AutomatonProfile Describes the shape of an automaton, for performance analysis of the large automata used
+// by matching services; see Profile.
+type AutomatonProfile struct {
+	// Number of states.
+	States int
+
+	// Number of transitions.
+	Transitions int
+
+	// Number of accept states, and their fraction of all states.
+	AcceptStates int
+	AcceptRatio  float64
+
+	// OutDegrees[d] is the number of states with d transitions leaving them.
+	OutDegrees []int
+
+	// Number of labels accepted by at least one transition, and their fraction of the labels of the alphabet.
+	LabelsCovered int
+	LabelCoverage float64
+
+	// Number of classes of the alphabet partition, i.e. the width of the transition table of a RunAutomaton.
+	LabelClasses int
+}
+
+// Profile Returns the profile of the given automaton: its out-degree histogram, label coverage and accept ratio.
+func Profile(a *Automaton) *AutomatonProfile {
+	p := &AutomatonProfile{
+		States:       a.GetNumStates(),
+		Transitions:  a.GetNumTransitions(),
+		AcceptStates: a.NumAcceptStates(),
+		LabelClasses: a.AlphabetPartition().NumClasses(),
+	}
+	if p.States > 0 {
+		p.AcceptRatio = float64(p.AcceptStates) / float64(p.States)
+	}
+
+	ranges := make([][2]int, 0, p.Transitions)
+	for state := 0; state < p.States; state++ {
+		count := a.GetNumTransitionsWithState(state)
+		for len(p.OutDegrees) <= count {
+			p.OutDegrees = append(p.OutDegrees, 0)
+		}
+		p.OutDegrees[count]++
+
+		offset := int(a.states[2*state])
+		for i := 0; i < count; i++ {
+			t := a.transitions[offset+i]
+			ranges = append(ranges, [2]int{int(t.min), int(t.max)})
+		}
+	}
+
+	for _, r := range NewRangeSet(ranges...).Ranges() {
+		p.LabelsCovered += r[1] - r[0] + 1
+	}
+	p.LabelCoverage = float64(p.LabelsCovered) / float64(a.alphabet.maxLabel()+1)
+	return p
+}
+
+// MaxOutDegree Returns the largest number of transitions leaving a state.
+func (p *AutomatonProfile) MaxOutDegree() int {
+	return max(0, len(p.OutDegrees)-1)
+}
+
+// String Returns the profile as a short human readable report.
+func (p *AutomatonProfile) String() string {
+	b := new(strings.Builder)
+	fmt.Fprintf(b, "states: %d, transitions: %d, accept: %d (%.1f%%)\n",
+		p.States, p.Transitions, p.AcceptStates, 100*p.AcceptRatio)
+	fmt.Fprintf(b, "labels covered: %d (%.2f%%), label classes: %d\n",
+		p.LabelsCovered, 100*p.LabelCoverage, p.LabelClasses)
+	b.WriteString("out-degree histogram:")
+	for degree, states := range p.OutDegrees {
+		if states > 0 {
+			fmt.Fprintf(b, " %d:%d", degree, states)
+		}
+	}
+	return b.String()
+}
+
+// StateCounter Counts how often matches visit each state of a RunAutomaton, to find the hot states, e.g. to lay
+// out the transition table so that they share cache lines. Attach it with RunAutomaton.SetStateCounter. It is
+// safe for concurrent use.
+type StateCounter struct {
+	counts []atomic.Int64
+}
+
+// NewStateCounter Creates a counter for the states of the given automaton.
+func NewStateCounter(r *RunAutomaton) *StateCounter {
+	return &StateCounter{counts: make([]atomic.Int64, r.GetSize())}
+}
+
+// Visit Counts a visit of the given state.
+func (c *StateCounter) Visit(state int) {
+	c.counts[state].Add(1)
+}
+
+// Count Returns the number of visits of the given state.
+func (c *StateCounter) Count(state int) int64 {
+	return c.counts[state].Load()
+}
+
+// Counts Returns the number of visits of every state.
+func (c *StateCounter) Counts() []int64 {
+	counts := make([]int64, len(c.counts))
+	for state := range c.counts {
+		counts[state] = c.counts[state].Load()
+	}
+	return counts
+}
+
+// HotStates Returns up to n visited states, most visited first; states visited as often are in increasing
+// order.
+func (c *StateCounter) HotStates(n int) []int {
+	counts := c.Counts()
+	states := make([]int, 0, len(counts))
+	for state, count := range counts {
+		if count > 0 {
+			states = append(states, state)
+		}
+	}
+	slices.SortFunc(states, func(s1, s2 int) int {
+		if counts[s1] != counts[s2] {
+			return cmp.Compare(counts[s2], counts[s1])
+		}
+		return cmp.Compare(s1, s2)
+	})
+	return states[:min(n, len(states))]
+}
+
+// Reset Sets all counts to zero.
+func (c *StateCounter) Reset() {
+	for state := range c.counts {
+		c.counts[state].Store(0)
+	}
+}
@@ -0,0 +1,103 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfile(t *testing.T) {
+	a := MustNewRegExp("ab|ac|[x-z]d").MustToAutomaton()
+	p := Profile(a)
+
+	assert.Equal(t, a.GetNumStates(), p.States)
+	assert.Equal(t, a.GetNumTransitions(), p.Transitions)
+	assert.Equal(t, 1, p.AcceptStates)
+	assert.InDelta(t, 1/float64(p.States), p.AcceptRatio, 1e-9)
+
+	// The initial state has two transitions, the accept state none:
+	assert.Equal(t, 2, p.MaxOutDegree())
+	assert.Equal(t, 1, p.OutDegrees[0])
+	assert.Equal(t, 1, p.OutDegrees[2])
+	sum := 0
+	for _, states := range p.OutDegrees {
+		sum += states
+	}
+	assert.Equal(t, p.States, sum)
+
+	// a, b, c, d, x, y and z:
+	assert.Equal(t, 7, p.LabelsCovered)
+	assert.InDelta(t, 7/float64(0x110000), p.LabelCoverage, 1e-12)
+	assert.Equal(t, a.AlphabetPartition().NumClasses(), p.LabelClasses)
+	assert.Contains(t, p.String(), "out-degree histogram: 0:1")
+
+	t.Run("testEmpty", func(t *testing.T) {
+		p := Profile(NewAutomaton())
+		assert.Equal(t, 0, p.States)
+		assert.Equal(t, 0.0, p.AcceptRatio)
+		assert.Equal(t, 0, p.MaxOutDegree())
+		assert.Equal(t, 0, p.LabelsCovered)
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		a, err := MakeAnyBinary()
+		assert.Nil(t, err)
+		p := Profile(a)
+		assert.Equal(t, 256, p.LabelsCovered)
+		assert.Equal(t, 1.0, p.LabelCoverage)
+	})
+}
+
+func TestStateCounter(t *testing.T) {
+	a := MustNewRegExp("a+b").MustToAutomaton()
+	r, err := NewByteRunAutomaton(a, false, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	r.BuildPairTable(DEFAULT_PAIR_TABLE_MAX_BYTES)
+
+	counter := NewStateCounter(r.RunAutomaton)
+	assert.Nil(t, r.SetStateCounter(counter))
+	assert.True(t, r.Run([]byte("aaab")))
+	assert.False(t, r.Run([]byte("ac")))
+
+	// Every byte visits a state, besides the initial state of both runs:
+	assert.Equal(t, int64(2), counter.Count(0))
+	var total int64
+	for _, count := range counter.Counts() {
+		total += count
+	}
+	assert.Equal(t, int64(2+4+1), total)
+	a1 := r.Step(0, 'a')
+	assert.Equal(t, []int{a1, 0}, counter.HotStates(2))
+	assert.Equal(t, int64(4), counter.Count(a1))
+
+	// Counting stops when the counter is removed:
+	assert.Nil(t, r.SetStateCounter(nil))
+	assert.True(t, r.Run([]byte("ab")))
+	assert.Equal(t, int64(2), counter.Count(0))
+
+	counter.Reset()
+	assert.Empty(t, counter.HotStates(10))
+
+	t.Run("testMatcher", func(t *testing.T) {
+		m, err := CompileMatcher("[ab]+c")
+		assert.Nil(t, err)
+		run := m.(*runMatcher).r
+		counter := NewStateCounter(run)
+		assert.Nil(t, run.SetStateCounter(counter))
+		assert.True(t, m.Run("abac"))
+		assert.Equal(t, int64(1), counter.Count(0))
+		assert.Len(t, counter.HotStates(10), 3)
+	})
+
+	t.Run("testOtherAutomaton", func(t *testing.T) {
+		other, err := NewByteRunAutomaton(MustNewRegExp("abcdef").MustToAutomaton(), false,
+			DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Nil(t, r.SetStateCounter(counter))
+		assert.Error(t, other.SetStateCounter(counter))
+
+		// The rejected counter is not attached, so matching other doesn't visit its states:
+		assert.True(t, other.Run([]byte("abcdef")))
+		assert.Empty(t, counter.HotStates(10))
+	})
+}
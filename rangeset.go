@@ -0,0 +1,178 @@
+package automaton
+
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RangeSet A set of code points stored as sorted, non-overlapping and non-adjacent ranges. It matches strings
+// made of a single code point of the set, the language of a regular expression that is a single character
+// class, without building an automaton; see RegExp.ToRangeSet.
+type RangeSet struct {
+	// Pairs of min, max (inclusive).
+	ranges []int
+
+	// Bitmap of the ASCII code points in the set.
+	ascii [2]uint64
+}
+
+// Creates a set from sorted, non-overlapping and non-adjacent ranges.
+func newRangeSet(ranges []int) *RangeSet {
+	s := &RangeSet{ranges: ranges}
+	for i := 0; i < len(ranges) && ranges[i] < utf8.RuneSelf; i += 2 {
+		for c := ranges[i]; c <= ranges[i+1] && c < utf8.RuneSelf; c++ {
+			s.ascii[c>>6] |= 1 << (c & 63)
+		}
+	}
+	return s
+}
+
+// NewRangeSet Creates a set from pairs of min, max (inclusive) code points, in any order; overlapping or
+// adjacent ranges are merged and ranges with min > max are ignored.
+func NewRangeSet(ranges ...[2]int) *RangeSet {
+	sorted := make([][2]int, 0, len(ranges))
+	for _, r := range ranges {
+		if r[0] <= r[1] {
+			sorted = append(sorted, r)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i][0] < sorted[j][0]
+	})
+
+	merged := make([]int, 0, 2*len(sorted))
+	for _, r := range sorted {
+		n := len(merged)
+		if n > 0 && r[0] <= merged[n-1]+1 {
+			merged[n-1] = max(merged[n-1], r[1])
+		} else {
+			merged = append(merged, r[0], r[1])
+		}
+	}
+	return newRangeSet(merged)
+}
+
+// Ranges Returns the ranges of this set, sorted, as pairs of min, max (inclusive).
+func (s *RangeSet) Ranges() [][2]int {
+	ranges := make([][2]int, len(s.ranges)/2)
+	for i := range ranges {
+		ranges[i] = [2]int{s.ranges[2*i], s.ranges[2*i+1]}
+	}
+	return ranges
+}
+
+// Contains Returns true if the code point is in this set.
+func (s *RangeSet) Contains(codepoint int) bool {
+	if codepoint >= 0 && codepoint < utf8.RuneSelf {
+		return s.ascii[codepoint>>6]&(1<<(codepoint&63)) != 0
+	}
+	// Binary search for the first range whose max is >= codepoint:
+	lo, hi := 0, len(s.ranges)/2
+	for lo < hi {
+		mid := int(uint(lo+hi) >> 1)
+		if s.ranges[2*mid+1] < codepoint {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo < len(s.ranges)/2 && s.ranges[2*lo] <= codepoint
+}
+
+// Run Returns true if the string consists of exactly one code point of this set.
+func (s *RangeSet) Run(str string) bool {
+	if len(str) == 1 && str[0] < utf8.RuneSelf {
+		return s.Contains(int(str[0]))
+	}
+	c, size := utf8.DecodeRuneInString(str)
+	if size == 0 || size != len(str) {
+		return false
+	}
+	return s.Contains(int(c))
+}
+
+func (s *RangeSet) union(other *RangeSet) *RangeSet {
+	return NewRangeSet(append(s.Ranges(), other.Ranges()...)...)
+}
+
+func (s *RangeSet) intersection(other *RangeSet) *RangeSet {
+	var ranges []int
+	i, j := 0, 0
+	for i < len(s.ranges) && j < len(other.ranges) {
+		lo := max(s.ranges[i], other.ranges[j])
+		hi := min(s.ranges[i+1], other.ranges[j+1])
+		if lo <= hi {
+			ranges = append(ranges, lo, hi)
+		}
+		if s.ranges[i+1] < other.ranges[j+1] {
+			i += 2
+		} else {
+			j += 2
+		}
+	}
+	return newRangeSet(ranges)
+}
+
+// Returns all code points that are not in this set.
+func (s *RangeSet) complement() *RangeSet {
+	var ranges []int
+	next := 0
+	for i := 0; i < len(s.ranges); i += 2 {
+		if s.ranges[i] > next {
+			ranges = append(ranges, next, s.ranges[i]-1)
+		}
+		next = s.ranges[i+1] + 1
+	}
+	if next <= unicode.MaxRune {
+		ranges = append(ranges, next, unicode.MaxRune)
+	}
+	return newRangeSet(ranges)
+}
+
+// ToRangeSet Returns the set of code points matched by this regular expression if it only matches strings of
+// a single code point, like "[a-z0-9_]", "[^,;]", "." or "a|b", and false otherwise.
+func (r *RegExp) ToRangeSet() (*RangeSet, bool) {
+	switch r.kind {
+	case REGEXP_CHAR:
+		var ranges [][2]int
+		for _, c := range r.applyCaseFolding(rune(r.c)) {
+			ranges = append(ranges, [2]int{int(c), int(c)})
+		}
+		return NewRangeSet(ranges...), true
+	case REGEXP_CHAR_RANGE:
+		return NewRangeSet([2]int{r.from, r.to}), true
+	case REGEXP_ANYCHAR:
+		return NewRangeSet([2]int{0, unicode.MaxRune}), true
+	case REGEXP_UNION:
+		s1, ok := r.exp1.ToRangeSet()
+		if !ok {
+			return nil, false
+		}
+		s2, ok := r.exp2.ToRangeSet()
+		if !ok {
+			return nil, false
+		}
+		return s1.union(s2), true
+	case REGEXP_INTERSECTION:
+		// A negated character class is parsed as the intersection of any char with the complement of the class:
+		s1, ok := r.exp1.ToRangeSet()
+		if !ok {
+			return nil, false
+		}
+		if r.exp2.kind == REGEXP_COMPLEMENT {
+			s2, ok := r.exp2.exp1.ToRangeSet()
+			if !ok {
+				return nil, false
+			}
+			return s1.intersection(s2.complement()), true
+		}
+		s2, ok := r.exp2.ToRangeSet()
+		if !ok {
+			return nil, false
+		}
+		return s1.intersection(s2), true
+	default:
+		return nil, false
+	}
+}
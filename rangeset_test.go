@@ -0,0 +1,132 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegExp_ToRangeSet(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    [][2]int
+	}{
+		{"a", [][2]int{{'a', 'a'}}},
+		{"[a-z0-9_]", [][2]int{{'0', '9'}, {'_', '_'}, {'a', 'z'}}},
+		{"[a-cb-f]", [][2]int{{'a', 'f'}}},
+		{"a|b|[c-d]", [][2]int{{'a', 'd'}}},
+		{"[^b-y]", [][2]int{{0, 'a'}, {'z', 0x10FFFF}}},
+		{".", [][2]int{{0, 0x10FFFF}}},
+		{"[a-z]&[^m]", [][2]int{{'a', 'l'}, {'n', 'z'}}},
+	}
+	for _, tt := range tests {
+		re, err := NewRegExp(tt.pattern)
+		assert.Nil(t, err)
+		s, ok := re.ToRangeSet()
+		if assert.True(t, ok, tt.pattern) {
+			assert.Equal(t, tt.want, s.Ranges(), tt.pattern)
+		}
+	}
+
+	for _, pattern := range []string{"ab", "a*", "[a-z]+", "a?", "(a|bc)", "~a", "@", "#"} {
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		_, ok := re.ToRangeSet()
+		assert.False(t, ok, pattern)
+	}
+
+	t.Run("testCaseInsensitive", func(t *testing.T) {
+		re, err := NewRegExp("[ab]", WithMatchFlags(ASCII_CASE_INSENSITIVE))
+		assert.Nil(t, err)
+		s, ok := re.ToRangeSet()
+		assert.True(t, ok)
+		assert.Equal(t, [][2]int{{'A', 'B'}, {'a', 'b'}}, s.Ranges())
+	})
+
+	t.Run("testInvalidUTF8", func(t *testing.T) {
+		re, err := NewRegExp("[a\u00FF]")
+		assert.Nil(t, err)
+		s, ok := re.ToRangeSet()
+		assert.True(t, ok)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		// A single byte >= 0x80 is not the code point of the same value:
+		for _, str := range []string{"a", "ÿ", "\xff", "\xc3", "a\xff"} {
+			assert.Equal(t, Run(a, str), s.Run(str), "%q", str)
+		}
+		assert.True(t, s.Run("ÿ"))
+		assert.False(t, s.Run("\xff"))
+	})
+}
+
+func TestCompileMatcher(t *testing.T) {
+	r := rand.New(rand.NewSource(15392))
+	for _, pattern := range []string{"[a-c]", "[^b]", "a|c", ".", "[a-b]c", "(a|b)*"} {
+		m, err := CompileMatcher(pattern)
+		assert.Nil(t, err)
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+
+		for i := 0; i < 100; i++ {
+			s := randomString(r, 3)
+			assert.Equal(t, runNFA(a, s), m.Run(s), "pattern=%q s=%q", pattern, s)
+		}
+		assert.Equal(t, runNFA(a, "é"), m.Run("é"), pattern)
+	}
+
+	m, err := CompileMatcher("[a-z]")
+	assert.Nil(t, err)
+	assert.IsType(t, &RangeSet{}, m)
+
+	m, err = CompileMatcher("#")
+	assert.Nil(t, err)
+	assert.False(t, m.Run(""))
+}
+
+func BenchmarkCompileMatcher(b *testing.B) {
+	const pattern = "[a-zA-Z0-9_\\-]"
+	input := []string{"a", "Z", "-", "!", "é", "7"}
+
+	b.Run("rangeset", func(b *testing.B) {
+		m, err := CompileMatcher(pattern)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Run(input[i%len(input)])
+		}
+	})
+
+	b.Run("automaton", func(b *testing.B) {
+		// Force the automaton path by appending an empty string:
+		m, err := CompileMatcher(pattern + "()")
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.Run(input[i%len(input)])
+		}
+	})
+
+	b.Run("compile/rangeset", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := CompileMatcher(pattern); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("compile/automaton", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := CompileMatcher(pattern + "()"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -4,10 +4,13 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"io"
+	"slices"
 	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 type Kind int
@@ -38,11 +41,22 @@ const (
 	ANYSTRING              = 0x0008
 	AUTOMATON              = 0x0010
 	INTERVAL               = 0x0020
+	INLINE_FLAGS           = 0x0040 // Enables "(?:...)" groups and the inline flags "(?i)", "(?-i)" and "(?s)"
 	ALL                    = 0xff
 	NONE                   = 0x0000
 	ASCII_CASE_INSENSITIVE = 0x0100
+	// UNICODE_CASE_INSENSITIVE Matches characters of any script case insensitively, by simple case folding
+	// (see unicode.SimpleFold): e.g. "k" matches k, K and the Kelvin sign, and "σ" matches σ, the final
+	// sigma ς and Σ.
+	UNICODE_CASE_INSENSITIVE = 0x0200
+	// TURKIC_CASE_INSENSITIVE Like UNICODE_CASE_INSENSITIVE, but with the Turkish and Azerbaijani case pairs of
+	// the letter i: "i" matches i and İ (U+0130), and "I" matches I and ı (U+0131), instead of each other.
+	TURKIC_CASE_INSENSITIVE = 0x0400
 )
 
+// caseInsensitiveFlags Any of the flags matching characters case insensitively.
+const caseInsensitiveFlags = ASCII_CASE_INSENSITIVE | UNICODE_CASE_INSENSITIVE | TURKIC_CASE_INSENSITIVE
+
 type RegExp struct {
 	kind             Kind
 	exp1, exp2       *RegExp
@@ -56,8 +70,9 @@ type RegExp struct {
 }
 
 type regExpOption struct {
-	syntaxFlags int
-	matchFlags  int
+	syntaxFlags   int
+	matchFlags    int
+	normalization *norm.Form
 }
 type RegExpOption func(*regExpOption)
 
@@ -73,6 +88,14 @@ func WithMatchFlags(matchFlags int) RegExpOption {
 	}
 }
 
+// WithNormalization Normalizes the literal strings and characters of the pattern to the given Unicode
+// normalization form, so the compiled automaton matches input normalized the same way (see RunNormalized).
+func WithNormalization(form norm.Form) RegExpOption {
+	return func(option *regExpOption) {
+		option.normalization = &form
+	}
+}
+
 func NewRegExp(s string, options ...RegExpOption) (*RegExp, error) {
 	opts := &regExpOption{
 		syntaxFlags: ALL,
@@ -104,9 +127,13 @@ func NewRegExp(s string, options ...RegExpOption) (*RegExp, error) {
 			return nil, err
 		}
 		if exp.pos < len(exp.originalString) {
-			return nil, fmt.Errorf("end-of-string expected at position %d", exp.pos)
+			return nil, exp.parseError(exp.pos, "end-of-string expected")
 		}
 	}
+	if opts.normalization != nil {
+		normalizeLiterals(e, *opts.normalization)
+	}
+	exp.flags = e.flags
 	exp.kind = e.kind
 	exp.exp1 = e.exp1
 	exp.exp2 = e.exp2
@@ -120,6 +147,16 @@ func NewRegExp(s string, options ...RegExpOption) (*RegExp, error) {
 	return exp, nil
 }
 
+// MustNewRegExp Is like NewRegExp but panics if the expression cannot be parsed. It simplifies safe
+// initialization of global variables holding static patterns.
+func MustNewRegExp(s string, options ...RegExpOption) *RegExp {
+	r, err := NewRegExp(s, options...)
+	if err != nil {
+		panic(fmt.Sprintf("automaton: NewRegExp(%q): %v", s, err))
+	}
+	return r
+}
+
 func newRegExp(flags int, kind Kind, exp1, exp2 *RegExp, s *string, c, min, max, digits, from, to int) *RegExp {
 	return &RegExp{
 		kind:           kind,
@@ -155,22 +192,17 @@ func makeUnion(flags int, exp1, exp2 *RegExp) *RegExp {
 }
 
 func makeConcatenation(flags int, exp1, exp2 *RegExp) *RegExp {
-	if (exp1.kind == REGEXP_CHAR || exp1.kind == REGEXP_STRING) &&
-		(exp2.kind == REGEXP_CHAR || exp2.kind == REGEXP_STRING) {
-		return makeStringRegExp(flags, exp1, exp2)
+	if mergeableStrings(exp1, exp2) {
+		return makeStringRegExp(exp1.flags, exp1, exp2)
 	}
 
 	var rexp1, rexp2 *RegExp
-	if exp1.kind == REGEXP_CONCATENATION &&
-		(exp1.exp2.kind == REGEXP_CHAR || exp1.exp2.kind == REGEXP_STRING) &&
-		(exp2.kind == REGEXP_CHAR || exp2.kind == REGEXP_STRING) {
+	if exp1.kind == REGEXP_CONCATENATION && mergeableStrings(exp1.exp2, exp2) {
 		rexp1 = exp1.exp1
-		rexp2 = makeStringRegExp(flags, exp1.exp2, exp2)
+		rexp2 = makeStringRegExp(exp1.exp2.flags, exp1.exp2, exp2)
 
-	} else if (exp1.kind == REGEXP_CHAR || exp1.kind == REGEXP_STRING) &&
-		exp2.kind == REGEXP_CONCATENATION &&
-		(exp2.exp1.kind == REGEXP_CHAR || exp2.exp1.kind == REGEXP_STRING) {
-		rexp1 = makeStringRegExp(flags, exp1, exp2.exp1)
+	} else if exp2.kind == REGEXP_CONCATENATION && mergeableStrings(exp1, exp2.exp1) {
+		rexp1 = makeStringRegExp(exp1.flags, exp1, exp2.exp1)
 		rexp2 = exp2.exp2
 	} else {
 		rexp1 = exp1
@@ -179,6 +211,14 @@ func makeConcatenation(flags int, exp1, exp2 *RegExp) *RegExp {
 	return newContainerNode(flags, REGEXP_CONCATENATION, rexp1, rexp2)
 }
 
+// Returns true if both expressions are characters or strings matched alike, so they can be merged into one
+// string; inline flags may make them differ in case sensitivity.
+func mergeableStrings(exp1, exp2 *RegExp) bool {
+	return (exp1.kind == REGEXP_CHAR || exp1.kind == REGEXP_STRING) &&
+		(exp2.kind == REGEXP_CHAR || exp2.kind == REGEXP_STRING) &&
+		(exp1.flags^exp2.flags)&caseInsensitiveFlags == 0
+}
+
 func makeStringRegExp(flags int, exp1, exp2 *RegExp) *RegExp {
 	b := new(bytes.Buffer)
 	if exp1.kind == REGEXP_STRING {
@@ -255,11 +295,54 @@ func makeInterval(flags, min, max, digits int) *RegExp {
 	return newLeafNode(flags, REGEXP_INTERVAL, nil, 0, min, max, digits, 0, 0)
 }
 
+// DEFAULT_MAX_STATES Default limit on the number of states of the automaton built for any sub expression
+// of a RegExp, see WithMaxStates and SetDefaultMaxStates.
+const DEFAULT_MAX_STATES = 10000
+
+// ErrTooManyStates Returned (wrapped) by RegExp.ToAutomaton when a sub expression exceeds the state limit
+// set with WithMaxStates.
+var ErrTooManyStates = errors.New("too many states")
+
+// ParseError Describes a syntax error in a regular expression. Pos is the position (in code points) in Pattern
+// at which the error was detected. Error renders the pattern with a caret pointing at that position:
+//
+//	expected ']' at position 5:
+//	  ab[cd
+//	       ^
+type ParseError struct {
+	Pattern string
+	Pos     int
+	Msg     string
+}
+
+func (e *ParseError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s at position %d:\n  ", e.Msg, e.Pos)
+	for _, c := range e.Pattern {
+		// keep the caret aligned with the pattern on a single line:
+		if unicode.IsControl(c) {
+			c = ' '
+		}
+		sb.WriteRune(c)
+	}
+	sb.WriteString("\n  ")
+	sb.WriteString(strings.Repeat(" ", e.Pos))
+	sb.WriteByte('^')
+	return sb.String()
+}
+
+func (r *RegExp) parseError(pos int, msg string) error {
+	return &ParseError{Pattern: string(r.originalString), Pos: pos, Msg: msg}
+}
+
 type Provider func(name string) (*Automaton, error)
 
 type toAutomatonOptions struct {
 	automata          map[string]*Automaton
 	automatonProvider Provider
+	maxStates         int
+	budget            *Budget
+	lazyMinimize      bool
 }
 
 type ToAutomatonOptions func(*toAutomatonOptions)
@@ -276,23 +359,105 @@ func WithAutomatonProvider(automatonProvider Provider) ToAutomatonOptions {
 	}
 }
 
-func (r *RegExp) ToAutomaton() (*Automaton, error) {
-	return r.toAutomaton(DEFAULT_DETERMINIZE_WORK_LIMIT)
+// WithMaxStates Limits the number of states of the automaton built for every sub expression (and for the
+// estimated expansion of a repeat, before it is built), so pathological patterns fail with ErrTooManyStates
+// instead of exhausting memory. A limit <= 0 disables the check. Defaults to DefaultMaxStates().
+func WithMaxStates(maxStates int) ToAutomatonOptions {
+	return func(options *toAutomatonOptions) {
+		options.maxStates = maxStates
+	}
+}
+
+// WithBudget Bounds the total work of determinizing and minimizing all sub expressions by the given budget,
+// on top of the work limit of each step. Since each step spends from the same budget, the total compile time of
+// a pattern is bounded regardless of its size; pass the same budget to several patterns to bound them together.
+func WithBudget(budget *Budget) ToAutomatonOptions {
+	return func(options *toAutomatonOptions) {
+		options.budget = budget
+	}
+}
+
+// WithLazyMinimize Builds the automaton of every sub expression without determinizing or minimizing it, and
+// minimizes only the automaton of the whole pattern. By default every sub expression is minimized, which keeps
+// intermediate automata small but determinizes once per node; for large patterns, e.g. long unions of
+// literals, minimizing once is often much faster. Complements and case-insensitive literals are still
+// determinized where they occur. WithMaxStates then limits the sizes of the intermediate, non-deterministic
+// automata.
+func WithLazyMinimize() ToAutomatonOptions {
+	return func(options *toAutomatonOptions) {
+		options.lazyMinimize = true
+	}
+}
+
+// ToAutomaton Constructs a new (minimal, deterministic) automaton from this regular expression.
+func (r *RegExp) ToAutomaton(options ...ToAutomatonOptions) (*Automaton, error) {
+	return r.toAutomaton(DefaultWorkLimit(), options...)
+}
+
+// MustToAutomaton Is like ToAutomaton but panics if the automaton cannot be built. It simplifies safe
+// initialization of global variables holding automata of static patterns.
+func (r *RegExp) MustToAutomaton(options ...ToAutomatonOptions) *Automaton {
+	a, err := r.ToAutomaton(options...)
+	if err != nil {
+		panic(fmt.Sprintf("automaton: ToAutomaton(%q): %v", string(r.originalString), err))
+	}
+	return a
 }
 
 func (r *RegExp) toAutomaton(determinizeWorkLimit int, options ...ToAutomatonOptions) (*Automaton, error) {
 	opts := &toAutomatonOptions{
 		automata:          nil,
 		automatonProvider: nil,
+		maxStates:         DefaultMaxStates(),
 	}
 	for _, fn := range options {
 		fn(opts)
 	}
-	return r.toAutomatonInternal(opts.automata, opts.automatonProvider, determinizeWorkLimit)
+	a, err := r.toAutomatonInternal(opts, determinizeWorkLimit)
+	if err != nil || !opts.lazyMinimize {
+		return a, err
+	}
+	a, err = minimize(a, determinizeWorkLimit, opts.budget)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.checkStates(a.GetNumStates()); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// minimize Minimizes the automaton of a sub expression, unless minimizing is left to the end (see
+// WithLazyMinimize).
+func (o *toAutomatonOptions) minimize(a *Automaton, determinizeWorkLimit int) (*Automaton, error) {
+	if o.lazyMinimize {
+		return a, nil
+	}
+	return minimize(a, determinizeWorkLimit, o.budget)
+}
+
+// Returns an error wrapping ErrTooManyStates if numStates exceeds the configured limit.
+func (o *toAutomatonOptions) checkStates(numStates int) error {
+	if o.maxStates > 0 && numStates > o.maxStates {
+		return fmt.Errorf("%w: %d > %d", ErrTooManyStates, numStates, o.maxStates)
+	}
+	return nil
+}
+
+func (r *RegExp) toAutomatonInternal(opts *toAutomatonOptions, determinizeWorkLimit int) (*Automaton, error) {
+	a, err := r.toAutomatonKind(opts, determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
+	if a != nil {
+		if err := opts.checkStates(a.GetNumStates()); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
 }
 
-func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
-	automatonProvider Provider, determinizeWorkLimit int) (*Automaton, error) {
+func (r *RegExp) toAutomatonKind(opts *toAutomatonOptions, determinizeWorkLimit int) (*Automaton, error) {
 
 	list := make([]*Automaton, 0)
 	var a *Automaton
@@ -300,30 +465,28 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 	switch r.kind {
 	case REGEXP_UNION:
 		list = make([]*Automaton, 0)
-		if err := r.findLeaves(r.exp1, REGEXP_UNION, &list, automata, automatonProvider,
-			determinizeWorkLimit); err != nil {
+		if err := r.findLeaves(r.exp1, REGEXP_UNION, &list, opts, determinizeWorkLimit); err != nil {
 			return nil, err
 		}
-		if err := r.findLeaves(r.exp2, REGEXP_UNION, &list, automata, automatonProvider,
-			determinizeWorkLimit); err != nil {
+		if err := r.findLeaves(r.exp2, REGEXP_UNION, &list, opts, determinizeWorkLimit); err != nil {
 			return nil, err
 		}
 		a, err = union(list...)
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_CONCATENATION:
 		list = make([]*Automaton, 0)
-		err := r.findLeaves(r.exp1, REGEXP_CONCATENATION, &list, automata, automatonProvider, determinizeWorkLimit)
+		err := r.findLeaves(r.exp1, REGEXP_CONCATENATION, &list, opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		err = r.findLeaves(r.exp2, REGEXP_CONCATENATION, &list, automata, automatonProvider, determinizeWorkLimit)
+		err = r.findLeaves(r.exp2, REGEXP_CONCATENATION, &list, opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -331,17 +494,17 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_INTERSECTION:
-		a1, err := r.exp1.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a1, err := r.exp1.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		a2, err := r.exp2.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a2, err := r.exp2.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -350,13 +513,13 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_OPTIONAL:
-		a1, err := r.exp1.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a1, err := r.exp1.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
@@ -365,77 +528,79 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_REPEAT:
 		a1, err := r.exp1.toAutomatonInternal(
-			automata, automatonProvider, determinizeWorkLimit)
+			opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		a, err = repeat(a1)
+		a, err = Repeat(a1)
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_REPEAT_MIN:
-		a, err = r.exp1.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a, err = r.exp1.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		minNumStates := (a.GetNumStates() - 1) * r.min
-		if minNumStates > determinizeWorkLimit {
-			return nil, fmt.Errorf("too complex to determinize: %d", minNumStates)
+		if err := opts.checkStates((a.GetNumStates() - 1) * r.min); err != nil {
+			return nil, err
 		}
-		a, err = repeatCount(a, r.min)
+		a, err = RepeatMin(a, r.min)
 		if err != nil {
 			return nil, err
 		}
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_REPEAT_MINMAX:
-		a, err = r.exp1.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a, err = r.exp1.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		minMaxNumStates := (a.GetNumStates() - 1) * r.max
-		if minMaxNumStates > determinizeWorkLimit {
-			return nil, fmt.Errorf("too complex to determinize: %d", minMaxNumStates)
+		if err := opts.checkStates((a.GetNumStates() - 1) * r.max); err != nil {
+			return nil, err
+		}
+		a, err = RepeatRange(a, r.min, r.max)
+		if err != nil {
+			return nil, err
 		}
-		a, err = repeatRange(a, r.min, r.max)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 
 		break
 	case REGEXP_COMPLEMENT:
-		a1, err := r.exp1.toAutomatonInternal(automata, automatonProvider, determinizeWorkLimit)
+		a1, err := r.exp1.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
-		a, err = complement(a1, determinizeWorkLimit)
+		a, err = complementBudget(a1, determinizeWorkLimit, opts.budget)
 		if err != nil {
 			return nil, err
 		}
 
-		a, err = Minimize(a, determinizeWorkLimit)
+		a, err = opts.minimize(a, determinizeWorkLimit)
 		if err != nil {
 			return nil, err
 		}
 		break
 	case REGEXP_CHAR:
-		if r.check(ASCII_CASE_INSENSITIVE) {
-			a, err = r.toCaseInsensitiveChar(rune(r.c), determinizeWorkLimit)
+		if r.check(caseInsensitiveFlags) {
+			a, err = r.toCaseInsensitiveChar(rune(r.c))
 			if err != nil {
 				return nil, err
 			}
@@ -459,8 +624,8 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 		a = defaultAutomata.MakeEmpty()
 		break
 	case REGEXP_STRING:
-		if r.check(ASCII_CASE_INSENSITIVE) {
-			a, err = r.toCaseInsensitiveString(determinizeWorkLimit)
+		if r.check(caseInsensitiveFlags) {
+			a, err = r.toCaseInsensitiveString(determinizeWorkLimit, opts.budget)
 			if err != nil {
 				return nil, err
 			}
@@ -476,11 +641,11 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 		break
 	case REGEXP_AUTOMATON:
 		var aa *Automaton
-		if automata != nil {
-			aa = automata[*r.s]
+		if opts.automata != nil {
+			aa = opts.automata[*r.s]
 		}
-		if aa == nil && automatonProvider != nil {
-			aa, err = automatonProvider(*r.s)
+		if aa == nil && opts.automatonProvider != nil {
+			aa, err = opts.automatonProvider(*r.s)
 			if err != nil {
 				return nil, err
 			}
@@ -497,45 +662,40 @@ func (r *RegExp) toAutomatonInternal(automata map[string]*Automaton,
 	return a, nil
 }
 
-func (r *RegExp) toCaseInsensitiveChar(codepoint rune, determinizeWorkLimit int) (*Automaton, error) {
-	case1, err := defaultAutomata.MakeChar(codepoint)
-	if err != nil {
-		return nil, err
-	}
-	// For now we only work with ASCII characters
-	if codepoint > 128 {
-		return case1, nil
-	}
-	altCase := codepoint
-	if unicode.IsLower(codepoint) {
-		altCase = unicode.ToUpper(codepoint)
-	}
+func (r *RegExp) toCaseInsensitiveChar(codepoint rune) (*Automaton, error) {
+	return defaultAutomata.MakeCharSet(r.applyCaseFolding(codepoint))
+}
 
-	var result *Automaton
-	if altCase != codepoint {
-		case2, err := defaultAutomata.MakeChar(altCase)
-		if err != nil {
-			return nil, err
+// applyCaseFolding Returns the code points, in increasing order, that the character c matches under the case
+// insensitivity flags of this expression: only c itself without them; c and its other case if it is an ASCII
+// letter with ASCII_CASE_INSENSITIVE; its whole simple case folding orbit with UNICODE_CASE_INSENSITIVE; and
+// the same with TURKIC_CASE_INSENSITIVE, except that the dotted i and the dotless i form their own pairs.
+func (r *RegExp) applyCaseFolding(c rune) []rune {
+	var variants []rune
+	switch {
+	case r.check(TURKIC_CASE_INSENSITIVE) && (c == 'i' || c == '\u0130'):
+		variants = []rune{'i', '\u0130'}
+	case r.check(TURKIC_CASE_INSENSITIVE) && (c == 'I' || c == '\u0131'):
+		variants = []rune{'I', '\u0131'}
+	case r.check(UNICODE_CASE_INSENSITIVE | TURKIC_CASE_INSENSITIVE):
+		variants = []rune{c}
+		for f := unicode.SimpleFold(c); f != c; f = unicode.SimpleFold(f) {
+			variants = append(variants, f)
 		}
-		result, err = union(case1, case2)
-		if err != nil {
-			return nil, err
-		}
-		result, err = Minimize(result, determinizeWorkLimit)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		result = case1
+	case r.check(ASCII_CASE_INSENSITIVE) && c < utf8.RuneSelf:
+		variants = []rune{c, unicode.ToLower(c), unicode.ToUpper(c)}
+	default:
+		return []rune{c}
 	}
-	return result, nil
+	slices.Sort(variants)
+	return slices.Compact(variants)
 }
 
-func (r *RegExp) toCaseInsensitiveString(determinizeWorkLimit int) (*Automaton, error) {
+func (r *RegExp) toCaseInsensitiveString(determinizeWorkLimit int, budget *Budget) (*Automaton, error) {
 	list := make([]*Automaton, 0)
 
 	for _, v := range []rune((*r.s)) {
-		a, err := r.toCaseInsensitiveChar(v, determinizeWorkLimit)
+		a, err := r.toCaseInsensitiveChar(v)
 		if err != nil {
 			return nil, err
 		}
@@ -546,24 +706,21 @@ func (r *RegExp) toCaseInsensitiveString(determinizeWorkLimit int) (*Automaton,
 	if err != nil {
 		return nil, err
 	}
-	return Minimize(automata, determinizeWorkLimit)
+	return minimize(automata, determinizeWorkLimit, budget)
 }
 
 func (r *RegExp) findLeaves(exp *RegExp, kind Kind, list *[]*Automaton,
-	automata map[string]*Automaton, automatonProvider Provider, determinizeWorkLimit int) error {
+	opts *toAutomatonOptions, determinizeWorkLimit int) error {
 	if exp.kind == kind {
-		if err := r.findLeaves(exp.exp1, kind, list, automata, automatonProvider,
-			determinizeWorkLimit); err != nil {
+		if err := r.findLeaves(exp.exp1, kind, list, opts, determinizeWorkLimit); err != nil {
 			return err
 		}
 
-		if err := r.findLeaves(exp.exp2, kind, list, automata, automatonProvider,
-			determinizeWorkLimit); err != nil {
+		if err := r.findLeaves(exp.exp2, kind, list, opts, determinizeWorkLimit); err != nil {
 			return err
 		}
 	} else {
-		automaton, err := exp.toAutomatonInternal(automata, automatonProvider,
-			determinizeWorkLimit)
+		automaton, err := exp.toAutomatonInternal(opts, determinizeWorkLimit)
 		if err != nil {
 			return err
 		}
@@ -593,7 +750,7 @@ func (r *RegExp) match(c int) bool {
 
 func (r *RegExp) next() (int, error) {
 	if !r.more() {
-		return 0, io.EOF
+		return 0, r.parseError(r.pos, "unexpected end of pattern")
 	}
 	ch := r.originalString[r.pos]
 	r.pos++
@@ -670,11 +827,11 @@ func (r *RegExp) parseRepeatExp() (*RegExp, error) {
 				}
 			}
 			if start == r.pos {
-				return nil, fmt.Errorf("integer expected at position %d", r.pos)
+				return nil, r.parseError(r.pos, "integer expected")
 			}
 			n, err := strconv.Atoi(string(r.originalString[start:r.pos]))
 			if err != nil {
-				return nil, err
+				return nil, r.parseError(start, "integer out of range")
 			}
 			m := -1
 			if r.match(',') {
@@ -688,7 +845,7 @@ func (r *RegExp) parseRepeatExp() (*RegExp, error) {
 				if start != r.pos {
 					m, err = strconv.Atoi(string(r.originalString[start:r.pos]))
 					if err != nil {
-						return nil, err
+						return nil, r.parseError(start, "integer out of range")
 					}
 				} else {
 					m = n
@@ -698,7 +855,7 @@ func (r *RegExp) parseRepeatExp() (*RegExp, error) {
 			}
 
 			if !r.match('}') {
-				return nil, fmt.Errorf("expected '}' at position %d", r.pos)
+				return nil, r.parseError(r.pos, "expected '}'")
 			}
 
 			if m == -1 {
@@ -737,7 +894,7 @@ func (r *RegExp) parseCharClassExp() (*RegExp, error) {
 			e = makeIntersection(r.flags, makeAnyChar(r.flags), makeComplement(r.flags, e))
 		}
 		if !r.match(']') {
-			return nil, fmt.Errorf("expected ']' at position %d", r.pos)
+			return nil, r.parseError(r.pos, "expected ']'")
 		}
 		return e, nil
 	}
@@ -760,6 +917,7 @@ func (r *RegExp) parseCharClasses() (*RegExp, error) {
 }
 
 func (r *RegExp) parseCharClass() (*RegExp, error) {
+	start := r.pos
 	c, err := r.parseCharExp()
 	if err != nil {
 		return nil, err
@@ -769,6 +927,9 @@ func (r *RegExp) parseCharClass() (*RegExp, error) {
 		if err != nil {
 			return nil, err
 		}
+		if c > e2 {
+			return nil, r.parseError(start, "invalid range")
+		}
 		return makeCharRange(r.flags, c, e2)
 	}
 	return makeChar(r.flags, c), err
@@ -795,21 +956,14 @@ func (r *RegExp) parseSimpleExp() (*RegExp, error) {
 			}
 		}
 		if !r.match('"') {
-			return nil, fmt.Errorf("expected '\\\"' at position %d", r.pos)
+			return nil, r.parseError(r.pos, "expected '\"'")
 		}
 		return makeString(r.flags, string(r.originalString[start:r.pos-1])), nil
 	} else if r.match('(') {
-		if r.match(')') {
-			return makeString(r.flags, ""), nil
-		}
-		e, err := r.parseUnionExp()
-		if err != nil {
-			return nil, err
-		}
-		if !r.match(')') {
-			return nil, fmt.Errorf("expected ')' at position %d", r.pos)
+		if r.check(INLINE_FLAGS) && r.match('?') {
+			return r.parseInlineGroup()
 		}
-		return e, nil
+		return r.parseGroup(r.flags)
 	} else if (r.check(AUTOMATON) || r.check(INTERVAL)) && r.match('<') {
 		start := r.pos
 		for r.more() && !r.peek(">") {
@@ -819,30 +973,30 @@ func (r *RegExp) parseSimpleExp() (*RegExp, error) {
 		}
 
 		if !r.match('>') {
-			return nil, fmt.Errorf("expected '>' at position %d", r.pos)
+			return nil, r.parseError(r.pos, "expected '>'")
 		}
 		s := string(r.originalString[start : r.pos-1])
 		i := strings.IndexRune(s, '-')
 		if i == -1 {
 			if !r.check(AUTOMATON) {
-				return nil, fmt.Errorf("interval syntax error at position %d", r.pos-1)
+				return nil, r.parseError(r.pos-1, "interval syntax error")
 			}
 			return makeAutomaton(r.flags, s), nil
 		} else {
 			if !r.check(INTERVAL) {
-				return nil, fmt.Errorf("illegal identifier at position %d", r.pos-1)
+				return nil, r.parseError(r.pos-1, "illegal identifier")
 			}
 
-			if i == 0 || i == len(s)-1 || i != strings.LastIndexByte(s, '-') {
+			if i != 0 && i != len(s)-1 && i == strings.LastIndexByte(s, '-') {
 				smin := s[:i]
 				smax := s[i+1:]
 				imin, err := strconv.Atoi(smin)
 				if err != nil {
-					return nil, err
+					return nil, r.parseError(r.pos-1, "interval syntax error")
 				}
 				imax, err := strconv.Atoi(smax)
 				if err != nil {
-					return nil, err
+					return nil, r.parseError(r.pos-1, "interval syntax error")
 				}
 				digits := 0
 				if len(smin) == len(smax) {
@@ -854,10 +1008,13 @@ func (r *RegExp) parseSimpleExp() (*RegExp, error) {
 				}
 				return makeInterval(r.flags, imin, imax, digits), nil
 			}
-			return nil, fmt.Errorf("interval syntax error at position %d", r.pos-1)
+			return nil, r.parseError(r.pos-1, "interval syntax error")
 		}
 	}
 
+	if err := r.checkBackreference(); err != nil {
+		return nil, err
+	}
 	c, err := r.parseCharExp()
 	if err != nil {
 		return nil, err
@@ -865,6 +1022,135 @@ func (r *RegExp) parseSimpleExp() (*RegExp, error) {
 	return makeChar(r.flags, c), nil
 }
 
+// parseGroup Parses the rest of a group after its "(" with the given flags. Flags changed inside the group
+// (see parseInlineGroup) are restored once it ends.
+func (r *RegExp) parseGroup(flags int) (*RegExp, error) {
+	outer := r.flags
+	r.flags = flags
+	defer func() {
+		r.flags = outer
+	}()
+
+	if r.match(')') {
+		return makeString(r.flags, ""), nil
+	}
+	e, err := r.parseUnionExp()
+	if err != nil {
+		return nil, err
+	}
+	if !r.match(')') {
+		return nil, r.parseError(r.pos, "expected ')'")
+	}
+	return e, nil
+}
+
+// parseInlineGroup Parses the rest of a group after its "(?": either a non-capturing group "(?:...)", flags
+// "(?i)" applying to the rest of the enclosing group, or flags applying to a group "(?i:...)". The flags are i
+// (ASCII_CASE_INSENSITIVE; "-i" clears the Unicode and Turkic case insensitivity too) and s (. matches
+// newlines, which it always does), and are cleared after a "-".
+func (r *RegExp) parseInlineGroup() (*RegExp, error) {
+	if err := r.checkUnsupportedGroup(); err != nil {
+		return nil, err
+	}
+	start := r.pos - 2
+	flags := r.flags
+	negate := false
+	numFlags := 0
+	for {
+		c, err := r.next()
+		if err != nil {
+			return nil, err
+		}
+		switch c {
+		case 'i':
+			if negate {
+				flags &^= caseInsensitiveFlags
+			} else {
+				flags |= ASCII_CASE_INSENSITIVE
+			}
+			numFlags++
+		case 's':
+			numFlags++
+		case '-':
+			if negate {
+				return nil, r.parseError(r.pos-1, "invalid inline flags")
+			}
+			negate = true
+			numFlags = 0
+		case ')':
+			if numFlags == 0 {
+				return nil, r.parseError(start, "missing inline flags")
+			}
+			// The flags apply to the rest of the enclosing group, which restores them:
+			r.flags = flags
+			return makeString(r.flags, ""), nil
+		case ':':
+			if negate && numFlags == 0 {
+				return nil, r.parseError(start, "missing inline flags")
+			}
+			return r.parseGroup(flags)
+		default:
+			return nil, r.parseError(r.pos-1, fmt.Sprintf("unsupported inline construct %q", string(rune(c))))
+		}
+	}
+}
+
+// unsupportedGroups The constructs of PCRE-like engines starting with "(?" that finite automata cannot express,
+// by their prefix after the "(?". Longer prefixes come first.
+var unsupportedGroups = []struct {
+	prefix, construct, hint string
+}{
+	{"<=", `lookbehind "(?<=...)"`, ""},
+	{"<!", `negative lookbehind "(?<!...)"`, ""},
+	{"=", `lookahead "(?=...)"`, ""},
+	{"!", `negative lookahead "(?!...)"`, ""},
+	{"P<", `named group "(?P<name>...)"`, `automata do not capture groups, use "(...)"`},
+	{"P=", `named backreference "(?P=name)"`, ""},
+	{"<", `named group "(?<name>...)"`, `automata do not capture groups, use "(...)"`},
+	{"'", `named group "(?'name'...)"`, `automata do not capture groups, use "(...)"`},
+	{">", `atomic group "(?>...)"`, `automata do not backtrack, use "(...)"`},
+	{"|", `branch reset group "(?|...)"`, ""},
+	{"#", `comment "(?#...)"`, ""},
+	{"R", `recursion "(?R)"`, ""},
+}
+
+// checkUnsupportedGroup Returns an error naming the construct if the group whose "(?" was just parsed is one of
+// unsupportedGroups, rather than the generic error of parseInlineGroup.
+func (r *RegExp) checkUnsupportedGroup() error {
+	rest := r.originalString[r.pos:]
+	for _, g := range unsupportedGroups {
+		if len(rest) >= len(g.prefix) && string(rest[:len(g.prefix)]) == g.prefix {
+			msg := g.construct + " is not supported by finite automata"
+			if g.hint != "" {
+				msg += ": " + g.hint
+			}
+			return r.parseError(r.pos, msg)
+		}
+	}
+	return nil
+}
+
+// checkBackreference Returns an error if the expression continues with a backreference, like "\1" or
+// "\k<name>", which would otherwise be parsed as an escaped literal character.
+func (r *RegExp) checkBackreference() error {
+	rest := r.originalString[r.pos:]
+	if len(rest) < 2 || rest[0] != '\\' {
+		return nil
+	}
+	switch {
+	case rest[1] >= '1' && rest[1] <= '9':
+		return r.parseError(r.pos, fmt.Sprintf("backreference %q is not supported by finite automata",
+			string(rest[:2])))
+	case (rest[1] == 'k' || rest[1] == 'g') && len(rest) > 2 && strings.ContainsRune("<{'", rest[2]):
+		return r.parseError(r.pos, fmt.Sprintf("named backreference %q is not supported by finite automata",
+			string(rest[:3])+"..."))
+	case rest[1] == 'g' && len(rest) > 2 && rest[2] >= '0' && rest[2] <= '9':
+		return r.parseError(r.pos, fmt.Sprintf("backreference %q is not supported by finite automata",
+			string(rest[:3])))
+	}
+	return nil
+}
+
 func (r *RegExp) parseCharExp() (int, error) {
 	r.match('\\')
 	return r.next()
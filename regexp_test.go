@@ -1,8 +1,11 @@
 package automaton
 
 import (
-	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"strings"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNewRegExp(t *testing.T) {
@@ -28,11 +31,324 @@ func TestNewRegExp(t *testing.T) {
 	t.Run("testSerializeTooManyStatesToRepeat", func(t *testing.T) {
 		r, err := NewRegExp("a{50001}")
 		assert.Nil(t, err)
-		_, err = r.toAutomaton(50000)
-		assert.Error(t, err)
+		_, err = r.ToAutomaton(WithMaxStates(50000))
+		assert.ErrorIs(t, err, ErrTooManyStates)
+	})
+
+	t.Run("testMaxStates", func(t *testing.T) {
+		r, err := NewRegExp("(a|b)c{2,4}")
+		assert.Nil(t, err)
+		a, err := r.ToAutomaton(WithMaxStates(6))
+		assert.Nil(t, err)
+		assert.Equal(t, 6, a.GetNumStates())
+		_, err = r.ToAutomaton(WithMaxStates(5))
+		assert.ErrorIs(t, err, ErrTooManyStates)
+
+		// The expansion of a repeat is checked before it is built:
+		r, err = NewRegExp("[ab]{3000000}")
+		assert.Nil(t, err)
+		_, err = r.ToAutomaton()
+		assert.ErrorIs(t, err, ErrTooManyStates)
+
+	})
+
+	t.Run("testRepeatMinMaxIsMinimal", func(t *testing.T) {
+		r, err := NewRegExp("(a|ab){1,3}")
+		assert.Nil(t, err)
+		a, err := r.ToAutomaton()
+		assert.Nil(t, err)
+		m, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, m.GetNumStates(), a.GetNumStates())
+		assert.True(t, a.IsDeterministic())
+	})
+
+	t.Run("testInterval", func(t *testing.T) {
+		for pattern, cases := range map[string]map[string]bool{
+			"<1-12>":  {"1": true, "7": true, "12": true, "0": false, "13": false, "07": true},
+			"<12-1>":  {"1": true, "12": true, "13": false},
+			"<01-12>": {"01": true, "12": true, "1": false, "13": false},
+		} {
+			r, err := NewRegExp(pattern)
+			if !assert.Nil(t, err, pattern) {
+				continue
+			}
+			a, err := r.ToAutomaton()
+			assert.Nil(t, err, pattern)
+			a, err = Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+			for s, want := range cases {
+				assert.Equal(t, want, Run(a, s), "%s %s", pattern, s)
+			}
+		}
+
+		for _, pattern := range []string{"<-12>", "<1->", "<1-2-3>"} {
+			_, err := NewRegExp(pattern)
+			assert.Error(t, err, pattern)
+		}
+	})
+}
+
+func TestParseError(t *testing.T) {
+	_, err := NewRegExp("ab[cd")
+	var perr *ParseError
+	assert.ErrorAs(t, err, &perr)
+	assert.Equal(t, &ParseError{Pattern: "ab[cd", Pos: 5, Msg: "expected ']'"}, perr)
+	assert.Equal(t, "expected ']' at position 5:\n  ab[cd\n       ^", err.Error())
+
+	for pattern, pos := range map[string]int{
+		"a(b":                     3,
+		"ab)":                     2,
+		"a{x}":                    2,
+		"a{2,x}":                  4,
+		"a{99999999999999999999}": 2,
+		`"ab`:                     3,
+		"[b-a]":                   1,
+		`a\`:                      2,
+		"<1-5":                    4,
+		"<-5>":                    3,
+		"<1-2-3>":                 6,
+		"a(?=b)":                  3,
+		"(?P<x>a)":                2,
+		"a(?m)":                   3,
+		"(?)":                     0,
+		"(?i--i)":                 4,
+		"(?i":                     3,
+	} {
+		_, err := NewRegExp(pattern)
+		assert.ErrorAs(t, err, &perr, pattern)
+		assert.Equal(t, pos, perr.Pos, pattern)
+		assert.Equal(t, pattern, perr.Pattern)
+	}
+
+	t.Run("testUnsupportedConstructs", func(t *testing.T) {
+		for pattern, msg := range map[string]string{
+			"a(?=b)":      `lookahead "(?=...)" is not supported by finite automata`,
+			"(?!b)a":      `negative lookahead "(?!...)" is not supported by finite automata`,
+			"(?<=a)b":     `lookbehind "(?<=...)" is not supported by finite automata`,
+			"(?<!a)b":     `negative lookbehind "(?<!...)" is not supported by finite automata`,
+			"(?<x>a)":     `named group "(?<name>...)" is not supported by finite automata: automata do not capture groups, use "(...)"`,
+			"(?P<x>a)":    `named group "(?P<name>...)" is not supported by finite automata: automata do not capture groups, use "(...)"`,
+			"(?'x'a)":     `named group "(?'name'...)" is not supported by finite automata: automata do not capture groups, use "(...)"`,
+			"(?>a+)b":     `atomic group "(?>...)" is not supported by finite automata: automata do not backtrack, use "(...)"`,
+			"(a)\\1":      `backreference "\\1" is not supported by finite automata`,
+			"(a)b\\g2":    `backreference "\\g2" is not supported by finite automata`,
+			"(?:a)\\k<x>": `named backreference "\\k<..." is not supported by finite automata`,
+		} {
+			_, err := NewRegExp(pattern)
+			assert.ErrorAs(t, err, &perr, pattern)
+			assert.Equal(t, msg, perr.Msg, pattern)
+		}
+
+		// Inside a character class and without INLINE_FLAGS, these are still literal characters:
+		a := MustNewRegExp("[\\1]").MustToAutomaton()
+		assert.True(t, runNFA(a, "1"))
+		a = MustNewRegExp("(?=a)", WithSyntaxFlags(NONE)).MustToAutomaton()
+		assert.True(t, runNFA(a, "?=a"))
+	})
+
+	// a well formed interval parses:
+	a := MustNewRegExp("<1-12>").MustToAutomaton()
+	assert.True(t, runNFA(a, "7"))
+	assert.True(t, runNFA(a, "12"))
+	assert.False(t, runNFA(a, "13"))
+}
+
+func TestMustNewRegExp(t *testing.T) {
+	a := MustNewRegExp("a(b+|c+)d").MustToAutomaton()
+	assert.True(t, Run(a, "abbd"))
+	assert.False(t, Run(a, "ad"))
+
+	assert.PanicsWithValue(t, "automaton: NewRegExp(\"a(b\"): expected ')' at position 3:\n  a(b\n     ^", func() {
+		MustNewRegExp("a(b")
+	})
+	assert.Panics(t, func() {
+		MustNewRegExp("[ac]*a[ac]{50,200}").MustToAutomaton()
 	})
 }
 
+func TestInlineFlags(t *testing.T) {
+	t.Run("caseInsensitive", func(t *testing.T) {
+		a := MustNewRegExp("(?i)abc").MustToAutomaton()
+		assert.True(t, Run(a, "abc"))
+		assert.True(t, Run(a, "AbC"))
+		assert.False(t, Run(a, "abd"))
+
+		// the flag applies to the rest of the enclosing group only:
+		a = MustNewRegExp("a(?i)b").MustToAutomaton()
+		assert.True(t, Run(a, "aB"))
+		assert.False(t, Run(a, "AB"))
+
+		a = MustNewRegExp("((?i)a|b)c").MustToAutomaton()
+		assert.True(t, Run(a, "Ac"))
+		assert.True(t, Run(a, "Bc"))
+		assert.False(t, Run(a, "AC"))
+
+		a = MustNewRegExp("(?i:ab)c").MustToAutomaton()
+		assert.True(t, Run(a, "ABc"))
+		assert.False(t, Run(a, "abC"))
+	})
+
+	t.Run("clearFlags", func(t *testing.T) {
+		a := MustNewRegExp("a(?-i)b", WithMatchFlags(ASCII_CASE_INSENSITIVE)).MustToAutomaton()
+		assert.True(t, Run(a, "Ab"))
+		assert.False(t, Run(a, "aB"))
+
+		a = MustNewRegExp("(?-i:a)b", WithMatchFlags(ASCII_CASE_INSENSITIVE)).MustToAutomaton()
+		assert.True(t, Run(a, "aB"))
+		assert.False(t, Run(a, "Ab"))
+	})
+
+	t.Run("nonCapturingGroup", func(t *testing.T) {
+		a := MustNewRegExp("(?:ab)+").MustToAutomaton()
+		assert.True(t, Run(a, "abab"))
+		assert.False(t, Run(a, "aba"))
+
+		a = MustNewRegExp("a(?:)b").MustToAutomaton()
+		assert.True(t, Run(a, "ab"))
+	})
+
+	t.Run("dotAll", func(t *testing.T) {
+		a := MustNewRegExp("(?s)a.b").MustToAutomaton()
+		assert.True(t, Run(a, "a\nb"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		// without INLINE_FLAGS, "?" is an ordinary character after "(":
+		a := MustNewRegExp("(?i)a", WithSyntaxFlags(ALL&^INLINE_FLAGS)).MustToAutomaton()
+		assert.True(t, Run(a, "?ia"))
+		assert.False(t, Run(a, "A"))
+	})
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	compile := func(pattern string, flags int) *Automaton {
+		return MustNewRegExp(pattern, WithMatchFlags(flags)).MustToAutomaton()
+	}
+
+	t.Run("testASCII", func(t *testing.T) {
+		a := compile("Go", ASCII_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "go"))
+		assert.True(t, Run(a, "GO"))
+		assert.False(t, Run(compile("σ", ASCII_CASE_INSENSITIVE), "Σ"))
+	})
+
+	t.Run("testUnicode", func(t *testing.T) {
+		a := compile("σοφία", UNICODE_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "ΣΟΦΊΑ"))
+		a = compile("λόγος", UNICODE_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "ΛΌΓΟΣ"))
+		assert.True(t, Run(a, "λόγοσ"))
+		assert.True(t, Run(compile("k", UNICODE_CASE_INSENSITIVE), "\u212a"))
+		// The dotted and dotless i have no simple case folding:
+		assert.False(t, Run(compile("i", UNICODE_CASE_INSENSITIVE), "İ"))
+	})
+
+	t.Run("testTurkic", func(t *testing.T) {
+		a := compile("istanbul", TURKIC_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "İSTANBUL"))
+		assert.False(t, Run(a, "ISTANBUL"))
+		a = compile("ılık", TURKIC_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "ILIK"))
+		assert.False(t, Run(a, "ilik"))
+	})
+
+	t.Run("testConsistent", func(t *testing.T) {
+		for _, flags := range []int{ASCII_CASE_INSENSITIVE, UNICODE_CASE_INSENSITIVE, TURKIC_CASE_INSENSITIVE} {
+			for _, pattern := range []string{"a", "A", "i", "I", "ς", "K"} {
+				re := MustNewRegExp(pattern, WithMatchFlags(flags))
+				s, ok := re.ToRangeSet()
+				assert.True(t, ok)
+				a := re.MustToAutomaton()
+				for _, c := range "aAiIİıςσΣkK\u212a" {
+					assert.Equal(t, Run(a, string(c)), s.Contains(int(c)), "%q %x %q", pattern, flags, c)
+				}
+			}
+		}
+	})
+
+	t.Run("testInlineFlagsClear", func(t *testing.T) {
+		a := compile("a(?-i)b", UNICODE_CASE_INSENSITIVE)
+		assert.True(t, Run(a, "Ab"))
+		assert.False(t, Run(a, "AB"))
+	})
+}
+
+func TestLazyMinimize(t *testing.T) {
+	r := rand.New(rand.NewSource(1614))
+	for i := 0; i < 200; i++ {
+		pattern := randomRegexp(r, 1+r.Intn(3))
+		re := MustNewRegExp(pattern)
+		eager, err := re.ToAutomaton()
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+		lazy, err := re.ToAutomaton(WithLazyMinimize())
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+		assert.True(t, lazy.IsDeterministic(), pattern)
+		assert.Equal(t, eager.GetNumStates(), lazy.GetNumStates(), pattern)
+		diff, err := DiffLanguages(eager, lazy, 1)
+		assert.Nil(t, err)
+		assert.True(t, diff.Empty(), "%q: %s", pattern, diff)
+	}
+
+	// The result is still checked against the state limit:
+	_, err := MustNewRegExp("(a|b)c{2,4}").ToAutomaton(WithLazyMinimize(), WithMaxStates(5))
+	assert.ErrorIs(t, err, ErrTooManyStates)
+}
+
+func TestComplementBinary(t *testing.T) {
+	bin, err := MakeBinary([]byte("ab"))
+	assert.Nil(t, err)
+	re, err := NewRegExp("~<bin>", WithSyntaxFlags(ALL))
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton(WithAutomata(map[string]*Automaton{"bin": bin}))
+	assert.Nil(t, err)
+	assert.Equal(t, ALPHABET_BINARY, a.Alphabet())
+	assert.Equal(t, 0xFF, a.MaxLabel())
+
+	state, err := a.StepBytes(0, []byte("ab"))
+	assert.Nil(t, err)
+	assert.False(t, a.IsAccept(state))
+	state, err = a.StepBytes(0, []byte{0xFF, 'a'})
+	assert.Nil(t, err)
+	assert.True(t, a.IsAccept(state))
+
+	// The complement of a unicode expression is still over code points:
+	a = MustNewRegExp("~(ab)", WithSyntaxFlags(ALL)).MustToAutomaton()
+	assert.Equal(t, ALPHABET_UNICODE, a.Alphabet())
+	assert.True(t, Run(a, "aĀ"))
+}
+
+func BenchmarkToAutomaton(b *testing.B) {
+	// Every alternative is a concatenation, which is minimized on its own unless minimizing is lazy:
+	words := make([]string, 0, 500)
+	r := rand.New(rand.NewSource(1614))
+	for i := 0; i < cap(words); i++ {
+		words = append(words, randomString(r, 6)+"x+"+randomString(r, 6))
+	}
+	re := MustNewRegExp("(" + strings.Join(words, "|") + ")[0-9]*")
+
+	for _, mode := range []struct {
+		name    string
+		options []ToAutomatonOptions
+	}{
+		{"eager", nil},
+		{"lazy", []ToAutomatonOptions{WithLazyMinimize()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := re.ToAutomaton(mode.options...); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
 //func TestNewRegExp(t *testing.T) {
 //	regExp, err := NewRegExp("+-*(A|.....|BC)*]", WithSyntaxFlags(NONE))
 //	assert.Nil(t, err)
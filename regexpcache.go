@@ -0,0 +1,120 @@
+package automaton
+
+import (
+	"container/list"
+	"sync"
+	"unicode"
+)
+
+// RegExpCache A concurrency-safe LRU cache of compiled regular expressions, so hot patterns skip parsing,
+// determinization and minimization. Entries are keyed by pattern, syntax flags, match flags and determinize
+// work limit. The cached automata are frozen (see Automaton.Freeze) since they are shared by all callers.
+type RegExpCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List // of *regExpCacheEntry, most recently used first
+	entries    map[regExpCacheKey]*list.Element
+}
+
+type regExpCacheKey struct {
+	pattern              string
+	syntaxFlags          int
+	matchFlags           int
+	determinizeWorkLimit int
+}
+
+type regExpCacheEntry struct {
+	key regExpCacheKey
+	a   *Automaton
+
+	// Built on first use by RunAutomaton.
+	run *RunAutomaton
+}
+
+// NewRegExpCache Creates a cache holding at most maxEntries compiled patterns; the least recently used
+// pattern is evicted when it is full. A maxEntries <= 0 means no limit.
+func NewRegExpCache(maxEntries int) *RegExpCache {
+	return &RegExpCache{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[regExpCacheKey]*list.Element),
+	}
+}
+
+// Automaton Returns the (minimal, deterministic, frozen) automaton of the given pattern, compiling it on a
+// cache miss.
+func (c *RegExpCache) Automaton(pattern string, syntaxFlags, matchFlags, determinizeWorkLimit int) (*Automaton, error) {
+	entry, err := c.get(regExpCacheKey{pattern, syntaxFlags, matchFlags, determinizeWorkLimit})
+	if err != nil {
+		return nil, err
+	}
+	return entry.a, nil
+}
+
+// RunAutomaton Returns a RunAutomaton over the full Unicode alphabet for the given pattern, compiling it on a
+// cache miss.
+func (c *RegExpCache) RunAutomaton(pattern string, syntaxFlags, matchFlags, determinizeWorkLimit int) (*RunAutomaton, error) {
+	entry, err := c.get(regExpCacheKey{pattern, syntaxFlags, matchFlags, determinizeWorkLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	run := entry.run
+	c.mu.Unlock()
+	if run != nil {
+		return run, nil
+	}
+
+	run = NewRunAutomaton(entry.a, unicode.MaxRune+1, determinizeWorkLimit)
+	c.mu.Lock()
+	if entry.run == nil {
+		entry.run = run
+	}
+	run = entry.run
+	c.mu.Unlock()
+	return run, nil
+}
+
+// Len Returns the number of cached patterns.
+func (c *RegExpCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *RegExpCache) get(key regExpCacheKey) (*regExpCacheEntry, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		c.mu.Unlock()
+		return e.Value.(*regExpCacheEntry), nil
+	}
+	c.mu.Unlock()
+
+	// Compile without holding the lock; concurrent misses on the same key may compile twice, the first one
+	// to finish wins.
+	re, err := NewRegExp(key.pattern, WithSyntaxFlags(key.syntaxFlags), WithMatchFlags(key.matchFlags))
+	if err != nil {
+		return nil, err
+	}
+	a, err := re.toAutomaton(key.determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
+	entry := &regExpCacheEntry{key: key, a: a.Freeze()}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(e)
+		return e.Value.(*regExpCacheEntry), nil
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	if c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*regExpCacheEntry).key)
+	}
+	return entry, nil
+}
@@ -0,0 +1,78 @@
+package automaton
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegExpCache(t *testing.T) {
+	c := NewRegExpCache(2)
+
+	a1, err := c.Automaton("ab+", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.True(t, a1.IsFrozen())
+	assert.True(t, Run(a1, "abbb"))
+	a2, err := c.Automaton("ab+", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.Same(t, a1, a2)
+
+	// Flags and work limit are part of the key:
+	a3, err := c.Automaton("ab+", NONE, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.NotSame(t, a1, a3)
+	assert.Equal(t, 2, c.Len())
+
+	// "ab+" with NONE was used last, so "ab+" with ALL is evicted:
+	_, err = c.Automaton("c", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, c.Len())
+	a4, err := c.Automaton("ab+", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.NotSame(t, a1, a4)
+	a5, err := c.Automaton("c", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	assert.True(t, Run(a5, "c"))
+
+	_, err = c.Automaton("(", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Error(t, err)
+	assert.Equal(t, 2, c.Len())
+
+	t.Run("testRunAutomaton", func(t *testing.T) {
+		r1, err := c.RunAutomaton("[a-c]*é", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, (&runMatcher{r: r1}).Run("abcé"))
+		assert.False(t, (&runMatcher{r: r1}).Run("abc"))
+		r2, err := c.RunAutomaton("[a-c]*é", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Same(t, r1, r2)
+
+		// The empty language has no states at all:
+		r3, err := c.RunAutomaton("#", ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.False(t, (&runMatcher{r: r3}).Run(""))
+		assert.False(t, (&runMatcher{r: r3}).Run("#"))
+	})
+
+	t.Run("testConcurrent", func(t *testing.T) {
+		c := NewRegExpCache(8)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					pattern := "x{" + strconv.Itoa((i+j)%12) + "}"
+					r, err := c.RunAutomaton(pattern, ALL, 0, DEFAULT_DETERMINIZE_WORK_LIMIT)
+					if assert.Nil(t, err) {
+						assert.False(t, (&runMatcher{r: r}).Run("y"))
+					}
+				}
+			}(i)
+		}
+		wg.Wait()
+		assert.Equal(t, 8, c.Len())
+	})
+}
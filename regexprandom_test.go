@@ -0,0 +1,201 @@
+package automaton
+
+import (
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Ported from Lucene's TestRegexpRandom2, TestAutomaton and TestOperations: random patterns and operations are
+// cross-checked by membership of random strings, using the standard library's regexp engine and a brute
+// force NFA simulation as the references.
+
+const randomAlphabet = "abc"
+
+// Returns a random pattern in the syntax shared by RegExp and the standard library's regexp package.
+func randomRegexp(r *rand.Rand, depth int) string {
+	if depth <= 0 {
+		return randomRegexpLeaf(r)
+	}
+	switch r.Intn(9) {
+	case 0:
+		return randomRegexp(r, depth-1) + "|" + randomRegexp(r, depth-1)
+	case 1, 2:
+		return randomRegexp(r, depth-1) + randomRegexp(r, depth-1)
+	case 3:
+		return "(" + randomRegexp(r, depth-1) + ")*"
+	case 4:
+		return "(" + randomRegexp(r, depth-1) + ")+"
+	case 5:
+		return "(" + randomRegexp(r, depth-1) + ")?"
+	case 6:
+		n := r.Intn(3)
+		m := n + r.Intn(3)
+		return "(" + randomRegexp(r, depth-1) + "){" + strconv.Itoa(n) + "," + strconv.Itoa(m) + "}"
+	case 7:
+		return "(" + randomRegexp(r, depth-1) + "){" + strconv.Itoa(r.Intn(3)) + "}"
+	default:
+		return "(" + randomRegexp(r, depth-1) + ")"
+	}
+}
+
+func randomRegexpLeaf(r *rand.Rand) string {
+	switch r.Intn(6) {
+	case 0:
+		return "."
+	case 1:
+		return "[a-b]"
+	case 2:
+		return "[^a]"
+	default:
+		return string(randomAlphabet[r.Intn(len(randomAlphabet))])
+	}
+}
+
+func randomString(r *rand.Rand, maxLength int) string {
+	b := new(strings.Builder)
+	n := r.Intn(maxLength + 1)
+	for i := 0; i < n; i++ {
+		if r.Intn(20) == 0 {
+			b.WriteRune('d')
+		} else {
+			b.WriteByte(randomAlphabet[r.Intn(len(randomAlphabet))])
+		}
+	}
+	return b.String()
+}
+
+// Runs the automaton by simulating all states in parallel, so it works on non-deterministic automata too.
+func runNFA(a *Automaton, s string) bool {
+	if a.GetNumStates() == 0 {
+		return false
+	}
+	current := map[int]struct{}{0: {}}
+	t := NewTransition()
+	for _, ch := range s {
+		next := make(map[int]struct{})
+		for state := range current {
+			count := a.InitTransition(state, t)
+			for i := 0; i < count; i++ {
+				a.GetNextTransition(t)
+				if t.Min <= int(ch) && int(ch) <= t.Max {
+					next[t.Dest] = struct{}{}
+				}
+			}
+		}
+		current = next
+	}
+	for state := range current {
+		if a.IsAccept(state) {
+			return true
+		}
+	}
+	return false
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+func TestRegexpRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	for i := 0; i < 300; i++ {
+		pattern := randomRegexp(r, 1+r.Intn(3))
+		expected := regexp.MustCompile("^(?s:" + pattern + ")$")
+
+		re, err := NewRegExp(pattern)
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+		a, err := re.ToAutomaton()
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+		d, err := determinize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		if !assert.Nil(t, err, pattern) {
+			continue
+		}
+
+		for j := 0; j < 30; j++ {
+			s := randomString(r, 8)
+			want := expected.MatchString(s)
+			if !assert.Equal(t, want, runNFA(a, s), "pattern=%q s=%q", pattern, s) {
+				break
+			}
+			if !assert.Equal(t, want, Run(d, s), "pattern=%q s=%q", pattern, s) {
+				break
+			}
+		}
+	}
+}
+
+func TestOperationsRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(7))
+	randomAutomaton := func() (string, *Automaton) {
+		pattern := randomRegexp(r, 1+r.Intn(3))
+		re, err := NewRegExp(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a, err := re.ToAutomaton()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pattern, a
+	}
+
+	for i := 0; i < 100; i++ {
+		p1, a1 := randomAutomaton()
+		p2, a2 := randomAutomaton()
+
+		u, err := union(a1, a2)
+		assert.Nil(t, err)
+		c, err := concatenate(a1, a2)
+		assert.Nil(t, err)
+		o, err := optional(a1)
+		assert.Nil(t, err)
+		rev, err := reverse(a1)
+		assert.Nil(t, err)
+		det, err := determinize(u, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, det.IsDeterministic())
+		min, err := Minimize(c, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		comp, err := complement(a1, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		inter, err := intersection(a1, a2)
+		assert.Nil(t, err)
+
+		for j := 0; j < 30; j++ {
+			s := randomString(r, 8)
+			in1 := runNFA(a1, s)
+			in2 := runNFA(a2, s)
+			msg := []any{"p1=%q p2=%q s=%q", p1, p2, s}
+
+			assert.Equal(t, in1 || in2, runNFA(u, s), msg...)
+			assert.Equal(t, in1 || in2, Run(det, s), msg...)
+			assert.Equal(t, s == "" || in1, runNFA(o, s), msg...)
+			assert.Equal(t, in1, runNFA(rev, reverseString(s)), msg...)
+			assert.Equal(t, !in1, Run(comp, s), msg...)
+			assert.Equal(t, in1 && in2, runNFA(inter, s), msg...)
+
+			concatenated := false
+			for k := 0; k <= len(s); k++ {
+				if runNFA(a1, s[:k]) && runNFA(a2, s[k:]) {
+					concatenated = true
+					break
+				}
+			}
+			assert.Equal(t, concatenated, runNFA(c, s), msg...)
+			assert.Equal(t, concatenated, Run(min, s), msg...)
+		}
+	}
+}
@@ -1,6 +1,15 @@
 package automaton
 
-// RunAutomaton Finite-state automaton with fast run operation. The initial state is always 0.
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// RunAutomaton Finite-state automaton with fast run operation. The initial state is always 0. A RunAutomaton is
+// never modified after construction, except for its state counter, so Step, StepClass, IsAccept and
+// GetCharClass may be called from any number of goroutines at once.
 type RunAutomaton struct {
 	automaton    *Automaton
 	alphabetSize int
@@ -16,11 +25,15 @@ type RunAutomaton struct {
 
 	// map from char number to class
 	classmap []int
+
+	// Optional counter of the states visited by matches, see SetStateCounter.
+	counter atomic.Pointer[StateCounter]
 }
 
 func NewRunAutomaton(a *Automaton, alphabetSize, determinizeWorkLimit int) *RunAutomaton {
 	size := max(1, a.GetNumStates())
-	points := a.GetStartPoints()
+	partition := a.AlphabetPartition()
+	points := partition.Points()
 
 	r := RunAutomaton{
 		automaton:    DeterminizeAutomaton(a, determinizeWorkLimit),
@@ -36,15 +49,18 @@ func NewRunAutomaton(a *Automaton, alphabetSize, determinizeWorkLimit int) *RunA
 		r.transitions[i] = -1
 	}
 
-	transition := &Transition{}
+	transition := NewTransition()
 
-	for n := 0; n < size; n++ {
+	// size is at least 1, so the empty automaton (no states) gets a single rejecting state without transitions.
+	// Every transition spans whole classes of the partition:
+	for n := 0; n < a.GetNumStates(); n++ {
 		r.accept[n] = a.IsAccept(n)
-		transition.Source = n
-		transition.TransitionUpto = -1
-		for c := 0; c < len(r.points); c++ {
-			dest := a.Next(transition, r.points[c])
-			r.transitions[n*len(r.points)+c] = dest
+		count := a.InitTransition(n, transition)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(transition)
+			for c := partition.Class(transition.Min); c <= partition.Class(transition.Max); c++ {
+				r.transitions[n*len(r.points)+c] = transition.Dest
+			}
 		}
 	}
 
@@ -59,6 +75,18 @@ func NewRunAutomaton(a *Automaton, alphabetSize, determinizeWorkLimit int) *RunA
 	return &r
 }
 
+// SetStateCounter Makes the matches run by ByteRunAutomaton.Run and the Matcher of CompileMatcher count the
+// states they visit in the given counter, or stops counting if it is nil. Counting slows matching down, and
+// disables the pair table of a ByteRunAutomaton; it may be switched on and off while matches run. Returns an
+// error, leaving the current counter in place, if the counter was not created for an automaton of this size.
+func (r *RunAutomaton) SetStateCounter(counter *StateCounter) error {
+	if counter != nil && len(counter.counts) != r.size {
+		return fmt.Errorf("state counter has %d states but the automaton has %d", len(counter.counts), r.size)
+	}
+	r.counter.Store(counter)
+	return nil
+}
+
 // GetSize Returns number of states in automaton.
 func (r *RunAutomaton) GetSize() int {
 	return r.size
@@ -103,3 +131,27 @@ func (r *RunAutomaton) Step(state int, c int) int {
 	}
 	return r.transitions[state*len(r.points)+r.classmap[c]]
 }
+
+// StepClass Returns the state obtained by reading a char of the given character class (as returned by
+// GetCharClass) from the given state. Returns -1 if not obtaining any such state.
+func (r *RunAutomaton) StepClass(state int, class int) int {
+	return r.transitions[state*len(r.points)+class]
+}
+
+// RunReader Returns true if the code points read from rr are accepted, stopping at io.EOF or as soon as every
+// continuation is rejected; see RunReader. Returns any other error of rr.
+func (r *RunAutomaton) RunReader(rr io.RuneReader) (bool, error) {
+	p := 0
+	for {
+		c, _, err := rr.ReadRune()
+		if errors.Is(err, io.EOF) {
+			return r.IsAccept(p), nil
+		}
+		if err != nil {
+			return false, err
+		}
+		if p = r.Step(p, int(c)); p == -1 {
+			return false, nil
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package automaton
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Run with -race: matchers and frozen automata are shared by goroutines without locking.
+func TestRunAutomaton_Concurrent(t *testing.T) {
+	re, err := NewRegExp("(ab|c)*d?é")
+	assert.Nil(t, err)
+	a, err := re.ToAutomaton()
+	assert.Nil(t, err)
+	a.Freeze()
+
+	r := NewRunAutomaton(a, 0x110000, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	utf8, err := UTF32ToUTF8(a)
+	assert.Nil(t, err)
+	b, err := NewByteRunAutomaton(utf8, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	assert.Nil(t, err)
+	b.BuildPairTable(DEFAULT_PAIR_TABLE_MAX_BYTES)
+	m := &runMatcher{r: r}
+
+	inputs := map[string]bool{"é": true, "abcé": true, "abcdé": true, "ab": false, "acé": false}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for s, want := range inputs {
+					assert.Equal(t, want, m.Run(s), s)
+					assert.Equal(t, want, b.Run([]byte(s)), s)
+					assert.Equal(t, want, Run(a, s), s)
+				}
+			}
+			// Operations only read their (shared) inputs:
+			d, err := Minimize(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+			assert.True(t, Run(d, "abé"))
+			u, err := union(a, a)
+			assert.Nil(t, err)
+			assert.True(t, runNFA(u, "cé"))
+			_ = NewRunAutomaton(a, 0x110000, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRunAutomaton_RunReader(t *testing.T) {
+	a := MustNewRegExp("[a-c]+é?").MustToAutomaton()
+	r := NewRunAutomaton(a, 0x110000, DEFAULT_DETERMINIZE_WORK_LIMIT)
+	for s, want := range map[string]bool{"": false, "abc": true, "cé": true, "éa": false, "abcéé": false} {
+		ok, err := r.RunReader(strings.NewReader(s))
+		assert.Nil(t, err)
+		assert.Equal(t, want, ok, s)
+	}
+
+	// Reading stops at the first rejected code point:
+	input := strings.NewReader("abd" + strings.Repeat("a", 100))
+	ok, err := r.RunReader(input)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 100, input.Len())
+}
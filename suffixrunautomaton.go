@@ -0,0 +1,61 @@
+package automaton
+
+import "unicode/utf8"
+
+// SuffixRunAutomaton Matches strings from their end, by running the reverse of an automaton over their code
+// points backwards. This finds the suffixes of a string that are accepted ("ends with" matching) in a single
+// backward pass. Like RunAutomaton it is never modified after construction and may be used from any number of
+// goroutines at once.
+type SuffixRunAutomaton struct {
+	r *RunAutomaton
+}
+
+// NewSuffixRunAutomaton Builds a SuffixRunAutomaton for the strings accepted by a, determinizing its reverse
+// with the given work limit.
+func NewSuffixRunAutomaton(a *Automaton, determinizeWorkLimit int) (*SuffixRunAutomaton, error) {
+	reversed, err := reverse(a)
+	if err != nil {
+		return nil, err
+	}
+	reversed, err = determinize(reversed, determinizeWorkLimit)
+	if err != nil {
+		return nil, err
+	}
+	return &SuffixRunAutomaton{r: NewRunAutomaton(reversed, 0x110000, determinizeWorkLimit)}, nil
+}
+
+// RunReverse Returns true if the original automaton accepts s, reading s from its last code point to its
+// first.
+func (m *SuffixRunAutomaton) RunReverse(s string) bool {
+	state := 0
+	for i := len(s); i > 0; {
+		c, size := utf8.DecodeLastRuneInString(s[:i])
+		state = m.r.Step(state, int(c))
+		if state == -1 {
+			return false
+		}
+		i -= size
+	}
+	return m.r.IsAccept(state)
+}
+
+// LongestMatchingSuffix Returns the length in bytes of the longest suffix of s accepted by the original
+// automaton, or -1 if no suffix (not even the empty one) is accepted. Scanning stops as soon as no longer
+// suffix can be accepted.
+func (m *SuffixRunAutomaton) LongestMatchingSuffix(s string) int {
+	longest := -1
+	state := 0
+	for i := len(s); ; {
+		if m.r.IsAccept(state) {
+			longest = len(s) - i
+		}
+		if i == 0 {
+			return longest
+		}
+		c, size := utf8.DecodeLastRuneInString(s[:i])
+		if state = m.r.Step(state, int(c)); state == -1 {
+			return longest
+		}
+		i -= size
+	}
+}
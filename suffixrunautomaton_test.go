@@ -0,0 +1,61 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuffixRunAutomaton(t *testing.T) {
+	t.Run("testLongestMatchingSuffix", func(t *testing.T) {
+		a := MustNewRegExp("(ab)+|世界").MustToAutomaton()
+		m, err := NewSuffixRunAutomaton(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+
+		assert.True(t, m.RunReverse("abab"))
+		assert.False(t, m.RunReverse("aba"))
+		assert.False(t, m.RunReverse(""))
+		assert.Equal(t, 6, m.LongestMatchingSuffix("xababab"))
+		assert.Equal(t, 6, m.LongestMatchingSuffix("hello 世界"))
+		assert.Equal(t, -1, m.LongestMatchingSuffix("aba"))
+		assert.Equal(t, -1, m.LongestMatchingSuffix(""))
+
+		m, err = NewSuffixRunAutomaton(MustNewRegExp("a*").MustToAutomaton(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, m.LongestMatchingSuffix("ab"))
+		assert.Equal(t, 2, m.LongestMatchingSuffix("baa"))
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		m, err := NewSuffixRunAutomaton(MakeEmpty(), DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.False(t, m.RunReverse(""))
+		assert.Equal(t, -1, m.LongestMatchingSuffix("abc"))
+	})
+
+	t.Run("testRandom", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1607))
+		for i := 0; i < 50; i++ {
+			re := randomRegexp(r, 3)
+			a, err := MustNewRegExp(re).ToAutomaton()
+			if err != nil {
+				continue
+			}
+			m, err := NewSuffixRunAutomaton(a, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+			for j := 0; j < 20; j++ {
+				s := randomString(r, 6)
+				longest := -1
+				for k := 0; k <= len(s); k++ {
+					if Run(a, s[k:]) {
+						longest = len(s) - k
+						break
+					}
+				}
+				assert.Equal(t, Run(a, s), m.RunReverse(s), "%q %q", re, s)
+				assert.Equal(t, longest, m.LongestMatchingSuffix(s), "%q %q", re, s)
+			}
+		}
+	})
+}
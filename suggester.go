@@ -0,0 +1,129 @@
+package automaton
+
+import "errors"
+
+// Suggester Suggests completions of a typed prefix from a fixed dictionary of terms. The dictionary is stored as
+// a minimal deterministic automaton; a lookup intersects it with an automaton accepting every string that
+// starts with the prefix (or, for fuzzy lookups, with something within one edit of the prefix) and walks the
+// intersection lazily, in code point order, stopping as soon as enough completions were found. It is safe for
+// concurrent use.
+type Suggester struct {
+	dict *Automaton
+}
+
+// NewSuggester Builds a suggester over the given terms.
+func NewSuggester(terms []string) (*Suggester, error) {
+	automata := make([]*Automaton, 0, len(terms))
+	for _, term := range terms {
+		a, err := defaultAutomata.MakeString(term)
+		if err != nil {
+			return nil, err
+		}
+		automata = append(automata, a)
+	}
+
+	dict := NewAutomaton()
+	if len(automata) > 0 {
+		a, err := union(automata...)
+		if err != nil {
+			return nil, err
+		}
+		dict, err = Minimize(a, DefaultWorkLimit())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Suggester{dict: dict}, nil
+}
+
+// Suggest Returns the first k terms, in code point order, starting with prefix.
+func (s *Suggester) Suggest(prefix string, k int) ([]string, error) {
+	query, err := s.prefixQuery(prefix, 0)
+	if err != nil {
+		return nil, err
+	}
+	return s.complete(query, k, nil), nil
+}
+
+// SuggestFuzzy Returns up to k terms starting with prefix or, if there are fewer than k of those, with a string
+// within one edit (insertion, deletion or substitution of a character) of prefix. Exact completions come first;
+// each group is in code point order.
+func (s *Suggester) SuggestFuzzy(prefix string, k int) ([]string, error) {
+	exact, err := s.Suggest(prefix, k)
+	if err != nil || len(exact) == k {
+		return exact, err
+	}
+
+	query, err := s.prefixQuery(prefix, 1)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(exact))
+	for _, term := range exact {
+		seen[term] = struct{}{}
+	}
+	return append(exact, s.complete(query, k-len(exact), seen)...), nil
+}
+
+// Returns a deterministic automaton accepting all strings that start with a string within maxEdits of prefix.
+func (s *Suggester) prefixQuery(prefix string, maxEdits int) (*Automaton, error) {
+	if maxEdits < 0 {
+		return nil, errors.New("maxEdits must be >= 0")
+	}
+	a, err := makeLevenshteinNFA([]rune(prefix), maxEdits, false)
+	if err != nil {
+		return nil, err
+	}
+	anyString, err := defaultAutomata.MakeAnyString()
+	if err != nil {
+		return nil, err
+	}
+	a, err = concatenate(a, anyString)
+	if err != nil {
+		return nil, err
+	}
+	return determinize(a, DefaultWorkLimit())
+}
+
+// Walks the intersection of the dictionary and the query depth first, in code point order, collecting up to k
+// accepted terms that are not in skip.
+func (s *Suggester) complete(query *Automaton, k int, skip map[string]struct{}) []string {
+	results := make([]string, 0)
+	if k <= 0 || s.dict.GetNumStates() == 0 || query.GetNumStates() == 0 {
+		return results
+	}
+
+	path := make([]rune, 0)
+	t := NewTransition()
+	var walk func(d, q int) bool
+	walk = func(d, q int) bool {
+		if s.dict.IsAccept(d) && query.IsAccept(q) {
+			term := string(path)
+			if _, ok := skip[term]; !ok {
+				results = append(results, term)
+				if len(results) == k {
+					return false
+				}
+			}
+		}
+		count := s.dict.GetNumTransitionsWithState(d)
+		for i := 0; i < count; i++ {
+			s.dict.getTransition(d, i, t)
+			dest, minLabel, maxLabel := t.Dest, t.Min, t.Max
+			for label := minLabel; label <= maxLabel; label++ {
+				next := query.Step(q, label)
+				if next == -1 {
+					continue
+				}
+				path = append(path, rune(label))
+				if !walk(dest, next) {
+					return false
+				}
+				path = path[:len(path)-1]
+			}
+		}
+		return true
+	}
+	walk(0, 0)
+	return results
+}
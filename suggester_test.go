@@ -0,0 +1,53 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuggester(t *testing.T) {
+	s, err := NewSuggester([]string{"cat", "car", "cart", "carbon", "care", "dog", "dot", "cut", "ca"})
+	assert.Nil(t, err)
+
+	suggest := func(prefix string, k int) []string {
+		results, err := s.Suggest(prefix, k)
+		assert.Nil(t, err)
+		return results
+	}
+	assert.Equal(t, []string{"ca", "car", "carbon", "care", "cart", "cat"}, suggest("ca", 10))
+	assert.Equal(t, []string{"ca", "car"}, suggest("ca", 2))
+	assert.Equal(t, []string{"carbon", "care", "cart"}, suggest("car", 10)[1:])
+	assert.Equal(t, []string{"dog", "dot"}, suggest("do", 10))
+	assert.Empty(t, suggest("x", 10))
+	assert.Empty(t, suggest("ca", 0))
+	assert.Len(t, suggest("", 100), 9)
+
+	t.Run("testFuzzy", func(t *testing.T) {
+		results, err := s.SuggestFuzzy("cu", 10)
+		assert.Nil(t, err)
+		// "cut" matches exactly; "ca..." substitute a character, "dog"/"dot" are two edits away.
+		assert.Equal(t, []string{"cut", "ca", "car", "carbon", "care", "cart", "cat"}, results)
+
+		// "do" is one deletion away from "dgo":
+		results, err = s.SuggestFuzzy("dgo", 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"dog", "dot"}, results)
+
+		results, err = s.SuggestFuzzy("carbn", 10)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"carbon"}, results)
+
+		results, err = s.SuggestFuzzy("cu", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"cut"}, results)
+	})
+
+	t.Run("testEmptyDictionary", func(t *testing.T) {
+		empty, err := NewSuggester(nil)
+		assert.Nil(t, err)
+		results, err := empty.SuggestFuzzy("a", 5)
+		assert.Nil(t, err)
+		assert.Empty(t, results)
+	})
+}
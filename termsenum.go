@@ -0,0 +1,228 @@
+package automaton
+
+import (
+	"bytes"
+
+	"github.com/bits-and-blooms/bitset"
+)
+
+// SortedTermSource A source of terms in increasing byte order that can seek, like the term dictionary of an
+// index (Lucene's TermsEnum). The returned terms may be overwritten by the next call.
+type SortedTermSource interface {
+	// SeekCeil Positions the source at the smallest term greater than or equal to target and returns it, or
+	// returns false if there is none.
+	SeekCeil(target []byte) ([]byte, bool)
+
+	// Next Advances the source to the next term and returns it, or returns false if there is none.
+	Next() ([]byte, bool)
+}
+
+// AutomatonTermsEnum Enumerates the terms of a SortedTermSource accepted by a CompiledAutomaton, as Lucene's
+// AutomatonTermsEnum. Instead of testing every term, after each term it computes from the transitions of the
+// automaton the smallest string greater than the term that could be accepted, and seeks the source to it, so
+// only the parts of the dictionary that can match are visited.
+type AutomatonTermsEnum struct {
+	compiled *CompiledAutomaton
+	source   SortedTermSource
+
+	// States from which an accept state can be reached, for NORMAL automata.
+	live *bitset.BitSet
+	// The current term, and the target of the next seek.
+	term, seek []byte
+	// The state reached by each prefix of seek.
+	savedStates []int
+	// Generation in which each state was last visited, to stop at loops.
+	visited    []int
+	curGen     int
+	transition *Transition
+
+	started, done bool
+}
+
+// IntersectTerms Returns an enumeration of the terms of source accepted by the given automaton, in order.
+func IntersectTerms(compiled *CompiledAutomaton, source SortedTermSource) *AutomatonTermsEnum {
+	e := &AutomatonTermsEnum{
+		compiled: compiled,
+		source:   source,
+	}
+	if compiled._type == AUTOMATON_TYPE_NORMAL {
+		e.live = getLiveStatesToAccept(compiled.automaton)
+		e.visited = make([]int, compiled.runAutomaton.GetSize())
+		e.transition = NewTransition()
+	}
+	return e
+}
+
+// Next Returns the next accepted term, or false if there is none. The term may be overwritten by the next
+// call.
+func (e *AutomatonTermsEnum) Next() ([]byte, bool) {
+	if e.done {
+		return nil, false
+	}
+	started := e.started
+	e.started = true
+
+	switch e.compiled._type {
+	case AUTOMATON_TYPE_NONE:
+		return e.end()
+	case AUTOMATON_TYPE_ALL:
+		var term []byte
+		var ok bool
+		if started {
+			term, ok = e.source.Next()
+		} else {
+			term, ok = e.source.SeekCeil(nil)
+		}
+		if !ok {
+			return e.end()
+		}
+		return term, true
+	case AUTOMATON_TYPE_SINGLE:
+		if started {
+			return e.end()
+		}
+		term, ok := e.source.SeekCeil(e.compiled.term)
+		if !ok || !bytes.Equal(term, e.compiled.term) {
+			return e.end()
+		}
+		return term, true
+	}
+
+	for {
+		if !e.nextSeekTerm(!started) {
+			return e.end()
+		}
+		started = true
+		term, ok := e.source.SeekCeil(e.seek)
+		if !ok {
+			return e.end()
+		}
+		e.term = append(e.term[:0], term...)
+		if e.accept(term) {
+			return term, true
+		}
+	}
+}
+
+func (e *AutomatonTermsEnum) end() ([]byte, bool) {
+	e.done = true
+	return nil, false
+}
+
+func (e *AutomatonTermsEnum) accept(term []byte) bool {
+	suffix := e.compiled.commonSuffixRef
+	return (suffix == nil || bytes.HasSuffix(term, suffix)) && e.compiled.runAutomaton.Run(term)
+}
+
+// nextSeekTerm Sets seek to the smallest string that could be accepted and is greater than the current term,
+// or, on the first call, greater than or equal to the empty string. Returns false if there is none.
+func (e *AutomatonTermsEnum) nextSeekTerm(first bool) bool {
+	if first {
+		e.seek = e.seek[:0]
+		if e.compiled.runAutomaton.IsAccept(0) {
+			// The empty string is accepted:
+			return true
+		}
+	} else {
+		e.seek = append(e.seek[:0], e.term...)
+	}
+	return e.nextString()
+}
+
+// nextString Increments seek to the next string that could be accepted.
+func (e *AutomatonTermsEnum) nextString() bool {
+	r := e.compiled.runAutomaton
+	e.savedStates = append(e.savedStates[:0], 0)
+	pos := 0
+	for {
+		e.curGen++
+		// Walk the automaton until a byte is rejected:
+		state := e.savedStates[pos]
+		for ; pos < len(e.seek); pos++ {
+			e.visited[state] = e.curGen
+			next := r.Step(state, int(e.seek[pos]))
+			if next == -1 {
+				break
+			}
+			e.savedStates = append(e.savedStates[:pos+1], next)
+			state = next
+		}
+
+		// Take the matched prefix and attempt to append bytes that lead to a match:
+		if e.nextStringFrom(state, pos) {
+			return true
+		}
+		// No more solutions from this prefix, backtrack:
+		if pos = e.backtrack(pos); pos < 0 {
+			return false
+		}
+		next := r.Step(e.savedStates[pos], int(e.seek[pos]))
+		if next >= 0 && r.IsAccept(next) {
+			// The string is accepted as is:
+			return true
+		}
+	}
+}
+
+// nextStringFrom Replaces the bytes of seek from position on with the smallest string leading from state to
+// an accept state (or a loop) that is greater than them. Returns false if there is none.
+func (e *AutomatonTermsEnum) nextStringFrom(state, position int) bool {
+	a := e.compiled.automaton
+	c := 0
+	if position < len(e.seek) {
+		// The next byte must be greater than the existing one:
+		c = int(e.seek[position]) + 1
+		if c > 0xFF {
+			return false
+		}
+	}
+	e.seek = e.seek[:position]
+	e.visited[state] = e.curGen
+
+	t := e.transition
+	count := a.InitTransition(state, t)
+	for i := 0; i < count; i++ {
+		a.GetNextTransition(t)
+		if t.Max < c || !e.live.Test(uint(t.Dest)) {
+			continue
+		}
+		e.seek = append(e.seek, byte(max(c, t.Min)))
+		state = t.Dest
+
+		// Follow the smallest transitions until an accept state or a loop:
+		for e.visited[state] != e.curGen && !e.compiled.runAutomaton.IsAccept(state) {
+			e.visited[state] = e.curGen
+			state = e.firstLiveTransition(state)
+			e.seek = append(e.seek, byte(t.Min))
+		}
+		return true
+	}
+	return false
+}
+
+// firstLiveTransition Loads the smallest transition of state leading to a live state into e.transition and
+// returns its destination. The state must be live and not an accept state, so there is one.
+func (e *AutomatonTermsEnum) firstLiveTransition(state int) int {
+	a := e.compiled.automaton
+	count := a.InitTransition(state, e.transition)
+	for i := 0; i < count; i++ {
+		a.GetNextTransition(e.transition)
+		if e.live.Test(uint(e.transition.Dest)) {
+			break
+		}
+	}
+	return e.transition.Dest
+}
+
+// backtrack Increments the last byte of seek before position that is not 0xFF, dropping the bytes after it.
+// Returns its position, or -1 if there is none.
+func (e *AutomatonTermsEnum) backtrack(position int) int {
+	for position--; position >= 0; position-- {
+		if e.seek[position] != 0xFF {
+			e.seek[position]++
+			e.seek = e.seek[:position+1]
+			return position
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package automaton
+
+import (
+	"bytes"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sliceTermSource A SortedTermSource over a sorted slice, counting the terms it visits.
+type sliceTermSource struct {
+	terms   [][]byte
+	pos     int
+	visited int
+}
+
+func newSliceTermSource(terms ...string) *sliceTermSource {
+	slices.Sort(terms)
+	terms = slices.Compact(terms)
+	s := &sliceTermSource{pos: -1}
+	for _, term := range terms {
+		s.terms = append(s.terms, []byte(term))
+	}
+	return s
+}
+
+func (s *sliceTermSource) SeekCeil(target []byte) ([]byte, bool) {
+	s.pos = sort.Search(len(s.terms), func(i int) bool {
+		return bytes.Compare(s.terms[i], target) >= 0
+	})
+	return s.current()
+}
+
+func (s *sliceTermSource) Next() ([]byte, bool) {
+	s.pos++
+	return s.current()
+}
+
+func (s *sliceTermSource) current() ([]byte, bool) {
+	if s.pos >= len(s.terms) {
+		return nil, false
+	}
+	s.visited++
+	return s.terms[s.pos], true
+}
+
+func intersectTerms(t *testing.T, pattern string, simplify bool, source *sliceTermSource) []string {
+	a, err := MustNewRegExp(pattern).ToAutomaton()
+	assert.Nil(t, err)
+	compiled, err := NewCompiledAutomaton(a, nil, simplify, DEFAULT_DETERMINIZE_WORK_LIMIT, false)
+	assert.Nil(t, err)
+
+	var terms []string
+	e := IntersectTerms(compiled, source)
+	for term, ok := e.Next(); ok; term, ok = e.Next() {
+		terms = append(terms, string(term))
+	}
+	_, ok := e.Next()
+	assert.False(t, ok)
+	return terms
+}
+
+func TestIntersectTerms(t *testing.T) {
+	t.Run("normal", func(t *testing.T) {
+		source := newSliceTermSource("", "a", "ab", "abc", "abd", "b", "bar", "baz", "foo", "föo", "zzz")
+		assert.Equal(t, []string{"ab", "abc", "abd", "bar", "baz"}, intersectTerms(t, "ab.?|ba.", false, source))
+		assert.Equal(t, []string{"", "foo", "föo"}, intersectTerms(t, "(f.o)?", false, source))
+	})
+
+	t.Run("simplified", func(t *testing.T) {
+		source := newSliceTermSource("a", "ab", "b")
+		assert.Equal(t, []string{"a", "ab", "b"}, intersectTerms(t, ".*", true, source))
+		assert.Equal(t, []string{"ab"}, intersectTerms(t, "ab", true, source))
+		assert.Empty(t, intersectTerms(t, "abc", true, source))
+		assert.Empty(t, intersectTerms(t, "#", true, source))
+	})
+
+	t.Run("seeks", func(t *testing.T) {
+		var terms []string
+		for i := 0; i < 26*26; i++ {
+			terms = append(terms, string([]byte{byte('a' + i/26), byte('a' + i%26)}))
+		}
+		source := newSliceTermSource(terms...)
+		assert.Equal(t, []string{"ka", "kb", "xa", "xb"}, intersectTerms(t, "[kx][ab]", false, source))
+		// Only the matches and the terms right after them are visited:
+		assert.Less(t, source.visited, 10)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		r := rand.New(rand.NewSource(1599))
+		for i := 0; i < 200; i++ {
+			pattern := randomRegexp(r, 3)
+			var terms []string
+			for j := 0; j < 50; j++ {
+				terms = append(terms, randomString(r, 6))
+			}
+			source := newSliceTermSource(terms...)
+
+			a := MustNewRegExp(pattern).MustToAutomaton()
+			var expected []string
+			for _, term := range source.terms {
+				if runNFA(a, string(term)) {
+					expected = append(expected, string(term))
+				}
+			}
+			assert.Equal(t, expected, intersectTerms(t, pattern, r.Intn(2) == 0, source), pattern)
+		}
+	})
+}
@@ -0,0 +1,267 @@
+package automaton
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DEFAULT_MAX_REGEXP_LENGTH Default limit on the length of the pattern built by ToRegExp.
+const DEFAULT_MAX_REGEXP_LENGTH = 1 << 16
+
+// ErrRegExpTooLong Returned (wrapped) by ToRegExp when the pattern exceeds the length limit.
+var ErrRegExpTooLong = errors.New("regexp too long")
+
+// A character class matching no label.
+const regExpEmptyLanguage = `[^\x00-\x{10FFFF}]`
+
+// ToRegExp
+// Returns a pattern, in the syntax of the standard library's regexp package, matching the language of the given
+// automaton. The pattern is not anchored: match it in full with "^(?:" + pattern + ")$". It is built by state
+// elimination, whose output can grow exponentially with the number of states, so this is meant for small
+// automata; once any intermediate expression is longer than maxLength the conversion stops with an error
+// wrapping ErrRegExpTooLong. A maxLength <= 0 means no limit. Labels are written as code points, so a binary
+// automaton with labels above 0x7F, which the regexp package would match as UTF-8, is rejected.
+func ToRegExp(a *Automaton, maxLength int) (string, error) {
+	a, err := RemoveDeadStates(a)
+	if err != nil {
+		return "", err
+	}
+	if a.GetNumStates() == 0 {
+		return regExpEmptyLanguage, nil
+	}
+
+	// Generalized NFA: the states of the automaton plus a new initial and final state, with edges labeled by
+	// expressions. A nil expression is the empty language.
+	numStates := a.GetNumStates()
+	initial, final := numStates, numStates+1
+	g := newRegExpGraph(numStates + 2)
+	g.add(initial, 0, regExpEmptyString)
+	for s := 0; s < numStates; s++ {
+		if a.IsAccept(s) {
+			g.add(s, final, regExpEmptyString)
+		}
+		// Group the ranges leaving s by destination into one character class each:
+		ranges := make(map[int][]int)
+		dests := make([]int, 0)
+		for _, t := range a.TransitionsSlice(s) {
+			if a.Alphabet() == ALPHABET_BINARY && t.Max > 0x7F {
+				return "", fmt.Errorf("binary label %d can not be expressed in a regexp", t.Max)
+			}
+			if _, ok := ranges[t.Dest]; !ok {
+				dests = append(dests, t.Dest)
+			}
+			ranges[t.Dest] = append(ranges[t.Dest], t.Min, t.Max)
+		}
+		for _, dest := range dests {
+			g.add(s, dest, regExpCharClass(ranges[dest]))
+		}
+	}
+
+	// Eliminate the states of the automaton, cheapest first, until only initial -> final is left:
+	remaining := make([]int, numStates)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	for len(remaining) > 0 {
+		best := 0
+		for i, s := range remaining {
+			if g.cost(s) < g.cost(remaining[best]) {
+				best = i
+			}
+		}
+		if err := g.eliminate(remaining[best], maxLength); err != nil {
+			return "", err
+		}
+		remaining = slices.Delete(remaining, best, best+1)
+	}
+
+	r := g.out[initial][final]
+	if r == nil {
+		return regExpEmptyLanguage, nil
+	}
+	return r.s, nil
+}
+
+// Precedence of a regExpPart, from loosest to tightest binding.
+const (
+	regExpPrecUnion = iota
+	regExpPrecConcat
+	regExpPrecRepeat
+	regExpPrecAtom
+)
+
+type regExpPart struct {
+	s    string
+	prec int
+}
+
+var regExpEmptyString = &regExpPart{s: "", prec: regExpPrecAtom}
+
+// Returns the expression with non-capturing parens if it binds looser than prec.
+func (r *regExpPart) wrap(prec int) string {
+	if r.prec < prec {
+		return "(?:" + r.s + ")"
+	}
+	return r.s
+}
+
+func (r *regExpPart) isEmptyString() bool {
+	return r.s == ""
+}
+
+func regExpUnion(r1, r2 *regExpPart) *regExpPart {
+	switch {
+	case r1 == nil:
+		return r2
+	case r2 == nil || r1.s == r2.s:
+		return r1
+	case r1.isEmptyString():
+		return regExpOptional(r2)
+	case r2.isEmptyString():
+		return regExpOptional(r1)
+	}
+	return &regExpPart{s: r1.s + "|" + r2.s, prec: regExpPrecUnion}
+}
+
+func regExpOptional(r *regExpPart) *regExpPart {
+	if r.prec == regExpPrecRepeat {
+		// Only * and ? are emitted, both of which already match the empty string:
+		return r
+	}
+	return &regExpPart{s: r.wrap(regExpPrecAtom) + "?", prec: regExpPrecRepeat}
+}
+
+func regExpConcat(parts ...*regExpPart) *regExpPart {
+	b := new(strings.Builder)
+	n := 0
+	for _, r := range parts {
+		if r == nil {
+			return nil
+		}
+		if r.isEmptyString() {
+			continue
+		}
+		b.WriteString(r.wrap(regExpPrecConcat))
+		n++
+	}
+	switch n {
+	case 0:
+		return regExpEmptyString
+	case 1:
+		for _, r := range parts {
+			if !r.isEmptyString() {
+				return r
+			}
+		}
+	}
+	return &regExpPart{s: b.String(), prec: regExpPrecConcat}
+}
+
+func regExpStar(r *regExpPart) *regExpPart {
+	switch {
+	case r == nil || r.isEmptyString():
+		return regExpEmptyString
+	case r.prec == regExpPrecRepeat && strings.HasSuffix(r.s, "*"):
+		return r
+	}
+	return &regExpPart{s: r.wrap(regExpPrecAtom) + "*", prec: regExpPrecRepeat}
+}
+
+// Returns an expression matching any label in the given (min, max) pairs, sorted by min and not overlapping.
+func regExpCharClass(ranges []int) *regExpPart {
+	if len(ranges) == 2 && ranges[0] == ranges[1] {
+		return &regExpPart{s: regExpChar(ranges[0]), prec: regExpPrecAtom}
+	}
+	b := new(strings.Builder)
+	b.WriteByte('[')
+	for i := 0; i < len(ranges); i += 2 {
+		b.WriteString(regExpChar(ranges[i]))
+		if ranges[i+1] > ranges[i] {
+			b.WriteByte('-')
+			b.WriteString(regExpChar(ranges[i+1]))
+		}
+	}
+	b.WriteByte(']')
+	return &regExpPart{s: b.String(), prec: regExpPrecAtom}
+}
+
+// Returns the label as is if it is an ASCII letter or digit, escaped otherwise.
+func regExpChar(c int) string {
+	if c < unicode.MaxASCII && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))) {
+		return string(rune(c))
+	}
+	return `\x{` + strconv.FormatInt(int64(c), 16) + `}`
+}
+
+// regExpGraph The generalized NFA used by ToRegExp, with edges labeled by expressions.
+type regExpGraph struct {
+	out []map[int]*regExpPart
+	in  []map[int]struct{}
+}
+
+func newRegExpGraph(numStates int) *regExpGraph {
+	g := &regExpGraph{
+		out: make([]map[int]*regExpPart, numStates),
+		in:  make([]map[int]struct{}, numStates),
+	}
+	for i := range g.out {
+		g.out[i] = make(map[int]*regExpPart)
+		g.in[i] = make(map[int]struct{})
+	}
+	return g
+}
+
+// Adds r to the expression labeling the edge from p to q.
+func (g *regExpGraph) add(p, q int, r *regExpPart) {
+	if r == nil {
+		return
+	}
+	g.out[p][q] = regExpUnion(g.out[p][q], r)
+	g.in[q][p] = struct{}{}
+}
+
+// Returns the number of edges eliminating s creates.
+func (g *regExpGraph) cost(s int) int {
+	return len(g.in[s]) * len(g.out[s])
+}
+
+// Removes s, relabeling every edge p -> s -> q as p -> q.
+func (g *regExpGraph) eliminate(s, maxLength int) error {
+	loop := regExpStar(g.out[s][s])
+	delete(g.out[s], s)
+	delete(g.in[s], s)
+
+	sources := make([]int, 0, len(g.in[s]))
+	for p := range g.in[s] {
+		sources = append(sources, p)
+	}
+	targets := make([]int, 0, len(g.out[s]))
+	for q := range g.out[s] {
+		targets = append(targets, q)
+	}
+	// Sort, so the output does not depend on map iteration order:
+	slices.Sort(sources)
+	slices.Sort(targets)
+
+	for _, p := range sources {
+		for _, q := range targets {
+			g.add(p, q, regExpConcat(g.out[p][s], loop, g.out[s][q]))
+			if maxLength > 0 && len(g.out[p][q].s) > maxLength {
+				return fmt.Errorf("%w: > %d", ErrRegExpTooLong, maxLength)
+			}
+		}
+	}
+	for _, p := range sources {
+		delete(g.out[p], s)
+	}
+	for _, q := range targets {
+		delete(g.in[q], s)
+	}
+	g.out[s] = nil
+	g.in[s] = nil
+	return nil
+}
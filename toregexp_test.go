@@ -0,0 +1,79 @@
+package automaton
+
+import (
+	"errors"
+	"math/rand"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToRegExp(t *testing.T) {
+	toRegExp := func(t *testing.T, pattern string) string {
+		re, err := NewRegExp(pattern)
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		s, err := ToRegExp(a, DEFAULT_MAX_REGEXP_LENGTH)
+		assert.Nil(t, err)
+		return s
+	}
+
+	assert.Equal(t, "abc", toRegExp(t, "abc"))
+	assert.Equal(t, "a[b-d]?", toRegExp(t, "a|a[b-d]"))
+	assert.Equal(t, "(?:ab)*", toRegExp(t, "(ab)*"))
+	assert.Equal(t, `\x{2e}[\x{0}-\x{10ffff}]`, toRegExp(t, `\..`))
+
+	t.Run("empty", func(t *testing.T) {
+		s, err := ToRegExp(MakeEmpty(), 0)
+		assert.Nil(t, err)
+		assert.False(t, regexp.MustCompile("^(?:"+s+")$").MatchString(""))
+
+		s, err = ToRegExp(MakeEmptyString(), 0)
+		assert.Nil(t, err)
+		assert.Equal(t, "", s)
+	})
+
+	t.Run("tooLong", func(t *testing.T) {
+		re, err := NewRegExp("((a|b)(c|d)*e)*f")
+		assert.Nil(t, err)
+		a, err := re.ToAutomaton()
+		assert.Nil(t, err)
+		_, err = ToRegExp(a, 5)
+		assert.True(t, errors.Is(err, ErrRegExpTooLong))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		a, err := MakeBinaryRange(0x70, 0x90)
+		assert.Nil(t, err)
+		_, err = ToRegExp(a, 0)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("random", func(t *testing.T) {
+		r := rand.New(rand.NewSource(3))
+		for i := 0; i < 200; i++ {
+			pattern := randomRegexp(r, 1+r.Intn(3))
+			re, err := NewRegExp(pattern)
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+
+			s, err := ToRegExp(a, 0)
+			if !assert.Nil(t, err, pattern) {
+				continue
+			}
+			got, err := regexp.Compile("^(?:" + s + ")$")
+			if !assert.Nil(t, err, "pattern=%q regexp=%q", pattern, s) {
+				continue
+			}
+			for j := 0; j < 30; j++ {
+				str := randomString(r, 8)
+				if !assert.Equal(t, runNFA(a, str), got.MatchString(str), "pattern=%q regexp=%q s=%q", pattern, s, str) {
+					break
+				}
+			}
+		}
+	})
+}
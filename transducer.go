@@ -0,0 +1,224 @@
+package automaton
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Transducer A subsequential transducer: a deterministic automaton whose transitions write an output string
+// for the label they read, and whose accept states may write a final output. Apply rewrites a string by
+// running it through the transducer, and Compose chains two transducers into one, so rules such as
+// transliteration or synonym rewriting can be built step by step and applied in a single pass.
+//
+// Besides a fixed output, a transition may copy its input label (see AddIdentityTransition), so a transition
+// over a whole range of labels can pass them through unchanged.
+type Transducer struct {
+	states []transducerState
+}
+
+type transducerState struct {
+	accept bool
+	final  string
+	// Sorted by min, not overlapping.
+	transitions []transducerTransition
+}
+
+type transducerTransition struct {
+	dest, min, max int
+	output         string
+	// If set, the transition writes its input label instead of output.
+	identity bool
+}
+
+func NewTransducer() *Transducer {
+	return &Transducer{}
+}
+
+// CreateState Create a new state. State 0 is the initial state.
+func (t *Transducer) CreateState() int {
+	t.states = append(t.states, transducerState{})
+	return len(t.states) - 1
+}
+
+// GetNumStates How many states this transducer has.
+func (t *Transducer) GetNumStates() int {
+	return len(t.states)
+}
+
+// SetAccept Set or clear this state as an accept state, with the output written when the input ends in it.
+func (t *Transducer) SetAccept(state int, accept bool, final string) {
+	t.states[state].accept = accept
+	t.states[state].final = final
+}
+
+// IsAccept Returns true if this state is an accept state.
+func (t *Transducer) IsAccept(state int) bool {
+	return t.states[state].accept
+}
+
+// AddTransition Add a new transition reading a label in [min, max] and writing output. Transitions leaving
+// a state may be added in any order, but must not overlap, so the transducer stays deterministic.
+func (t *Transducer) AddTransition(source, dest, min, max int, output string) error {
+	return t.addTransition(source, transducerTransition{dest: dest, min: min, max: max, output: output})
+}
+
+// AddIdentityTransition Add a new transition reading a label in [min, max] and writing that same label.
+func (t *Transducer) AddIdentityTransition(source, dest, min, max int) error {
+	return t.addTransition(source, transducerTransition{dest: dest, min: min, max: max, identity: true})
+}
+
+func (t *Transducer) addTransition(source int, tr transducerTransition) error {
+	if err := checkTransition(source, tr.dest, tr.min, tr.max, len(t.states)); err != nil {
+		return err
+	}
+	if tr.max > unicode.MaxRune {
+		return fmt.Errorf("label %d is not a code point", tr.max)
+	}
+	transitions := t.states[source].transitions
+	i, _ := slices.BinarySearchFunc(transitions, tr.min, func(e transducerTransition, min int) int {
+		return e.min - min
+	})
+	if (i > 0 && transitions[i-1].max >= tr.min) || (i < len(transitions) && transitions[i].min <= tr.max) {
+		return fmt.Errorf("transition [%d, %d] overlaps another transition of state %d", tr.min, tr.max, source)
+	}
+	t.states[source].transitions = slices.Insert(transitions, i, tr)
+	return nil
+}
+
+// step Returns the transition of state reading label, or nil if there is none.
+func (t *Transducer) step(state, label int) *transducerTransition {
+	transitions := t.states[state].transitions
+	i, found := slices.BinarySearchFunc(transitions, label, func(e transducerTransition, label int) int {
+		if e.max < label {
+			return -1
+		}
+		if e.min > label {
+			return 1
+		}
+		return 0
+	})
+	if !found {
+		return nil
+	}
+	return &transitions[i]
+}
+
+// Apply Runs s through the transducer and returns the outputs of the transitions taken followed by the final
+// output of the state reached, or false if s is not accepted.
+func (t *Transducer) Apply(s string) (string, bool) {
+	if len(t.states) == 0 {
+		return "", false
+	}
+	state, output, ok := t.run(0, s)
+	if !ok || !t.states[state].accept {
+		return "", false
+	}
+	return output + t.states[state].final, true
+}
+
+// Domain Returns the (deterministic) automaton accepting the strings the transducer accepts.
+func (t *Transducer) Domain() *Automaton {
+	builder := NewBuilder()
+	for s, state := range t.states {
+		builder.CreateState()
+		builder.SetAccept(s, state.accept)
+	}
+	for s, state := range t.states {
+		for _, tr := range state.transitions {
+			builder.AddTransition(s, tr.dest, tr.min, tr.max)
+		}
+	}
+	return builder.Finish()
+}
+
+// run Runs s from state, returning the state reached and the outputs of the transitions taken, or false if s
+// is rejected on the way.
+func (t *Transducer) run(state int, s string) (int, string, bool) {
+	var sb strings.Builder
+	for _, c := range s {
+		tr := t.step(state, int(c))
+		if tr == nil {
+			return 0, "", false
+		}
+		if tr.identity {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteString(tr.output)
+		}
+		state = tr.dest
+	}
+	return state, sb.String(), true
+}
+
+// Compose Returns the transducer applying t1 and then t2 to the output of t1: it accepts s if t1 accepts s
+// and t2 accepts the output of t1, and its output is that of t2. Only pairs of states reachable together are
+// created.
+func Compose(t1, t2 *Transducer) (*Transducer, error) {
+	result := NewTransducer()
+	if t1.GetNumStates() == 0 || t2.GetNumStates() == 0 {
+		return result, nil
+	}
+
+	type pair struct {
+		s1, s2 int
+	}
+	states := map[pair]int{}
+	var worklist []pair
+	state := func(p pair) int {
+		s, ok := states[p]
+		if !ok {
+			s = result.CreateState()
+			states[p] = s
+			worklist = append(worklist, p)
+		}
+		return s
+	}
+
+	state(pair{0, 0})
+	for len(worklist) > 0 {
+		p := worklist[0]
+		worklist = worklist[1:]
+		source := states[p]
+
+		if t1.states[p.s1].accept {
+			if s2, output, ok := t2.run(p.s2, t1.states[p.s1].final); ok && t2.states[s2].accept {
+				result.SetAccept(source, true, output+t2.states[s2].final)
+			}
+		}
+
+		for _, tr1 := range t1.states[p.s1].transitions {
+			if !tr1.identity {
+				s2, output, ok := t2.run(p.s2, tr1.output)
+				if !ok {
+					continue
+				}
+				dest := state(pair{tr1.dest, s2})
+				if err := result.AddTransition(source, dest, tr1.min, tr1.max, output); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			// The label read by t1 is read by t2 too, so split the range along the transitions of t2:
+			for _, tr2 := range t2.states[p.s2].transitions {
+				lo, hi := max(tr1.min, tr2.min), min(tr1.max, tr2.max)
+				if lo > hi {
+					continue
+				}
+				dest := state(pair{tr1.dest, tr2.dest})
+				var err error
+				if tr2.identity {
+					err = result.AddIdentityTransition(source, dest, lo, hi)
+				} else {
+					err = result.AddTransition(source, dest, lo, hi, tr2.output)
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return result, nil
+}
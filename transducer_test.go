@@ -0,0 +1,114 @@
+package automaton
+
+import (
+	"math/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newRewriteTransducer Returns a single state transducer replacing the keys of rules by their values and
+// copying all other characters.
+func newRewriteTransducer(t *testing.T, rules map[rune]string) *Transducer {
+	tr := NewTransducer()
+	tr.CreateState()
+	tr.SetAccept(0, true, "")
+	next := 0
+	keys := make([]int, 0, len(rules))
+	for c := range rules {
+		keys = append(keys, int(c))
+	}
+	slices.Sort(keys)
+	for _, c := range keys {
+		if next < c {
+			assert.Nil(t, tr.AddIdentityTransition(0, 0, next, c-1))
+		}
+		assert.Nil(t, tr.AddTransition(0, 0, c, c, rules[rune(c)]))
+		next = c + 1
+	}
+	assert.Nil(t, tr.AddIdentityTransition(0, 0, next, 0x10FFFF))
+	return tr
+}
+
+func TestTransducer(t *testing.T) {
+	// "ab" becomes "x", "ac" becomes "yz!":
+	tr := NewTransducer()
+	for i := 0; i < 3; i++ {
+		tr.CreateState()
+	}
+	assert.Nil(t, tr.AddTransition(0, 1, 'a', 'a', ""))
+	assert.Nil(t, tr.AddTransition(1, 2, 'b', 'b', "x"))
+	assert.Nil(t, tr.AddTransition(1, 2, 'c', 'c', "yz"))
+	tr.SetAccept(2, true, "!")
+
+	out, ok := tr.Apply("ab")
+	assert.True(t, ok)
+	assert.Equal(t, "x!", out)
+	out, ok = tr.Apply("ac")
+	assert.True(t, ok)
+	assert.Equal(t, "yz!", out)
+	_, ok = tr.Apply("a")
+	assert.False(t, ok)
+	_, ok = tr.Apply("ad")
+	assert.False(t, ok)
+
+	domain := tr.Domain()
+	assert.True(t, domain.IsDeterministic())
+	assert.True(t, Run(domain, "ab"))
+	assert.False(t, Run(domain, "a"))
+
+	assert.NotNil(t, tr.AddTransition(1, 2, 'a', 'b', ""))
+	assert.NotNil(t, tr.AddIdentityTransition(1, 2, 'c', 'z'))
+	assert.NotNil(t, tr.AddTransition(1, 3, 'd', 'd', ""))
+	assert.NotNil(t, tr.AddTransition(1, 2, 'z', 'a', ""))
+
+	t.Run("testCompose", func(t *testing.T) {
+		sharpS := newRewriteTransducer(t, map[rune]string{'ß': "ss", 'æ': "ae"})
+		upper := newRewriteTransducer(t, map[rune]string{'a': "A", 'e': "E", 's': "S"})
+
+		composed, err := Compose(sharpS, upper)
+		assert.Nil(t, err)
+		out, ok := composed.Apply("straße æ")
+		assert.True(t, ok)
+		assert.Equal(t, "StrASSE AE", out)
+
+		r := rand.New(rand.NewSource(1592))
+		alphabet := []rune("aesßæx")
+		for i := 0; i < 100; i++ {
+			var sb strings.Builder
+			for j := r.Intn(8); j > 0; j-- {
+				sb.WriteRune(alphabet[r.Intn(len(alphabet))])
+			}
+			s := sb.String()
+			first, ok := sharpS.Apply(s)
+			assert.True(t, ok)
+			want, ok := upper.Apply(first)
+			assert.True(t, ok)
+			got, ok := composed.Apply(s)
+			assert.True(t, ok)
+			assert.Equal(t, want, got, s)
+		}
+
+		// t2 rejecting some outputs of t1 restricts the domain:
+		composed, err = Compose(tr, newRewriteTransducer(t, nil))
+		assert.Nil(t, err)
+		out, ok = composed.Apply("ac")
+		assert.True(t, ok)
+		assert.Equal(t, "yz!", out)
+
+		onlyX := NewTransducer()
+		onlyX.CreateState()
+		onlyX.SetAccept(0, true, "")
+		assert.Nil(t, onlyX.AddIdentityTransition(0, 0, 'x', 'x'))
+		assert.Nil(t, onlyX.AddIdentityTransition(0, 0, '!', '!'))
+		composed, err = Compose(tr, onlyX)
+		assert.Nil(t, err)
+		out, ok = composed.Apply("ab")
+		assert.True(t, ok)
+		assert.Equal(t, "x!", out)
+		_, ok = composed.Apply("ac")
+		assert.False(t, ok)
+	})
+}
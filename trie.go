@@ -0,0 +1,109 @@
+package automaton
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"unicode"
+)
+
+// TrieNode One node of a byte-oriented prefix tree, as produced by ExportTrie. The JSON encoding of a node is
+//
+//	{"accept": true, "children": [{"label": 97, "node": {...}}, ...]}
+//
+// where children are sorted by label and "children" is omitted for leaves.
+type TrieNode struct {
+	// True if the path from the root to this node spells an accepted string.
+	Accept bool `json:"accept"`
+
+	// Outgoing edges, sorted by label.
+	Children []*TrieEdge `json:"children,omitempty"`
+}
+
+// TrieEdge A labeled edge between two TrieNode.
+type TrieEdge struct {
+	Label byte      `json:"label"`
+	Node  *TrieNode `json:"node"`
+}
+
+// Child Returns the child reached by the given label, or nil if there is none.
+func (n *TrieNode) Child(label byte) *TrieNode {
+	i, ok := n.search(label)
+	if !ok {
+		return nil
+	}
+	return n.Children[i].Node
+}
+
+func (n *TrieNode) search(label byte) (int, bool) {
+	return slices.BinarySearchFunc(n.Children, label, func(e *TrieEdge, label byte) int {
+		return int(e.Label) - int(label)
+	})
+}
+
+func (n *TrieNode) getOrAdd(label byte) *TrieNode {
+	i, ok := n.search(label)
+	if ok {
+		return n.Children[i].Node
+	}
+	child := &TrieNode{}
+	n.Children = slices.Insert(n.Children, i, &TrieEdge{Label: label, Node: child})
+	return child
+}
+
+// ExportTrie
+// Returns a prefix tree holding every string accepted by the given automaton, so that systems which only
+// understand tries can consume it. If isBinary is true the labels of the automaton are taken as bytes, otherwise
+// they are code points and are encoded as UTF-8, surrogates included (as UTF32ToUTF8 does). Returns an error if
+// the automaton accepts an infinite language.
+func ExportTrie(a *Automaton, isBinary bool) (*TrieNode, error) {
+	a, err := RemoveDeadStates(a)
+	if err != nil {
+		return nil, err
+	}
+	if !IsFiniteAutomaton(a).Load() {
+		return nil, errors.New("automaton accepts an infinite language")
+	}
+
+	root := &TrieNode{}
+	if a.GetNumStates() == 0 {
+		return root, nil
+	}
+	if err := exportTrie(a, 0, root, isBinary); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+func exportTrie(a *Automaton, state int, node *TrieNode, isBinary bool) error {
+	if a.IsAccept(state) {
+		node.Accept = true
+	}
+
+	t := NewTransition()
+	count := a.InitTransition(state, t)
+	for i := 0; i < count; i++ {
+		a.GetNextTransition(t)
+		for label := t.Min; label <= t.Max; label++ {
+			child := node
+			if isBinary {
+				if label > 255 {
+					return fmt.Errorf("automaton is not binary: state %d has label %d above byte 255", state, label)
+				}
+				child = child.getOrAdd(byte(label))
+			} else {
+				if label > unicode.MaxRune {
+					return fmt.Errorf("state %d has label %d above the largest code point", state, label)
+				}
+				// Encode like UTF32ToUTF8, surrogates included:
+				for _, b := range encodeUTF8(label, utf8Length(label)) {
+					child = child.getOrAdd(byte(b))
+				}
+			}
+			if err := exportTrie(a, t.Dest, child, isBinary); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package automaton
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportTrie(t *testing.T) {
+	t.Run("testStrings", func(t *testing.T) {
+		a1, err := defaultAutomata.MakeString("ab")
+		assert.Nil(t, err)
+		a2, err := defaultAutomata.MakeString("a")
+		assert.Nil(t, err)
+		a3, err := defaultAutomata.MakeString("c")
+		assert.Nil(t, err)
+		a, err := union(a1, a2, a3)
+		assert.Nil(t, err)
+
+		root, err := ExportTrie(a, false)
+		assert.Nil(t, err)
+		assert.False(t, root.Accept)
+		assert.Len(t, root.Children, 2)
+		assert.Equal(t, byte('a'), root.Children[0].Label)
+		assert.Equal(t, byte('c'), root.Children[1].Label)
+
+		node := root.Child('a')
+		assert.True(t, node.Accept)
+		assert.True(t, node.Child('b').Accept)
+		assert.Nil(t, node.Child('c'))
+	})
+
+	t.Run("testUnicodeIsEncodedAsUTF8", func(t *testing.T) {
+		a, err := defaultAutomata.MakeString("é")
+		assert.Nil(t, err)
+		root, err := ExportTrie(a, false)
+		assert.Nil(t, err)
+		node := root.Child(0xc3)
+		if assert.NotNil(t, node) {
+			assert.True(t, node.Child(0xa9).Accept)
+		}
+	})
+
+	t.Run("testSurrogates", func(t *testing.T) {
+		a, err := defaultAutomata.MakeCharRange(0xD000, 0xD900)
+		assert.Nil(t, err)
+		root, err := ExportTrie(a, false)
+		assert.Nil(t, err)
+
+		// Surrogates are encoded like UTF32ToUTF8 encodes them:
+		utf8, err := UTF32ToUTF8(a)
+		assert.Nil(t, err)
+		for _, c := range []int{0xD000, 0xD7FF, 0xD800, 0xD900} {
+			encoded := encodeUTF8(c, 3)
+			node := root.Child(byte(encoded[0])).Child(byte(encoded[1])).Child(byte(encoded[2]))
+			if assert.NotNil(t, node, c) {
+				assert.True(t, node.Accept)
+			}
+			state, err := utf8.StepBytes(0, []byte{byte(encoded[0]), byte(encoded[1]), byte(encoded[2])})
+			assert.Nil(t, err)
+			assert.True(t, state != -1 && utf8.IsAccept(state))
+		}
+		assert.Nil(t, root.Child(0xED).Child(0xA4).Child(0x81))
+	})
+
+	t.Run("testBinary", func(t *testing.T) {
+		a, err := defaultAutomata.MakeBinary([]byte{0xff, 0x00})
+		assert.Nil(t, err)
+		root, err := ExportTrie(a, true)
+		assert.Nil(t, err)
+		assert.True(t, root.Child(0xff).Child(0x00).Accept)
+
+		a, err = defaultAutomata.MakeChar(0x100)
+		assert.Nil(t, err)
+		_, err = ExportTrie(a, true)
+		assert.Error(t, err)
+	})
+
+	t.Run("testEmpty", func(t *testing.T) {
+		root, err := ExportTrie(defaultAutomata.MakeEmpty(), false)
+		assert.Nil(t, err)
+		assert.False(t, root.Accept)
+		assert.Empty(t, root.Children)
+
+		root, err = ExportTrie(defaultAutomata.MakeEmptyString(), false)
+		assert.Nil(t, err)
+		assert.True(t, root.Accept)
+	})
+
+	t.Run("testInfinite", func(t *testing.T) {
+		a, err := defaultAutomata.MakeAnyString()
+		assert.Nil(t, err)
+		_, err = ExportTrie(a, false)
+		assert.Error(t, err)
+	})
+
+	t.Run("testJSON", func(t *testing.T) {
+		a, err := defaultAutomata.MakeString("a")
+		assert.Nil(t, err)
+		root, err := ExportTrie(a, false)
+		assert.Nil(t, err)
+		bs, err := json.Marshal(root)
+		assert.Nil(t, err)
+		assert.JSONEq(t, `{"accept":false,"children":[{"label":97,"node":{"accept":true}}]}`, string(bs))
+	})
+}
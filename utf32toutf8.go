@@ -0,0 +1,230 @@
+package automaton
+
+import (
+	"slices"
+	"unicode"
+)
+
+// UTF32ToUTF8
+// Converts an automaton over code points into an equivalent binary automaton over their UTF-8 encoding, as
+// Lucene's UTF32ToUTF8, so it can match UTF-8 byte strings directly (see ByteRunAutomaton). Each code point
+// range is split into sequences of byte ranges; the sequences leaving a state share common prefixes, so a
+// deterministic automaton converts to a deterministic one. Surrogates are encoded like any other code point.
+func UTF32ToUTF8(a *Automaton) (*Automaton, error) {
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return defaultAutomata.MakeEmptyWithKind(ALPHABET_BINARY), nil
+	}
+
+	builder := NewBuilder()
+	for s := 0; s < numStates; s++ {
+		builder.CreateState()
+		builder.SetAccept(s, a.IsAccept(s))
+	}
+
+	// Intermediate states, keyed by the state and byte range leading to them:
+	type edge struct {
+		source, min, max int
+	}
+	intermediate := make(map[edge]int)
+
+	t := NewTransition()
+	for s := 0; s < numStates; s++ {
+		count := a.InitTransition(s, t)
+		for i := 0; i < count; i++ {
+			a.GetNextTransition(t)
+			splitUTF8(t.Min, t.Max, func(seq [][2]int) {
+				state := s
+				for _, r := range seq[:len(seq)-1] {
+					e := edge{state, r[0], r[1]}
+					next, ok := intermediate[e]
+					if !ok {
+						next = builder.CreateState()
+						intermediate[e] = next
+						builder.AddTransition(state, next, r[0], r[1])
+					}
+					state = next
+				}
+				last := seq[len(seq)-1]
+				builder.AddTransition(state, t.Dest, last[0], last[1])
+			})
+		}
+	}
+
+	result := builder.Finish()
+	result.alphabet = ALPHABET_BINARY
+	return result, nil
+}
+
+// UTF8ToUTF32
+// Converts a binary automaton over UTF-8 encoded code points into an equivalent automaton over the code points,
+// the inverse of UTF32ToUTF8. Each path spelling the UTF-8 encoding of a code point becomes a transition
+// labeled with it; byte sequences that are not well-formed UTF-8 (overlong encodings, code points above
+// unicode.MaxRune, stray continuation bytes) are dropped, except surrogates, which UTF32ToUTF8 encodes as well.
+// As UTF-8 is prefix free, a deterministic automaton converts to a deterministic one. Returns an error if the
+// automaton has labels above 255.
+func UTF8ToUTF32(a *Automaton) (*Automaton, error) {
+	if err := checkBinary(a); err != nil {
+		return nil, err
+	}
+	numStates := a.GetNumStates()
+	if numStates == 0 {
+		return defaultAutomata.MakeEmpty(), nil
+	}
+
+	// The byte ranges of the well-formed sequences, one per encoded length and leading byte range:
+	var sequences [][][2]int
+	splitUTF8(0, unicode.MaxRune, func(seq [][2]int) {
+		sequences = append(sequences, slices.Clone(seq))
+	})
+
+	builder := NewBuilder()
+	for s := 0; s < numStates; s++ {
+		builder.CreateState()
+		builder.SetAccept(s, a.IsAccept(s))
+	}
+
+	// Follows the transitions matching seq from state, narrowing the byte ranges of path to those matched:
+	var follow func(source, state int, seq, path [][2]int)
+	follow = func(source, state int, seq, path [][2]int) {
+		i := len(path)
+		t := NewTransition()
+		count := a.InitTransition(state, t)
+		for j := 0; j < count; j++ {
+			a.GetNextTransition(t)
+			lo, hi := max(t.Min, seq[i][0]), min(t.Max, seq[i][1])
+			if lo > hi {
+				continue
+			}
+			if i+1 < len(seq) {
+				follow(source, t.Dest, seq, append(path, [2]int{lo, hi}))
+				continue
+			}
+			// The payload bits of the leading byte, by encoded length:
+			bits := []int{7, 5, 4, 3}[i]
+			decodeUTF8Ranges(append(path, [2]int{lo, hi}), 0, bits, func(min, max int) {
+				builder.AddTransition(source, t.Dest, min, max)
+			})
+		}
+	}
+	for s := 0; s < numStates; s++ {
+		for _, seq := range sequences {
+			follow(s, s, seq, make([][2]int, 0, 4))
+		}
+	}
+
+	return RemoveDeadStates(builder.Finish())
+}
+
+// Calls emit with the code point ranges whose UTF-8 encodings are the byte sequences matching path, a
+// well-formed sequence of byte ranges; code holds the bits decoded from the bytes before path, and bits is the
+// number of payload bits of the first byte of path.
+func decodeUTF8Ranges(path [][2]int, code, bits int, emit func(min, max int)) {
+	mask := 1<<bits - 1
+	shift := 6 * (len(path) - 1)
+
+	// The code points are a single range if the bytes after the first match every continuation byte:
+	full := true
+	for _, r := range path[1:] {
+		full = full && r[0] == 0x80 && r[1] == 0xBF
+	}
+	if full {
+		code <<= bits + shift
+		emit(code|(path[0][0]&mask)<<shift, code|(path[0][1]&mask)<<shift|(1<<shift-1))
+		return
+	}
+	for b := path[0][0]; b <= path[0][1]; b++ {
+		decodeUTF8Ranges(path[1:], code<<bits|b&mask, 6, emit)
+	}
+}
+
+// ConvertAlphabet Returns an automaton accepting the language of the given automaton over the given alphabet:
+// the automaton itself if it already has that alphabet, or else its conversion with UTF32ToUTF8 or UTF8ToUTF32.
+// This allows to combine automata of different alphabets, e.g. to intersect a binary automaton with the UTF-8
+// encoding of a code point automaton, doing the work in whichever alphabet is smaller.
+func ConvertAlphabet(a *Automaton, alphabet Alphabet) (*Automaton, error) {
+	switch {
+	case a.alphabet == alphabet:
+		return a, nil
+	case alphabet == ALPHABET_BINARY:
+		return UTF32ToUTF8(a)
+	default:
+		return UTF8ToUTF32(a)
+	}
+}
+
+// Calls emit with sequences of byte ranges whose UTF-8 encoded code points are exactly [start, end]. Within a
+// sequence, a range of more than one byte is only followed by full continuation ranges [0x80, 0xBF].
+func splitUTF8(start, end int, emit func(seq [][2]int)) {
+	// Split at the boundaries between encoded lengths:
+	for _, boundary := range []int{0x7F, 0x7FF, 0xFFFF} {
+		if start <= boundary && end > boundary {
+			splitUTF8(start, boundary, emit)
+			splitUTF8(boundary+1, end, emit)
+			return
+		}
+	}
+	if end < 0x80 {
+		emit([][2]int{{start, end}})
+		return
+	}
+
+	n := utf8Length(start)
+	for i := 1; i < n; i++ {
+		// The i trailing continuation bytes must either be equal or span their full range:
+		m := 1<<(6*i) - 1
+		if start&^m != end&^m {
+			if start&m != 0 {
+				splitUTF8(start, start|m, emit)
+				splitUTF8((start|m)+1, end, emit)
+				return
+			}
+			if end&m != m {
+				splitUTF8(start, (end&^m)-1, emit)
+				splitUTF8(end&^m, end, emit)
+				return
+			}
+		}
+	}
+
+	startBytes := encodeUTF8(start, n)
+	endBytes := encodeUTF8(end, n)
+	seq := make([][2]int, n)
+	for i := range seq {
+		seq[i] = [2]int{startBytes[i], endBytes[i]}
+	}
+	emit(seq)
+}
+
+func utf8Length(c int) int {
+	switch {
+	case c < 0x80:
+		return 1
+	case c < 0x800:
+		return 2
+	case c < 0x10000:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// Returns the n byte UTF-8 encoding of c; unlike utf8.EncodeRune, surrogates are encoded as well.
+func encodeUTF8(c, n int) []int {
+	bs := make([]int, n)
+	for i := n - 1; i > 0; i-- {
+		bs[i] = 0x80 | c&0x3F
+		c >>= 6
+	}
+	switch n {
+	case 1:
+		bs[0] = c
+	case 2:
+		bs[0] = 0xC0 | c
+	case 3:
+		bs[0] = 0xE0 | c
+	default:
+		bs[0] = 0xF0 | c
+	}
+	return bs
+}
@@ -0,0 +1,185 @@
+package automaton
+
+import (
+	"math/rand"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTF32ToUTF8(t *testing.T) {
+	r := rand.New(rand.NewSource(1576))
+	randomCodePoint := func() int {
+		// Favor the boundaries between encoded lengths:
+		switch r.Intn(4) {
+		case 0:
+			return []int{0, 0x7F, 0x80, 0x7FF, 0x800, 0xFFFF, 0x10000, unicode.MaxRune}[r.Intn(8)]
+		case 1:
+			return r.Intn(0x800)
+		case 2:
+			return r.Intn(0x10000)
+		default:
+			return r.Intn(unicode.MaxRune + 1)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		lo, hi := randomCodePoint(), randomCodePoint()
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		a, err := MakeCharRange(int32(lo), int32(hi))
+		assert.Nil(t, err)
+		b, err := UTF32ToUTF8(a)
+		assert.Nil(t, err)
+		assert.Equal(t, ALPHABET_BINARY, b.Alphabet())
+		assert.True(t, b.IsDeterministic())
+		run, err := NewByteRunAutomaton(b, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+
+		for j := 0; j < 100; j++ {
+			c := randomCodePoint()
+			if j%2 == 0 {
+				c = lo + r.Intn(hi-lo+1)
+			}
+			if !utf8.ValidRune(rune(c)) {
+				continue
+			}
+			want := lo <= c && c <= hi
+			if !assert.Equal(t, want, run.Run([]byte(string(rune(c)))), "[%x, %x] %x", lo, hi, c) {
+				break
+			}
+		}
+	}
+
+	t.Run("random", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			re, err := NewRegExp(randomRegexp(r, 1+r.Intn(3)) + "(é|世|😀)?")
+			assert.Nil(t, err)
+			a, err := re.ToAutomaton()
+			assert.Nil(t, err)
+			b, err := UTF32ToUTF8(a)
+			assert.Nil(t, err)
+			assert.True(t, b.IsDeterministic())
+			run, err := NewByteRunAutomaton(b, true, DEFAULT_DETERMINIZE_WORK_LIMIT)
+			assert.Nil(t, err)
+
+			for j := 0; j < 30; j++ {
+				s := randomString(r, 6) + []string{"", "é", "世", "😀", "\u0080"}[r.Intn(5)]
+				assert.Equal(t, runNFA(a, s), run.Run([]byte(s)), s)
+			}
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		b, err := UTF32ToUTF8(MakeEmpty())
+		assert.Nil(t, err)
+		assert.Equal(t, 0, b.GetNumStates())
+	})
+}
+
+func TestUTF8ToUTF32(t *testing.T) {
+	r := rand.New(rand.NewSource(1626))
+
+	t.Run("testRoundTrip", func(t *testing.T) {
+		for i := 0; i < 50; i++ {
+			a, err := MustNewRegExp(randomRegexp(r, 1+r.Intn(3)) + "(é|世|😀|[^a-z])?").ToAutomaton()
+			assert.Nil(t, err)
+			b, err := UTF32ToUTF8(a)
+			assert.Nil(t, err)
+			c, err := UTF8ToUTF32(b)
+			assert.Nil(t, err)
+			assert.Equal(t, ALPHABET_UNICODE, c.Alphabet())
+			assert.True(t, c.IsDeterministic())
+
+			diff, err := DiffLanguages(a, c, 3)
+			assert.Nil(t, err)
+			assert.True(t, diff.Empty(), diff.String())
+		}
+	})
+
+	t.Run("testRandomBinary", func(t *testing.T) {
+		// Bytes of valid and invalid sequences:
+		bytes := []int{'a', 0x7F, 0x80, 0x8F, 0x90, 0xBF, 0xC0, 0xC2, 0xC3, 0xDF, 0xE0, 0xE4, 0xED, 0xF0, 0xF4, 0xF5, 0xFF}
+		anyString, err := MakeAnyString()
+		assert.Nil(t, err)
+		anyUTF8, err := UTF32ToUTF8(anyString)
+		assert.Nil(t, err)
+
+		for i := 0; i < 40; i++ {
+			numStates := 1 + r.Intn(4)
+			b := NewBuilder()
+			for s := 0; s < numStates; s++ {
+				b.CreateState()
+				b.SetAccept(s, r.Intn(3) == 0)
+			}
+			for j := r.Intn(4 * numStates); j >= 0; j-- {
+				lo, hi := bytes[r.Intn(len(bytes))], bytes[r.Intn(len(bytes))]
+				b.AddTransition(r.Intn(numStates), r.Intn(numStates), min(lo, hi), max(lo, hi))
+			}
+			a := b.Finish()
+			a.SetAlphabet(ALPHABET_BINARY)
+
+			c, err := UTF8ToUTF32(a)
+			assert.Nil(t, err)
+			back, err := UTF32ToUTF8(c)
+			assert.Nil(t, err)
+
+			// Converting back yields the well-formed sequences of the original:
+			wellFormed, err := intersection(a, anyUTF8)
+			assert.Nil(t, err)
+			wellFormed, err = determinize(wellFormed, 1<<20)
+			assert.Nil(t, err)
+			back, err = determinize(back, 1<<20)
+			assert.Nil(t, err)
+			diff, err := DiffLanguages(wellFormed, back, 3)
+			assert.Nil(t, err)
+			assert.True(t, diff.Empty(), diff.String())
+		}
+	})
+
+	t.Run("testSurrogates", func(t *testing.T) {
+		a, err := MakeCharRange(0xD800, 0xDFFF)
+		assert.Nil(t, err)
+		b, err := UTF32ToUTF8(a)
+		assert.Nil(t, err)
+		c, err := UTF8ToUTF32(b)
+		assert.Nil(t, err)
+		assert.True(t, StructurallyEqual(a, c))
+	})
+
+	t.Run("testNotBinary", func(t *testing.T) {
+		a, err := MakeAnyString()
+		assert.Nil(t, err)
+		_, err = UTF8ToUTF32(a)
+		assert.NotNil(t, err)
+
+		c, err := UTF8ToUTF32(MakeEmpty())
+		assert.Nil(t, err)
+		assert.Equal(t, 0, c.GetNumStates())
+	})
+}
+
+func TestConvertAlphabet(t *testing.T) {
+	a := MustNewRegExp("[a-c]é+").MustToAutomaton()
+	same, err := ConvertAlphabet(a, ALPHABET_UNICODE)
+	assert.Nil(t, err)
+	assert.Same(t, a, same)
+
+	// Intersect with a binary automaton, in the binary alphabet:
+	binary, err := MakeBinary([]byte("bé"))
+	assert.Nil(t, err)
+	b, err := ConvertAlphabet(a, binary.Alphabet())
+	assert.Nil(t, err)
+	both, err := intersection(b, binary)
+	assert.Nil(t, err)
+	assert.Equal(t, ALPHABET_BINARY, both.Alphabet())
+	assert.False(t, IsEmptyAutomaton(both))
+
+	c, err := ConvertAlphabet(both, ALPHABET_UNICODE)
+	assert.Nil(t, err)
+	assert.True(t, runNFA(c, "bé"))
+	assert.False(t, runNFA(c, "bée"))
+}
@@ -0,0 +1,53 @@
+package automaton
+
+const (
+	// WILDCARD_STRING Matches any string, including the empty one, in a wildcard pattern.
+	WILDCARD_STRING = '*'
+
+	// WILDCARD_CHAR Matches any single code point in a wildcard pattern.
+	WILDCARD_CHAR = '?'
+
+	// WILDCARD_ESCAPE Makes the following code point literal in a wildcard pattern.
+	WILDCARD_ESCAPE = '\\'
+)
+
+// MakeWildcard
+// Returns a deterministic automaton for the given glob-style wildcard pattern, as Lucene's
+// WildcardQuery.toAutomaton: WILDCARD_STRING matches any string, WILDCARD_CHAR any single code point, and
+// WILDCARD_ESCAPE makes the next code point (including itself) literal; a trailing WILDCARD_ESCAPE is literal.
+// Every other code point matches itself, so unlike with RegExp no other characters need escaping.
+func MakeWildcard(pattern string, determinizeWorkLimit int) (*Automaton, error) {
+	automata := make([]*Automaton, 0, len(pattern))
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		var a *Automaton
+		var err error
+		switch c := runes[i]; c {
+		case WILDCARD_STRING:
+			a, err = defaultAutomata.MakeAnyString()
+		case WILDCARD_CHAR:
+			a, err = defaultAutomata.MakeAnyChar()
+		case WILDCARD_ESCAPE:
+			if i+1 < len(runes) {
+				i++
+				c = runes[i]
+			}
+			fallthrough
+		default:
+			a, err = defaultAutomata.MakeChar(c)
+		}
+		if err != nil {
+			return nil, err
+		}
+		automata = append(automata, a)
+	}
+	if len(automata) == 0 {
+		return defaultAutomata.MakeEmptyString(), nil
+	}
+
+	a, err := concatenate(automata...)
+	if err != nil {
+		return nil, err
+	}
+	return determinize(a, determinizeWorkLimit)
+}
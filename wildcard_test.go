@@ -0,0 +1,37 @@
+package automaton
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMakeWildcard(t *testing.T) {
+	testCases := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{"", []string{""}, []string{"a"}},
+		{"abc", []string{"abc"}, []string{"ab", "abcd"}},
+		{"a*c", []string{"ac", "abc", "abbbc", "a*c"}, []string{"ab", "bc"}},
+		{"a?c", []string{"abc", "aéc"}, []string{"ac", "abbc"}},
+		{"*", []string{"", "anything"}, nil},
+		{`a\*c`, []string{"a*c"}, []string{"abc", "ac"}},
+		{`a\?`, []string{"a?"}, []string{"ab"}},
+		{`a\\`, []string{`a\`}, []string{`a\\`}},
+		{`a\`, []string{`a\`}, []string{"a"}},
+		{"a.c[d]", []string{"a.c[d]"}, []string{"abcd"}},
+	}
+	for _, tc := range testCases {
+		a, err := MakeWildcard(tc.pattern, DEFAULT_DETERMINIZE_WORK_LIMIT)
+		assert.Nil(t, err)
+		assert.True(t, a.IsDeterministic(), tc.pattern)
+		for _, s := range tc.match {
+			assert.True(t, Run(a, s), "pattern=%q s=%q", tc.pattern, s)
+		}
+		for _, s := range tc.noMatch {
+			assert.False(t, Run(a, s), "pattern=%q s=%q", tc.pattern, s)
+		}
+	}
+}